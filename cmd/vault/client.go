@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type entry struct {
+	ID   string `json:"ID"`
+	Name string `json:"Name"`
+}
+
+type updateRequest struct {
+	Name        *string `json:"name,omitempty"`
+	Description *string `json:"description,omitempty"`
+	Password    *string `json:"password,omitempty"`
+}
+
+// apiError mirrors the server's JSON error body.
+type apiError struct {
+	Status    int    `json:"-"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id"`
+}
+
+func (e *apiError) Error() string {
+	msg := fmt.Sprintf("%s (%d %s)", e.Message, e.Status, e.Code)
+	if e.RequestID != "" {
+		msg += ", request id " + e.RequestID
+	}
+
+	return msg
+}
+
+type apiClient struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+func newAPIClient(profile Profile) apiClient {
+	return apiClient{
+		baseURL: strings.TrimRight(profile.URL, "/"),
+		token:   profile.Token,
+		http:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (c apiClient) add(ctx context.Context, name, description, password string) (string, error) {
+	var response struct {
+		ID string `json:"id"`
+	}
+
+	body := map[string]string{"name": name, "description": description, "password": password}
+	err := c.do(ctx, http.MethodPost, "/save", body, &response)
+	if err != nil {
+		return "", err
+	}
+
+	if response.ID == "" {
+		return "", errors.New("unexpected response: no id")
+	}
+
+	return response.ID, nil
+}
+
+func (c apiClient) get(ctx context.Context, id string) (string, error) {
+	var password bytes.Buffer
+
+	err := c.do(ctx, http.MethodGet, "/retrieve/"+url.PathEscape(id), nil, &password)
+	if err != nil {
+		return "", err
+	}
+
+	return password.String(), nil
+}
+
+func (c apiClient) list(ctx context.Context) ([]entry, error) {
+	var entries []entry
+
+	err := c.do(ctx, http.MethodGet, "/retrieve/all", nil, &entries)
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+func (c apiClient) edit(ctx context.Context, id string, request updateRequest) (entry, error) {
+	var updated entry
+
+	err := c.do(ctx, http.MethodPatch, "/entries/"+url.PathEscape(id), request, &updated)
+
+	return updated, err
+}
+
+func (c apiClient) remove(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/entries/"+url.PathEscape(id), nil, nil)
+}
+
+func (c apiClient) trash(ctx context.Context) ([]entry, error) {
+	var entries []entry
+
+	err := c.do(ctx, http.MethodGet, "/trash", nil, &entries)
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+func (c apiClient) restore(ctx context.Context, id string) (entry, error) {
+	var restored entry
+
+	err := c.do(ctx, http.MethodPost, "/trash/"+url.PathEscape(id)+"/restore", nil, &restored)
+
+	return restored, err
+}
+
+type shareResponse struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+	MaxViews  int       `json:"max_views"`
+}
+
+func (c apiClient) share(ctx context.Context, id, expiresIn string, maxViews int) (shareResponse, error) {
+	var response shareResponse
+
+	body := map[string]any{"expires_in": expiresIn, "max_views": maxViews}
+	err := c.do(ctx, http.MethodPost, "/entries/"+url.PathEscape(id)+"/share", body, &response)
+
+	return response, err
+}
+
+type role struct {
+	Principal string `json:"principal"`
+	Prefix    string `json:"prefix"`
+	Role      string `json:"role"`
+	GrantedBy string `json:"granted_by"`
+}
+
+func (c apiClient) roles(ctx context.Context) ([]role, error) {
+	var roles []role
+
+	err := c.do(ctx, http.MethodGet, "/roles", nil, &roles)
+	if err != nil {
+		return nil, err
+	}
+
+	return roles, nil
+}
+
+func (c apiClient) grant(ctx context.Context, principal, prefix, name string) (role, error) {
+	var granted role
+
+	body := map[string]string{"principal": principal, "prefix": prefix, "role": name}
+	err := c.do(ctx, http.MethodPut, "/roles", body, &granted)
+
+	return granted, err
+}
+
+func (c apiClient) revoke(ctx context.Context, principal, prefix string) error {
+	query := url.Values{"principal": {principal}, "prefix": {prefix}}
+	return c.do(ctx, http.MethodDelete, "/roles?"+query.Encode(), nil, nil)
+}
+
+// do sends body as JSON and decodes the response into out. A *bytes.Buffer
+// out receives the raw body.
+func (c apiClient) do(ctx context.Context, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if _, raw := out.(*bytes.Buffer); raw {
+		req.Header.Set("Accept", "text/plain")
+	} else {
+		req.Header.Set("Accept", "application/json")
+	}
+
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		apiErr := &apiError{Status: resp.StatusCode}
+		if json.Unmarshal(data, apiErr) != nil || apiErr.Message == "" {
+			apiErr.Message = http.StatusText(resp.StatusCode)
+		}
+
+		return apiErr
+	}
+
+	switch out := out.(type) {
+	case nil:
+		return nil
+	case *bytes.Buffer:
+		_, err = out.Write(data)
+		return err
+	default:
+		return json.Unmarshal(data, out)
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+)
+
+var errNoClipboard = errors.New("no clipboard tool found (pbcopy, wl-copy, xclip, xsel or clip.exe); use --print")
+
+var clipboardCommands = [][]string{
+	{"pbcopy"},
+	{"wl-copy"},
+	{"xclip", "-selection", "clipboard"},
+	{"xsel", "--clipboard", "--input"},
+	{"clip.exe"},
+}
+
+func copyToClipboard(text string) error {
+	for _, command := range clipboardCommands {
+		path, err := exec.LookPath(command[0])
+		if err != nil {
+			continue
+		}
+
+		cmd := exec.Command(path, command[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+
+		return cmd.Run()
+	}
+
+	return errNoClipboard
+}
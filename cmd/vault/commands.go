@@ -0,0 +1,610 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"personal-vault/internal/prompt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/pflag"
+)
+
+func (c *cli) flags(name, args string) *pflag.FlagSet {
+	fs := pflag.NewFlagSet(name, pflag.ContinueOnError)
+	fs.SetOutput(c.errOut)
+	fs.Usage = func() {
+		fmt.Fprintf(c.errOut, "Usage: vault %s %s\n", name, args)
+		fs.PrintDefaults()
+	}
+
+	return fs
+}
+
+func (c *cli) add(ctx context.Context, args []string) error {
+	fs := c.flags("add", "NAME [flags]")
+	description := fs.String("description", "", "description of the entry")
+	passwordStdin := fs.Bool("password-stdin", false, "read the password from stdin")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("expected exactly one NAME")
+	}
+
+	password, err := c.readSecret(*passwordStdin, "Password")
+	if err != nil {
+		return err
+	}
+
+	return c.save(ctx, fs.Arg(0), *description, password)
+}
+
+func (c *cli) save(ctx context.Context, name, description, password string) error {
+	id, err := c.client.add(ctx, name, description, password)
+	if err != nil {
+		return err
+	}
+
+	if c.json {
+		return c.writeJSON(entry{ID: id, Name: name})
+	}
+
+	fmt.Fprintln(c.out, id)
+
+	return nil
+}
+
+func (c *cli) get(ctx context.Context, args []string) error {
+	fs := c.flags("get", "ID|NAME [flags]")
+	printOnly := fs.Bool("print", false, "write only the password to stdout instead of using the clipboard")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("expected exactly one ID or NAME")
+	}
+
+	e, err := c.resolve(ctx, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	password, err := c.client.get(ctx, e.ID)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case c.json:
+		return c.writeJSON(struct {
+			entry
+			Password string `json:"password"`
+		}{e, password})
+	case *printOnly:
+		// no trailing newline, so the output can be piped as is
+		_, err = fmt.Fprint(c.out, password)
+		return err
+	default:
+		err = copyToClipboard(password)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintln(c.errOut, "password copied to the clipboard")
+		return nil
+	}
+}
+
+func (c *cli) list(ctx context.Context, args []string) error {
+	fs := c.flags("list", "")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	entries, err := c.client.list(ctx)
+	if err != nil {
+		return err
+	}
+
+	return c.printEntries(entries)
+}
+
+func (c *cli) search(ctx context.Context, args []string) error {
+	fs := c.flags("search", "QUERY")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("expected exactly one QUERY")
+	}
+
+	entries, err := c.client.list(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := strings.ToLower(fs.Arg(0))
+	matches := []entry{}
+	for _, e := range entries {
+		if strings.Contains(strings.ToLower(e.Name), query) {
+			matches = append(matches, e)
+		}
+	}
+
+	return c.printEntries(matches)
+}
+
+func (c *cli) edit(ctx context.Context, args []string) error {
+	fs := c.flags("edit", "ID|NAME [flags]")
+	name := fs.String("name", "", "new name")
+	description := fs.String("description", "", "new description")
+	password := fs.Bool("password", false, "prompt for a new password")
+	passwordStdin := fs.Bool("password-stdin", false, "read the new password from stdin")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("expected exactly one ID or NAME")
+	}
+
+	var request updateRequest
+	if fs.Changed("name") {
+		request.Name = name
+	}
+	if fs.Changed("description") {
+		request.Description = description
+	}
+	if *password || *passwordStdin {
+		secret, err := c.readSecret(*passwordStdin, "New password")
+		if err != nil {
+			return err
+		}
+
+		request.Password = &secret
+	}
+
+	if request.Name == nil && request.Description == nil && request.Password == nil {
+		return errors.New("nothing to change: use --name, --description or --password")
+	}
+
+	e, err := c.resolve(ctx, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	updated, err := c.client.edit(ctx, e.ID, request)
+	if err != nil {
+		return err
+	}
+
+	if c.json {
+		return c.writeJSON(updated)
+	}
+
+	fmt.Fprintln(c.out, updated.ID)
+
+	return nil
+}
+
+func (c *cli) remove(ctx context.Context, args []string) error {
+	fs := c.flags("rm", "ID|NAME [flags]")
+	force := fs.Bool("force", false, "do not ask for confirmation")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("expected exactly one ID or NAME")
+	}
+
+	e, err := c.resolve(ctx, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	if !*force {
+		answer, err := prompt.Terminal{In: c.in, Out: c.errOut}.Line(fmt.Sprintf("Delete %s? [y/N]", e.label()))
+		if errors.Is(err, prompt.ErrNotTerminal) {
+			return errors.New("stdin is not a terminal, use --force to delete without confirmation")
+		}
+		if err != nil {
+			return err
+		}
+
+		if !strings.EqualFold(answer, "y") && !strings.EqualFold(answer, "yes") {
+			return errors.New("aborted")
+		}
+	}
+
+	err = c.client.remove(ctx, e.ID)
+	if err != nil {
+		return err
+	}
+
+	if c.json {
+		return c.writeJSON(e)
+	}
+
+	fmt.Fprintln(c.errOut, "moved to the trash", e.label())
+
+	return nil
+}
+
+func (c *cli) share(ctx context.Context, args []string) error {
+	fs := c.flags("share", "ID|NAME [flags]")
+	expiresIn := fs.String("expires-in", "24h", "how long the link works, such as 24h or 7d")
+	views := fs.Int("views", 1, "how many times the link can be opened")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("expected exactly one ID or NAME")
+	}
+
+	e, err := c.resolve(ctx, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	shared, err := c.client.share(ctx, e.ID, *expiresIn, *views)
+	if err != nil {
+		return err
+	}
+
+	if c.json {
+		return c.writeJSON(shared)
+	}
+
+	fmt.Fprintln(c.out, shared.URL)
+	fmt.Fprintf(c.errOut, "link to %s works %d time(s) until %s\n", e.label(), shared.MaxViews, shared.ExpiresAt.Local().Format(time.RFC1123))
+
+	return nil
+}
+
+func (c *cli) trash(ctx context.Context, args []string) error {
+	fs := c.flags("trash", "")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	entries, err := c.client.trash(ctx)
+	if err != nil {
+		return err
+	}
+
+	return c.printEntries(entries)
+}
+
+func (c *cli) restore(ctx context.Context, args []string) error {
+	fs := c.flags("restore", "ID|NAME")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("expected exactly one ID or NAME")
+	}
+
+	e, err := c.resolveIn(ctx, fs.Arg(0), c.client.trash)
+	if err != nil {
+		return err
+	}
+
+	restored, err := c.client.restore(ctx, e.ID)
+	if err != nil {
+		return err
+	}
+
+	if c.json {
+		return c.writeJSON(restored)
+	}
+
+	fmt.Fprintln(c.errOut, "restored", restored.label())
+
+	return nil
+}
+
+func (c *cli) roles(ctx context.Context, args []string) error {
+	fs := c.flags("roles", "")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	roles, err := c.client.roles(ctx)
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(roles, func(i, j int) bool {
+		if roles[i].Prefix != roles[j].Prefix {
+			return roles[i].Prefix < roles[j].Prefix
+		}
+		return roles[i].Principal < roles[j].Principal
+	})
+
+	if c.json {
+		if roles == nil {
+			roles = []role{}
+		}
+		return c.writeJSON(roles)
+	}
+
+	w := tabwriter.NewWriter(c.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PREFIX\tPRINCIPAL\tROLE\tGRANTED BY")
+	for _, r := range roles {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Prefix, r.Principal, r.Role, r.GrantedBy)
+	}
+
+	return w.Flush()
+}
+
+func (c *cli) grant(ctx context.Context, args []string) error {
+	fs := c.flags("grant", "PRINCIPAL PREFIX ROLE")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if fs.NArg() != 3 {
+		fs.Usage()
+		return errors.New("expected PRINCIPAL, PREFIX and ROLE")
+	}
+
+	granted, err := c.client.grant(ctx, fs.Arg(0), fs.Arg(1), fs.Arg(2))
+	if err != nil {
+		return err
+	}
+
+	if c.json {
+		return c.writeJSON(granted)
+	}
+
+	fmt.Fprintf(c.errOut, "%s is %s on %s\n", granted.Principal, granted.Role, granted.Prefix)
+
+	return nil
+}
+
+func (c *cli) revoke(ctx context.Context, args []string) error {
+	fs := c.flags("revoke", "PRINCIPAL PREFIX")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return errors.New("expected PRINCIPAL and PREFIX")
+	}
+
+	err = c.client.revoke(ctx, fs.Arg(0), fs.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.errOut, "revoked the role of %s on %s\n", fs.Arg(0), fs.Arg(1))
+
+	return nil
+}
+
+func (c *cli) generate(ctx context.Context, args []string) error {
+	fs := c.flags("generate", "[flags]")
+	length := fs.Int("length", 24, "password length")
+	symbols := fs.Bool("symbols", true, "include symbols")
+	save := fs.String("save", "", "store the password as a new entry with this name")
+	description := fs.String("description", "", "description when saving")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	password, err := generatePassword(*length, *symbols)
+	if err != nil {
+		return err
+	}
+
+	if *save != "" {
+		return c.save(ctx, *save, *description, password)
+	}
+
+	if c.json {
+		return c.writeJSON(map[string]string{"password": password})
+	}
+
+	fmt.Fprintln(c.out, password)
+
+	return nil
+}
+
+func (c *cli) profile(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+
+	fs := c.flags("profile "+args[0], "")
+	profileURL := fs.String("url", "", "server URL")
+	tokenStdin := fs.Bool("token-stdin", false, "read the API token from stdin")
+
+	err := fs.Parse(args[1:])
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "list":
+		for _, name := range c.profiles.names() {
+			marker := " "
+			if name == c.profiles.Current {
+				marker = "*"
+			}
+			fmt.Fprintf(c.out, "%s %s\t%s\n", marker, name, c.profiles.Profiles[name].URL)
+		}
+		return nil
+	case "add":
+		if fs.NArg() != 1 || *profileURL == "" {
+			return errors.New("usage: vault profile add NAME --url URL [--token-stdin]")
+		}
+
+		profile := Profile{URL: *profileURL}
+		if *tokenStdin {
+			profile.Token, err = prompt.ReadLine(c.in)
+			if err != nil {
+				return err
+			}
+		}
+
+		c.profiles.Profiles[fs.Arg(0)] = profile
+		if c.profiles.Current == "" {
+			c.profiles.Current = fs.Arg(0)
+		}
+	case "use":
+		if fs.NArg() != 1 {
+			return errors.New("usage: vault profile use NAME")
+		}
+
+		if _, ok := c.profiles.Profiles[fs.Arg(0)]; !ok {
+			return fmt.Errorf("unknown profile %q", fs.Arg(0))
+		}
+
+		c.profiles.Current = fs.Arg(0)
+	case "rm":
+		if fs.NArg() != 1 {
+			return errors.New("usage: vault profile rm NAME")
+		}
+
+		delete(c.profiles.Profiles, fs.Arg(0))
+		if c.profiles.Current == fs.Arg(0) {
+			c.profiles.Current = ""
+		}
+	default:
+		return fmt.Errorf("unknown profile command %q", args[0])
+	}
+
+	return c.profiles.save()
+}
+
+// readSecret prompts twice without echo, or reads one line from stdin.
+func (c *cli) readSecret(fromStdin bool, label string) (string, error) {
+	if fromStdin {
+		return prompt.ReadLine(c.in)
+	}
+
+	secret, err := prompt.Terminal{In: c.in, Out: c.errOut}.Confirmed(label)
+	if errors.Is(err, prompt.ErrNotTerminal) {
+		return "", errors.New("stdin is not a terminal, use --password-stdin")
+	}
+
+	return secret, err
+}
+
+// resolve accepts an entry id or an exact entry name.
+func (c *cli) resolve(ctx context.Context, ref string) (entry, error) {
+	return c.resolveIn(ctx, ref, c.client.list)
+}
+
+// resolveIn looks a name up in the entries returned by list. An id is used
+// as is.
+func (c *cli) resolveIn(ctx context.Context, ref string, list func(ctx context.Context) ([]entry, error)) (entry, error) {
+	if _, err := uuid.Parse(ref); err == nil {
+		return entry{ID: ref}, nil
+	}
+
+	entries, err := list(ctx)
+	if err != nil {
+		return entry{}, err
+	}
+
+	var matches []entry
+	for _, e := range entries {
+		if e.Name == ref {
+			matches = append(matches, e)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return entry{}, fmt.Errorf("no entry named %q", ref)
+	case 1:
+		return matches[0], nil
+	default:
+		ids := make([]string, 0, len(matches))
+		for _, m := range matches {
+			ids = append(ids, m.ID)
+		}
+		return entry{}, fmt.Errorf("%d entries are named %q, use one of the ids: %s", len(matches), ref, strings.Join(ids, ", "))
+	}
+}
+
+func (e entry) label() string {
+	if e.Name == "" {
+		return e.ID
+	}
+
+	return fmt.Sprintf("%s (%s)", e.Name, e.ID)
+}
+
+func (c *cli) printEntries(entries []entry) error {
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+
+	if c.json {
+		if entries == nil {
+			entries = []entry{}
+		}
+		return c.writeJSON(entries)
+	}
+
+	w := tabwriter.NewWriter(c.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME")
+	for _, e := range entries {
+		fmt.Fprintf(w, "%s\t%s\n", e.ID, e.Name)
+	}
+
+	return w.Flush()
+}
+
+func (c *cli) writeJSON(v any) error {
+	enc := json.NewEncoder(c.out)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(v)
+}
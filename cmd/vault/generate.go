@@ -0,0 +1,71 @@
+package main
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+)
+
+const (
+	lowerChars  = "abcdefghijkmnopqrstuvwxyz"
+	upperChars  = "ABCDEFGHJKLMNPQRSTUVWXYZ"
+	digitChars  = "23456789"
+	symbolChars = "!#$%&*+-=?@^_"
+
+	minGeneratedLength = 8
+)
+
+// generatePassword returns a random password with at least one character
+// from every enabled class. Look-alike characters are left out.
+func generatePassword(length int, symbols bool) (string, error) {
+	if length < minGeneratedLength {
+		return "", errors.New("length must be at least 8")
+	}
+
+	classes := []string{lowerChars, upperChars, digitChars}
+	if symbols {
+		classes = append(classes, symbolChars)
+	}
+
+	var all string
+	for _, class := range classes {
+		all += class
+	}
+
+	password := make([]byte, length)
+	for i := range password {
+		// the first characters cover every class, the rest come from all of them
+		charset := all
+		if i < len(classes) {
+			charset = classes[i]
+		}
+
+		c, err := randomChar(charset)
+		if err != nil {
+			return "", err
+		}
+
+		password[i] = c
+	}
+
+	// shuffle so the guaranteed characters are not always in front
+	for i := len(password) - 1; i > 0; i-- {
+		j, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return "", err
+		}
+
+		password[i], password[j.Int64()] = password[j.Int64()], password[i]
+	}
+
+	return string(password), nil
+}
+
+func randomChar(charset string) (byte, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(len(charset))))
+	if err != nil {
+		return 0, err
+	}
+
+	return charset[n.Int64()], nil
+}
@@ -0,0 +1,142 @@
+// Command vault is a command line client for the personal-vault API.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/pflag"
+)
+
+const usage = `Usage: vault [global flags] <command> [flags] [args]
+
+Commands:
+  add NAME         store a new entry, the password is prompted for
+  get ID|NAME      copy the password to the clipboard (--print to write it to stdout)
+  list             list entries
+  search QUERY     list entries whose name contains QUERY
+  edit ID|NAME     change the name, description or password of an entry
+  share ID|NAME    print a link that reveals the password once (--views, --expires-in)
+  rm ID|NAME       move an entry to the trash
+  trash            list the entries in the trash
+  restore ID|NAME  take an entry out of the trash
+  generate         print a random password (--save NAME to store it)
+  roles            list your roles and the roles on prefixes you own
+  grant PRINCIPAL PREFIX ROLE
+                   give PRINCIPAL a role (owner, editor, viewer or use) on PREFIX
+  revoke PRINCIPAL PREFIX
+                   take PRINCIPAL's role on PREFIX away
+  profile          manage server profiles: list, add, use, rm
+
+Global flags:
+`
+
+// cli carries what every command needs. in is an *os.File so secrets can be
+// read from a terminal without echo.
+type cli struct {
+	in       *os.File
+	out      io.Writer
+	errOut   io.Writer
+	json     bool
+	profiles Profiles
+	client   apiClient
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	os.Exit(run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(ctx context.Context, args []string, in *os.File, out, errOut io.Writer) int {
+	global := pflag.NewFlagSet("vault", pflag.ContinueOnError)
+	global.SetInterspersed(false)
+	global.SetOutput(errOut)
+	profileName := global.String("profile", "", "profile to use (default: the current profile)")
+	serverURL := global.String("url", "", "server URL, overrides the profile")
+	jsonOutput := global.Bool("json", false, "write machine readable JSON")
+	global.Usage = func() {
+		fmt.Fprint(errOut, usage)
+		global.PrintDefaults()
+	}
+
+	err := global.Parse(args)
+	if errors.Is(err, pflag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		return 2
+	}
+
+	if global.NArg() == 0 {
+		global.Usage()
+		return 2
+	}
+
+	path, err := profilesPath()
+	if err != nil {
+		fmt.Fprintln(errOut, "vault:", err)
+		return 1
+	}
+
+	profiles, err := loadProfiles(path)
+	if err != nil {
+		fmt.Fprintln(errOut, "vault:", err)
+		return 1
+	}
+
+	c := &cli{in: in, out: out, errOut: errOut, json: *jsonOutput, profiles: profiles}
+
+	command, commandArgs := global.Arg(0), global.Args()[1:]
+
+	if command != "profile" {
+		profile, err := profiles.resolve(*profileName, *serverURL)
+		if err != nil {
+			fmt.Fprintln(errOut, "vault:", err)
+			return 1
+		}
+
+		c.client = newAPIClient(profile)
+	}
+
+	commands := map[string]func(ctx context.Context, args []string) error{
+		"add":      c.add,
+		"get":      c.get,
+		"list":     c.list,
+		"search":   c.search,
+		"edit":     c.edit,
+		"share":    c.share,
+		"rm":       c.remove,
+		"trash":    c.trash,
+		"restore":  c.restore,
+		"generate": c.generate,
+		"roles":    c.roles,
+		"grant":    c.grant,
+		"revoke":   c.revoke,
+		"profile":  c.profile,
+	}
+
+	cmd, ok := commands[command]
+	if !ok {
+		fmt.Fprintf(errOut, "vault: unknown command %q\n", command)
+		global.Usage()
+		return 2
+	}
+
+	err = cmd(ctx, commandArgs)
+	if errors.Is(err, pflag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintf(errOut, "vault %s: %v\n", command, err)
+		return 1
+	}
+
+	return 0
+}
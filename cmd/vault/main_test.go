@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"personal-vault/internal/db"
+	"personal-vault/internal/dbtest"
+	"personal-vault/internal/handler"
+	"personal-vault/internal/rbac"
+	"personal-vault/internal/server"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestServer serves an in-memory vault. auth gets the table client set;
+// its zero value leaves the API open.
+func newTestServer(t *testing.T, auth rbac.Authorizer) *httptest.Server {
+	t.Helper()
+
+	// secret is for testing only
+	secret, err := hex.DecodeString("0f6f8edf954592d7523b475bb56fd0486b7a049d67c1e5aa522bbc8bfe961971")
+	assert.NoError(t, err)
+
+	dbClient := db.NewClient(dbtest.NewMemoryAPI(), "personal-vault")
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	auth.Client = *dbClient
+
+	router := server.NewRouter(logger, server.Handlers{
+		Save:     handler.SaveHandler{Client: *dbClient, Validate: handler.NewValidator(), Key: string(secret)},
+		Retrieve: handler.RetrieveHandler{Client: *dbClient, Key: string(secret)},
+		Delete:   handler.DeleteHandler{Client: *dbClient, Retention: time.Hour},
+		Expiry:   handler.ExpiryHandler{Client: *dbClient},
+		Share:    handler.ShareHandler{Client: *dbClient, Validate: handler.NewValidator(), Key: string(secret)},
+		Role:     handler.RoleHandler{Client: *dbClient, Validate: handler.NewValidator()},
+		Auth:     auth,
+	})
+
+	srv := httptest.NewServer(router)
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+// vault runs the CLI with stdin as a regular file, i.e. not a terminal.
+func vault(t *testing.T, stdin string, args ...string) (string, string, int) {
+	t.Helper()
+
+	in, err := os.CreateTemp(t.TempDir(), "stdin")
+	assert.NoError(t, err)
+	defer in.Close()
+
+	_, err = in.WriteString(stdin)
+	assert.NoError(t, err)
+	_, err = in.Seek(0, io.SeekStart)
+	assert.NoError(t, err)
+
+	var out, errOut bytes.Buffer
+	code := run(context.Background(), args, in, &out, &errOut)
+
+	return out.String(), errOut.String(), code
+}
+
+func TestCLI(t *testing.T) {
+	srv := newTestServer(t, rbac.Authorizer{})
+	t.Setenv("VAULT_CLI_CONFIG", filepath.Join(t.TempDir(), "cli.yaml"))
+	t.Setenv("VAULT_URL", srv.URL)
+
+	out, _, code := vault(t, "s3cret with spaces\n", "add", "github", "--description", "work", "--password-stdin")
+	assert.Equal(t, 0, code)
+	id := strings.TrimSpace(out)
+	assert.Len(t, id, 36)
+
+	out, _, code = vault(t, "", "get", "github", "--print")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "s3cret with spaces", out)
+
+	out, _, code = vault(t, "", "--json", "get", id)
+	assert.Equal(t, 0, code)
+	var got map[string]string
+	assert.NoError(t, json.Unmarshal([]byte(out), &got))
+	assert.Equal(t, "s3cret with spaces", got["password"])
+
+	_, _, code = vault(t, "", "generate", "--save", "gitlab")
+	assert.Equal(t, 0, code)
+
+	out, _, code = vault(t, "", "--json", "search", "GIT")
+	assert.Equal(t, 0, code)
+	var entries []entry
+	assert.NoError(t, json.Unmarshal([]byte(out), &entries))
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "github", entries[0].Name)
+	assert.Equal(t, "gitlab", entries[1].Name)
+
+	_, _, code = vault(t, "new password\n", "edit", "github", "--name", "github-work", "--password-stdin")
+	assert.Equal(t, 0, code)
+
+	out, _, code = vault(t, "", "get", "github-work", "--print")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "new password", out)
+
+	out, _, code = vault(t, "", "share", "github-work", "--views", "2")
+	assert.Equal(t, 0, code)
+	link, err := url.Parse(strings.TrimSpace(out))
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(link.Path, "/s/"))
+	assert.NotEmpty(t, link.Fragment)
+
+	_, errOut, code := vault(t, "", "rm", "github-work")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, errOut, "--force")
+
+	_, _, code = vault(t, "", "rm", "github-work", "--force")
+	assert.Equal(t, 0, code)
+
+	out, _, code = vault(t, "", "list")
+	assert.Equal(t, 0, code)
+	assert.NotContains(t, out, "github-work")
+	assert.Contains(t, out, "gitlab")
+
+	out, _, code = vault(t, "", "trash")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, out, "github-work")
+
+	_, errOut, code = vault(t, "", "restore", "github-work")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, errOut, "restored github-work")
+
+	out, _, code = vault(t, "", "get", "github-work", "--print")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "new password", out)
+
+	_, _, code = vault(t, "", "rm", id, "--force")
+	assert.Equal(t, 0, code)
+
+	_, errOut, code = vault(t, "", "get", "6b2bfbc0-8c23-414b-9c39-cf9b76520b39", "--print")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, errOut, "NOT_FOUND")
+}
+
+func TestCLI_Roles(t *testing.T) {
+	srv := newTestServer(t, rbac.Authorizer{
+		Tokens: map[string]string{rbac.HashToken("alice-token"): "alice", rbac.HashToken("bob-token"): "bob"},
+		Admins: []string{"alice"},
+	})
+	t.Setenv("VAULT_CLI_CONFIG", filepath.Join(t.TempDir(), "cli.yaml"))
+	t.Setenv("VAULT_URL", srv.URL)
+
+	_, errOut, code := vault(t, "", "list")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, errOut, "UNAUTHORIZED")
+
+	t.Setenv("VAULT_TOKEN", "alice-token")
+
+	for _, name := range []string{"prod/db", "dev/db"} {
+		_, _, code = vault(t, "secret\n", "add", name, "--password-stdin")
+		assert.Equal(t, 0, code)
+	}
+
+	_, errOut, code = vault(t, "", "grant", "bob", "prod/", "viewer")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, errOut, "bob is viewer on prod/")
+
+	out, _, code := vault(t, "", "roles")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, out, "alice")
+
+	t.Setenv("VAULT_TOKEN", "bob-token")
+
+	out, _, code = vault(t, "", "list")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, out, "prod/db")
+	assert.NotContains(t, out, "dev/db")
+
+	out, _, code = vault(t, "", "get", "prod/db", "--print")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "secret", out)
+
+	_, errOut, code = vault(t, "secret\n", "add", "prod/api", "--password-stdin")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, errOut, "FORBIDDEN")
+
+	_, errOut, code = vault(t, "", "grant", "bob", "prod/", "owner")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, errOut, "FORBIDDEN")
+
+	t.Setenv("VAULT_TOKEN", "alice-token")
+
+	_, _, code = vault(t, "", "revoke", "bob", "prod/")
+	assert.Equal(t, 0, code)
+
+	out, _, code = vault(t, "", "--json", "roles")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "[]\n", out)
+}
+
+func TestCLI_Profiles(t *testing.T) {
+	srv := newTestServer(t, rbac.Authorizer{})
+	path := filepath.Join(t.TempDir(), "cli.yaml")
+	t.Setenv("VAULT_CLI_CONFIG", path)
+	t.Setenv("VAULT_URL", "")
+
+	_, _, code := vault(t, "token-1\n", "profile", "add", "test", "--url", srv.URL, "--token-stdin")
+	assert.Equal(t, 0, code)
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	profiles, err := loadProfiles(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "test", profiles.Current)
+	assert.Equal(t, Profile{URL: srv.URL, Token: "token-1"}, profiles.Profiles["test"])
+
+	// the current profile points at the test server
+	out, _, code := vault(t, "", "list")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, out, "NAME")
+
+	_, errOut, code := vault(t, "", "--profile", "missing", "list")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, errOut, "unknown profile")
+}
+
+func TestGeneratePassword(t *testing.T) {
+	t.Parallel()
+
+	password, err := generatePassword(32, true)
+	assert.NoError(t, err)
+	assert.Len(t, password, 32)
+	assert.True(t, strings.ContainsAny(password, lowerChars))
+	assert.True(t, strings.ContainsAny(password, upperChars))
+	assert.True(t, strings.ContainsAny(password, digitChars))
+	assert.True(t, strings.ContainsAny(password, symbolChars))
+
+	password, err = generatePassword(16, false)
+	assert.NoError(t, err)
+	assert.False(t, strings.ContainsAny(password, symbolChars))
+
+	_, err = generatePassword(4, true)
+	assert.Error(t, err)
+}
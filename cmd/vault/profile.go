@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+const defaultURL = "http://localhost:8080"
+
+type Profile struct {
+	URL   string `yaml:"url"`
+	Token string `yaml:"token,omitempty"`
+}
+
+// Profiles is the CLI config file. It holds tokens, so it is written with
+// owner-only permissions.
+type Profiles struct {
+	Current  string             `yaml:"current,omitempty"`
+	Profiles map[string]Profile `yaml:"profiles,omitempty"`
+
+	path string
+}
+
+func profilesPath() (string, error) {
+	if path := os.Getenv("VAULT_CLI_CONFIG"); path != "" {
+		return path, nil
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "personal-vault", "cli.yaml"), nil
+}
+
+func loadProfiles(path string) (Profiles, error) {
+	profiles := Profiles{Profiles: map[string]Profile{}, path: path}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return profiles, nil
+	}
+	if err != nil {
+		return profiles, err
+	}
+
+	err = yaml.Unmarshal(data, &profiles)
+	if err != nil {
+		return profiles, fmt.Errorf("unable to parse %s: %w", path, err)
+	}
+
+	if profiles.Profiles == nil {
+		profiles.Profiles = map[string]Profile{}
+	}
+
+	return profiles, nil
+}
+
+func (p Profiles) save() error {
+	data, err := yaml.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(p.path), 0o700)
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(p.path, data, 0o600)
+	if err != nil {
+		return err
+	}
+
+	return os.Chmod(p.path, 0o600)
+}
+
+func (p Profiles) names() []string {
+	names := make([]string, 0, len(p.Profiles))
+	for name := range p.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// resolve picks the server and token: --url and VAULT_URL / VAULT_TOKEN win
+// over the selected profile, which falls back to the current one.
+func (p Profiles) resolve(name, url string) (Profile, error) {
+	var profile Profile
+
+	if name == "" {
+		name = os.Getenv("VAULT_PROFILE")
+	}
+	if name == "" {
+		name = p.Current
+	}
+
+	if name != "" {
+		var ok bool
+		profile, ok = p.Profiles[name]
+		if !ok {
+			return profile, fmt.Errorf("unknown profile %q", name)
+		}
+	}
+
+	if env := os.Getenv("VAULT_URL"); env != "" {
+		profile.URL = env
+	}
+	if url != "" {
+		profile.URL = url
+	}
+	if profile.URL == "" {
+		profile.URL = defaultURL
+	}
+
+	if env := os.Getenv("VAULT_TOKEN"); env != "" {
+		profile.Token = env
+	}
+
+	return profile, nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"personal-vault/internal/configuration"
+	"personal-vault/internal/db"
+	"personal-vault/internal/keycheck"
+	"personal-vault/internal/prompt"
+	"time"
+)
+
+// initVault creates the table, records the KDF salt and parameters in it and
+// writes the derived master key to the config file. The metadata item is
+// written conditionally, so a second init against the same table fails.
+func initVault(cfg configuration.Config, passwordStdin bool) error {
+	if cfg.Secret != "" {
+		return errors.New("vault is already initialised: a master key is configured")
+	}
+
+	path := cfg.File
+	if path == "" {
+		path = configuration.DefaultConfigFile
+	}
+
+	// the key is only kept in the config file, so it must be writable
+	// before the vault is created with it
+	err := configuration.CheckInitialConfig(path)
+	if err != nil {
+		return err
+	}
+
+	password, err := readMasterPassword(passwordStdin)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	svc, err := newDynamoDB(ctx, cfg.DB)
+	if err != nil {
+		return err
+	}
+
+	created, err := db.CreateTable(ctx, svc, cfg.DB.Table)
+	if err != nil {
+		return fmt.Errorf("unable to create table %s: %w", cfg.DB.Table, err)
+	}
+
+	if created {
+		fmt.Fprintf(os.Stderr, "created table %s\n", cfg.DB.Table)
+	}
+
+	salt, err := configuration.NewSalt(cfg.KDF)
+	if err != nil {
+		return err
+	}
+
+	key := configuration.DeriveKey(password, salt, cfg.KDF)
+
+	check, err := keycheck.New(string(key))
+	if err != nil {
+		return err
+	}
+
+	dbClient := db.NewClient(svc, cfg.DB.Table)
+	err = dbClient.CreateVaultMeta(ctx, db.VaultMeta{
+		KDF:        configuration.KDFAlgorithm,
+		Iterations: cfg.KDF.Iterations,
+		Salt:       salt,
+		KeyCheck:   check,
+		CreatedAt:  time.Now().UTC(),
+	})
+	if errors.Is(err, db.ErrConflict) {
+		return fmt.Errorf("table %s already holds an initialised vault", cfg.DB.Table)
+	}
+	if err != nil {
+		return err
+	}
+
+	err = configuration.WriteInitialConfig(path, key, cfg.KDF)
+	if err != nil {
+		return fmt.Errorf("vault metadata was stored but the config could not be written: %w", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "vault initialised, master key written to %s\n", path)
+
+	return nil
+}
+
+func readMasterPassword(fromStdin bool) (string, error) {
+	if fromStdin {
+		password, err := prompt.ReadLine(os.Stdin)
+		if err != nil {
+			return "", err
+		}
+
+		if len(password) < prompt.MinPasswordLength {
+			return "", fmt.Errorf("%w: at least %d characters are required", prompt.ErrPasswordTooShort, prompt.MinPasswordLength)
+		}
+
+		return password, nil
+	}
+
+	password, err := prompt.Terminal{In: os.Stdin, Out: os.Stderr}.NewPassword("Master password")
+	if errors.Is(err, prompt.ErrNotTerminal) {
+		return "", errors.New("init needs an interactive terminal; use --password-stdin to pipe the password")
+	}
+
+	return password, err
+}
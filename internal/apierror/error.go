@@ -0,0 +1,143 @@
+package apierror
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"personal-vault/internal/db"
+	"personal-vault/internal/decryption"
+	"personal-vault/internal/logging"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+const (
+	CodeInvalidRequest     = "INVALID_REQUEST"
+	CodeValidationFailed   = "VALIDATION_FAILED"
+	CodeUnauthorized       = "UNAUTHORIZED"
+	CodeForbidden          = "FORBIDDEN"
+	CodeNotFound           = "NOT_FOUND"
+	CodeConflict           = "CONFLICT"
+	CodeTooManyRequests    = "TOO_MANY_REQUESTS"
+	CodeDecryptionFailed   = "DECRYPTION_FAILED"
+	CodeServiceUnavailable = "SERVICE_UNAVAILABLE"
+	CodeTimeout            = "TIMEOUT"
+	CodeInternal           = "INTERNAL_ERROR"
+	CodePageNotFound       = "PAGE_NOT_FOUND"
+	CodeMethodNotAllowed   = "METHOD_NOT_ALLOWED"
+)
+
+// retryAfterSeconds is suggested to clients when DynamoDB is throttling.
+const retryAfterSeconds = "1"
+
+// FieldError describes a single failed validation rule on a request field.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Error is the body returned by every failing endpoint.
+type Error struct {
+	Status    int          `json:"-"`
+	Code      string       `json:"code"`
+	Message   string       `json:"message"`
+	Details   []FieldError `json:"details,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+
+	err error
+}
+
+func New(status int, code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+func (e *Error) Error() string {
+	if e.err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.err)
+	}
+
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// Wrap keeps the underlying cause for logging; it is never serialized.
+func (e *Error) Wrap(err error) *Error {
+	e.err = err
+	return e
+}
+
+func BadRequest(message string) *Error {
+	return New(http.StatusBadRequest, CodeInvalidRequest, message)
+}
+
+// Validation converts validator errors into field level details.
+func Validation(err error) *Error {
+	apiErr := New(http.StatusBadRequest, CodeValidationFailed, "request validation failed").Wrap(err)
+
+	var validationErrors validator.ValidationErrors
+	if errors.As(err, &validationErrors) {
+		for _, fe := range validationErrors {
+			apiErr.Details = append(apiErr.Details, FieldError{
+				Field:   fe.Field(),
+				Rule:    fe.Tag(),
+				Message: fieldMessage(fe),
+			})
+		}
+	}
+
+	return apiErr
+}
+
+// FromError maps domain errors to the matching HTTP status.
+func FromError(err error) *Error {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+
+	switch {
+	case errors.Is(err, db.ErrNotFound):
+		return New(http.StatusNotFound, CodeNotFound, "entry not found").Wrap(err)
+	case errors.Is(err, db.ErrConflict):
+		return New(http.StatusConflict, CodeConflict, "the entry was modified concurrently").Wrap(err)
+	case errors.Is(err, db.ErrThrottled):
+		return New(http.StatusServiceUnavailable, CodeServiceUnavailable, "the service is busy, retry later").Wrap(err)
+	case errors.Is(err, db.ErrUnavailable):
+		return New(http.StatusServiceUnavailable, CodeServiceUnavailable, "the service is unavailable, retry later").Wrap(err)
+	case errors.Is(err, db.ErrUncertain):
+		return New(http.StatusGatewayTimeout, CodeTimeout, "the change timed out and may have been applied, check before retrying").Wrap(err)
+	case errors.Is(err, decryption.ErrDecrypt):
+		return New(http.StatusInternalServerError, CodeDecryptionFailed, "unable to decrypt the entry").Wrap(err)
+	default:
+		return New(http.StatusInternalServerError, CodeInternal, "internal server error").Wrap(err)
+	}
+}
+
+// Respond aborts the request with the JSON representation of err.
+func Respond(c *gin.Context, err error) {
+	apiErr := *FromError(err)
+
+	if c.Request != nil {
+		apiErr.RequestID = logging.RequestIDFromContext(c.Request.Context())
+	}
+
+	if apiErr.Status == http.StatusServiceUnavailable {
+		c.Header("Retry-After", retryAfterSeconds)
+	}
+
+	c.AbortWithStatusJSON(apiErr.Status, apiErr)
+}
+
+func fieldMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return fmt.Sprintf("%s is required", fe.Field())
+	default:
+		return fmt.Sprintf("%s failed on the %s rule", fe.Field(), fe.Tag())
+	}
+}
@@ -0,0 +1,110 @@
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"personal-vault/internal/db"
+	"personal-vault/internal/decryption"
+	"personal-vault/internal/logging"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromError(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name           string
+		err            error
+		expectedStatus int
+		expectedCode   string
+	}{
+		{
+			name:           "api error is kept",
+			err:            BadRequest("bad"),
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   CodeInvalidRequest,
+		},
+		{
+			name:           "decrypt failure",
+			err:            fmt.Errorf("get: %w", decryption.ErrDecrypt),
+			expectedStatus: http.StatusInternalServerError,
+			expectedCode:   CodeDecryptionFailed,
+		},
+		{
+			name:           "not found",
+			err:            db.ErrNotFound,
+			expectedStatus: http.StatusNotFound,
+			expectedCode:   CodeNotFound,
+		},
+		{
+			name:           "conflict",
+			err:            fmt.Errorf("%w: mock", db.ErrConflict),
+			expectedStatus: http.StatusConflict,
+			expectedCode:   CodeConflict,
+		},
+		{
+			name:           "throttled",
+			err:            fmt.Errorf("%w: mock", db.ErrThrottled),
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedCode:   CodeServiceUnavailable,
+		},
+		{
+			name:           "unavailable",
+			err:            fmt.Errorf("%w: mock", db.ErrUnavailable),
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedCode:   CodeServiceUnavailable,
+		},
+		{
+			name:           "uncertain write",
+			err:            fmt.Errorf("%w: mock", db.ErrUncertain),
+			expectedStatus: http.StatusGatewayTimeout,
+			expectedCode:   CodeTimeout,
+		},
+		{
+			name:           "unknown error",
+			err:            errors.New("this is mock error"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedCode:   CodeInternal,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			apiErr := FromError(tt.err)
+			assert.Equal(t, tt.expectedStatus, apiErr.Status)
+			assert.Equal(t, tt.expectedCode, apiErr.Code)
+		})
+	}
+}
+
+func TestRespond(t *testing.T) {
+	t.Parallel()
+
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	req := httptest.NewRequest(http.MethodGet, "/retrieve/all", nil)
+	ctx.Request = req.WithContext(logging.WithRequestID(req.Context(), "req-1"))
+
+	Respond(ctx, db.ErrThrottled)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, retryAfterSeconds, w.Header().Get("Retry-After"))
+
+	var body map[string]any
+	err := json.Unmarshal(w.Body.Bytes(), &body)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"code":       CodeServiceUnavailable,
+		"message":    "the service is busy, retry later",
+		"request_id": "req-1",
+	}, body)
+}
@@ -1,89 +1,692 @@
 package configuration
 
 import (
-	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
-	"github.com/spf13/viper"
-	"golang.org/x/crypto/pbkdf2"
+	"log/slog"
+	"net"
+	"net/url"
 	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
 )
 
+const (
+	DefaultConfigFile = "vault.yaml"
+	DefaultTableName  = "personal-vault"
+
+	envPrefix  = "VAULT"
+	keyLength  = 32
+	minKDFIter = 100_000
+
+	EncryptionServer = "server"
+	EncryptionClient = "client"
+
+	RateLimitMemory   = "memory"
+	RateLimitDynamoDB = "dynamodb"
+
+	TracingNone   = "none"
+	TracingStdout = "stdout"
+	TracingOTLP   = "otlp"
+)
+
+// RateLimitGroups are the route groups with their own limit: retrieve for
+// /retrieve, shares for the public share links, accounts for users and
+// collections, and default for every other route.
+var RateLimitGroups = []string{"retrieve", "shares", "accounts", "default"}
+
+type DBConfig struct {
+	Table          string               `mapstructure:"table"`
+	Region         string               `mapstructure:"region"`
+	Endpoint       string               `mapstructure:"endpoint"`
+	Timeouts       DBTimeoutsConfig     `mapstructure:"timeouts"`
+	Retry          RetryConfig          `mapstructure:"retry"`
+	CircuitBreaker CircuitBreakerConfig `mapstructure:"circuit_breaker"`
+}
+
+// DBTimeoutsConfig bounds each attempt of a DynamoDB operation.
+type DBTimeoutsConfig struct {
+	GetItem       time.Duration `mapstructure:"get_item"`
+	PutItem       time.Duration `mapstructure:"put_item"`
+	UpdateItem    time.Duration `mapstructure:"update_item"`
+	DeleteItem    time.Duration `mapstructure:"delete_item"`
+	Scan          time.Duration `mapstructure:"scan"`
+	DescribeTable time.Duration `mapstructure:"describe_table"`
+}
+
+// RetryConfig retries throttled and transient DynamoDB failures up to
+// MaxAttempts times in all. The backoff starts at BaseDelay, or ThrottleDelay
+// for throttled calls, and doubles up to MaxDelay.
+type RetryConfig struct {
+	MaxAttempts   int           `mapstructure:"max_attempts"`
+	BaseDelay     time.Duration `mapstructure:"base_delay"`
+	ThrottleDelay time.Duration `mapstructure:"throttle_delay"`
+	MaxDelay      time.Duration `mapstructure:"max_delay"`
+}
+
+// CircuitBreakerConfig fails DynamoDB calls fast for OpenTimeout after
+// Failures failed attempts in a row. Failures 0 turns the breaker off.
+type CircuitBreakerConfig struct {
+	Failures    int           `mapstructure:"failures"`
+	OpenTimeout time.Duration `mapstructure:"open_timeout"`
+}
+
+type LogConfig struct {
+	Level  string `mapstructure:"level"`
+	Format string `mapstructure:"format"`
+}
+
+type ServerConfig struct {
+	ListenAddr      string        `mapstructure:"listen_addr"`
+	TLSCertFile     string        `mapstructure:"tls_cert_file"`
+	TLSKeyFile      string        `mapstructure:"tls_key_file"`
+	TLSSelfSigned   bool          `mapstructure:"tls_self_signed"`
+	ReadTimeout     time.Duration `mapstructure:"read_timeout"`
+	WriteTimeout    time.Duration `mapstructure:"write_timeout"`
+	IdleTimeout     time.Duration `mapstructure:"idle_timeout"`
+	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+	// TrustedProxies are the IPs or CIDRs whose X-Forwarded-For header is
+	// believed when the client IP is resolved.
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
+}
+
+// KDFConfig holds the PBKDF2-SHA256 parameters used to derive the master key
+// from the master password.
+type KDFConfig struct {
+	Iterations int `mapstructure:"iterations"`
+	SaltLength int `mapstructure:"salt_length"`
+}
+
+// ExpiryConfig controls the background check for expired entries.
+type ExpiryConfig struct {
+	CheckInterval time.Duration `mapstructure:"check_interval"`
+	// WebhookURL receives a JSON POST for every expired entry, if set.
+	WebhookURL string `mapstructure:"webhook_url"`
+}
+
+// TrashConfig controls how long deleted entries are kept before they are
+// purged for good.
+type TrashConfig struct {
+	Retention     time.Duration `mapstructure:"retention"`
+	SweepInterval time.Duration `mapstructure:"sweep_interval"`
+}
+
+// ShareConfig controls the links created by POST /entries/:id/share.
+type ShareConfig struct {
+	// BaseURL is the public URL of the server, used to build the links. The
+	// request host is used when it is empty.
+	BaseURL string `mapstructure:"base_url"`
+}
+
+// EncryptionConfig selects who encrypts the passwords of entries. In server
+// mode the server encrypts them with the master key. In client mode clients
+// send secrets sealed with pkg/clientcrypto, and the server stores and
+// returns them without ever seeing a password.
+type EncryptionConfig struct {
+	Mode string `mapstructure:"mode"`
+}
+
+// ClientSide reports whether the server only stores secrets sealed by
+// clients.
+func (enc EncryptionConfig) ClientSide() bool {
+	return enc.Mode == EncryptionClient
+}
+
+// AuthConfig identifies API callers by bearer token. The entry routes are
+// open while no tokens are configured.
+type AuthConfig struct {
+	Tokens []TokenConfig `mapstructure:"tokens"`
+	// Admins are principals with every role on every entry. They grant the
+	// first roles.
+	Admins []string `mapstructure:"admins"`
+}
+
+type TokenConfig struct {
+	Principal string `mapstructure:"principal"`
+	// SHA256 is the hex encoded SHA-256 of the token, so the config never
+	// holds the token itself. See `personal-vault token`.
+	SHA256 string `mapstructure:"sha256"`
+}
+
+// Principals maps each token hash to its principal.
+func (auth AuthConfig) Principals() map[string]string {
+	principals := make(map[string]string, len(auth.Tokens))
+	for _, token := range auth.Tokens {
+		principals[strings.ToLower(token.SHA256)] = token.Principal
+	}
+
+	return principals
+}
+
+// RateLimitConfig throttles clients by IP with a token bucket per route group,
+// see RateLimitGroups.
+type RateLimitConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Store is memory, where each instance limits on its own, or dynamodb to
+	// share the limits between the instances of a deployment through the
+	// table.
+	Store   string                 `mapstructure:"store"`
+	Groups  map[string]LimitConfig `mapstructure:"groups"`
+	Penalty PenaltyConfig          `mapstructure:"penalty"`
+}
+
+type LimitConfig struct {
+	// Rate is the sustained number of requests per second, Burst how many a
+	// client can send at once.
+	Rate  float64 `mapstructure:"rate"`
+	Burst int     `mapstructure:"burst"`
+}
+
+// PenaltyConfig blocks a client after Failures responses with 400, 401 or 404
+// within Window. Blocks start at Block and double up to MaxBlock. Failures 0
+// blocks no one.
+type PenaltyConfig struct {
+	Failures int           `mapstructure:"failures"`
+	Window   time.Duration `mapstructure:"window"`
+	Block    time.Duration `mapstructure:"block"`
+	MaxBlock time.Duration `mapstructure:"max_block"`
+}
+
+// TracingConfig exports OpenTelemetry traces. Exporter is none, stdout to
+// print the spans, or otlp to send them to Endpoint, an OTLP HTTP receiver
+// such as http://localhost:4318. SampleRatio is the share of traces started
+// here that are kept; a caller's sampling decision is always followed.
+type TracingConfig struct {
+	Exporter    string  `mapstructure:"exporter"`
+	Endpoint    string  `mapstructure:"endpoint"`
+	SampleRatio float64 `mapstructure:"sample_ratio"`
+}
+
+// HealthConfig tunes /readyz: its report is reused for CacheTTL, and each
+// check gives up after Timeout.
+type HealthConfig struct {
+	CacheTTL time.Duration `mapstructure:"cache_ttl"`
+	Timeout  time.Duration `mapstructure:"timeout"`
+}
+
+// CacheConfig keeps the entry listing in memory for TTL. Writes through an
+// instance drop its own cache. With Streams, every instance follows the stream
+// of the table every PollInterval and drops its cache on writes of the
+// others; otherwise they see those writes after TTL.
+type CacheConfig struct {
+	Enabled      bool          `mapstructure:"enabled"`
+	TTL          time.Duration `mapstructure:"ttl"`
+	Streams      bool          `mapstructure:"streams"`
+	PollInterval time.Duration `mapstructure:"poll_interval"`
+}
+
+// Config is resolved from, in increasing order of precedence: defaults, the
+// YAML/TOML config file, VAULT_* environment variables and command line flags.
 type Config struct {
-	DBUrl  string `mapstructure:"AWS_ENDPOINT_URL_DYNAMODB"`
-	Secret string `mapstructure:"SECRET"`
+	// Secret is the raw master key. It is configured hex encoded.
+	Secret     string           `mapstructure:"secret"`
+	DB         DBConfig         `mapstructure:"db"`
+	Log        LogConfig        `mapstructure:"log"`
+	Server     ServerConfig     `mapstructure:"server"`
+	KDF        KDFConfig        `mapstructure:"kdf"`
+	Expiry     ExpiryConfig     `mapstructure:"expiry"`
+	Trash      TrashConfig      `mapstructure:"trash"`
+	Share      ShareConfig      `mapstructure:"share"`
+	Auth       AuthConfig       `mapstructure:"auth"`
+	Encryption EncryptionConfig `mapstructure:"encryption"`
+	RateLimit  RateLimitConfig  `mapstructure:"rate_limit"`
+	Tracing    TracingConfig    `mapstructure:"tracing"`
+	Health     HealthConfig     `mapstructure:"health"`
+	Cache      CacheConfig      `mapstructure:"cache"`
+
+	// File is the config file that was read, if any.
+	File string `mapstructure:"-"`
+}
+
+var defaults = map[string]any{
+	"secret":                  "",
+	"db.table":                DefaultTableName,
+	"db.region":               "",
+	"db.endpoint":             "",
+	"log.level":               "info",
+	"log.format":              "json",
+	"server.listen_addr":      "localhost:8080",
+	"server.tls_cert_file":    "",
+	"server.tls_key_file":     "",
+	"server.tls_self_signed":  false,
+	"server.read_timeout":     "10s",
+	"server.write_timeout":    "10s",
+	"server.idle_timeout":     "60s",
+	"server.shutdown_timeout": "15s",
+	"server.trusted_proxies":  []string{},
+	"kdf.iterations":          600_000,
+	"kdf.salt_length":         32,
+	"expiry.check_interval":   "1h",
+	"expiry.webhook_url":      "",
+	"trash.retention":         "720h",
+	"trash.sweep_interval":    "1h",
+	"share.base_url":          "",
+	"auth.admins":             []string{},
+	"encryption.mode":         EncryptionServer,
+
+	"db.timeouts.get_item":            "2s",
+	"db.timeouts.put_item":            "3s",
+	"db.timeouts.update_item":         "3s",
+	"db.timeouts.delete_item":         "3s",
+	"db.timeouts.scan":                "10s",
+	"db.timeouts.describe_table":      "2s",
+	"db.retry.max_attempts":           3,
+	"db.retry.base_delay":             "25ms",
+	"db.retry.throttle_delay":         "100ms",
+	"db.retry.max_delay":              "1s",
+	"db.circuit_breaker.failures":     5,
+	"db.circuit_breaker.open_timeout": "10s",
+
+	"rate_limit.enabled":               true,
+	"rate_limit.store":                 RateLimitMemory,
+	"rate_limit.groups.retrieve.rate":  2,
+	"rate_limit.groups.retrieve.burst": 20,
+	"rate_limit.groups.shares.rate":    0.2,
+	"rate_limit.groups.shares.burst":   5,
+	"rate_limit.groups.accounts.rate":  1,
+	"rate_limit.groups.accounts.burst": 10,
+	"rate_limit.groups.default.rate":   10,
+	"rate_limit.groups.default.burst":  50,
+	"rate_limit.penalty.failures":      10,
+	"rate_limit.penalty.window":        "1m",
+	"rate_limit.penalty.block":         "1m",
+	"rate_limit.penalty.max_block":     "1h",
+
+	"tracing.exporter":     TracingNone,
+	"tracing.endpoint":     "",
+	"tracing.sample_ratio": 1,
+
+	"health.cache_ttl": "5s",
+	"health.timeout":   "2s",
+
+	"cache.enabled":       true,
+	"cache.ttl":           "30s",
+	"cache.streams":       false,
+	"cache.poll_interval": "1s",
 }
 
-func LoadConfig() (Config, error) {
+// legacyEnv keeps the variable names used before the VAULT_ prefix working.
+var legacyEnv = map[string]string{
+	"secret":      "SECRET",
+	"db.endpoint": "AWS_ENDPOINT_URL_DYNAMODB",
+	"db.region":   "AWS_REGION",
+	"log.level":   "LOG_LEVEL",
+	"log.format":  "LOG_FORMAT",
+}
+
+var flagKeys = map[string]string{
+	"table":            "db.table",
+	"region":           "db.region",
+	"endpoint":         "db.endpoint",
+	"listen-addr":      "server.listen_addr",
+	"tls-cert":         "server.tls_cert_file",
+	"tls-key":          "server.tls_key_file",
+	"tls-self-signed":  "server.tls_self_signed",
+	"log-level":        "log.level",
+	"log-format":       "log.format",
+	"kdf-iterations":   "kdf.iterations",
+	"expiry-webhook":   "expiry.webhook_url",
+	"trash-retention":  "trash.retention",
+	"share-base-url":   "share.base_url",
+	"encryption-mode":  "encryption.mode",
+	"rate-limit":       "rate_limit.enabled",
+	"rate-limit-store": "rate_limit.store",
+	"tracing-exporter": "tracing.exporter",
+	"tracing-endpoint": "tracing.endpoint",
+}
+
+// NewFlagSet declares the flags understood by LoadConfig so commands can add
+// their own flags to the same set.
+func NewFlagSet(name string) *pflag.FlagSet {
+	fs := pflag.NewFlagSet(name, pflag.ContinueOnError)
+	fs.String("config", "", "path to a YAML or TOML config file (default "+DefaultConfigFile+" if present)")
+	fs.String("table", DefaultTableName, "DynamoDB table name")
+	fs.String("region", "", "AWS region")
+	fs.String("endpoint", "", "DynamoDB endpoint URL, e.g. http://localhost:8000 for DynamoDB Local")
+	fs.String("listen-addr", "localhost:8080", "address the HTTP server listens on")
+	fs.String("tls-cert", "", "TLS certificate file")
+	fs.String("tls-key", "", "TLS private key file")
+	fs.Bool("tls-self-signed", false, "serve TLS with a generated self-signed certificate (development only)")
+	fs.String("log-level", "info", "log level: debug, info, warn or error")
+	fs.String("log-format", "json", "log format: json or text")
+	fs.Int("kdf-iterations", 600_000, "PBKDF2 iterations used to derive the master key")
+	fs.String("expiry-webhook", "", "URL that receives a JSON POST for every expired entry")
+	fs.Duration("trash-retention", 720*time.Hour, "how long deleted entries stay in the trash")
+	fs.String("share-base-url", "", "public URL of the server used in share links (default the request host)")
+	fs.String("encryption-mode", EncryptionServer, "server: encrypt passwords with the master key, client: only store secrets sealed by clients")
+	fs.Bool("rate-limit", true, "throttle clients by IP and block clients whose requests keep failing")
+	fs.String("rate-limit-store", RateLimitMemory, "memory, or dynamodb to share the rate limits between instances")
+	fs.String("tracing-exporter", TracingNone, "none, stdout, or otlp to send traces to --tracing-endpoint")
+	fs.String("tracing-endpoint", "", "OTLP HTTP receiver, e.g. http://localhost:4318 (default from OTEL_EXPORTER_OTLP_*)")
+
+	return fs
+}
+
+// LoadConfig resolves and validates the configuration. fs must have been
+// created by NewFlagSet and already parsed. It never writes to disk.
+func LoadConfig(fs *pflag.FlagSet) (Config, error) {
 	var cfg Config
 
-	viper.SetConfigFile("app.env")
-	viper.AutomaticEnv()
+	v := viper.New()
+	for key, value := range defaults {
+		v.SetDefault(key, value)
+	}
 
-	err := viper.ReadInConfig()
-	if err != nil {
-		return cfg, err
+	v.SetEnvPrefix(envPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+
+	for key, legacy := range legacyEnv {
+		err := v.BindEnv(key, envPrefix+"_"+strings.ToUpper(strings.ReplaceAll(key, ".", "_")), legacy)
+		if err != nil {
+			return cfg, err
+		}
 	}
 
-	err = viper.Unmarshal(&cfg)
-	if err != nil {
-		return cfg, err
+	for name, key := range flagKeys {
+		err := v.BindPFlag(key, fs.Lookup(name))
+		if err != nil {
+			return cfg, err
+		}
 	}
 
-	err = os.Setenv("AWS_ENDPOINT_URL_DYNAMODB", cfg.DBUrl)
+	file, err := configFile(fs)
 	if err != nil {
 		return cfg, err
 	}
 
-	if len(cfg.Secret) == 0 {
-		secret, err := generateKey()
+	if file != "" {
+		v.SetConfigFile(file)
+
+		err = v.ReadInConfig()
 		if err != nil {
-			return cfg, err
+			return cfg, fmt.Errorf("unable to read config file %s: %w", file, err)
 		}
+	}
 
-		viper.Set("AWS_ENDPOINT_URL_DYNAMODB", "http://localhost:8000")
-		viper.Set("SECRET", hex.EncodeToString(secret))
-		cfg.Secret = string(secret)
+	err = v.Unmarshal(&cfg)
+	if err != nil {
+		return cfg, err
+	}
 
-		err = viper.WriteConfigAs(viper.ConfigFileUsed())
-		if err != nil {
-			return cfg, err
-		}
+	cfg.File = file
 
-	} else {
+	if cfg.Secret != "" {
 		secret, err := hex.DecodeString(cfg.Secret)
 		if err != nil {
-			return cfg, err
+			return cfg, errors.New("invalid config: secret must be hex encoded")
 		}
 
 		cfg.Secret = string(secret)
+	}
 
+	err = cfg.Validate()
+	if err != nil {
+		return cfg, err
 	}
 
 	return cfg, nil
 }
 
-func generateKey() ([]byte, error) {
-	fmt.Println("Please Enter Your Password: ")
+// configFile returns the explicitly requested file, or the default file when it
+// exists. An explicitly requested file must exist.
+func configFile(fs *pflag.FlagSet) (string, error) {
+	file, err := fs.GetString("config")
+	if err != nil {
+		return "", err
+	}
 
-	var (
-		pw  string
-		key []byte
-	)
+	if file == "" {
+		file = os.Getenv(envPrefix + "_CONFIG")
+	}
 
-	_, err := fmt.Scanln(&pw)
-	if err != nil {
-		return nil, err
+	if file != "" {
+		return file, nil
 	}
 
-	salt := make([]byte, 32)
-	_, err = rand.Read(salt)
+	_, err = os.Stat(DefaultConfigFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
 	if err != nil {
-		return key, err
+		return "", err
+	}
+
+	return DefaultConfigFile, nil
+}
+
+// Validate reports every invalid setting at once. A missing secret is not an
+// error here because commands such as init run before one exists; see
+// RequireSecret.
+func (cfg Config) Validate() error {
+	var errs []error
+
+	if cfg.Secret != "" && len(cfg.Secret) != keyLength {
+		errs = append(errs, fmt.Errorf("secret must be %d bytes (%d hex characters)", keyLength, keyLength*2))
 	}
 
-	key = pbkdf2.Key([]byte(pw), salt, 1, 32, sha256.New)
+	if cfg.DB.Table == "" {
+		errs = append(errs, errors.New("db.table must not be empty"))
+	}
+
+	if cfg.DB.Endpoint != "" {
+		u, err := url.Parse(cfg.DB.Endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("db.endpoint %q must be an http(s) URL", cfg.DB.Endpoint))
+		}
+	}
+
+	if cfg.Expiry.WebhookURL != "" {
+		u, err := url.Parse(cfg.Expiry.WebhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("expiry.webhook_url %q must be an http(s) URL", cfg.Expiry.WebhookURL))
+		}
+	}
+
+	if cfg.Share.BaseURL != "" {
+		u, err := url.Parse(cfg.Share.BaseURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("share.base_url %q must be an http(s) URL", cfg.Share.BaseURL))
+		}
+	}
+
+	principals := map[string]bool{}
+	hashes := map[string]bool{}
+	for i, token := range cfg.Auth.Tokens {
+		if token.Principal == "" || strings.Contains(token.Principal, "#") {
+			errs = append(errs, fmt.Errorf("auth.tokens[%d].principal must be set and cannot contain #", i))
+		}
+
+		hash, err := hex.DecodeString(token.SHA256)
+		if err != nil || len(hash) != sha256.Size {
+			errs = append(errs, fmt.Errorf("auth.tokens[%d].sha256 must be a hex encoded SHA-256", i))
+		}
+
+		if hashes[strings.ToLower(token.SHA256)] {
+			errs = append(errs, fmt.Errorf("auth.tokens[%d].sha256 is configured twice", i))
+		}
+
+		principals[token.Principal] = true
+		hashes[strings.ToLower(token.SHA256)] = true
+	}
+
+	for _, admin := range cfg.Auth.Admins {
+		if !principals[admin] {
+			errs = append(errs, fmt.Errorf("auth.admins: %q has no token", admin))
+		}
+	}
+
+	if _, _, err := net.SplitHostPort(cfg.Server.ListenAddr); err != nil {
+		errs = append(errs, fmt.Errorf("server.listen_addr %q must be host:port", cfg.Server.ListenAddr))
+	}
+
+	if (cfg.Server.TLSCertFile == "") != (cfg.Server.TLSKeyFile == "") {
+		errs = append(errs, errors.New("server.tls_cert_file and server.tls_key_file must be set together"))
+	}
+
+	if cfg.Server.TLSSelfSigned && cfg.Server.TLSCertFile != "" {
+		errs = append(errs, errors.New("server.tls_self_signed cannot be combined with a certificate file"))
+	}
+
+	for _, timeout := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"server.read_timeout", cfg.Server.ReadTimeout},
+		{"server.write_timeout", cfg.Server.WriteTimeout},
+		{"server.idle_timeout", cfg.Server.IdleTimeout},
+		{"server.shutdown_timeout", cfg.Server.ShutdownTimeout},
+		{"expiry.check_interval", cfg.Expiry.CheckInterval},
+		{"trash.retention", cfg.Trash.Retention},
+		{"trash.sweep_interval", cfg.Trash.SweepInterval},
+		{"health.timeout", cfg.Health.Timeout},
+		{"db.timeouts.get_item", cfg.DB.Timeouts.GetItem},
+		{"db.timeouts.put_item", cfg.DB.Timeouts.PutItem},
+		{"db.timeouts.update_item", cfg.DB.Timeouts.UpdateItem},
+		{"db.timeouts.delete_item", cfg.DB.Timeouts.DeleteItem},
+		{"db.timeouts.scan", cfg.DB.Timeouts.Scan},
+		{"db.timeouts.describe_table", cfg.DB.Timeouts.DescribeTable},
+		{"db.retry.base_delay", cfg.DB.Retry.BaseDelay},
+		{"db.retry.throttle_delay", cfg.DB.Retry.ThrottleDelay},
+	} {
+		if timeout.value <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive", timeout.name))
+		}
+	}
+
+	if cfg.DB.Retry.MaxAttempts < 1 {
+		errs = append(errs, errors.New("db.retry.max_attempts must be at least 1"))
+	}
+
+	if cfg.DB.Retry.MaxDelay < max(cfg.DB.Retry.BaseDelay, cfg.DB.Retry.ThrottleDelay) {
+		errs = append(errs, errors.New("db.retry.max_delay must be at least base_delay and throttle_delay"))
+	}
+
+	if cfg.DB.CircuitBreaker.Failures < 0 {
+		errs = append(errs, errors.New("db.circuit_breaker.failures must not be negative"))
+	}
+
+	if cfg.DB.CircuitBreaker.Failures > 0 && cfg.DB.CircuitBreaker.OpenTimeout <= 0 {
+		errs = append(errs, errors.New("db.circuit_breaker.open_timeout must be positive"))
+	}
+
+	if cfg.Health.CacheTTL < 0 {
+		errs = append(errs, errors.New("health.cache_ttl must not be negative"))
+	}
+
+	if cfg.Cache.Enabled && cfg.Cache.TTL <= 0 {
+		errs = append(errs, errors.New("cache.ttl must be positive"))
+	}
+
+	if cfg.Cache.Streams && !cfg.Cache.Enabled {
+		errs = append(errs, errors.New("cache.streams requires cache.enabled"))
+	}
+
+	if cfg.Cache.Streams && cfg.Cache.PollInterval <= 0 {
+		errs = append(errs, errors.New("cache.poll_interval must be positive"))
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.Log.Level)); err != nil {
+		errs = append(errs, fmt.Errorf("log.level %q must be debug, info, warn or error", cfg.Log.Level))
+	}
+
+	if cfg.Log.Format != "json" && cfg.Log.Format != "text" {
+		errs = append(errs, fmt.Errorf("log.format %q must be json or text", cfg.Log.Format))
+	}
+
+	if cfg.KDF.Iterations < minKDFIter {
+		errs = append(errs, fmt.Errorf("kdf.iterations must be at least %d", minKDFIter))
+	}
+
+	if cfg.Encryption.Mode != EncryptionServer && cfg.Encryption.Mode != EncryptionClient {
+		errs = append(errs, fmt.Errorf("encryption.mode %q must be %s or %s", cfg.Encryption.Mode, EncryptionServer, EncryptionClient))
+	}
+
+	errs = append(errs, cfg.validateRateLimit()...)
+
+	switch cfg.Tracing.Exporter {
+	case TracingNone, TracingStdout, TracingOTLP:
+	default:
+		errs = append(errs, fmt.Errorf("tracing.exporter %q must be %s, %s or %s", cfg.Tracing.Exporter, TracingNone, TracingStdout, TracingOTLP))
+	}
+
+	if cfg.Tracing.SampleRatio < 0 || cfg.Tracing.SampleRatio > 1 {
+		errs = append(errs, errors.New("tracing.sample_ratio must be between 0 and 1"))
+	}
+
+	if cfg.Tracing.Endpoint != "" {
+		u, err := url.Parse(cfg.Tracing.Endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("tracing.endpoint %q must be an http(s) URL", cfg.Tracing.Endpoint))
+		}
+	}
+
+	if cfg.KDF.SaltLength < 16 {
+		errs = append(errs, errors.New("kdf.salt_length must be at least 16"))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid config: %w", errors.Join(errs...))
+	}
+
+	return nil
+}
+
+func (cfg Config) validateRateLimit() []error {
+	var errs []error
+
+	for _, proxy := range cfg.Server.TrustedProxies {
+		_, _, err := net.ParseCIDR(proxy)
+		if err != nil && net.ParseIP(proxy) == nil {
+			errs = append(errs, fmt.Errorf("server.trusted_proxies: %q must be an IP or CIDR", proxy))
+		}
+	}
+
+	limits := cfg.RateLimit
+	if limits.Store != RateLimitMemory && limits.Store != RateLimitDynamoDB {
+		errs = append(errs, fmt.Errorf("rate_limit.store %q must be %s or %s", limits.Store, RateLimitMemory, RateLimitDynamoDB))
+	}
+
+	for name, limit := range limits.Groups {
+		if !slices.Contains(RateLimitGroups, name) {
+			errs = append(errs, fmt.Errorf("rate_limit.groups: unknown group %q, must be one of %s", name, strings.Join(RateLimitGroups, ", ")))
+		}
+
+		if limit.Rate <= 0 || limit.Burst < 1 {
+			errs = append(errs, fmt.Errorf("rate_limit.groups.%s needs a positive rate and a burst of at least 1", name))
+		}
+	}
+
+	penalty := limits.Penalty
+	if penalty.Failures < 0 {
+		errs = append(errs, errors.New("rate_limit.penalty.failures must not be negative"))
+	}
+
+	if penalty.Failures > 0 {
+		if penalty.Window <= 0 || penalty.Block <= 0 {
+			errs = append(errs, errors.New("rate_limit.penalty.window and block must be positive"))
+		}
+
+		if penalty.MaxBlock < penalty.Block {
+			errs = append(errs, errors.New("rate_limit.penalty.max_block must be at least block"))
+		}
+	}
+
+	return errs
+}
+
+// RequireSecret is checked by commands that need the master key.
+func (cfg Config) RequireSecret() error {
+	if cfg.Secret == "" {
+		return errors.New("no master key configured: run `personal-vault init` or set VAULT_SECRET")
+	}
 
-	return key, nil
+	return nil
 }
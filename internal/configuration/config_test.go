@@ -0,0 +1,456 @@
+package configuration
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testSecret = "0f6f8edf954592d7523b475bb56fd0486b7a049d67c1e5aa522bbc8bfe961971"
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	err := os.WriteFile(path, []byte(content), 0o600)
+	assert.NoError(t, err)
+
+	return path
+}
+
+func load(t *testing.T, args ...string) (Config, error) {
+	t.Helper()
+
+	fs := NewFlagSet("test")
+	err := fs.Parse(args)
+	assert.NoError(t, err)
+
+	return LoadConfig(fs)
+}
+
+func TestLoadConfig_Defaults(t *testing.T) {
+	path := writeFile(t, "vault.yaml", "")
+
+	cfg, err := load(t, "--config", path)
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultTableName, cfg.DB.Table)
+	assert.Equal(t, "localhost:8080", cfg.Server.ListenAddr)
+	assert.Equal(t, 10*time.Second, cfg.Server.ReadTimeout)
+	assert.Equal(t, 600_000, cfg.KDF.Iterations)
+	assert.Equal(t, 30*24*time.Hour, cfg.Trash.Retention)
+	assert.False(t, cfg.Encryption.ClientSide())
+	assert.True(t, cfg.RateLimit.Enabled)
+	assert.Equal(t, LimitConfig{Rate: 2, Burst: 20}, cfg.RateLimit.Groups["retrieve"])
+	assert.Len(t, cfg.RateLimit.Groups, len(RateLimitGroups))
+	assert.Empty(t, cfg.Secret)
+}
+
+func TestLoadConfig_Layering(t *testing.T) {
+	path := writeFile(t, "vault.yaml", `
+secret: `+testSecret+`
+db:
+  table: from-file
+  region: eu-west-1
+  endpoint: http://localhost:8000
+log:
+  level: debug
+server:
+  listen_addr: 0.0.0.0:9000
+`)
+
+	t.Setenv("VAULT_DB_REGION", "us-east-1")
+	t.Setenv("VAULT_LOG_LEVEL", "warn")
+
+	cfg, err := load(t, "--config", path, "--log-level", "error")
+	assert.NoError(t, err)
+
+	// file overrides defaults
+	assert.Equal(t, "from-file", cfg.DB.Table)
+	assert.Equal(t, "0.0.0.0:9000", cfg.Server.ListenAddr)
+	// env overrides file
+	assert.Equal(t, "us-east-1", cfg.DB.Region)
+	// flags override env
+	assert.Equal(t, "error", cfg.Log.Level)
+
+	assert.Len(t, cfg.Secret, keyLength)
+	assert.Equal(t, path, cfg.File)
+}
+
+func TestLoadConfig_TOML(t *testing.T) {
+	path := writeFile(t, "vault.toml", `
+[db]
+table = "from-toml"
+`)
+
+	cfg, err := load(t, "--config", path)
+	assert.NoError(t, err)
+	assert.Equal(t, "from-toml", cfg.DB.Table)
+}
+
+func TestLoadConfig_LegacyEnv(t *testing.T) {
+	t.Setenv("VAULT_CONFIG", writeFile(t, "vault.yaml", ""))
+	t.Setenv("SECRET", testSecret)
+	t.Setenv("AWS_ENDPOINT_URL_DYNAMODB", "http://localhost:8000")
+
+	cfg, err := load(t)
+	assert.NoError(t, err)
+	assert.Len(t, cfg.Secret, keyLength)
+	assert.Equal(t, "http://localhost:8000", cfg.DB.Endpoint)
+}
+
+func TestLoadConfig_Validation(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		expectedErr string
+	}{
+		{
+			name:        "missing explicit file",
+			args:        []string{"--config", "does-not-exist.yaml"},
+			expectedErr: "unable to read config file",
+		},
+		{
+			name:        "invalid endpoint",
+			args:        []string{"--endpoint", "localhost:8000"},
+			expectedErr: "db.endpoint",
+		},
+		{
+			name:        "invalid listen address",
+			args:        []string{"--listen-addr", "8080"},
+			expectedErr: "server.listen_addr",
+		},
+		{
+			name:        "invalid log level",
+			args:        []string{"--log-level", "loud"},
+			expectedErr: "log.level",
+		},
+		{
+			name:        "weak kdf",
+			args:        []string{"--kdf-iterations", "1"},
+			expectedErr: "kdf.iterations",
+		},
+		{
+			name:        "tls key without cert",
+			args:        []string{"--tls-key", "key.pem"},
+			expectedErr: "tls_cert_file",
+		},
+		{
+			name:        "invalid expiry webhook",
+			args:        []string{"--expiry-webhook", "ftp://localhost/hook"},
+			expectedErr: "expiry.webhook_url",
+		},
+		{
+			name:        "no trash retention",
+			args:        []string{"--trash-retention", "0s"},
+			expectedErr: "trash.retention",
+		},
+		{
+			name:        "unknown encryption mode",
+			args:        []string{"--encryption-mode", "none"},
+			expectedErr: "encryption.mode",
+		},
+		{
+			name:        "unknown rate limit store",
+			args:        []string{"--rate-limit-store", "redis"},
+			expectedErr: "rate_limit.store",
+		},
+		{
+			name:        "invalid share base url",
+			args:        []string{"--share-base-url", "vault.example.com"},
+			expectedErr: "share.base_url",
+		},
+	}
+
+	t.Setenv("VAULT_CONFIG", writeFile(t, "vault.yaml", ""))
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := load(t, tt.args...)
+			assert.ErrorContains(t, err, tt.expectedErr)
+		})
+	}
+}
+
+func TestLoadConfig_Auth(t *testing.T) {
+	hash := "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+
+	path := writeFile(t, "vault.yaml", `
+auth:
+  tokens:
+    - principal: alice
+      sha256: `+hash+`
+  admins: [alice]
+`)
+
+	cfg, err := load(t, "--config", path)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{hash: "alice"}, cfg.Auth.Principals())
+	assert.Equal(t, []string{"alice"}, cfg.Auth.Admins)
+
+	path = writeFile(t, "vault.yaml", `
+auth:
+  tokens:
+    - principal: "team#ops"
+      sha256: not-a-hash
+  admins: [bob]
+`)
+
+	_, err = load(t, "--config", path)
+	assert.ErrorContains(t, err, "auth.tokens[0].principal")
+	assert.ErrorContains(t, err, "auth.tokens[0].sha256")
+	assert.ErrorContains(t, err, `auth.admins: "bob" has no token`)
+}
+
+func TestLoadConfig_RateLimit(t *testing.T) {
+	path := writeFile(t, "vault.yaml", `
+server:
+  trusted_proxies: [10.0.0.0/8, 192.168.1.1]
+rate_limit:
+  store: dynamodb
+  groups:
+    retrieve:
+      burst: 5
+  penalty:
+    block: 30s
+`)
+
+	cfg, err := load(t, "--config", path)
+	assert.NoError(t, err)
+	assert.Equal(t, RateLimitDynamoDB, cfg.RateLimit.Store)
+	assert.Equal(t, LimitConfig{Rate: 2, Burst: 5}, cfg.RateLimit.Groups["retrieve"], "the rate keeps its default")
+	assert.Equal(t, LimitConfig{Rate: 10, Burst: 50}, cfg.RateLimit.Groups["default"])
+	assert.Equal(t, PenaltyConfig{Failures: 10, Window: time.Minute, Block: 30 * time.Second, MaxBlock: time.Hour}, cfg.RateLimit.Penalty)
+	assert.Equal(t, []string{"10.0.0.0/8", "192.168.1.1"}, cfg.Server.TrustedProxies)
+
+	path = writeFile(t, "vault.yaml", `
+server:
+  trusted_proxies: [proxy.local]
+rate_limit:
+  groups:
+    retreive:
+      rate: 1
+      burst: 1
+    shares:
+      rate: 0
+  penalty:
+    block: 2h
+`)
+
+	_, err = load(t, "--config", path)
+	assert.ErrorContains(t, err, "server.trusted_proxies")
+	assert.ErrorContains(t, err, `unknown group "retreive"`)
+	assert.ErrorContains(t, err, "rate_limit.groups.shares")
+	assert.ErrorContains(t, err, "rate_limit.penalty.max_block")
+}
+
+func TestLoadConfig_Tracing(t *testing.T) {
+	path := writeFile(t, "vault.yaml", "")
+
+	cfg, err := load(t, "--config", path)
+	assert.NoError(t, err)
+	assert.Equal(t, TracingConfig{Exporter: TracingNone, SampleRatio: 1}, cfg.Tracing)
+
+	cfg, err = load(t, "--config", path, "--tracing-exporter", "otlp", "--tracing-endpoint", "http://localhost:4318")
+	assert.NoError(t, err)
+	assert.Equal(t, TracingConfig{Exporter: TracingOTLP, Endpoint: "http://localhost:4318", SampleRatio: 1}, cfg.Tracing)
+
+	path = writeFile(t, "vault.yaml", `
+tracing:
+  exporter: jaeger
+  endpoint: localhost:4318
+  sample_ratio: 2
+`)
+
+	_, err = load(t, "--config", path)
+	assert.ErrorContains(t, err, `tracing.exporter "jaeger"`)
+	assert.ErrorContains(t, err, "tracing.endpoint")
+	assert.ErrorContains(t, err, "tracing.sample_ratio")
+}
+
+func TestLoadConfig_Health(t *testing.T) {
+	path := writeFile(t, "vault.yaml", "health:\n  cache_ttl: 0s\n")
+
+	cfg, err := load(t, "--config", path)
+	assert.NoError(t, err)
+	assert.Equal(t, HealthConfig{CacheTTL: 0, Timeout: 2 * time.Second}, cfg.Health)
+
+	path = writeFile(t, "vault.yaml", "health:\n  cache_ttl: -1s\n  timeout: 0s\n")
+
+	_, err = load(t, "--config", path)
+	assert.ErrorContains(t, err, "health.cache_ttl")
+	assert.ErrorContains(t, err, "health.timeout")
+}
+
+func TestLoadConfig_DBPolicy(t *testing.T) {
+	path := writeFile(t, "vault.yaml", `
+db:
+  timeouts:
+    scan: 30s
+  retry:
+    max_attempts: 5
+  circuit_breaker:
+    failures: 0
+`)
+
+	cfg, err := load(t, "--config", path)
+	assert.NoError(t, err)
+	assert.Equal(t, DBTimeoutsConfig{
+		GetItem:       2 * time.Second,
+		PutItem:       3 * time.Second,
+		UpdateItem:    3 * time.Second,
+		DeleteItem:    3 * time.Second,
+		Scan:          30 * time.Second,
+		DescribeTable: 2 * time.Second,
+	}, cfg.DB.Timeouts)
+	assert.Equal(t, RetryConfig{MaxAttempts: 5, BaseDelay: 25 * time.Millisecond, ThrottleDelay: 100 * time.Millisecond, MaxDelay: time.Second}, cfg.DB.Retry)
+	assert.Equal(t, CircuitBreakerConfig{Failures: 0, OpenTimeout: 10 * time.Second}, cfg.DB.CircuitBreaker)
+
+	path = writeFile(t, "vault.yaml", `
+db:
+  timeouts:
+    get_item: 0s
+  retry:
+    max_attempts: 0
+    throttle_delay: 2s
+  circuit_breaker:
+    failures: 3
+    open_timeout: 0s
+`)
+
+	_, err = load(t, "--config", path)
+	assert.ErrorContains(t, err, "db.timeouts.get_item")
+	assert.ErrorContains(t, err, "db.retry.max_attempts")
+	assert.ErrorContains(t, err, "db.retry.max_delay")
+	assert.ErrorContains(t, err, "db.circuit_breaker.open_timeout")
+}
+
+func TestLoadConfig_Cache(t *testing.T) {
+	path := writeFile(t, "vault.yaml", "cache:\n  streams: true\n")
+
+	cfg, err := load(t, "--config", path)
+	assert.NoError(t, err)
+	assert.Equal(t, CacheConfig{Enabled: true, TTL: 30 * time.Second, Streams: true, PollInterval: time.Second}, cfg.Cache)
+
+	path = writeFile(t, "vault.yaml", "cache:\n  enabled: false\n  ttl: 0s\n")
+
+	cfg, err = load(t, "--config", path)
+	assert.NoError(t, err, "the TTL of a disabled cache is not used")
+	assert.False(t, cfg.Cache.Enabled)
+
+	path = writeFile(t, "vault.yaml", "cache:\n  enabled: false\n  streams: true\n  poll_interval: 0s\n")
+
+	_, err = load(t, "--config", path)
+	assert.ErrorContains(t, err, "cache.streams")
+	assert.ErrorContains(t, err, "cache.poll_interval")
+
+	path = writeFile(t, "vault.yaml", "cache:\n  ttl: -1s\n")
+
+	_, err = load(t, "--config", path)
+	assert.ErrorContains(t, err, "cache.ttl")
+}
+
+func TestLoadConfig_InvalidSecret(t *testing.T) {
+	t.Setenv("VAULT_CONFIG", writeFile(t, "vault.yaml", ""))
+	t.Setenv("VAULT_SECRET", "abcd")
+
+	_, err := load(t)
+	assert.ErrorContains(t, err, "secret must be")
+}
+
+func TestLoadConfig_DoesNotWrite(t *testing.T) {
+	path := writeFile(t, "vault.yaml", "db:\n  table: unchanged\n")
+	before, err := os.ReadFile(path)
+	assert.NoError(t, err)
+
+	cfg, err := load(t, "--config", path)
+	assert.NoError(t, err)
+	assert.ErrorContains(t, cfg.RequireSecret(), "init")
+
+	after, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, before, after)
+}
+
+func TestWriteInitialConfig(t *testing.T) {
+	path := writeFile(t, "vault.yaml", "db:\n  table: kept\n")
+
+	cfg, err := load(t, "--config", path)
+	assert.NoError(t, err)
+
+	salt, err := NewSalt(cfg.KDF)
+	assert.NoError(t, err)
+
+	key := DeriveKey("correct horse battery staple", salt, cfg.KDF)
+	err = WriteInitialConfig(path, key, cfg.KDF)
+	assert.NoError(t, err)
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	cfg, err = load(t, "--config", path)
+	assert.NoError(t, err)
+	assert.NoError(t, cfg.RequireSecret())
+	assert.Equal(t, string(key), cfg.Secret)
+	assert.Equal(t, "kept", cfg.DB.Table)
+
+	err = WriteInitialConfig(path, key, cfg.KDF)
+	assert.ErrorContains(t, err, "already contains a secret")
+}
+
+func TestCheckInitialConfig(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		path        func(t *testing.T) string
+		expectedErr string
+	}{
+		{
+			name: "new file",
+			path: func(t *testing.T) string { return filepath.Join(t.TempDir(), "vault.yaml") },
+		},
+		{
+			name: "existing file",
+			path: func(t *testing.T) string { return writeFile(t, "vault.yaml", "db:\n  table: kept\n") },
+		},
+		{
+			name:        "existing secret",
+			path:        func(t *testing.T) string { return writeFile(t, "vault.yaml", "secret: abcd\n") },
+			expectedErr: "already contains a secret",
+		},
+		{
+			name:        "missing directory",
+			path:        func(t *testing.T) string { return filepath.Join(t.TempDir(), "missing", "vault.yaml") },
+			expectedErr: "unable to write config file",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			path := tt.path(t)
+			before, _ := os.ReadFile(path)
+			entries, _ := os.ReadDir(filepath.Dir(path))
+
+			err := CheckInitialConfig(path)
+			if tt.expectedErr != "" {
+				assert.ErrorContains(t, err, tt.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			// the check leaves the directory and the file as they were
+			after, _ := os.ReadFile(path)
+			assert.Equal(t, before, after)
+			afterEntries, _ := os.ReadDir(filepath.Dir(path))
+			assert.Equal(t, len(entries), len(afterEntries))
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package configuration
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/viper"
+)
+
+// WriteInitialConfig stores the master key in the config file at path,
+// keeping the settings already in it. It refuses to replace an existing
+// secret. The file is only readable by its owner.
+func WriteInitialConfig(path string, key []byte, kdf KDFConfig) error {
+	v, err := readInitialConfig(path)
+	if err != nil {
+		return err
+	}
+
+	v.Set("secret", hex.EncodeToString(key))
+	v.Set("kdf.iterations", kdf.Iterations)
+	v.Set("kdf.salt_length", kdf.SaltLength)
+
+	err = v.WriteConfigAs(path)
+	if err != nil {
+		return err
+	}
+
+	// viper keeps the mode of an existing file
+	return os.Chmod(path, 0o600)
+}
+
+// CheckInitialConfig reports an error when WriteInitialConfig could not
+// store a key at path, so init finds out before the vault is created with a
+// key that cannot be kept. It leaves the file as it is.
+func CheckInitialConfig(path string) error {
+	_, err := readInitialConfig(path)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if errors.Is(err, os.ErrNotExist) {
+		file, err = os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+		if err == nil {
+			defer os.Remove(file.Name())
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("unable to write config file %s: %w", path, err)
+	}
+
+	return file.Close()
+}
+
+func readInitialConfig(path string) (*viper.Viper, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	v.SetConfigPermissions(0o600)
+
+	err := v.ReadInConfig()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("unable to read config file %s: %w", path, err)
+	}
+
+	if v.GetString("secret") != "" {
+		return nil, fmt.Errorf("%s already contains a secret", path)
+	}
+
+	return v, nil
+}
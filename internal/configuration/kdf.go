@@ -0,0 +1,25 @@
+package configuration
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const KDFAlgorithm = "pbkdf2-sha256"
+
+func NewSalt(kdf KDFConfig) ([]byte, error) {
+	salt := make([]byte, kdf.SaltLength)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return nil, err
+	}
+
+	return salt, nil
+}
+
+// DeriveKey derives the AES-256 master key from the master password.
+func DeriveKey(password string, salt []byte, kdf KDFConfig) []byte {
+	return pbkdf2.Key([]byte(password), salt, kdf.Iterations, keyLength, sha256.New)
+}
@@ -0,0 +1,157 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	accessPrefix = reservedPrefix + "access#"
+
+	// accessTimeout bounds the write of RecordAccessAsync.
+	accessTimeout = 5 * time.Second
+)
+
+// accessStats are the access stats of one entry. They are kept in a reserved
+// item next to the entry, so reading a password does not change the entry:
+// the listing cache and the stream consumers of other instances only see
+// changes to entries. Entries read before the split may still carry stats of
+// their own, which are added to these.
+type accessStats struct {
+	EntryID        string     `dynamodbav:"entry_id"`
+	LastAccessedAt *time.Time `dynamodbav:"last_accessed_at"`
+	AccessCount    int        `dynamodbav:"access_count"`
+}
+
+func accessKey(id string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"id": &types.AttributeValueMemberS{Value: accessPrefix + id},
+	}
+}
+
+// addTo adds the stats to those the entry carries itself.
+func (stats accessStats) addTo(lastAccessedAt **time.Time, accessCount *int) {
+	*accessCount += stats.AccessCount
+
+	if stats.LastAccessedAt != nil && (*lastAccessedAt == nil || (*lastAccessedAt).Before(*stats.LastAccessedAt)) {
+		*lastAccessedAt = stats.LastAccessedAt
+	}
+}
+
+// withAccess adds the stored access stats of the entry to it.
+func (dbClient DynamoDBClient) withAccess(ctx context.Context, vaultEntity VaultEntity) (VaultEntity, error) {
+	var stats accessStats
+
+	err := dbClient.getReserved(ctx, accessPrefix+vaultEntity.ID, &stats)
+	if errors.Is(err, ErrNotFound) {
+		return vaultEntity, nil
+	}
+	if err != nil {
+		return vaultEntity, err
+	}
+
+	stats.addTo(&vaultEntity.LastAccessedAt, &vaultEntity.AccessCount)
+
+	return vaultEntity, nil
+}
+
+// withAccessAll adds the stored access stats of every entry to the listing.
+func (dbClient DynamoDBClient) withAccessAll(ctx context.Context, items []VaultMetadata) error {
+	var stored []accessStats
+
+	err := dbClient.scanPrefix(ctx, accessPrefix, &stored)
+	if err != nil {
+		return err
+	}
+
+	byID := make(map[string]accessStats, len(stored))
+	for _, stats := range stored {
+		byID[stats.EntryID] = stats
+	}
+
+	for i := range items {
+		if stats, ok := byID[items[i].ID]; ok {
+			stats.addTo(&items[i].LastAccessedAt, &items[i].AccessCount)
+		}
+	}
+
+	return nil
+}
+
+// RecordAccess sets the last access time of an entry and increments its
+// access count. The cached listing is updated in place instead of dropped.
+func (dbClient DynamoDBClient) RecordAccess(ctx context.Context, id string, at time.Time) error {
+	values, err := attributevalue.MarshalMap(map[string]any{
+		":entry": id,
+		":at":    at.UTC(),
+		":one":   1,
+	})
+	if err != nil {
+		return err
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(dbClient.TableName),
+		Key:                       accessKey(id),
+		UpdateExpression:          aws.String("SET entry_id = :entry, last_accessed_at = :at ADD access_count :one"),
+		ExpressionAttributeValues: values,
+	}
+
+	slog.DebugContext(ctx, "dynamodb record access", slog.String("table", dbClient.TableName), slog.String("id", id))
+
+	_, err = dbClient.API.UpdateItem(ctx, input)
+	if err != nil {
+		return translateError(err)
+	}
+
+	dbClient.Cache.recordAccess(id, at.UTC())
+
+	return nil
+}
+
+// deleteAccess removes the access stats of a purged entry.
+func (dbClient DynamoDBClient) deleteAccess(ctx context.Context, id string) error {
+	input := &dynamodb.DeleteItemInput{
+		TableName: aws.String(dbClient.TableName),
+		Key:       accessKey(id),
+	}
+
+	slog.DebugContext(ctx, "dynamodb delete access", slog.String("table", dbClient.TableName), slog.String("id", id))
+
+	// deleting twice deletes once
+	_, err := dbClient.API.DeleteItem(Idempotent(ctx), input)
+
+	return translateError(err)
+}
+
+// RecordAccessAsync runs RecordAccess in the background so reads are not
+// slowed down. It outlives the request, and failures are only logged. With
+// SyncAccess set it writes before it returns instead.
+func (dbClient DynamoDBClient) RecordAccessAsync(ctx context.Context, id string) {
+	ctx = context.WithoutCancel(ctx)
+	at := time.Now()
+
+	record := func() {
+		ctx, cancel := context.WithTimeout(ctx, accessTimeout)
+		defer cancel()
+
+		err := dbClient.RecordAccess(ctx, id, at)
+		if err != nil {
+			slog.WarnContext(ctx, "unable to record access", slog.String("id", id), slog.Any("error", err))
+		}
+	}
+
+	if dbClient.SyncAccess {
+		record()
+		return
+	}
+
+	go record()
+}
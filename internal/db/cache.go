@@ -0,0 +1,92 @@
+package db
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// MetadataCache keeps the result of ScanItems for TTL so listing the vault
+// does not scan the table every time. It only ever holds VaultMetadata, which
+// has no password. Every write to an entry through DynamoDBClient drops it,
+// while an access only updates the stats of the cached entry; writes of other
+// instances only show after TTL, unless a stream consumer calls Invalidate.
+// A nil MetadataCache caches nothing.
+type MetadataCache struct {
+	TTL time.Duration
+
+	mu      sync.Mutex
+	items   []VaultMetadata
+	expires time.Time
+	// generation counts the invalidations, so a scan that raced a write is
+	// not stored
+	generation uint64
+}
+
+func NewMetadataCache(ttl time.Duration) *MetadataCache {
+	return &MetadataCache{TTL: ttl}
+}
+
+// Invalidate drops the cached listing.
+func (c *MetadataCache) Invalidate() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.items, c.expires = nil, time.Time{}
+	c.generation++
+}
+
+// get returns a copy of the listing while it is fresh, and the generation to
+// store a new listing under otherwise.
+func (c *MetadataCache) get(now time.Time) ([]VaultMetadata, uint64, bool) {
+	if c == nil {
+		return nil, 0, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !now.Before(c.expires) {
+		return nil, c.generation, false
+	}
+
+	return slices.Clone(c.items), c.generation, true
+}
+
+// put stores a copy of items unless the cache was invalidated since the
+// generation was read.
+func (c *MetadataCache) put(items []VaultMetadata, generation uint64, now time.Time) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+
+	c.items, c.expires = slices.Clone(items), now.Add(c.TTL)
+}
+
+// recordAccess counts an access in the cached listing, as RecordAccess did in
+// the table.
+func (c *MetadataCache) recordAccess(id string, at time.Time) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i := range c.items {
+		if c.items[i].ID == id {
+			accessStats{LastAccessedAt: &at, AccessCount: 1}.addTo(&c.items[i].LastAccessedAt, &c.items[i].AccessCount)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package db
+
+import (
+	"context"
+	"personal-vault/internal/dbtest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/stretchr/testify/assert"
+)
+
+// countingAPI counts the scans that reach the table. Listing the entries
+// scans them and their access stats.
+type countingAPI struct {
+	*dbtest.MemoryAPI
+	scans atomic.Int32
+}
+
+func (c *countingAPI) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	c.scans.Add(1)
+	return c.MemoryAPI.Scan(ctx, params, optFns...)
+}
+
+func TestDynamoDBClient_ScanItemsCache(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	api := &countingAPI{MemoryAPI: dbtest.NewMemoryAPI()}
+	client := DynamoDBClient{API: api, TableName: "vault", Cache: NewMetadataCache(time.Minute)}
+
+	entity, err := client.PutItem(ctx, VaultEntity{ID: "0f8fad5b-d9cb-469f-a165-70867728950e", Name: "github", Password: "ciphertext"})
+	assert.NoError(t, err)
+
+	items, err := client.ScanItems(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, items, 1)
+
+	// the copies of the client share the cache, and callers can reorder what
+	// they get
+	items[0] = VaultMetadata{}
+	copied := client
+	items, err = copied.ScanItems(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "github", items[0].Name)
+	assert.Equal(t, int32(2), api.scans.Load())
+
+	tests := []struct {
+		name  string
+		write func() error
+	}{
+		{name: "put", write: func() error {
+			_, err := client.PutItem(ctx, VaultEntity{ID: "7c9e6679-7425-40de-944b-e07fc1f90ae7", Name: "gitlab"})
+			return err
+		}},
+		{name: "update", write: func() error {
+			entity.Name = "github.com"
+			entity, err = client.UpdateEntity(ctx, entity)
+			return err
+		}},
+		{name: "delete", write: func() error {
+			return client.DeleteItem(ctx, entity.ID, time.Hour)
+		}},
+		{name: "restore", write: func() error {
+			return client.RestoreItem(ctx, entity.ID)
+		}},
+		{name: "failed write", write: func() error {
+			return client.RestoreItem(ctx, entity.ID)
+		}},
+	}
+
+	// the subtests share the client, so they run in order
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			before := api.scans.Load()
+
+			_ = tt.write()
+
+			_, err := client.ScanItems(ctx)
+			assert.NoError(t, err)
+			_, err = client.ScanItems(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, before+2, api.scans.Load(), "one listing after the write")
+		})
+	}
+
+	items, err = client.ScanItems(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, items, 2)
+}
+
+func TestDynamoDBClient_ScanItemsCacheAccess(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	api := &countingAPI{MemoryAPI: dbtest.NewMemoryAPI()}
+	client := DynamoDBClient{API: api, TableName: "vault", Cache: NewMetadataCache(time.Minute)}
+
+	entity, err := client.PutItem(ctx, VaultEntity{ID: "0f8fad5b-d9cb-469f-a165-70867728950e", Name: "github"})
+	assert.NoError(t, err)
+
+	_, err = client.ScanItems(ctx)
+	assert.NoError(t, err)
+
+	// an access is counted in the cached listing instead of dropping it
+	at := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	err = client.RecordAccess(ctx, entity.ID, at)
+	assert.NoError(t, err)
+
+	items, err := client.ScanItems(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), api.scans.Load(), "no listing after the access")
+	assert.Equal(t, 1, items[0].AccessCount)
+	assert.Equal(t, &at, items[0].LastAccessedAt)
+
+	client.Cache.Invalidate()
+	items, err = client.ScanItems(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, items[0].AccessCount)
+	assert.Equal(t, &at, items[0].LastAccessedAt)
+}
+
+func TestMetadataCache(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	cache := NewMetadataCache(time.Minute)
+
+	_, generation, ok := cache.get(now)
+	assert.False(t, ok)
+
+	// a listing read before an invalidation is not stored
+	cache.Invalidate()
+	cache.put([]VaultMetadata{{ID: "stale"}}, generation, now)
+	_, generation, ok = cache.get(now)
+	assert.False(t, ok)
+
+	cache.put([]VaultMetadata{{ID: "fresh"}}, generation, now)
+	items, _, ok := cache.get(now.Add(59 * time.Second))
+	assert.True(t, ok)
+	assert.Equal(t, []VaultMetadata{{ID: "fresh"}}, items)
+
+	_, _, ok = cache.get(now.Add(time.Minute))
+	assert.False(t, ok, "expired")
+
+	var none *MetadataCache
+	none.Invalidate()
+	none.put([]VaultMetadata{{ID: "any"}}, 0, now)
+	_, _, ok = none.get(now)
+	assert.False(t, ok)
+}
@@ -6,22 +6,44 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 )
 
-const (
-	tableName = "personal-vault"
-)
-
 type DynamoDBAPI interface {
 	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
 	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
 	Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+	DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error)
 }
 
 type DynamoDBClient struct {
 	API       DynamoDBAPI
 	TableName string
+	// Cache holds the listing of ScanItems. It is shared by the copies of the
+	// client, and nil caches nothing.
+	Cache *MetadataCache
+	// SyncAccess makes RecordAccessAsync write before it returns, for
+	// runtimes such as Lambda that freeze the process between requests.
+	SyncAccess bool
+}
+
+type idempotentKey struct{}
+
+// Idempotent marks the writes made with the returned context as safe to send
+// again after an attempt that may have been applied, such as an
+// unconditional put of a whole item. Other writes are never retried once
+// they may have reached DynamoDB, see internal/resilience.
+func Idempotent(ctx context.Context) context.Context {
+	return context.WithValue(ctx, idempotentKey{}, true)
+}
+
+// IsIdempotent reports whether ctx was marked by Idempotent.
+func IsIdempotent(ctx context.Context) bool {
+	idempotent, _ := ctx.Value(idempotentKey{}).(bool)
+
+	return idempotent
 }
 
-func NewClient(svc DynamoDBAPI) *DynamoDBClient {
+func NewClient(svc DynamoDBAPI, tableName string) *DynamoDBClient {
 
 	return &DynamoDBClient{
 		API:       svc,
@@ -0,0 +1,172 @@
+package db
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	collectionPrefix      = reservedPrefix + "collection#"
+	collectionEntryPrefix = reservedPrefix + "collection_entry#"
+)
+
+// WrappedKey is a collection key encrypted to one member, see
+// keyring.WrappedKey.
+type WrappedKey struct {
+	EphemeralPublicKey []byte `dynamodbav:"ephemeral_public_key"`
+	Ciphertext         []byte `dynamodbav:"ciphertext"`
+}
+
+// Collection is a team vault. Its entries are encrypted under the collection
+// key of KeyVersion, which Keys holds wrapped to every member by name. While
+// a re-key is in progress PendingKeys holds the key of KeyVersion+1 for the
+// same members, and entries move from one version to the next.
+type Collection struct {
+	ID          string                `dynamodbav:"collection_id"`
+	Name        string                `dynamodbav:"name"`
+	KeyVersion  int                   `dynamodbav:"key_version"`
+	Keys        map[string]WrappedKey `dynamodbav:"keys"`
+	PendingKeys map[string]WrappedKey `dynamodbav:"pending_keys,omitempty"`
+	CreatedAt   time.Time             `dynamodbav:"created_at"`
+	UpdatedAt   time.Time             `dynamodbav:"updated_at"`
+	Version     int                   `dynamodbav:"version"`
+}
+
+// Members returns the names of the members, sorted.
+func (collection Collection) Members() []string {
+	members := make([]string, 0, len(collection.Keys))
+	for name := range collection.Keys {
+		members = append(members, name)
+	}
+
+	sort.Strings(members)
+
+	return members
+}
+
+// CollectionEntry is an entry of a collection. Password is encrypted under
+// the collection key of KeyVersion.
+type CollectionEntry struct {
+	ID           string    `dynamodbav:"entry_id"`
+	CollectionID string    `dynamodbav:"collection_id"`
+	Name         string    `dynamodbav:"name"`
+	Description  string    `dynamodbav:"description"`
+	Password     string    `dynamodbav:"password"`
+	KeyVersion   int       `dynamodbav:"key_version"`
+	CreatedAt    time.Time `dynamodbav:"created_at"`
+	UpdatedAt    time.Time `dynamodbav:"updated_at"`
+}
+
+func collectionEntryKey(collectionID, id string) string {
+	return collectionEntryPrefix + collectionID + "#" + id
+}
+
+// CreateCollection stores a new collection with its first version.
+func (dbClient DynamoDBClient) CreateCollection(ctx context.Context, collection Collection) (Collection, error) {
+	collection.CreatedAt = time.Now().UTC()
+	collection.UpdatedAt = collection.CreatedAt
+	collection.Version = 1
+
+	err := dbClient.putReserved(ctx, collectionPrefix+collection.ID, collection, "attribute_not_exists(id)", nil, nil)
+
+	return collection, err
+}
+
+func (dbClient DynamoDBClient) GetCollection(ctx context.Context, id string) (Collection, error) {
+	var collection Collection
+
+	err := dbClient.getReserved(ctx, collectionPrefix+id, &collection)
+
+	return collection, err
+}
+
+func (dbClient DynamoDBClient) ScanCollections(ctx context.Context) ([]Collection, error) {
+	collections := []Collection{}
+
+	err := dbClient.scanPrefix(ctx, collectionPrefix, &collections)
+
+	return collections, err
+}
+
+// UpdateCollection replaces a collection read before and bumps its version.
+// It returns ErrConflict when the collection changed in between, so members
+// and keys are never overwritten with a stale copy.
+func (dbClient DynamoDBClient) UpdateCollection(ctx context.Context, collection Collection) (Collection, error) {
+	previous := collection.Version
+	collection.UpdatedAt = time.Now().UTC()
+	collection.Version++
+
+	err := dbClient.putReserved(ctx, collectionPrefix+collection.ID, collection, "#version = :version",
+		map[string]string{"#version": "version"},
+		map[string]types.AttributeValue{":version": &types.AttributeValueMemberN{Value: strconv.Itoa(previous)}})
+
+	return collection, err
+}
+
+// PutCollectionEntry stores a new entry of a collection.
+func (dbClient DynamoDBClient) PutCollectionEntry(ctx context.Context, entry CollectionEntry) (CollectionEntry, error) {
+	entry.CreatedAt = time.Now().UTC()
+	entry.UpdatedAt = entry.CreatedAt
+
+	err := dbClient.putReserved(ctx, collectionEntryKey(entry.CollectionID, entry.ID), entry, "attribute_not_exists(id)", nil, nil)
+
+	return entry, err
+}
+
+// RekeyCollectionEntry replaces an entry re-encrypted under a new collection
+// key. It returns ErrConflict when the entry is no longer under the key of
+// fromVersion.
+func (dbClient DynamoDBClient) RekeyCollectionEntry(ctx context.Context, entry CollectionEntry, fromVersion int) error {
+	entry.UpdatedAt = time.Now().UTC()
+
+	return dbClient.putReserved(ctx, collectionEntryKey(entry.CollectionID, entry.ID), entry, "key_version = :from", nil,
+		map[string]types.AttributeValue{":from": &types.AttributeValueMemberN{Value: strconv.Itoa(fromVersion)}})
+}
+
+// DeleteCollectionEntry removes an entry that is still under the key of
+// keyVersion. It returns ErrConflict when the entry was moved to another key
+// or is gone.
+func (dbClient DynamoDBClient) DeleteCollectionEntry(ctx context.Context, collectionID, id string, keyVersion int) error {
+	input := &dynamodb.DeleteItemInput{
+		TableName: aws.String(dbClient.TableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: collectionEntryKey(collectionID, id)},
+		},
+		ConditionExpression: aws.String("key_version = :version"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":version": &types.AttributeValueMemberN{Value: strconv.Itoa(keyVersion)},
+		},
+	}
+
+	slog.DebugContext(ctx, "dynamodb delete collection entry", slog.String("table", dbClient.TableName))
+
+	_, err := dbClient.API.DeleteItem(ctx, input)
+	if err != nil {
+		return translateError(err)
+	}
+
+	return nil
+}
+
+func (dbClient DynamoDBClient) GetCollectionEntry(ctx context.Context, collectionID, id string) (CollectionEntry, error) {
+	var entry CollectionEntry
+
+	err := dbClient.getReserved(ctx, collectionEntryKey(collectionID, id), &entry)
+
+	return entry, err
+}
+
+func (dbClient DynamoDBClient) ScanCollectionEntries(ctx context.Context, collectionID string) ([]CollectionEntry, error) {
+	entries := []CollectionEntry{}
+
+	err := dbClient.scanPrefix(ctx, collectionEntryKey(collectionID, ""), &entries)
+
+	return entries, err
+}
@@ -0,0 +1,107 @@
+package db
+
+import (
+	"context"
+	"personal-vault/internal/dbtest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDynamoDBClient_Users(t *testing.T) {
+	t.Parallel()
+
+	dbClient := DynamoDBClient{API: dbtest.NewMemoryAPI(), TableName: "personal-vault"}
+	ctx := context.Background()
+
+	users, err := dbClient.ScanUsers(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, users)
+
+	alice, err := dbClient.CreateUser(ctx, User{Name: "alice", PublicKey: []byte("public")})
+	assert.NoError(t, err)
+	assert.False(t, alice.CreatedAt.IsZero())
+
+	_, err = dbClient.CreateUser(ctx, User{Name: "alice"})
+	assert.ErrorIs(t, err, ErrConflict)
+
+	user, err := dbClient.GetUser(ctx, "alice")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("public"), user.PublicKey)
+
+	_, err = dbClient.GetUser(ctx, "bob")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	users, err = dbClient.ScanUsers(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, users, 1)
+}
+
+func TestDynamoDBClient_Collections(t *testing.T) {
+	t.Parallel()
+
+	dbClient := DynamoDBClient{API: dbtest.NewMemoryAPI(), TableName: "personal-vault"}
+	ctx := context.Background()
+
+	collection, err := dbClient.CreateCollection(ctx, Collection{
+		ID:         "c1",
+		Name:       "team",
+		KeyVersion: 1,
+		Keys:       map[string]WrappedKey{"bob": {}, "alice": {Ciphertext: []byte("wrapped")}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, collection.Version)
+	assert.Equal(t, []string{"alice", "bob"}, collection.Members())
+
+	_, err = dbClient.CreateCollection(ctx, Collection{ID: "c2", Name: "other"})
+	assert.NoError(t, err)
+
+	stale := collection
+
+	delete(collection.Keys, "bob")
+	collection, err = dbClient.UpdateCollection(ctx, collection)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, collection.Version)
+
+	// a concurrent change is not overwritten
+	_, err = dbClient.UpdateCollection(ctx, stale)
+	assert.ErrorIs(t, err, ErrConflict)
+
+	collection, err = dbClient.GetCollection(ctx, "c1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"alice"}, collection.Members())
+	assert.Equal(t, []byte("wrapped"), collection.Keys["alice"].Ciphertext)
+
+	collections, err := dbClient.ScanCollections(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, collections, 2)
+
+	entry, err := dbClient.PutCollectionEntry(ctx, CollectionEntry{ID: "e1", CollectionID: "c1", Name: "db", Password: "sealed", KeyVersion: 1})
+	assert.NoError(t, err)
+	_, err = dbClient.PutCollectionEntry(ctx, CollectionEntry{ID: "e2", CollectionID: "c2", Name: "other"})
+	assert.NoError(t, err)
+
+	entries, err := dbClient.ScanCollectionEntries(ctx, "c1")
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "e1", entries[0].ID)
+	}
+
+	// collections and their entries are never listed as vault entries
+	items, err := dbClient.ScanItems(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, items)
+
+	entry.Password = "resealed"
+	entry.KeyVersion = 2
+	assert.NoError(t, dbClient.RekeyCollectionEntry(ctx, entry, 1))
+	assert.ErrorIs(t, dbClient.RekeyCollectionEntry(ctx, entry, 1), ErrConflict)
+
+	entry, err = dbClient.GetCollectionEntry(ctx, "c1", "e1")
+	assert.NoError(t, err)
+	assert.Equal(t, "resealed", entry.Password)
+	assert.Equal(t, 2, entry.KeyVersion)
+
+	_, err = dbClient.GetCollectionEntry(ctx, "c2", "e1")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
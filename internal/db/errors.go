@@ -0,0 +1,93 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+)
+
+var (
+	ErrNotFound  = errors.New("record not found")
+	ErrConflict  = errors.New("record was modified concurrently")
+	ErrThrottled = errors.New("dynamodb request throttled")
+	// ErrUnavailable is returned while DynamoDB cannot be reached or keeps
+	// failing, see internal/resilience.
+	ErrUnavailable = errors.New("dynamodb unavailable")
+	// ErrUncertain is returned for a write that kept failing after it may
+	// have reached DynamoDB, such as one that timed out. It may have been
+	// applied, so it is not safe to send again as it is.
+	ErrUncertain = errors.New("dynamodb write outcome unknown")
+)
+
+// translateError wraps SDK errors with the matching sentinel so callers can use
+// errors.Is without depending on the AWS error types. The SDK error is kept in
+// the chain for logging.
+func translateError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var (
+		conditionFailed *types.ConditionalCheckFailedException
+		txConflict      *types.TransactionConflictException
+		throughput      *types.ProvisionedThroughputExceededException
+		requestLimit    *types.RequestLimitExceeded
+		apiErr          smithy.APIError
+	)
+
+	switch {
+	case errors.As(err, &conditionFailed), errors.As(err, &txConflict):
+		return fmt.Errorf("%w: %w", ErrConflict, err)
+	case errors.As(err, &throughput), errors.As(err, &requestLimit):
+		return fmt.Errorf("%w: %w", ErrThrottled, err)
+	case errors.As(err, &apiErr) && apiErr.ErrorCode() == "ThrottlingException":
+		return fmt.Errorf("%w: %w", ErrThrottled, err)
+	default:
+		return err
+	}
+}
+
+// Outcome names the result of an SDK call for metrics and traces: ok,
+// conditional_check_failed, throttled or error. Failed conditions of
+// optimistic writes are part of normal operation and kept apart from errors.
+func Outcome(err error) string {
+	var conditionFailed *types.ConditionalCheckFailedException
+
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.As(err, &conditionFailed):
+		return "conditional_check_failed"
+	case errors.Is(translateError(err), ErrThrottled):
+		return "throttled"
+	default:
+		return "error"
+	}
+}
+
+// translateMissingError is used for writes conditioned on attribute_exists,
+// where a failed condition means the item is missing rather than a conflict.
+func translateMissingError(err error) error {
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		return fmt.Errorf("%w: %w", ErrNotFound, err)
+	}
+
+	return translateError(err)
+}
+
+// translateStaleError is used for versioned writes to live entries that
+// return the item when their condition fails. The entry was changed in
+// between, ErrConflict, unless it is missing or in the trash, ErrNotFound.
+func translateStaleError(err error) error {
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		if _, trashed := conditionFailed.Item["deleted_at"]; conditionFailed.Item == nil || trashed {
+			return fmt.Errorf("%w: %w", ErrNotFound, err)
+		}
+	}
+
+	return translateError(err)
+}
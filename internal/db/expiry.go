@@ -0,0 +1,88 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	day = 24 * time.Hour
+
+	expiryCheckpointID = reservedPrefix + "expiry_checkpoint"
+)
+
+// expiryCheckpoint is shared by every instance, so a restart or another
+// instance does not report the same expired entries again.
+type expiryCheckpoint struct {
+	CheckedUntil time.Time `dynamodbav:"checked_until"`
+}
+
+// ScheduleRotation sets the expiry one rotation interval after from. It does
+// nothing for entries without a rotation interval.
+func (vaultEntity *VaultEntity) ScheduleRotation(from time.Time) {
+	if vaultEntity.RotationDays <= 0 {
+		return
+	}
+
+	expiresAt := from.Add(time.Duration(vaultEntity.RotationDays) * day).UTC()
+	vaultEntity.ExpiresAt = &expiresAt
+}
+
+// ExpiringItems returns the entries that expire before the given time,
+// including those that already expired, soonest first.
+func (dbClient DynamoDBClient) ExpiringItems(ctx context.Context, before time.Time) ([]VaultMetadata, error) {
+	items, err := dbClient.ScanItems(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	expiring := []VaultMetadata{}
+	for _, item := range items {
+		if item.ExpiresAt != nil && !item.ExpiresAt.After(before) {
+			expiring = append(expiring, item)
+		}
+	}
+
+	sort.SliceStable(expiring, func(i, j int) bool {
+		return expiring[i].ExpiresAt.Before(*expiring[j].ExpiresAt)
+	})
+
+	return expiring, nil
+}
+
+// ExpiryCheckpoint returns the time up to which expired entries were
+// reported, or the zero time when they never were.
+func (dbClient DynamoDBClient) ExpiryCheckpoint(ctx context.Context) (time.Time, error) {
+	var checkpoint expiryCheckpoint
+
+	err := dbClient.getReserved(ctx, expiryCheckpointID, &checkpoint)
+	if errors.Is(err, ErrNotFound) {
+		return time.Time{}, nil
+	}
+
+	return checkpoint.CheckedUntil, err
+}
+
+// PutExpiryCheckpoint moves the checkpoint read by ExpiryCheckpoint from
+// previous to until. It returns ErrConflict when it was moved in the
+// meantime.
+func (dbClient DynamoDBClient) PutExpiryCheckpoint(ctx context.Context, previous, until time.Time) error {
+	condition := "attribute_not_exists(id)"
+	var values map[string]types.AttributeValue
+	if !previous.IsZero() {
+		value, err := attributevalue.Marshal(previous.UTC())
+		if err != nil {
+			return err
+		}
+
+		condition = "checked_until = :previous"
+		values = map[string]types.AttributeValue{":previous": value}
+	}
+
+	return dbClient.putReserved(ctx, expiryCheckpointID, expiryCheckpoint{CheckedUntil: until.UTC()}, condition, nil, values)
+}
@@ -0,0 +1,86 @@
+package db
+
+import (
+	"context"
+	"personal-vault/internal/dbtest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDynamoDBClient_ExpiringItems(t *testing.T) {
+	t.Parallel()
+
+	dbClient := DynamoDBClient{API: dbtest.NewMemoryAPI(), TableName: "personal-vault"}
+	now := time.Now().UTC()
+	expired := now.Add(-time.Hour)
+	later := now.Add(30 * day)
+
+	entities := []VaultEntity{
+		{ID: "001", Name: "rotates", RotationDays: 10},
+		{ID: "002", Name: "expired", ExpiresAt: &expired},
+		{ID: "003", Name: "later", ExpiresAt: &later},
+		{ID: "004", Name: "never"},
+	}
+
+	for _, entity := range entities {
+		stored, err := dbClient.PutItem(context.Background(), entity)
+		assert.NoError(t, err)
+
+		if entity.RotationDays > 0 {
+			assert.Equal(t, stored.CreatedAt.Add(10*day), *stored.ExpiresAt)
+		}
+	}
+
+	items, err := dbClient.ExpiringItems(context.Background(), now.Add(14*day))
+	assert.NoError(t, err)
+
+	var names []string
+	for _, item := range items {
+		names = append(names, item.Name)
+	}
+	assert.Equal(t, []string{"expired", "rotates"}, names)
+}
+
+func TestDynamoDBClient_ExpiryCheckpoint(t *testing.T) {
+	t.Parallel()
+
+	dbClient := DynamoDBClient{API: dbtest.NewMemoryAPI(), TableName: "personal-vault"}
+	ctx := context.Background()
+	first := time.Date(2024, 5, 1, 10, 0, 0, 123456789, time.UTC)
+
+	checkpoint, err := dbClient.ExpiryCheckpoint(ctx)
+	assert.NoError(t, err)
+	assert.True(t, checkpoint.IsZero())
+
+	assert.NoError(t, dbClient.PutExpiryCheckpoint(ctx, checkpoint, first))
+	assert.ErrorIs(t, dbClient.PutExpiryCheckpoint(ctx, checkpoint, first), ErrConflict)
+
+	checkpoint, err = dbClient.ExpiryCheckpoint(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, first, checkpoint)
+
+	// only the instance that read the current checkpoint moves it
+	assert.NoError(t, dbClient.PutExpiryCheckpoint(ctx, checkpoint, first.Add(time.Hour)))
+	assert.ErrorIs(t, dbClient.PutExpiryCheckpoint(ctx, checkpoint, first.Add(2*time.Hour)), ErrConflict)
+
+	// the checkpoint is never listed as an entry
+	items, err := dbClient.ScanItems(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, items)
+}
+
+func TestVaultEntity_ScheduleRotation(t *testing.T) {
+	t.Parallel()
+
+	from := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	entity := VaultEntity{}
+	entity.ScheduleRotation(from)
+	assert.Nil(t, entity.ExpiresAt)
+
+	entity.RotationDays = 90
+	entity.ScheduleRotation(from)
+	assert.Equal(t, time.Date(2024, 7, 30, 10, 0, 0, 0, time.UTC), *entity.ExpiresAt)
+}
@@ -2,58 +2,165 @@ package db
 
 import (
 	"context"
-	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 )
 
+const (
+	// liveCondition matches an existing entry that is not in the trash.
+	liveCondition = "attribute_exists(id) AND attribute_not_exists(deleted_at)"
+
+	// EncryptionClient marks an entry whose password is a secret sealed by
+	// the client, see pkg/clientcrypto.
+	EncryptionClient = "client"
+)
+
+// VaultEntity is a stored entry. Version starts at 1 and is incremented on
+// every update; entries written before versioning have version 0 and no
+// timestamps. The timestamps and access stats are maintained by this package.
 type VaultEntity struct {
 	ID          string `dynamodbav:"id"`
 	Name        string `dynamodbav:"name"`
 	Description string `dynamodbav:"description"`
 	Password    string `dynamodbav:"password"`
+	// Encryption is EncryptionClient when Password holds the JSON of a secret
+	// sealed by the client. Otherwise Password is encrypted with the master
+	// key.
+	Encryption     string     `dynamodbav:"encryption,omitempty"`
+	CreatedAt      time.Time  `dynamodbav:"created_at,omitempty"`
+	UpdatedAt      time.Time  `dynamodbav:"updated_at,omitempty"`
+	LastAccessedAt *time.Time `dynamodbav:"last_accessed_at,omitempty"`
+	AccessCount    int        `dynamodbav:"access_count"`
+	ExpiresAt      *time.Time `dynamodbav:"expires_at,omitempty"`
+	RotationDays   int        `dynamodbav:"rotation_days,omitempty"`
+	DeletedAt      *time.Time `dynamodbav:"deleted_at,omitempty"`
+	// PurgeAt is the DynamoDB TTL attribute, in Unix seconds, of an entry in
+	// the trash.
+	PurgeAt int64 `dynamodbav:"purge_at,omitempty"`
+	Version int   `dynamodbav:"version"`
 }
 
+// VaultMetadata is what lists show of an entry. Its JSON keys are the Go
+// field names, which clients of /retrieve/all have always read.
 type VaultMetadata struct {
-	ID   string `dynamodbav:"id"`
-	Name string `dynamodbav:"name"`
+	ID             string     `dynamodbav:"id" json:"ID"`
+	Name           string     `dynamodbav:"name" json:"Name"`
+	CreatedAt      time.Time  `dynamodbav:"created_at" json:"CreatedAt"`
+	UpdatedAt      time.Time  `dynamodbav:"updated_at" json:"UpdatedAt"`
+	LastAccessedAt *time.Time `dynamodbav:"last_accessed_at" json:"LastAccessedAt"`
+	AccessCount    int        `dynamodbav:"access_count" json:"AccessCount"`
+	ExpiresAt      *time.Time `dynamodbav:"expires_at" json:"ExpiresAt"`
+	RotationDays   int        `dynamodbav:"rotation_days" json:"RotationDays"`
+	DeletedAt      *time.Time `dynamodbav:"deleted_at" json:"DeletedAt"`
 }
 
-func (dbClient DynamoDBClient) PutItem(ctx context.Context, vaultEntity VaultEntity) error {
+// Metadata returns the fields of the entry that are safe to list.
+func (vaultEntity VaultEntity) Metadata() VaultMetadata {
+	return VaultMetadata{
+		ID:             vaultEntity.ID,
+		Name:           vaultEntity.Name,
+		CreatedAt:      vaultEntity.CreatedAt,
+		UpdatedAt:      vaultEntity.UpdatedAt,
+		LastAccessedAt: vaultEntity.LastAccessedAt,
+		AccessCount:    vaultEntity.AccessCount,
+		ExpiresAt:      vaultEntity.ExpiresAt,
+		RotationDays:   vaultEntity.RotationDays,
+		DeletedAt:      vaultEntity.DeletedAt,
+	}
+}
+
+// PutItem stores a new entry and returns it with its creation time and first
+// version set. Without an explicit expiry, an entry with a rotation interval
+// expires one interval after its creation.
+func (dbClient DynamoDBClient) PutItem(ctx context.Context, vaultEntity VaultEntity) (VaultEntity, error) {
+	defer dbClient.Cache.Invalidate()
+
+	vaultEntity.CreatedAt = time.Now().UTC()
+	vaultEntity.UpdatedAt = vaultEntity.CreatedAt
+	vaultEntity.LastAccessedAt = nil
+	vaultEntity.AccessCount = 0
+	vaultEntity.Version = 1
+
+	if vaultEntity.ExpiresAt == nil {
+		vaultEntity.ScheduleRotation(vaultEntity.CreatedAt)
+	}
+
 	item, err := attributevalue.MarshalMap(vaultEntity)
 	if err != nil {
-		return err
+		return vaultEntity, err
 	}
 
 	input := &dynamodb.PutItemInput{
-		TableName: aws.String(tableName),
+		TableName: aws.String(dbClient.TableName),
 		Item:      item,
 	}
 
-	_, err = dbClient.API.PutItem(ctx, input)
+	slog.DebugContext(ctx, "dynamodb put item", slog.String("table", dbClient.TableName))
+
+	// the entry has a new id, so putting it twice stores it once
+	_, err = dbClient.API.PutItem(Idempotent(ctx), input)
 	if err != nil {
-		return err
+		return vaultEntity, translateError(err)
 	}
 
-	return nil
-
+	return vaultEntity, nil
 }
 
+// ScanItems lists the entries that are not in the trash, from Cache while it
+// holds a fresh listing.
 func (dbClient DynamoDBClient) ScanItems(ctx context.Context) ([]VaultMetadata, error) {
+	items, generation, ok := dbClient.Cache.get(time.Now())
+	if ok {
+		slog.DebugContext(ctx, "metadata cache hit", slog.String("table", dbClient.TableName))
+		return items, nil
+	}
+
+	items, err := dbClient.scan(ctx, "attribute_not_exists(deleted_at)", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	err = dbClient.withAccessAll(ctx, items)
+	if err != nil {
+		return nil, err
+	}
+
+	dbClient.Cache.put(items, generation, time.Now())
+
+	return items, nil
+}
+
+// scan lists the entries matching filter, never the reserved items.
+func (dbClient DynamoDBClient) scan(ctx context.Context, filter string, values map[string]types.AttributeValue) ([]VaultMetadata, error) {
 	var metadatas []VaultMetadata
 
+	expressionValues := map[string]types.AttributeValue{
+		":reserved": &types.AttributeValueMemberS{Value: reservedPrefix},
+	}
+	for name, value := range values {
+		expressionValues[name] = value
+	}
+
 	input := &dynamodb.ScanInput{
-		TableName: aws.String(tableName),
+		TableName:                 aws.String(dbClient.TableName),
+		FilterExpression:          aws.String("NOT begins_with(id, :reserved) AND " + filter),
+		ExpressionAttributeValues: expressionValues,
 	}
 
-	output, err := dbClient.API.Scan(ctx, input)
+	slog.DebugContext(ctx, "dynamodb scan", slog.String("table", dbClient.TableName))
+
+	items, err := dbClient.scanPages(ctx, input)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, i := range output.Items {
+	for _, i := range items {
 		var metadata VaultMetadata
 
 		err = attributevalue.UnmarshalMap(i, &metadata)
@@ -68,29 +175,226 @@ func (dbClient DynamoDBClient) ScanItems(ctx context.Context) ([]VaultMetadata,
 	return metadatas, err
 }
 
-func (dbClient DynamoDBClient) GetItem(ctx context.Context, id string) (string, error) {
+// scanPrefix unmarshals the reserved items whose id starts with prefix into
+// out, a pointer to a slice.
+func (dbClient DynamoDBClient) scanPrefix(ctx context.Context, prefix string, out any) error {
+	input := &dynamodb.ScanInput{
+		TableName:        aws.String(dbClient.TableName),
+		FilterExpression: aws.String("begins_with(id, :prefix)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":prefix": &types.AttributeValueMemberS{Value: prefix},
+		},
+	}
+
+	slog.DebugContext(ctx, "dynamodb scan", slog.String("table", dbClient.TableName), slog.String("prefix", prefix))
+
+	items, err := dbClient.scanPages(ctx, input)
+	if err != nil {
+		return err
+	}
+
+	return attributevalue.UnmarshalListOfMaps(items, out)
+}
+
+// scanPages runs input over the whole table. A scan reads at most 1 MB per
+// call, so the pages are followed until there is no LastEvaluatedKey.
+func (dbClient DynamoDBClient) scanPages(ctx context.Context, input *dynamodb.ScanInput) ([]map[string]types.AttributeValue, error) {
+	var items []map[string]types.AttributeValue
+	for {
+		output, err := dbClient.API.Scan(ctx, input)
+		if err != nil {
+			return nil, translateError(err)
+		}
+
+		items = append(items, output.Items...)
+
+		if len(output.LastEvaluatedKey) == 0 {
+			return items, nil
+		}
+
+		input.ExclusiveStartKey = output.LastEvaluatedKey
+	}
+}
+
+// getReserved unmarshals the reserved item with the given id into out. It
+// returns ErrNotFound when the item does not exist.
+func (dbClient DynamoDBClient) getReserved(ctx context.Context, id string, out any) error {
 	input := &dynamodb.GetItemInput{
-		TableName: aws.String(tableName),
+		TableName: aws.String(dbClient.TableName),
 		Key: map[string]types.AttributeValue{
 			"id": &types.AttributeValueMemberS{Value: id},
 		},
+		ConsistentRead: aws.Bool(true),
 	}
 
 	output, err := dbClient.API.GetItem(ctx, input)
 	if err != nil {
-		return "", err
+		return translateError(err)
 	}
 
 	if output.Item == nil {
-		return "", errors.New("unable to find the record")
+		return ErrNotFound
 	}
 
+	return attributevalue.UnmarshalMap(output.Item, out)
+}
+
+// putReserved stores v under the reserved id. condition, when set, guards the
+// write and is mapped to ErrConflict when it fails.
+func (dbClient DynamoDBClient) putReserved(ctx context.Context, id string, v any, condition string, names map[string]string, values map[string]types.AttributeValue) error {
+	item, err := attributevalue.MarshalMap(v)
+	if err != nil {
+		return err
+	}
+
+	item["id"] = &types.AttributeValueMemberS{Value: id}
+
+	input := &dynamodb.PutItemInput{
+		TableName:                 aws.String(dbClient.TableName),
+		Item:                      item,
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+	}
+	if condition != "" {
+		input.ConditionExpression = aws.String(condition)
+	}
+
+	slog.DebugContext(ctx, "dynamodb put reserved item", slog.String("table", dbClient.TableName))
+
+	_, err = dbClient.API.PutItem(ctx, input)
+	if err != nil {
+		return translateError(err)
+	}
+
+	return nil
+}
+
+func (dbClient DynamoDBClient) GetItem(ctx context.Context, id string) (string, error) {
+	item, err := dbClient.GetEntity(ctx, id)
+	if err != nil {
+		return "", err
+	}
+
+	return item.Password, nil
+}
+
+// GetEntity returns the full entry, with the password still encrypted. An
+// entry in the trash is not found.
+func (dbClient DynamoDBClient) GetEntity(ctx context.Context, id string) (VaultEntity, error) {
 	item := VaultEntity{}
 
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(dbClient.TableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+	}
+
+	slog.DebugContext(ctx, "dynamodb get item", slog.String("table", dbClient.TableName), slog.String("id", id))
+
+	output, err := dbClient.API.GetItem(ctx, input)
+	if err != nil {
+		return item, translateError(err)
+	}
+
+	if output.Item == nil {
+		return item, ErrNotFound
+	}
+
 	err = attributevalue.UnmarshalMap(output.Item, &item)
 	if err != nil {
-		return "", err
+		return item, err
+	}
+
+	if item.DeletedAt != nil {
+		return VaultEntity{}, ErrNotFound
+	}
+
+	return dbClient.withAccess(ctx, item)
+}
+
+// UpdateEntity writes the editable fields of an entry read before, bumps its
+// version and returns it with the new update time. The access stats are left
+// alone so a concurrent read is not lost. It returns ErrConflict when the
+// entry was changed since it was read, so one of two concurrent edits is not
+// silently lost, and ErrNotFound when the entry does not exist or is in the
+// trash, so an edit never recreates a removed entry.
+func (dbClient DynamoDBClient) UpdateEntity(ctx context.Context, vaultEntity VaultEntity) (VaultEntity, error) {
+	defer dbClient.Cache.Invalidate()
+
+	// entries stored before versions were tracked have none
+	condition := liveCondition + " AND #version = :expected"
+	if vaultEntity.Version == 0 {
+		condition = liveCondition + " AND attribute_not_exists(#version)"
+	}
+
+	expected := vaultEntity.Version
+	vaultEntity.UpdatedAt = time.Now().UTC()
+	vaultEntity.Version++
+
+	// name and password are reserved words in expressions
+	set := "SET #name = :name, #description = :description, #password = :password, updated_at = :updated_at, rotation_days = :rotation_days, #version = :version"
+	values := map[string]any{
+		":name":          vaultEntity.Name,
+		":description":   vaultEntity.Description,
+		":password":      vaultEntity.Password,
+		":updated_at":    vaultEntity.UpdatedAt,
+		":rotation_days": vaultEntity.RotationDays,
+		":version":       vaultEntity.Version,
+	}
+	if expected > 0 {
+		values[":expected"] = expected
+	}
+
+	var remove []string
+
+	if vaultEntity.Encryption != "" {
+		set += ", encryption = :encryption"
+		values[":encryption"] = vaultEntity.Encryption
+	} else {
+		remove = append(remove, "encryption")
+	}
+
+	if vaultEntity.ExpiresAt != nil {
+		set += ", expires_at = :expires_at"
+		values[":expires_at"] = vaultEntity.ExpiresAt
+	} else {
+		remove = append(remove, "expires_at")
+	}
+
+	expression := set
+	if len(remove) > 0 {
+		expression += " REMOVE " + strings.Join(remove, ", ")
+	}
+
+	attributeValues, err := attributevalue.MarshalMap(values)
+	if err != nil {
+		return vaultEntity, err
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(dbClient.TableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: vaultEntity.ID},
+		},
+		UpdateExpression:    aws.String(expression),
+		ConditionExpression: aws.String(condition),
+		ExpressionAttributeNames: map[string]string{
+			"#name":        "name",
+			"#description": "description",
+			"#password":    "password",
+			"#version":     "version",
+		},
+		ExpressionAttributeValues:           attributeValues,
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	}
+
+	slog.DebugContext(ctx, "dynamodb update item", slog.String("table", dbClient.TableName), slog.String("id", vaultEntity.ID))
+
+	_, err = dbClient.API.UpdateItem(ctx, input)
+	if err != nil {
+		return vaultEntity, translateStaleError(err)
 	}
 
-	return item.Password, err
+	return vaultEntity, nil
 }
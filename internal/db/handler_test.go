@@ -3,16 +3,23 @@ package db
 import (
 	"context"
 	"errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
 	"github.com/stretchr/testify/assert"
+	"personal-vault/internal/dbtest"
 	"testing"
+	"time"
 )
 
 type dynamoDBMockAPI struct {
-	getItem func(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
-	putItem func(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
-	scan    func(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+	getItem       func(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	putItem       func(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	updateItem    func(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+	scan          func(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+	deleteItem    func(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+	describeTable func(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error)
 }
 
 func (m *dynamoDBMockAPI) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
@@ -23,10 +30,22 @@ func (m *dynamoDBMockAPI) PutItem(ctx context.Context, params *dynamodb.PutItemI
 	return m.putItem(ctx, params, optFns...)
 }
 
+func (m *dynamoDBMockAPI) UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	return m.updateItem(ctx, params, optFns...)
+}
+
 func (m *dynamoDBMockAPI) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
 	return m.scan(ctx, params, optFns...)
 }
 
+func (m *dynamoDBMockAPI) DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	return m.deleteItem(ctx, params, optFns...)
+}
+
+func (m *dynamoDBMockAPI) DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error) {
+	return m.describeTable(ctx, params, optFns...)
+}
+
 func TestDynamoDBClient_PutItem(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
@@ -44,6 +63,8 @@ func TestDynamoDBClient_PutItem(t *testing.T) {
 				Password:    "testPassword",
 			},
 			putItem: func(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+				// a new entry can be put again after a timeout
+				assert.True(t, IsIdempotent(ctx))
 				return &dynamodb.PutItemOutput{}, nil
 			},
 			expectedErr: nil,
@@ -72,8 +93,11 @@ func TestDynamoDBClient_PutItem(t *testing.T) {
 				API: &dynamoDBMockAPI{
 					putItem: tt.putItem,
 				}}
-			err := dynamdbMockClient.PutItem(context.Background(), tt.vaultEntity)
+			stored, err := dynamdbMockClient.PutItem(context.Background(), tt.vaultEntity)
 			assert.Equal(t, err, tt.expectedErr)
+			assert.Equal(t, 1, stored.Version)
+			assert.WithinDuration(t, time.Now(), stored.CreatedAt, time.Minute)
+			assert.Equal(t, stored.CreatedAt, stored.UpdatedAt)
 		})
 	}
 }
@@ -93,22 +117,43 @@ func TestDynamoDBClient_ScanItems(t *testing.T) {
 		"Password":    &types.AttributeValueMemberS{Value: "testPassword"},
 	}
 
-	items = append(items, item)
+	items = append(items, item, map[string]types.AttributeValue{
+		"ID":   &types.AttributeValueMemberS{Value: "002"},
+		"Name": &types.AttributeValueMemberS{Value: "otherName"},
+	})
 
 	tests := []struct {
 		name        string
 		scan        func(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
 		expectedErr error
+		expectedLen int
 	}{
 		{
 			name: "success case",
 			scan: func(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+				// reserved items such as the vault metadata and trashed entries are never listed
+				assert.Equal(t, "NOT begins_with(id, :reserved) AND attribute_not_exists(deleted_at)", aws.ToString(params.FilterExpression))
 				return &dynamodb.ScanOutput{
 					Items: items,
 				}, nil
 			},
 			expectedErr: nil,
 		},
+		{
+			name: "paged case",
+			scan: func(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+				// the second page starts where the first ended
+				if params.ExclusiveStartKey == nil {
+					return &dynamodb.ScanOutput{
+						Items:            items[:1],
+						LastEvaluatedKey: map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: "001"}},
+					}, nil
+				}
+				assert.Equal(t, &types.AttributeValueMemberS{Value: "001"}, params.ExclusiveStartKey["id"])
+				return &dynamodb.ScanOutput{Items: items[1:]}, nil
+			},
+			expectedLen: len(items),
+		},
 		{
 			name: "error case",
 			scan: func(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
@@ -125,9 +170,18 @@ func TestDynamoDBClient_ScanItems(t *testing.T) {
 
 			dynamdbMockClient := DynamoDBClient{
 				API: &dynamoDBMockAPI{
-					scan: tt.scan,
+					scan: func(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+						// no entry has access stats
+						if _, ok := params.ExpressionAttributeValues[":prefix"]; ok {
+							return &dynamodb.ScanOutput{}, nil
+						}
+						return tt.scan(ctx, params, optFns...)
+					},
 				}}
 			metadatas, err := dynamdbMockClient.ScanItems(context.Background())
+			if tt.expectedLen > 0 {
+				assert.Len(t, metadatas, tt.expectedLen)
+			}
 			if tt.expectedErr != nil {
 				assert.Equal(t, err, tt.expectedErr)
 				assert.Nil(t, metadatas)
@@ -177,7 +231,7 @@ func TestDynamoDBClient_GetItem(t *testing.T) {
 					Item: nil,
 				}, nil
 			},
-			expectedErr: errors.New("unable to find the record"),
+			expectedErr: ErrNotFound,
 		},
 	}
 
@@ -201,3 +255,240 @@ func TestDynamoDBClient_GetItem(t *testing.T) {
 		})
 	}
 }
+
+func TestDynamoDBClient_ErrorTranslation(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		sdkErr      error
+		expectedErr error
+	}{
+		{
+			name:        "conditional check failed",
+			sdkErr:      &types.ConditionalCheckFailedException{Message: aws.String("mock")},
+			expectedErr: ErrConflict,
+		},
+		{
+			name:        "transaction conflict",
+			sdkErr:      &types.TransactionConflictException{Message: aws.String("mock")},
+			expectedErr: ErrConflict,
+		},
+		{
+			name:        "provisioned throughput exceeded",
+			sdkErr:      &types.ProvisionedThroughputExceededException{Message: aws.String("mock")},
+			expectedErr: ErrThrottled,
+		},
+		{
+			name:        "request limit exceeded",
+			sdkErr:      &types.RequestLimitExceeded{Message: aws.String("mock")},
+			expectedErr: ErrThrottled,
+		},
+		{
+			name:        "throttling exception",
+			sdkErr:      &smithy.GenericAPIError{Code: "ThrottlingException", Message: "mock"},
+			expectedErr: ErrThrottled,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			dynamdbMockClient := DynamoDBClient{
+				API: &dynamoDBMockAPI{
+					getItem: func(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+						return nil, tt.sdkErr
+					},
+					putItem: func(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+						return nil, tt.sdkErr
+					},
+					scan: func(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+						return nil, tt.sdkErr
+					},
+				}}
+
+			_, err := dynamdbMockClient.GetItem(context.Background(), "001")
+			assert.ErrorIs(t, err, tt.expectedErr)
+			assert.ErrorIs(t, err, tt.sdkErr)
+
+			_, err = dynamdbMockClient.PutItem(context.Background(), VaultEntity{ID: "001"})
+			assert.ErrorIs(t, err, tt.expectedErr)
+
+			_, err = dynamdbMockClient.ScanItems(context.Background())
+			assert.ErrorIs(t, err, tt.expectedErr)
+		})
+	}
+}
+
+func TestDynamoDBClient_UpdateEntity(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		updateItem  func(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+		expectedErr error
+	}{
+		{
+			name: "success case",
+			updateItem: func(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+				// trashed entries can only be restored, not changed
+				// and only when nobody changed them since they were read
+				assert.Equal(t, "attribute_exists(id) AND attribute_not_exists(deleted_at) AND #version = :expected", aws.ToString(params.ConditionExpression))
+				assert.NotContains(t, aws.ToString(params.UpdateExpression), "access")
+				assert.Equal(t, &types.AttributeValueMemberN{Value: "2"}, params.ExpressionAttributeValues[":expected"])
+				assert.Equal(t, &types.AttributeValueMemberN{Value: "3"}, params.ExpressionAttributeValues[":version"])
+				return &dynamodb.UpdateItemOutput{}, nil
+			},
+		},
+		{
+			name: "item not found",
+			updateItem: func(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+				return nil, &types.ConditionalCheckFailedException{Message: aws.String("mock")}
+			},
+			expectedErr: ErrNotFound,
+		},
+		{
+			name: "item in the trash",
+			updateItem: func(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+				return nil, &types.ConditionalCheckFailedException{Message: aws.String("mock"), Item: map[string]types.AttributeValue{
+					"id":         &types.AttributeValueMemberS{Value: "001"},
+					"deleted_at": &types.AttributeValueMemberS{Value: "2024-05-01T10:00:00Z"},
+				}}
+			},
+			expectedErr: ErrNotFound,
+		},
+		{
+			name: "modified concurrently",
+			updateItem: func(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+				return nil, &types.ConditionalCheckFailedException{Message: aws.String("mock"), Item: map[string]types.AttributeValue{
+					"id":      &types.AttributeValueMemberS{Value: "001"},
+					"version": &types.AttributeValueMemberN{Value: "3"},
+				}}
+			},
+			expectedErr: ErrConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			dynamdbMockClient := DynamoDBClient{
+				API: &dynamoDBMockAPI{
+					updateItem: tt.updateItem,
+				}}
+			updated, err := dynamdbMockClient.UpdateEntity(context.Background(), VaultEntity{ID: "001", Name: "testName", Version: 2})
+			assert.ErrorIs(t, err, tt.expectedErr)
+			assert.Equal(t, 3, updated.Version)
+			assert.WithinDuration(t, time.Now(), updated.UpdatedAt, time.Minute)
+		})
+	}
+}
+
+func TestDynamoDBClient_UpdateEntityConcurrently(t *testing.T) {
+	t.Parallel()
+
+	dbClient := DynamoDBClient{API: dbtest.NewMemoryAPI(), TableName: "personal-vault"}
+	ctx := context.Background()
+
+	_, err := dbClient.PutItem(ctx, VaultEntity{ID: "001", Name: "testName"})
+	assert.NoError(t, err)
+
+	first, err := dbClient.GetEntity(ctx, "001")
+	assert.NoError(t, err)
+	second, err := dbClient.GetEntity(ctx, "001")
+	assert.NoError(t, err)
+
+	first.Name = "first"
+	_, err = dbClient.UpdateEntity(ctx, first)
+	assert.NoError(t, err)
+
+	second.Description = "second"
+	_, err = dbClient.UpdateEntity(ctx, second)
+	assert.ErrorIs(t, err, ErrConflict, "the first edit is not overwritten")
+
+	stored, err := dbClient.GetEntity(ctx, "001")
+	assert.NoError(t, err)
+	assert.Equal(t, "first", stored.Name)
+	assert.Empty(t, stored.Description)
+}
+
+func TestDynamoDBClient_RecordAccess(t *testing.T) {
+	t.Parallel()
+
+	api := dbtest.NewMemoryAPI()
+	dbClient := DynamoDBClient{API: api, TableName: "personal-vault"}
+
+	stored, err := dbClient.PutItem(context.Background(), VaultEntity{ID: "001", Name: "testName"})
+	assert.NoError(t, err)
+
+	at := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 2; i++ {
+		err = dbClient.RecordAccess(context.Background(), "001", at)
+		assert.NoError(t, err)
+	}
+
+	entity, err := dbClient.GetEntity(context.Background(), "001")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, entity.AccessCount)
+	assert.Equal(t, &at, entity.LastAccessedAt)
+
+	// an edit keeps the access stats
+	entity.Name = "newName"
+	_, err = dbClient.UpdateEntity(context.Background(), entity)
+	assert.NoError(t, err)
+
+	entity, err = dbClient.GetEntity(context.Background(), "001")
+	assert.NoError(t, err)
+	assert.Equal(t, "newName", entity.Name)
+	assert.Equal(t, 2, entity.AccessCount)
+	assert.Equal(t, 2, entity.Version)
+	assert.True(t, entity.CreatedAt.Equal(stored.CreatedAt))
+
+	// the stats are kept next to the entry, which a read leaves alone
+	raw, err := api.GetItem(context.Background(), &dynamodb.GetItemInput{
+		Key: map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: "001"}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, &types.AttributeValueMemberN{Value: "2"}, raw.Item["version"])
+	assert.Equal(t, &types.AttributeValueMemberN{Value: "0"}, raw.Item["access_count"])
+
+	// stats an entry carried before they were moved are added to the new ones
+	_, err = api.UpdateItem(context.Background(), &dynamodb.UpdateItemInput{
+		Key:                       map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: "001"}},
+		UpdateExpression:          aws.String("SET access_count = :count"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{":count": &types.AttributeValueMemberN{Value: "3"}},
+	})
+	assert.NoError(t, err)
+
+	entity, err = dbClient.GetEntity(context.Background(), "001")
+	assert.NoError(t, err)
+	assert.Equal(t, 5, entity.AccessCount)
+
+	items, err := dbClient.ScanItems(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 5, items[0].AccessCount)
+	assert.Equal(t, &at, items[0].LastAccessedAt)
+}
+
+func TestDynamoDBClient_RecordAccessAsync(t *testing.T) {
+	t.Parallel()
+
+	api := dbtest.NewMemoryAPI()
+	dbClient := DynamoDBClient{API: api, TableName: "personal-vault", SyncAccess: true}
+
+	_, err := dbClient.PutItem(context.Background(), VaultEntity{ID: "001", Name: "testName"})
+	assert.NoError(t, err)
+
+	// a cancelled request still records, and SyncAccess has written it on return
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	dbClient.RecordAccessAsync(ctx, "001")
+
+	entity, err := dbClient.GetEntity(context.Background(), "001")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, entity.AccessCount)
+}
@@ -0,0 +1,91 @@
+package db
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	// reservedPrefix marks items that are not vault entries. Entry ids are
+	// UUIDs and never start with it.
+	reservedPrefix = "_"
+	vaultMetaID    = reservedPrefix + "vault"
+)
+
+// IsReserved reports whether id belongs to an item other than a vault entry.
+func IsReserved(id string) bool {
+	return strings.HasPrefix(id, reservedPrefix)
+}
+
+// VaultMeta records how the master key was derived, so the same key can be
+// derived again from the master password, and a key check value to detect a
+// wrong key before any entry is read.
+type VaultMeta struct {
+	ID         string    `dynamodbav:"id"`
+	KDF        string    `dynamodbav:"kdf"`
+	Iterations int       `dynamodbav:"kdf_iterations"`
+	Salt       []byte    `dynamodbav:"kdf_salt"`
+	KeyCheck   string    `dynamodbav:"key_check"`
+	CreatedAt  time.Time `dynamodbav:"created_at"`
+}
+
+// CreateVaultMeta stores the vault metadata once. It returns ErrConflict when
+// the vault has already been initialised.
+func (dbClient DynamoDBClient) CreateVaultMeta(ctx context.Context, meta VaultMeta) error {
+	meta.ID = vaultMetaID
+
+	item, err := attributevalue.MarshalMap(meta)
+	if err != nil {
+		return err
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName:           aws.String(dbClient.TableName),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(id)"),
+	}
+
+	slog.DebugContext(ctx, "dynamodb put vault meta", slog.String("table", dbClient.TableName))
+
+	_, err = dbClient.API.PutItem(ctx, input)
+	if err != nil {
+		return translateError(err)
+	}
+
+	return nil
+}
+
+func (dbClient DynamoDBClient) GetVaultMeta(ctx context.Context) (VaultMeta, error) {
+	var meta VaultMeta
+
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(dbClient.TableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: vaultMetaID},
+		},
+		ConsistentRead: aws.Bool(true),
+	}
+
+	output, err := dbClient.API.GetItem(ctx, input)
+	if err != nil {
+		return meta, translateError(err)
+	}
+
+	if output.Item == nil {
+		return meta, ErrNotFound
+	}
+
+	err = attributevalue.UnmarshalMap(output.Item, &meta)
+	if err != nil {
+		return meta, err
+	}
+
+	return meta, nil
+}
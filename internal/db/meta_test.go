@@ -0,0 +1,91 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDynamoDBClient_CreateVaultMeta(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		putItem     func(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+		expectedErr error
+	}{
+		{
+			name: "success case",
+			putItem: func(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+				assert.Equal(t, "attribute_not_exists(id)", aws.ToString(params.ConditionExpression))
+				assert.Equal(t, &types.AttributeValueMemberS{Value: vaultMetaID}, params.Item["id"])
+				return &dynamodb.PutItemOutput{}, nil
+			},
+		},
+		{
+			name: "already initialised",
+			putItem: func(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+				return nil, &types.ConditionalCheckFailedException{Message: aws.String("mock")}
+			},
+			expectedErr: ErrConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			dynamdbMockClient := DynamoDBClient{
+				API: &dynamoDBMockAPI{
+					putItem: tt.putItem,
+				}}
+
+			err := dynamdbMockClient.CreateVaultMeta(context.Background(), VaultMeta{KDF: "pbkdf2-sha256", Iterations: 1, Salt: []byte("salt")})
+			assert.ErrorIs(t, err, tt.expectedErr)
+		})
+	}
+}
+
+func TestDynamoDBClient_GetVaultMeta(t *testing.T) {
+	t.Parallel()
+
+	stored := VaultMeta{ID: vaultMetaID, KDF: "pbkdf2-sha256", Iterations: 600000, Salt: []byte("salt"), CreatedAt: time.Unix(0, 0).UTC()}
+	item, err := attributevalue.MarshalMap(stored)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		item        map[string]types.AttributeValue
+		expectedErr error
+	}{
+		{name: "success case", item: item},
+		{name: "not initialised", item: nil, expectedErr: ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			dynamdbMockClient := DynamoDBClient{
+				API: &dynamoDBMockAPI{
+					getItem: func(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+						return &dynamodb.GetItemOutput{Item: tt.item}, nil
+					},
+				}}
+
+			meta, err := dynamdbMockClient.GetVaultMeta(context.Background())
+			assert.ErrorIs(t, err, tt.expectedErr)
+			if tt.expectedErr == nil {
+				assert.Equal(t, stored, meta)
+			}
+		})
+	}
+}
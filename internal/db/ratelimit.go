@@ -0,0 +1,119 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const rateLimitPrefix = reservedPrefix + "ratelimit#"
+
+// RateLimit is the token bucket and failure count of one client, shared by
+// every instance of the server. See the ratelimit package.
+type RateLimit struct {
+	Tokens       float64   `dynamodbav:"tokens"`
+	UpdatedAt    time.Time `dynamodbav:"updated_at"`
+	Failures     int       `dynamodbav:"failures"`
+	WindowStart  time.Time `dynamodbav:"window_start"`
+	Blocks       int       `dynamodbav:"blocks"`
+	BlockedUntil time.Time `dynamodbav:"blocked_until"`
+	// ExpiresAt is when the state can be forgotten. DynamoDB removes the
+	// item through TTL some time after.
+	ExpiresAt time.Time `dynamodbav:"expires_at"`
+	Version   int       `dynamodbav:"version"`
+}
+
+func rateLimitKey(key string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"id": &types.AttributeValueMemberS{Value: rateLimitPrefix + key},
+	}
+}
+
+// GetRateLimit returns ErrNotFound when the client has no state.
+func (dbClient DynamoDBClient) GetRateLimit(ctx context.Context, key string) (RateLimit, error) {
+	var limit RateLimit
+
+	err := dbClient.getReserved(ctx, rateLimitPrefix+key, &limit)
+
+	return limit, err
+}
+
+// PutRateLimit stores the state read by GetRateLimit, or a new state with
+// Version 0. It returns ErrConflict when another instance stored the state in
+// the meantime.
+func (dbClient DynamoDBClient) PutRateLimit(ctx context.Context, key string, limit RateLimit) (RateLimit, error) {
+	condition := "attribute_not_exists(id)"
+	var values map[string]types.AttributeValue
+	if limit.Version > 0 {
+		condition = "version = :version"
+		values = map[string]types.AttributeValue{
+			":version": &types.AttributeValueMemberN{Value: strconv.Itoa(limit.Version)},
+		}
+	}
+
+	limit.Version++
+
+	item, err := attributevalue.MarshalMap(limit)
+	if err != nil {
+		return limit, err
+	}
+
+	for name, value := range rateLimitKey(key) {
+		item[name] = value
+	}
+	item[TTLAttribute] = &types.AttributeValueMemberN{Value: strconv.FormatInt(limit.ExpiresAt.Unix(), 10)}
+
+	input := &dynamodb.PutItemInput{
+		TableName:                 aws.String(dbClient.TableName),
+		Item:                      item,
+		ConditionExpression:       aws.String(condition),
+		ExpressionAttributeValues: values,
+	}
+
+	slog.DebugContext(ctx, "dynamodb put rate limit", slog.String("table", dbClient.TableName))
+
+	_, err = dbClient.API.PutItem(ctx, input)
+	if err != nil {
+		return limit, translateError(err)
+	}
+
+	return limit, nil
+}
+
+// UpdateRateLimit applies update to the state of key and stores it, retrying
+// when instances race on the same key. State that expired before now is
+// passed to update as a new state.
+func (dbClient DynamoDBClient) UpdateRateLimit(ctx context.Context, key string, now time.Time, update func(*RateLimit)) (RateLimit, error) {
+	const attempts = 5
+
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		var limit RateLimit
+
+		limit, err = dbClient.GetRateLimit(ctx, key)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return limit, err
+		}
+
+		// TTL deletes items late, expired state is reset in place
+		if !limit.ExpiresAt.After(now) {
+			limit = RateLimit{Version: limit.Version}
+		}
+
+		update(&limit)
+
+		limit, err = dbClient.PutRateLimit(ctx, key, limit)
+		if !errors.Is(err, ErrConflict) {
+			return limit, err
+		}
+	}
+
+	return RateLimit{}, err
+}
@@ -0,0 +1,62 @@
+package db
+
+import (
+	"context"
+	"personal-vault/internal/dbtest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDynamoDBClient_RateLimit(t *testing.T) {
+	t.Parallel()
+
+	dbClient := DynamoDBClient{API: dbtest.NewMemoryAPI(), TableName: "personal-vault"}
+	ctx := context.Background()
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	_, err := dbClient.GetRateLimit(ctx, "retrieve#192.0.2.1")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	stored, err := dbClient.PutRateLimit(ctx, "retrieve#192.0.2.1", RateLimit{Tokens: 4, UpdatedAt: now, ExpiresAt: now.Add(time.Minute)})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, stored.Version)
+
+	// a second new state lost the race
+	_, err = dbClient.PutRateLimit(ctx, "retrieve#192.0.2.1", RateLimit{Tokens: 9})
+	assert.ErrorIs(t, err, ErrConflict)
+
+	got, err := dbClient.GetRateLimit(ctx, "retrieve#192.0.2.1")
+	assert.NoError(t, err)
+	assert.Equal(t, stored, got)
+
+	// another instance updates between the read and the write of the first
+	// attempt, which is then retried on the new state
+	attempts := 0
+	updated, err := dbClient.UpdateRateLimit(ctx, "retrieve#192.0.2.1", now, func(limit *RateLimit) {
+		attempts++
+		if attempts == 1 {
+			_, err := dbClient.PutRateLimit(ctx, "retrieve#192.0.2.1", stored)
+			assert.NoError(t, err)
+		}
+		limit.Tokens--
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, attempts)
+	assert.Equal(t, float64(3), updated.Tokens)
+	assert.Equal(t, 3, updated.Version)
+
+	// expired state starts over
+	updated, err = dbClient.UpdateRateLimit(ctx, "retrieve#192.0.2.1", now.Add(time.Hour), func(limit *RateLimit) {
+		assert.Zero(t, limit.Tokens)
+		limit.ExpiresAt = now.Add(2 * time.Hour)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 4, updated.Version)
+
+	// rate limits are never listed as entries
+	items, err := dbClient.ScanItems(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, items)
+}
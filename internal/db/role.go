@@ -0,0 +1,220 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	rolesPrefix = reservedPrefix + "roles#"
+
+	// legacyRolePrefix is the first layout of roles, one item per principal
+	// and prefix, see MigrateRoles
+	legacyRolePrefix = reservedPrefix + "role#"
+	rolesMigratedID  = reservedPrefix + "roles_migrated"
+)
+
+// RoleAssignment grants a principal a role on the entries whose name starts
+// with Prefix. See the rbac package for what each role allows.
+type RoleAssignment struct {
+	Principal string    `dynamodbav:"principal"`
+	Prefix    string    `dynamodbav:"prefix"`
+	Role      string    `dynamodbav:"role"`
+	GrantedBy string    `dynamodbav:"granted_by"`
+	CreatedAt time.Time `dynamodbav:"created_at"`
+}
+
+// principalRoles holds every role of a principal in a single item, so the
+// roles checked on each request are read by key rather than by a scan.
+type principalRoles struct {
+	Principal   string           `dynamodbav:"principal"`
+	Assignments []RoleAssignment `dynamodbav:"assignments"`
+	Version     int              `dynamodbav:"version"`
+}
+
+// PutRoleAssignment grants a role, replacing the role the principal had on
+// the same prefix.
+func (dbClient DynamoDBClient) PutRoleAssignment(ctx context.Context, assignment RoleAssignment) (RoleAssignment, error) {
+	assignment.CreatedAt = time.Now().UTC()
+
+	err := dbClient.updateRoles(ctx, assignment.Principal, func(assignments []RoleAssignment) ([]RoleAssignment, error) {
+		assignments = slices.DeleteFunc(assignments, func(granted RoleAssignment) bool {
+			return granted.Prefix == assignment.Prefix
+		})
+
+		return append(assignments, assignment), nil
+	})
+
+	return assignment, err
+}
+
+// DeleteRoleAssignment revokes a role. It returns ErrNotFound when the
+// principal has no role on the prefix.
+func (dbClient DynamoDBClient) DeleteRoleAssignment(ctx context.Context, principal, prefix string) error {
+	return dbClient.updateRoles(ctx, principal, func(assignments []RoleAssignment) ([]RoleAssignment, error) {
+		remaining := slices.DeleteFunc(assignments, func(granted RoleAssignment) bool {
+			return granted.Prefix == prefix
+		})
+		if len(remaining) == len(assignments) {
+			return nil, ErrNotFound
+		}
+
+		return remaining, nil
+	})
+}
+
+// updateRoles applies update to the roles of principal and stores them,
+// retrying when two grants race on the same principal.
+func (dbClient DynamoDBClient) updateRoles(ctx context.Context, principal string, update func([]RoleAssignment) ([]RoleAssignment, error)) error {
+	const attempts = 5
+
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		var roles principalRoles
+
+		err = dbClient.getReserved(ctx, rolesPrefix+principal, &roles)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+
+		condition := "attribute_not_exists(id)"
+		var names map[string]string
+		var values map[string]types.AttributeValue
+		if roles.Version > 0 {
+			condition = "#version = :version"
+			names = map[string]string{"#version": "version"}
+			values = map[string]types.AttributeValue{
+				":version": &types.AttributeValueMemberN{Value: strconv.Itoa(roles.Version)},
+			}
+		}
+
+		roles.Assignments, err = update(roles.Assignments)
+		if err != nil {
+			return err
+		}
+
+		roles.Principal = principal
+		roles.Version++
+
+		err = dbClient.putReserved(ctx, rolesPrefix+principal, roles, condition, names, values)
+		if !errors.Is(err, ErrConflict) {
+			return err
+		}
+	}
+
+	return err
+}
+
+func (dbClient DynamoDBClient) ScanRoleAssignments(ctx context.Context) ([]RoleAssignment, error) {
+	var principals []principalRoles
+
+	err := dbClient.scanPrefix(ctx, rolesPrefix, &principals)
+	if err != nil {
+		return nil, err
+	}
+
+	assignments := []RoleAssignment{}
+	for _, roles := range principals {
+		assignments = append(assignments, roles.Assignments...)
+	}
+
+	return assignments, nil
+}
+
+// RoleAssignmentsFor returns the roles granted to a single principal, with
+// one consistent read so a revoked role is never used again.
+func (dbClient DynamoDBClient) RoleAssignmentsFor(ctx context.Context, principal string) ([]RoleAssignment, error) {
+	var roles principalRoles
+
+	err := dbClient.getReserved(ctx, rolesPrefix+principal, &roles)
+	if errors.Is(err, ErrNotFound) {
+		return []RoleAssignment{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if roles.Assignments == nil {
+		return []RoleAssignment{}, nil
+	}
+
+	return roles.Assignments, nil
+}
+
+// MigrateRoles moves the roles stored one item per principal and prefix, as
+// roles were first stored, into the item of their principal, where
+// RoleAssignmentsFor finds them. A role granted on the same prefix since is
+// kept. Once the roles are moved a marker is stored, so later calls only read
+// the marker. It returns the number of roles moved.
+func (dbClient DynamoDBClient) MigrateRoles(ctx context.Context) (int, error) {
+	var marker struct {
+		MigratedAt time.Time `dynamodbav:"migrated_at"`
+	}
+
+	err := dbClient.getReserved(ctx, rolesMigratedID, &marker)
+	if err == nil {
+		return 0, nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return 0, err
+	}
+
+	var legacy []RoleAssignment
+
+	err = dbClient.scanPrefix(ctx, legacyRolePrefix, &legacy)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, assignment := range legacy {
+		err = dbClient.updateRoles(ctx, assignment.Principal, func(assignments []RoleAssignment) ([]RoleAssignment, error) {
+			if slices.ContainsFunc(assignments, func(granted RoleAssignment) bool { return granted.Prefix == assignment.Prefix }) {
+				return assignments, nil
+			}
+
+			return append(assignments, assignment), nil
+		})
+		if err != nil {
+			return i, err
+		}
+
+		input := &dynamodb.DeleteItemInput{
+			TableName: aws.String(dbClient.TableName),
+			Key: map[string]types.AttributeValue{
+				"id": &types.AttributeValueMemberS{Value: legacyRolePrefix + assignment.Principal + "#" + assignment.Prefix},
+			},
+		}
+
+		slog.DebugContext(ctx, "dynamodb delete legacy role assignment", slog.String("table", dbClient.TableName))
+
+		// deleting twice deletes once
+		_, err = dbClient.API.DeleteItem(Idempotent(ctx), input)
+		if err != nil {
+			return i, translateError(err)
+		}
+	}
+
+	marker.MigratedAt = time.Now().UTC()
+
+	return len(legacy), dbClient.putReserved(ctx, rolesMigratedID, marker, "", nil, nil)
+}
+
+// EntryName returns the name of an entry, whether it is live or in the trash,
+// so access can be checked before the entry is read or changed.
+func (dbClient DynamoDBClient) EntryName(ctx context.Context, id string) (string, error) {
+	var entity struct {
+		Name string `dynamodbav:"name"`
+	}
+
+	err := dbClient.getReserved(ctx, id, &entity)
+
+	return entity.Name, err
+}
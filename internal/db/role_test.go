@@ -0,0 +1,163 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"personal-vault/internal/dbtest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDynamoDBClient_RoleAssignments(t *testing.T) {
+	t.Parallel()
+
+	dbClient := DynamoDBClient{API: dbtest.NewMemoryAPI(), TableName: "personal-vault"}
+	ctx := context.Background()
+
+	_, err := dbClient.PutItem(ctx, VaultEntity{ID: "001", Name: "prod/db"})
+	assert.NoError(t, err)
+
+	for _, assignment := range []RoleAssignment{
+		{Principal: "al", Prefix: "prod/", Role: "viewer"},
+		{Principal: "alice", Prefix: "prod/", Role: "viewer"},
+		{Principal: "alice", Prefix: "dev/", Role: "owner"},
+	} {
+		_, err = dbClient.PutRoleAssignment(ctx, assignment)
+		assert.NoError(t, err)
+	}
+
+	// a second grant on the same prefix replaces the first
+	_, err = dbClient.PutRoleAssignment(ctx, RoleAssignment{Principal: "alice", Prefix: "prod/", Role: "editor", GrantedBy: "root"})
+	assert.NoError(t, err)
+
+	all, err := dbClient.ScanRoleAssignments(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, all, 3)
+
+	// role assignments are never listed as entries
+	items, err := dbClient.ScanItems(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, items, 1)
+
+	alice, err := dbClient.RoleAssignmentsFor(ctx, "alice")
+	assert.NoError(t, err)
+	assert.Len(t, alice, 2, "the roles of al are not alice's")
+	for _, assignment := range alice {
+		if assignment.Prefix == "prod/" {
+			assert.Equal(t, "editor", assignment.Role)
+			assert.Equal(t, "root", assignment.GrantedBy)
+			assert.WithinDuration(t, time.Now(), assignment.CreatedAt, time.Minute)
+		}
+	}
+
+	assert.NoError(t, dbClient.DeleteRoleAssignment(ctx, "alice", "prod/"))
+	assert.ErrorIs(t, dbClient.DeleteRoleAssignment(ctx, "alice", "prod/"), ErrNotFound)
+
+	alice, err = dbClient.RoleAssignmentsFor(ctx, "alice")
+	assert.NoError(t, err)
+	assert.Len(t, alice, 1)
+
+	// the name of an entry is found in the trash too
+	assert.NoError(t, dbClient.DeleteItem(ctx, "001", time.Hour))
+	name, err := dbClient.EntryName(ctx, "001")
+	assert.NoError(t, err)
+	assert.Equal(t, "prod/db", name)
+
+	_, err = dbClient.EntryName(ctx, "002")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+// scanlessAPI fails every scan.
+type scanlessAPI struct {
+	*dbtest.MemoryAPI
+}
+
+func (scanlessAPI) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	return nil, errors.New("this is mock error")
+}
+
+// TestDynamoDBClient_RoleAssignmentsByKey checks that the roles checked on
+// every request are read by key, never by scanning the table.
+func TestDynamoDBClient_RoleAssignmentsByKey(t *testing.T) {
+	t.Parallel()
+
+	dbClient := DynamoDBClient{API: scanlessAPI{MemoryAPI: dbtest.NewMemoryAPI()}, TableName: "personal-vault"}
+	ctx := context.Background()
+
+	assignments, err := dbClient.RoleAssignmentsFor(ctx, "bob")
+	assert.NoError(t, err)
+	assert.Empty(t, assignments)
+
+	_, err = dbClient.PutRoleAssignment(ctx, RoleAssignment{Principal: "bob", Prefix: "dev/", Role: "viewer"})
+	assert.NoError(t, err)
+	_, err = dbClient.PutRoleAssignment(ctx, RoleAssignment{Principal: "bob", Prefix: "prod/", Role: "viewer"})
+	assert.NoError(t, err)
+
+	assignments, err = dbClient.RoleAssignmentsFor(ctx, "bob")
+	assert.NoError(t, err)
+	assert.Len(t, assignments, 2)
+
+	// revoking the last role leaves the principal with none
+	assert.NoError(t, dbClient.DeleteRoleAssignment(ctx, "bob", "dev/"))
+	assert.NoError(t, dbClient.DeleteRoleAssignment(ctx, "bob", "prod/"))
+	assert.ErrorIs(t, dbClient.DeleteRoleAssignment(ctx, "carol", "prod/"), ErrNotFound)
+
+	assignments, err = dbClient.RoleAssignmentsFor(ctx, "bob")
+	assert.NoError(t, err)
+	assert.Empty(t, assignments)
+}
+
+func TestDynamoDBClient_MigrateRoles(t *testing.T) {
+	t.Parallel()
+
+	api := dbtest.NewMemoryAPI()
+	dbClient := DynamoDBClient{API: api, TableName: "personal-vault"}
+	ctx := context.Background()
+
+	putLegacy := func(assignment RoleAssignment) {
+		item, err := attributevalue.MarshalMap(assignment)
+		assert.NoError(t, err)
+		item["id"] = &types.AttributeValueMemberS{Value: "_role#" + assignment.Principal + "#" + assignment.Prefix}
+
+		_, err = api.PutItem(ctx, &dynamodb.PutItemInput{Item: item})
+		assert.NoError(t, err)
+	}
+
+	putLegacy(RoleAssignment{Principal: "alice", Prefix: "prod/", Role: "viewer"})
+	putLegacy(RoleAssignment{Principal: "alice", Prefix: "dev/", Role: "owner", GrantedBy: "root"})
+	putLegacy(RoleAssignment{Principal: "bob", Prefix: "prod/", Role: "viewer"})
+
+	// a role granted again since the layout changed wins
+	_, err := dbClient.PutRoleAssignment(ctx, RoleAssignment{Principal: "alice", Prefix: "prod/", Role: "editor"})
+	assert.NoError(t, err)
+
+	migrated, err := dbClient.MigrateRoles(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, migrated)
+
+	alice, err := dbClient.RoleAssignmentsFor(ctx, "alice")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"prod/ editor", "dev/ owner"}, []string{
+		alice[0].Prefix + " " + alice[0].Role,
+		alice[1].Prefix + " " + alice[1].Role,
+	})
+
+	bob, err := dbClient.RoleAssignmentsFor(ctx, "bob")
+	assert.NoError(t, err)
+	assert.Len(t, bob, 1)
+
+	var legacy []RoleAssignment
+	assert.NoError(t, dbClient.scanPrefix(ctx, "_role#", &legacy))
+	assert.Empty(t, legacy)
+
+	// later starts only read the marker
+	putLegacy(RoleAssignment{Principal: "carol", Prefix: "prod/", Role: "viewer"})
+	migrated, err = dbClient.MigrateRoles(ctx)
+	assert.NoError(t, err)
+	assert.Zero(t, migrated)
+}
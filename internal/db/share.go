@@ -0,0 +1,131 @@
+package db
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// sharePrefix keeps share records among the reserved items, so they are never
+// listed as entries.
+const sharePrefix = reservedPrefix + "share#"
+
+// Share is a copy of one entry's password, encrypted under a key the server
+// does not keep. It can be viewed ViewsLeft more times until ExpiresAt.
+type Share struct {
+	ID         string    `dynamodbav:"share_id"`
+	EntryID    string    `dynamodbav:"entry_id"`
+	Ciphertext string    `dynamodbav:"ciphertext"`
+	CreatedAt  time.Time `dynamodbav:"created_at"`
+	ExpiresAt  time.Time `dynamodbav:"expires_at"`
+	ViewsLeft  int       `dynamodbav:"views_left"`
+}
+
+func shareKey(id string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"id": &types.AttributeValueMemberS{Value: sharePrefix + id},
+	}
+}
+
+// CreateShare stores a share. DynamoDB removes it through TTL once it
+// expires.
+func (dbClient DynamoDBClient) CreateShare(ctx context.Context, share Share) error {
+	item, err := attributevalue.MarshalMap(share)
+	if err != nil {
+		return err
+	}
+
+	for name, value := range shareKey(share.ID) {
+		item[name] = value
+	}
+	item[TTLAttribute] = &types.AttributeValueMemberN{Value: strconv.FormatInt(share.ExpiresAt.Unix(), 10)}
+
+	input := &dynamodb.PutItemInput{
+		TableName:           aws.String(dbClient.TableName),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(id)"),
+	}
+
+	slog.DebugContext(ctx, "dynamodb put share", slog.String("table", dbClient.TableName), slog.String("entry_id", share.EntryID))
+
+	_, err = dbClient.API.PutItem(ctx, input)
+	if err != nil {
+		return translateError(err)
+	}
+
+	return nil
+}
+
+// ConsumeShare counts one view of a share and returns it. The share is deleted
+// after its last view. It returns ErrNotFound when the share does not exist,
+// expired or has no views left, and without using up a view while its entry
+// is in the trash.
+func (dbClient DynamoDBClient) ConsumeShare(ctx context.Context, id string, now time.Time) (Share, error) {
+	var share Share
+
+	err := dbClient.getReserved(ctx, sharePrefix+id, &share)
+	if err != nil {
+		return Share{}, err
+	}
+
+	var entry struct {
+		DeletedAt *time.Time `dynamodbav:"deleted_at"`
+	}
+
+	err = dbClient.getReserved(ctx, share.EntryID, &entry)
+	if err != nil {
+		return Share{}, err
+	}
+	if entry.DeletedAt != nil {
+		return Share{}, ErrNotFound
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName:        aws.String(dbClient.TableName),
+		Key:              shareKey(id),
+		UpdateExpression: aws.String("ADD views_left :minus_one"),
+		// the views are counted down atomically, so concurrent requests never
+		// see more views than allowed
+		ConditionExpression: aws.String("views_left > :zero AND " + TTLAttribute + " > :now"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":minus_one": &types.AttributeValueMemberN{Value: "-1"},
+			":zero":      &types.AttributeValueMemberN{Value: "0"},
+			":now":       &types.AttributeValueMemberN{Value: strconv.FormatInt(now.Unix(), 10)},
+		},
+		ReturnValues: types.ReturnValueAllNew,
+	}
+
+	slog.DebugContext(ctx, "dynamodb consume share", slog.String("table", dbClient.TableName))
+
+	output, err := dbClient.API.UpdateItem(ctx, input)
+	if err != nil {
+		return share, translateMissingError(err)
+	}
+
+	err = attributevalue.UnmarshalMap(output.Attributes, &share)
+	if err != nil {
+		return share, err
+	}
+
+	if share.ViewsLeft > 0 {
+		return share, nil
+	}
+
+	// without views left the share is unusable already, so a failed delete
+	// only leaves it to TTL
+	_, err = dbClient.API.DeleteItem(Idempotent(ctx), &dynamodb.DeleteItemInput{
+		TableName: aws.String(dbClient.TableName),
+		Key:       shareKey(id),
+	})
+	if err != nil {
+		slog.WarnContext(ctx, "unable to delete used share", slog.Any("error", translateError(err)))
+	}
+
+	return share, nil
+}
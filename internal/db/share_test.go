@@ -0,0 +1,75 @@
+package db
+
+import (
+	"context"
+	"personal-vault/internal/dbtest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDynamoDBClient_Share(t *testing.T) {
+	t.Parallel()
+
+	api := dbtest.NewMemoryAPI()
+	dbClient := DynamoDBClient{API: api, TableName: "personal-vault"}
+	ctx := context.Background()
+	now := time.Now()
+
+	_, err := dbClient.PutItem(ctx, VaultEntity{ID: "001", Name: "name 001"})
+	assert.NoError(t, err)
+
+	twice := Share{ID: "twice", EntryID: "001", Ciphertext: "sealed", CreatedAt: now, ExpiresAt: now.Add(time.Hour), ViewsLeft: 2}
+	assert.NoError(t, dbClient.CreateShare(ctx, twice))
+	assert.Error(t, dbClient.CreateShare(ctx, twice), "share ids are never reused")
+
+	expired := Share{ID: "expired", EntryID: "001", Ciphertext: "sealed", CreatedAt: now, ExpiresAt: now.Add(time.Minute), ViewsLeft: 1}
+	assert.NoError(t, dbClient.CreateShare(ctx, expired))
+
+	// shares are never listed as entries
+	items, err := dbClient.ScanItems(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, items, 1)
+
+	share, err := dbClient.ConsumeShare(ctx, "twice", now)
+	assert.NoError(t, err)
+	assert.Equal(t, "001", share.EntryID)
+	assert.Equal(t, "sealed", share.Ciphertext)
+	assert.Equal(t, 1, share.ViewsLeft)
+	assert.Equal(t, 3, api.Len())
+
+	// the last view burns the share
+	share, err = dbClient.ConsumeShare(ctx, "twice", now)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, share.ViewsLeft)
+	assert.Equal(t, 2, api.Len())
+
+	_, err = dbClient.ConsumeShare(ctx, "twice", now)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = dbClient.ConsumeShare(ctx, "expired", now.Add(2*time.Minute))
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = dbClient.ConsumeShare(ctx, "missing", now)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// the shares of an entry in the trash are not served, and keep their views
+	trashed := Share{ID: "trashed", EntryID: "001", Ciphertext: "sealed", CreatedAt: now, ExpiresAt: now.Add(time.Hour), ViewsLeft: 1}
+	assert.NoError(t, dbClient.CreateShare(ctx, trashed))
+	assert.NoError(t, dbClient.DeleteItem(ctx, "001", time.Hour))
+
+	_, err = dbClient.ConsumeShare(ctx, "trashed", now)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	assert.NoError(t, dbClient.RestoreItem(ctx, "001"))
+	share, err = dbClient.ConsumeShare(ctx, "trashed", now)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, share.ViewsLeft)
+
+	// nor those of a purged entry
+	purged := Share{ID: "purged", EntryID: "002", Ciphertext: "sealed", CreatedAt: now, ExpiresAt: now.Add(time.Hour), ViewsLeft: 1}
+	assert.NoError(t, dbClient.CreateShare(ctx, purged))
+	_, err = dbClient.ConsumeShare(ctx, "purged", now)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
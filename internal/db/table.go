@@ -0,0 +1,140 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const tableActiveTimeout = 2 * time.Minute
+
+// TableAPI is the part of the DynamoDB client used to manage the table itself.
+// It is kept apart from DynamoDBAPI because only init needs it.
+type TableAPI interface {
+	TTLAPI
+	CreateTable(ctx context.Context, params *dynamodb.CreateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error)
+	DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error)
+}
+
+// TTLAPI is the part of the DynamoDB client used by EnableTTL.
+type TTLAPI interface {
+	DescribeTimeToLive(ctx context.Context, params *dynamodb.DescribeTimeToLiveInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTimeToLiveOutput, error)
+	UpdateTimeToLive(ctx context.Context, params *dynamodb.UpdateTimeToLiveInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateTimeToLiveOutput, error)
+}
+
+// CreateTable creates the vault table if it does not exist yet, waits until
+// it is active and enables TTL on TTLAttribute, see EnableTTL. New tables
+// have a KEYS_ONLY stream for the cache invalidation of other instances. It
+// reports whether the table was created.
+func CreateTable(ctx context.Context, api TableAPI, tableName string) (bool, error) {
+	input := &dynamodb.CreateTableInput{
+		TableName: aws.String(tableName),
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String("id"), KeyType: types.KeyTypeHash},
+		},
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String("id"), AttributeType: types.ScalarAttributeTypeS},
+		},
+		BillingMode: types.BillingModePayPerRequest,
+		StreamSpecification: &types.StreamSpecification{
+			StreamEnabled:  aws.Bool(true),
+			StreamViewType: types.StreamViewTypeKeysOnly,
+		},
+	}
+
+	created := true
+
+	_, err := api.CreateTable(ctx, input)
+	if err != nil {
+		var inUse *types.ResourceInUseException
+		if !errors.As(err, &inUse) {
+			return false, translateError(err)
+		}
+
+		created = false
+	}
+
+	waiter := dynamodb.NewTableExistsWaiter(api)
+	err = waiter.Wait(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(tableName)}, tableActiveTimeout)
+	if err != nil {
+		return created, err
+	}
+
+	return created, EnableTTL(ctx, api, tableName)
+}
+
+// EnableTTL makes DynamoDB purge trashed entries by TTLAttribute, unless it
+// already does. Tables created before the trash existed have no TTL, and on
+// Lambda nothing else purges the trash. A table can expire items on a single
+// attribute, so TTL on another one is an error.
+func EnableTTL(ctx context.Context, api TTLAPI, tableName string) error {
+	output, err := api.DescribeTimeToLive(ctx, &dynamodb.DescribeTimeToLiveInput{TableName: aws.String(tableName)})
+	if err != nil {
+		return translateError(err)
+	}
+
+	if description := output.TimeToLiveDescription; description != nil {
+		attribute := aws.ToString(description.AttributeName)
+
+		switch description.TimeToLiveStatus {
+		case types.TimeToLiveStatusEnabled, types.TimeToLiveStatusEnabling:
+			if attribute != TTLAttribute {
+				return fmt.Errorf("table %s expires items on %s instead of %s", tableName, attribute, TTLAttribute)
+			}
+
+			return nil
+		case types.TimeToLiveStatusDisabling:
+			return fmt.Errorf("TTL of table %s is being disabled, retry later", tableName)
+		}
+	}
+
+	_, err = api.UpdateTimeToLive(ctx, &dynamodb.UpdateTimeToLiveInput{
+		TableName: aws.String(tableName),
+		TimeToLiveSpecification: &types.TimeToLiveSpecification{
+			AttributeName: aws.String(TTLAttribute),
+			Enabled:       aws.Bool(true),
+		},
+	})
+	if err != nil {
+		return translateError(err)
+	}
+
+	return nil
+}
+
+// PingTable checks that the table can be reached and serves requests. A
+// table that is being updated still does.
+func (dbClient DynamoDBClient) PingTable(ctx context.Context) error {
+	output, err := dbClient.API.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(dbClient.TableName)})
+	if err != nil {
+		return translateError(err)
+	}
+
+	status := output.Table.TableStatus
+	if status != types.TableStatusActive && status != types.TableStatusUpdating {
+		return fmt.Errorf("table %s is %s", dbClient.TableName, status)
+	}
+
+	return nil
+}
+
+// StreamARN returns the ARN of the stream of the table, which must be
+// enabled.
+func (dbClient DynamoDBClient) StreamARN(ctx context.Context) (string, error) {
+	output, err := dbClient.API.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(dbClient.TableName)})
+	if err != nil {
+		return "", translateError(err)
+	}
+
+	spec := output.Table.StreamSpecification
+	if spec == nil || !aws.ToBool(spec.StreamEnabled) || output.Table.LatestStreamArn == nil {
+		return "", fmt.Errorf("table %s has no stream", dbClient.TableName)
+	}
+
+	return aws.ToString(output.Table.LatestStreamArn), nil
+}
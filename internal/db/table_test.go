@@ -0,0 +1,261 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type tableMockAPI struct {
+	createTable   func(ctx context.Context, params *dynamodb.CreateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error)
+	describeTable func(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error)
+	describeTTL   func(ctx context.Context, params *dynamodb.DescribeTimeToLiveInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTimeToLiveOutput, error)
+	updateTTL     func(ctx context.Context, params *dynamodb.UpdateTimeToLiveInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateTimeToLiveOutput, error)
+}
+
+func (m *tableMockAPI) CreateTable(ctx context.Context, params *dynamodb.CreateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error) {
+	return m.createTable(ctx, params, optFns...)
+}
+
+func (m *tableMockAPI) DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error) {
+	return m.describeTable(ctx, params, optFns...)
+}
+
+func (m *tableMockAPI) DescribeTimeToLive(ctx context.Context, params *dynamodb.DescribeTimeToLiveInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTimeToLiveOutput, error) {
+	return m.describeTTL(ctx, params, optFns...)
+}
+
+func (m *tableMockAPI) UpdateTimeToLive(ctx context.Context, params *dynamodb.UpdateTimeToLiveInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateTimeToLiveOutput, error) {
+	return m.updateTTL(ctx, params, optFns...)
+}
+
+func TestCreateTable(t *testing.T) {
+	t.Parallel()
+
+	activeTable := func(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error) {
+		return &dynamodb.DescribeTableOutput{
+			Table: &types.TableDescription{TableName: params.TableName, TableStatus: types.TableStatusActive},
+		}, nil
+	}
+
+	tests := []struct {
+		name            string
+		createTable     func(ctx context.Context, params *dynamodb.CreateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error)
+		expectedCreated bool
+		expectedTTL     bool
+		expectedErr     bool
+	}{
+		{
+			name: "new table",
+			createTable: func(ctx context.Context, params *dynamodb.CreateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error) {
+				assert.Equal(t, "vault", aws.ToString(params.TableName))
+				assert.Equal(t, types.StreamViewTypeKeysOnly, params.StreamSpecification.StreamViewType)
+				return &dynamodb.CreateTableOutput{}, nil
+			},
+			expectedCreated: true,
+			expectedTTL:     true,
+		},
+		{
+			// tables created before the trash get TTL too
+			name: "existing table",
+			createTable: func(ctx context.Context, params *dynamodb.CreateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error) {
+				return nil, &types.ResourceInUseException{Message: aws.String("mock")}
+			},
+			expectedCreated: false,
+			expectedTTL:     true,
+		},
+		{
+			name: "error case",
+			createTable: func(ctx context.Context, params *dynamodb.CreateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error) {
+				return nil, errors.New("this is mock error")
+			},
+			expectedErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ttlEnabled := false
+			api := &tableMockAPI{
+				createTable:   tt.createTable,
+				describeTable: activeTable,
+				describeTTL: func(ctx context.Context, params *dynamodb.DescribeTimeToLiveInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTimeToLiveOutput, error) {
+					return &dynamodb.DescribeTimeToLiveOutput{TimeToLiveDescription: &types.TimeToLiveDescription{TimeToLiveStatus: types.TimeToLiveStatusDisabled}}, nil
+				},
+				updateTTL: func(ctx context.Context, params *dynamodb.UpdateTimeToLiveInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateTimeToLiveOutput, error) {
+					assert.Equal(t, TTLAttribute, aws.ToString(params.TimeToLiveSpecification.AttributeName))
+					ttlEnabled = aws.ToBool(params.TimeToLiveSpecification.Enabled)
+					return &dynamodb.UpdateTimeToLiveOutput{}, nil
+				},
+			}
+
+			created, err := CreateTable(context.Background(), api, "vault")
+			assert.Equal(t, tt.expectedTTL, ttlEnabled)
+			if tt.expectedErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expectedCreated, created)
+		})
+	}
+}
+
+func TestEnableTTL(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name           string
+		description    *types.TimeToLiveDescription
+		expectedUpdate bool
+		expectedErr    bool
+	}{
+		{name: "disabled", description: &types.TimeToLiveDescription{TimeToLiveStatus: types.TimeToLiveStatusDisabled}, expectedUpdate: true},
+		{name: "never configured", expectedUpdate: true},
+		{name: "already enabled", description: &types.TimeToLiveDescription{TimeToLiveStatus: types.TimeToLiveStatusEnabled, AttributeName: aws.String(TTLAttribute)}},
+		{name: "being enabled", description: &types.TimeToLiveDescription{TimeToLiveStatus: types.TimeToLiveStatusEnabling, AttributeName: aws.String(TTLAttribute)}},
+		{name: "enabled on another attribute", description: &types.TimeToLiveDescription{TimeToLiveStatus: types.TimeToLiveStatusEnabled, AttributeName: aws.String("expires")}, expectedErr: true},
+		{name: "being disabled", description: &types.TimeToLiveDescription{TimeToLiveStatus: types.TimeToLiveStatusDisabling, AttributeName: aws.String(TTLAttribute)}, expectedErr: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			updated := false
+			api := &tableMockAPI{
+				describeTTL: func(ctx context.Context, params *dynamodb.DescribeTimeToLiveInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTimeToLiveOutput, error) {
+					assert.Equal(t, "vault", aws.ToString(params.TableName))
+					return &dynamodb.DescribeTimeToLiveOutput{TimeToLiveDescription: tt.description}, nil
+				},
+				updateTTL: func(ctx context.Context, params *dynamodb.UpdateTimeToLiveInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateTimeToLiveOutput, error) {
+					assert.Equal(t, TTLAttribute, aws.ToString(params.TimeToLiveSpecification.AttributeName))
+					updated = true
+					return &dynamodb.UpdateTimeToLiveOutput{}, nil
+				},
+			}
+
+			err := EnableTTL(context.Background(), api, "vault")
+			if tt.expectedErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expectedUpdate, updated)
+		})
+	}
+}
+
+func TestDynamoDBClient_PingTable(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		describeTable func(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error)
+		expectedErr   error
+	}{
+		{
+			name: "active table",
+			describeTable: func(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error) {
+				assert.Equal(t, "vault", aws.ToString(params.TableName))
+				return &dynamodb.DescribeTableOutput{Table: &types.TableDescription{TableStatus: types.TableStatusActive}}, nil
+			},
+		},
+		{
+			name: "table being deleted",
+			describeTable: func(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error) {
+				return &dynamodb.DescribeTableOutput{Table: &types.TableDescription{TableStatus: types.TableStatusDeleting}}, nil
+			},
+			expectedErr: errors.New("table vault is DELETING"),
+		},
+		{
+			name: "throttled",
+			describeTable: func(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error) {
+				return nil, &types.RequestLimitExceeded{Message: aws.String("mock")}
+			},
+			expectedErr: ErrThrottled,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			client := DynamoDBClient{API: &dynamoDBMockAPI{describeTable: tt.describeTable}, TableName: "vault"}
+
+			err := client.PingTable(context.Background())
+			switch {
+			case tt.expectedErr == nil:
+				assert.NoError(t, err)
+			case errors.Is(tt.expectedErr, ErrThrottled):
+				assert.ErrorIs(t, err, tt.expectedErr)
+			default:
+				assert.EqualError(t, err, tt.expectedErr.Error())
+			}
+		})
+	}
+}
+
+func TestDynamoDBClient_StreamARN(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		table       *types.TableDescription
+		expectedARN string
+		expectedErr bool
+	}{
+		{
+			name: "stream enabled",
+			table: &types.TableDescription{
+				StreamSpecification: &types.StreamSpecification{StreamEnabled: aws.Bool(true), StreamViewType: types.StreamViewTypeKeysOnly},
+				LatestStreamArn:     aws.String("arn:aws:dynamodb:eu-west-1:123456789012:table/vault/stream/2024-01-01T00:00:00.000"),
+			},
+			expectedARN: "arn:aws:dynamodb:eu-west-1:123456789012:table/vault/stream/2024-01-01T00:00:00.000",
+		},
+		{
+			name: "stream disabled",
+			table: &types.TableDescription{
+				StreamSpecification: &types.StreamSpecification{StreamEnabled: aws.Bool(false)},
+				LatestStreamArn:     aws.String("arn:aws:dynamodb:eu-west-1:123456789012:table/vault/stream/2024-01-01T00:00:00.000"),
+			},
+			expectedErr: true,
+		},
+		{
+			name:        "no stream",
+			table:       &types.TableDescription{},
+			expectedErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			client := DynamoDBClient{API: &dynamoDBMockAPI{
+				describeTable: func(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error) {
+					return &dynamodb.DescribeTableOutput{Table: tt.table}, nil
+				},
+			}, TableName: "vault"}
+
+			arn, err := client.StreamARN(context.Background())
+			if tt.expectedErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expectedARN, arn)
+		})
+	}
+}
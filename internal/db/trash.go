@@ -0,0 +1,133 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// TTLAttribute is the attribute DynamoDB expires trashed entries by.
+const TTLAttribute = "purge_at"
+
+// DeleteItem moves an entry to the trash, where it is kept for retention
+// before DynamoDB purges it. It returns ErrNotFound when the entry does not
+// exist or is already in the trash.
+func (dbClient DynamoDBClient) DeleteItem(ctx context.Context, id string, retention time.Duration) error {
+	defer dbClient.Cache.Invalidate()
+
+	now := time.Now().UTC()
+
+	values, err := attributevalue.MarshalMap(map[string]any{
+		":deleted_at": now,
+		":purge_at":   now.Add(retention).Unix(),
+	})
+	if err != nil {
+		return err
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(dbClient.TableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+		UpdateExpression:          aws.String("SET deleted_at = :deleted_at, " + TTLAttribute + " = :purge_at"),
+		ConditionExpression:       aws.String(liveCondition),
+		ExpressionAttributeValues: values,
+	}
+
+	slog.DebugContext(ctx, "dynamodb trash item", slog.String("table", dbClient.TableName), slog.String("id", id))
+
+	_, err = dbClient.API.UpdateItem(ctx, input)
+	if err != nil {
+		return translateMissingError(err)
+	}
+
+	return nil
+}
+
+// ScanTrash lists the entries in the trash.
+func (dbClient DynamoDBClient) ScanTrash(ctx context.Context) ([]VaultMetadata, error) {
+	return dbClient.scan(ctx, "attribute_exists(deleted_at)", nil)
+}
+
+// RestoreItem takes an entry out of the trash. It returns ErrNotFound when the
+// entry is not in the trash, or its retention ended and it only waits for
+// DynamoDB to purge it.
+func (dbClient DynamoDBClient) RestoreItem(ctx context.Context, id string) error {
+	defer dbClient.Cache.Invalidate()
+
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(dbClient.TableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+		UpdateExpression:    aws.String("REMOVE deleted_at, " + TTLAttribute),
+		ConditionExpression: aws.String("attribute_exists(deleted_at) AND " + TTLAttribute + " > :now"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":now": &types.AttributeValueMemberN{Value: strconv.FormatInt(time.Now().Unix(), 10)},
+		},
+	}
+
+	slog.DebugContext(ctx, "dynamodb restore item", slog.String("table", dbClient.TableName), slog.String("id", id))
+
+	_, err := dbClient.API.UpdateItem(ctx, input)
+	if err != nil {
+		return translateMissingError(err)
+	}
+
+	return nil
+}
+
+// PurgeTrash permanently deletes the trashed entries whose retention ended
+// before now. DynamoDB does the same through TTL, but only eventually, and
+// DynamoDB Local not promptly at all. It returns the number of purged entries.
+func (dbClient DynamoDBClient) PurgeTrash(ctx context.Context, now time.Time) (int, error) {
+	cutoff := &types.AttributeValueMemberN{Value: strconv.FormatInt(now.Unix(), 10)}
+
+	items, err := dbClient.scan(ctx, "attribute_exists(deleted_at) AND "+TTLAttribute+" <= :now",
+		map[string]types.AttributeValue{":now": cutoff})
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, item := range items {
+		input := &dynamodb.DeleteItemInput{
+			TableName: aws.String(dbClient.TableName),
+			Key: map[string]types.AttributeValue{
+				"id": &types.AttributeValueMemberS{Value: item.ID},
+			},
+			// the entry may have been restored since the scan
+			ConditionExpression:       aws.String(TTLAttribute + " <= :now"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{":now": cutoff},
+		}
+
+		slog.DebugContext(ctx, "dynamodb purge item", slog.String("table", dbClient.TableName), slog.String("id", item.ID))
+
+		_, err = dbClient.API.DeleteItem(ctx, input)
+		if err != nil {
+			err = translateMissingError(err)
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+
+			return purged, err
+		}
+
+		err = dbClient.deleteAccess(ctx, item.ID)
+		if err != nil {
+			return purged, err
+		}
+
+		purged++
+	}
+
+	return purged, nil
+}
@@ -0,0 +1,123 @@
+package db
+
+import (
+	"context"
+	"personal-vault/internal/dbtest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDynamoDBClient_DeleteItem(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		updateItem  func(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+		expectedErr error
+	}{
+		{
+			name: "success case",
+			updateItem: func(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+				assert.Equal(t, liveCondition, aws.ToString(params.ConditionExpression))
+				assert.Contains(t, aws.ToString(params.UpdateExpression), TTLAttribute)
+				return &dynamodb.UpdateItemOutput{}, nil
+			},
+		},
+		{
+			name: "item not found",
+			updateItem: func(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+				return nil, &types.ConditionalCheckFailedException{Message: aws.String("mock")}
+			},
+			expectedErr: ErrNotFound,
+		},
+		{
+			name: "throttled",
+			updateItem: func(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+				return nil, &types.RequestLimitExceeded{Message: aws.String("mock")}
+			},
+			expectedErr: ErrThrottled,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			dynamdbMockClient := DynamoDBClient{
+				API: &dynamoDBMockAPI{
+					updateItem: tt.updateItem,
+				}}
+			err := dynamdbMockClient.DeleteItem(context.Background(), "001", time.Hour)
+			assert.ErrorIs(t, err, tt.expectedErr)
+		})
+	}
+}
+
+func TestDynamoDBClient_Trash(t *testing.T) {
+	t.Parallel()
+
+	api := dbtest.NewMemoryAPI()
+	dbClient := DynamoDBClient{API: api, TableName: "personal-vault"}
+	ctx := context.Background()
+
+	for _, id := range []string{"001", "002", "003", "004"} {
+		_, err := dbClient.PutItem(ctx, VaultEntity{ID: id, Name: "name " + id})
+		assert.NoError(t, err)
+	}
+
+	assert.NoError(t, dbClient.DeleteItem(ctx, "001", time.Hour))
+	assert.NoError(t, dbClient.DeleteItem(ctx, "002", 48*time.Hour))
+	assert.ErrorIs(t, dbClient.DeleteItem(ctx, "001", time.Hour), ErrNotFound)
+
+	// an entry whose retention ended cannot be restored while it waits for
+	// DynamoDB to purge it
+	assert.NoError(t, dbClient.DeleteItem(ctx, "004", -time.Minute))
+	assert.ErrorIs(t, dbClient.RestoreItem(ctx, "004"), ErrNotFound)
+	_, err := dbClient.PurgeTrash(ctx, time.Now())
+	assert.NoError(t, err)
+
+	// trashed entries are hidden everywhere but in the trash
+	items, err := dbClient.ScanItems(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, items, 1)
+	assert.Equal(t, "003", items[0].ID)
+
+	_, err = dbClient.GetEntity(ctx, "001")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = dbClient.UpdateEntity(ctx, VaultEntity{ID: "001", Name: "edited"})
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	trash, err := dbClient.ScanTrash(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, trash, 2)
+	for _, item := range trash {
+		assert.NotNil(t, item.DeletedAt)
+	}
+
+	// only entries whose retention ended are purged
+	purged, err := dbClient.PurgeTrash(ctx, time.Now().Add(2*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, purged)
+	assert.Equal(t, 2, api.Len())
+
+	assert.ErrorIs(t, dbClient.RestoreItem(ctx, "001"), ErrNotFound)
+	assert.ErrorIs(t, dbClient.RestoreItem(ctx, "003"), ErrNotFound)
+	assert.NoError(t, dbClient.RestoreItem(ctx, "002"))
+
+	entity, err := dbClient.GetEntity(ctx, "002")
+	assert.NoError(t, err)
+	assert.Nil(t, entity.DeletedAt)
+	assert.Zero(t, entity.PurgeAt)
+
+	// a restored entry is never purged
+	purged, err = dbClient.PurgeTrash(ctx, time.Now().Add(72*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, purged)
+}
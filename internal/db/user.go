@@ -0,0 +1,45 @@
+package db
+
+import (
+	"context"
+	"time"
+)
+
+const userPrefix = reservedPrefix + "user#"
+
+// User can be a member of collections. Users are identified by name. The
+// private key is sealed under the user's passphrase, see keyring.UserKey.
+type User struct {
+	Name             string    `dynamodbav:"name"`
+	PublicKey        []byte    `dynamodbav:"public_key"`
+	SealedPrivateKey []byte    `dynamodbav:"sealed_private_key"`
+	KDFSalt          []byte    `dynamodbav:"kdf_salt"`
+	KDFIterations    int       `dynamodbav:"kdf_iterations"`
+	CreatedAt        time.Time `dynamodbav:"created_at"`
+}
+
+// CreateUser stores a new user. It returns ErrConflict when the name is
+// taken.
+func (dbClient DynamoDBClient) CreateUser(ctx context.Context, user User) (User, error) {
+	user.CreatedAt = time.Now().UTC()
+
+	err := dbClient.putReserved(ctx, userPrefix+user.Name, user, "attribute_not_exists(id)", nil, nil)
+
+	return user, err
+}
+
+func (dbClient DynamoDBClient) GetUser(ctx context.Context, name string) (User, error) {
+	var user User
+
+	err := dbClient.getReserved(ctx, userPrefix+name, &user)
+
+	return user, err
+}
+
+func (dbClient DynamoDBClient) ScanUsers(ctx context.Context) ([]User, error) {
+	users := []User{}
+
+	err := dbClient.scanPrefix(ctx, userPrefix, &users)
+
+	return users, err
+}
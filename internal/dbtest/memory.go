@@ -0,0 +1,393 @@
+// Package dbtest provides an in-memory DynamoDB table for tests that need to
+// run the real router end to end.
+package dbtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	streamtypes "github.com/aws/aws-sdk-go-v2/service/dynamodbstreams/types"
+)
+
+// MemoryAPI implements db.DynamoDBAPI for a table keyed by the string
+// attribute "id". It understands the condition, filter and update expressions
+// used by the db package, nothing more.
+type MemoryAPI struct {
+	// Stream, when set, receives a record for every successful write.
+	Stream *MemoryStream
+
+	mu    sync.Mutex
+	items map[string]map[string]types.AttributeValue
+}
+
+func NewMemoryAPI() *MemoryAPI {
+	return &MemoryAPI{items: map[string]map[string]types.AttributeValue{}}
+}
+
+func (m *MemoryAPI) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return &dynamodb.GetItemOutput{Item: m.items[keyOf(params.Key)]}, nil
+}
+
+func (m *MemoryAPI) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := keyOf(params.Item)
+	err := m.checkCondition(id, params.ConditionExpression, params.ExpressionAttributeNames, params.ExpressionAttributeValues, params.ReturnValuesOnConditionCheckFailure)
+	if err != nil {
+		return nil, err
+	}
+
+	event := streamtypes.OperationTypeInsert
+	if _, ok := m.items[id]; ok {
+		event = streamtypes.OperationTypeModify
+	}
+
+	m.items[id] = params.Item
+	m.publish(event, id)
+
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (m *MemoryAPI) UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := keyOf(params.Key)
+	err := m.checkCondition(id, params.ConditionExpression, params.ExpressionAttributeNames, params.ExpressionAttributeValues, params.ReturnValuesOnConditionCheckFailure)
+	if err != nil {
+		return nil, err
+	}
+
+	// like DynamoDB, an update without condition creates the item
+	item := map[string]types.AttributeValue{}
+	for name, value := range m.items[id] {
+		item[name] = value
+	}
+	for name, value := range params.Key {
+		item[name] = value
+	}
+
+	err = applyUpdate(item, aws.ToString(params.UpdateExpression), params.ExpressionAttributeNames, params.ExpressionAttributeValues)
+	if err != nil {
+		return nil, err
+	}
+
+	event := streamtypes.OperationTypeInsert
+	if _, ok := m.items[id]; ok {
+		event = streamtypes.OperationTypeModify
+	}
+
+	m.items[id] = item
+	m.publish(event, id)
+
+	if params.ReturnValues == types.ReturnValueAllNew {
+		return &dynamodb.UpdateItemOutput{Attributes: item}, nil
+	}
+
+	return &dynamodb.UpdateItemOutput{}, nil
+}
+
+func (m *MemoryAPI) DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := keyOf(params.Key)
+	err := m.checkCondition(id, params.ConditionExpression, params.ExpressionAttributeNames, params.ExpressionAttributeValues, params.ReturnValuesOnConditionCheckFailure)
+	if err != nil {
+		return nil, err
+	}
+
+	// deleting a missing item writes nothing, like in DynamoDB
+	if _, ok := m.items[id]; ok {
+		delete(m.items, id)
+		m.publish(streamtypes.OperationTypeRemove, id)
+	}
+
+	return &dynamodb.DeleteItemOutput{}, nil
+}
+
+func (m *MemoryAPI) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var items []map[string]types.AttributeValue
+	for _, item := range m.items {
+		ok, err := matches(item, aws.ToString(params.FilterExpression), params.ExpressionAttributeNames, params.ExpressionAttributeValues)
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			items = append(items, item)
+		}
+	}
+
+	return &dynamodb.ScanOutput{Items: items, Count: int32(len(items))}, nil
+}
+
+// DescribeTable reports an active table of the requested name, with the ARN
+// of Stream when it is set.
+func (m *MemoryAPI) DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	table := &types.TableDescription{
+		TableName:   params.TableName,
+		TableStatus: types.TableStatusActive,
+		ItemCount:   aws.Int64(int64(len(m.items))),
+	}
+	if m.Stream != nil {
+		table.LatestStreamArn = aws.String(m.Stream.ARN)
+		table.StreamSpecification = &types.StreamSpecification{
+			StreamEnabled:  aws.Bool(true),
+			StreamViewType: types.StreamViewTypeKeysOnly,
+		}
+	}
+
+	return &dynamodb.DescribeTableOutput{Table: table}, nil
+}
+
+func (m *MemoryAPI) publish(event streamtypes.OperationType, id string) {
+	if m.Stream != nil {
+		m.Stream.Publish(event, id)
+	}
+}
+
+// Len returns the number of stored items, including reserved ones.
+func (m *MemoryAPI) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return len(m.items)
+}
+
+// checkCondition fails with the item as it is when returnValues asks for it,
+// like ReturnValuesOnConditionCheckFailure does.
+func (m *MemoryAPI) checkCondition(id string, expression *string, names map[string]string, values map[string]types.AttributeValue, returnValues types.ReturnValuesOnConditionCheckFailure) error {
+	ok, err := matches(m.items[id], aws.ToString(expression), names, values)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		failed := &types.ConditionalCheckFailedException{Message: aws.String("the conditional request failed")}
+		if returnValues == types.ReturnValuesOnConditionCheckFailureAllOld {
+			failed.Item = m.items[id]
+		}
+
+		return failed
+	}
+
+	return nil
+}
+
+var (
+	functionTerm   = regexp.MustCompile(`^(\w+)\(\s*([^,\s]+)\s*(?:,\s*(\S+)\s*)?\)$`)
+	comparisonTerm = regexp.MustCompile(`^(\S+)\s*(<=|>=|<>|=|<|>)\s*(\S+)$`)
+)
+
+// matches evaluates condition and filter expressions made of terms joined by
+// AND: attribute_exists(a), attribute_not_exists(a), begins_with(a, :v) and
+// comparisons such as a <= :v, each optionally negated with NOT. An empty
+// expression matches everything, and a nil item is one that does not exist.
+func matches(item map[string]types.AttributeValue, expression string, names map[string]string, values map[string]types.AttributeValue) (bool, error) {
+	if strings.TrimSpace(expression) == "" {
+		return true, nil
+	}
+
+	resolve := func(name string) string {
+		if resolved, ok := names[name]; ok {
+			return resolved
+		}
+		return name
+	}
+
+	for _, term := range strings.Split(expression, " AND ") {
+		term = strings.TrimSpace(term)
+		term, negate := strings.CutPrefix(term, "NOT ")
+		term = strings.TrimSpace(term)
+
+		var result bool
+
+		if match := functionTerm.FindStringSubmatch(term); match != nil {
+			attribute, exists := item[resolve(match[2])]
+
+			switch match[1] {
+			case "attribute_exists":
+				result = exists
+			case "attribute_not_exists":
+				result = !exists
+			case "begins_with":
+				s, ok := attribute.(*types.AttributeValueMemberS)
+				prefix, _ := values[match[3]].(*types.AttributeValueMemberS)
+				result = ok && prefix != nil && strings.HasPrefix(s.Value, prefix.Value)
+			default:
+				return false, fmt.Errorf("dbtest: unsupported function %q", match[1])
+			}
+		} else if match := comparisonTerm.FindStringSubmatch(term); match != nil {
+			cmp, ok, err := compare(item[resolve(match[1])], values[match[3]])
+			if err != nil {
+				return false, err
+			}
+
+			switch match[2] {
+			case "=":
+				result = ok && cmp == 0
+			case "<>":
+				result = ok && cmp != 0
+			case "<":
+				result = ok && cmp < 0
+			case "<=":
+				result = ok && cmp <= 0
+			case ">":
+				result = ok && cmp > 0
+			case ">=":
+				result = ok && cmp >= 0
+			}
+		} else {
+			return false, fmt.Errorf("dbtest: unsupported expression %q", term)
+		}
+
+		if result == negate {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// compare orders two strings or two numbers. ok is false when the values are
+// missing or of different types, which never satisfies a comparison.
+func compare(a, b types.AttributeValue) (int, bool, error) {
+	switch a := a.(type) {
+	case *types.AttributeValueMemberS:
+		b, ok := b.(*types.AttributeValueMemberS)
+		if !ok {
+			return 0, false, nil
+		}
+		return strings.Compare(a.Value, b.Value), true, nil
+	case *types.AttributeValueMemberN:
+		b, ok := b.(*types.AttributeValueMemberN)
+		if !ok {
+			return 0, false, nil
+		}
+
+		x, err := strconv.ParseFloat(a.Value, 64)
+		if err != nil {
+			return 0, false, err
+		}
+		y, err := strconv.ParseFloat(b.Value, 64)
+		if err != nil {
+			return 0, false, err
+		}
+
+		switch {
+		case x < y:
+			return -1, true, nil
+		case x > y:
+			return 1, true, nil
+		default:
+			return 0, true, nil
+		}
+	default:
+		return 0, false, nil
+	}
+}
+
+// applyUpdate supports "SET a = :v, ...", "ADD n :v" for numbers and
+// "REMOVE a, ...", in any order.
+func applyUpdate(item map[string]types.AttributeValue, expression string, names map[string]string, values map[string]types.AttributeValue) error {
+	resolve := func(name string) string {
+		name = strings.TrimSpace(name)
+		if resolved, ok := names[name]; ok {
+			return resolved
+		}
+		return name
+	}
+
+	// split the expression into one clause per action keyword
+	clauses := map[string]string{}
+	var action string
+	for _, token := range strings.Fields(expression) {
+		switch token {
+		case "SET", "ADD", "REMOVE":
+			action = token
+		default:
+			clauses[action] += " " + token
+		}
+	}
+
+	for action, rest := range clauses {
+		for _, part := range strings.Split(rest, ",") {
+			switch action {
+			case "SET":
+				name, value, found := strings.Cut(part, "=")
+				if !found {
+					return fmt.Errorf("dbtest: unsupported SET clause %q", part)
+				}
+				item[resolve(name)] = values[strings.TrimSpace(value)]
+			case "ADD":
+				fields := strings.Fields(part)
+				if len(fields) != 2 {
+					return fmt.Errorf("dbtest: unsupported ADD clause %q", part)
+				}
+				sum, err := addNumbers(item[resolve(fields[0])], values[fields[1]])
+				if err != nil {
+					return err
+				}
+				item[resolve(fields[0])] = sum
+			case "REMOVE":
+				delete(item, resolve(part))
+			default:
+				return fmt.Errorf("dbtest: unsupported update action %q", action)
+			}
+		}
+	}
+
+	return nil
+}
+
+func addNumbers(current, delta types.AttributeValue) (types.AttributeValue, error) {
+	var sum float64
+
+	for _, value := range []types.AttributeValue{current, delta} {
+		if value == nil {
+			continue
+		}
+
+		n, ok := value.(*types.AttributeValueMemberN)
+		if !ok {
+			return nil, errors.New("dbtest: ADD only supports numbers")
+		}
+
+		f, err := strconv.ParseFloat(n.Value, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		sum += f
+	}
+
+	return &types.AttributeValueMemberN{Value: strconv.FormatFloat(sum, 'f', -1, 64)}, nil
+}
+
+func keyOf(item map[string]types.AttributeValue) string {
+	id, ok := item["id"].(*types.AttributeValueMemberS)
+	if !ok {
+		return ""
+	}
+
+	return id.Value
+}
@@ -0,0 +1,171 @@
+package dbtest
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodbstreams"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodbstreams/types"
+)
+
+// MemoryStream is a KEYS_ONLY DynamoDB stream for a MemoryAPI table. It
+// implements the DescribeStream, GetShardIterator and GetRecords calls of the
+// dynamodbstreams client. Split and ExpireIterators stand in for the shard
+// rollovers and iterator expiry of DynamoDB.
+type MemoryStream struct {
+	ARN string
+
+	mu     sync.Mutex
+	shards []*memoryShard
+	// epoch is part of every iterator and bumped to expire them
+	epoch int
+}
+
+type memoryShard struct {
+	id      string
+	parent  string
+	records []types.Record
+	closed  bool
+}
+
+func NewMemoryStream(arn string) *MemoryStream {
+	return &MemoryStream{ARN: arn, shards: []*memoryShard{{id: "shard-0"}}}
+}
+
+// Publish appends a record for the item with the given id to the open shard.
+func (s *MemoryStream) Publish(event types.OperationType, id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	shard := s.shards[len(s.shards)-1]
+	shard.records = append(shard.records, types.Record{
+		EventName: event,
+		Dynamodb: &types.StreamRecord{
+			Keys:           map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: id}},
+			SequenceNumber: aws.String(fmt.Sprintf("%s-%d", shard.id, len(shard.records))),
+			StreamViewType: types.StreamViewTypeKeysOnly,
+		},
+	})
+}
+
+// Split closes the open shard and opens a child shard for the next records.
+func (s *MemoryStream) Split() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	parent := s.shards[len(s.shards)-1]
+	parent.closed = true
+	s.shards = append(s.shards, &memoryShard{id: "shard-" + strconv.Itoa(len(s.shards)), parent: parent.id})
+}
+
+// ExpireIterators makes every iterator handed out so far fail with
+// ExpiredIteratorException.
+func (s *MemoryStream) ExpireIterators() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.epoch++
+}
+
+func (s *MemoryStream) DescribeStream(ctx context.Context, params *dynamodbstreams.DescribeStreamInput, optFns ...func(*dynamodbstreams.Options)) (*dynamodbstreams.DescribeStreamOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if aws.ToString(params.StreamArn) != s.ARN {
+		return nil, &types.ResourceNotFoundException{Message: aws.String("stream not found")}
+	}
+
+	shards := make([]types.Shard, 0, len(s.shards))
+	for _, shard := range s.shards {
+		description := types.Shard{
+			ShardId:             aws.String(shard.id),
+			SequenceNumberRange: &types.SequenceNumberRange{StartingSequenceNumber: aws.String(shard.id + "-0")},
+		}
+		if shard.parent != "" {
+			description.ParentShardId = aws.String(shard.parent)
+		}
+		if shard.closed {
+			description.SequenceNumberRange.EndingSequenceNumber = aws.String(fmt.Sprintf("%s-%d", shard.id, len(shard.records)))
+		}
+
+		shards = append(shards, description)
+	}
+
+	return &dynamodbstreams.DescribeStreamOutput{StreamDescription: &types.StreamDescription{
+		StreamArn:    params.StreamArn,
+		StreamStatus: types.StreamStatusEnabled,
+		Shards:       shards,
+	}}, nil
+}
+
+func (s *MemoryStream) GetShardIterator(ctx context.Context, params *dynamodbstreams.GetShardIteratorInput, optFns ...func(*dynamodbstreams.Options)) (*dynamodbstreams.GetShardIteratorOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	shard := s.shard(aws.ToString(params.ShardId))
+	if shard == nil {
+		return nil, &types.ResourceNotFoundException{Message: aws.String("shard not found")}
+	}
+
+	var position int
+	switch params.ShardIteratorType {
+	case types.ShardIteratorTypeTrimHorizon:
+		position = 0
+	case types.ShardIteratorTypeLatest:
+		position = len(shard.records)
+	default:
+		return nil, fmt.Errorf("unsupported shard iterator type %s", params.ShardIteratorType)
+	}
+
+	return &dynamodbstreams.GetShardIteratorOutput{ShardIterator: s.iterator(shard, position)}, nil
+}
+
+func (s *MemoryStream) GetRecords(ctx context.Context, params *dynamodbstreams.GetRecordsInput, optFns ...func(*dynamodbstreams.Options)) (*dynamodbstreams.GetRecordsOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var (
+		epoch, position int
+		shardID         string
+	)
+	_, err := fmt.Sscanf(aws.ToString(params.ShardIterator), "%d/%d/%s", &epoch, &position, &shardID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid shard iterator: %w", err)
+	}
+
+	if epoch != s.epoch {
+		return nil, &types.ExpiredIteratorException{Message: aws.String("iterator expired")}
+	}
+
+	shard := s.shard(shardID)
+	if shard == nil {
+		return nil, &types.ResourceNotFoundException{Message: aws.String("shard not found")}
+	}
+
+	records := append([]types.Record(nil), shard.records[position:]...)
+
+	// a closed shard that was read to its end has no next iterator
+	var next *string
+	if !shard.closed {
+		next = s.iterator(shard, len(shard.records))
+	}
+
+	return &dynamodbstreams.GetRecordsOutput{Records: records, NextShardIterator: next}, nil
+}
+
+func (s *MemoryStream) shard(id string) *memoryShard {
+	for _, shard := range s.shards {
+		if shard.id == id {
+			return shard
+		}
+	}
+
+	return nil
+}
+
+func (s *MemoryStream) iterator(shard *memoryShard, position int) *string {
+	return aws.String(fmt.Sprintf("%d/%d/%s", s.epoch, position, shard.id))
+}
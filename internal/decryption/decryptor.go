@@ -3,8 +3,14 @@ package decryption
 import (
 	"crypto/aes"
 	"crypto/cipher"
+	"errors"
+	"fmt"
 )
 
+// ErrDecrypt is returned when the ciphertext cannot be opened with the key,
+// either because the key is wrong or the ciphertext is corrupted.
+var ErrDecrypt = errors.New("unable to decrypt ciphertext")
+
 func Decrypt(ciphertext, secretKey string) (string, error) {
 	block, err := aes.NewCipher([]byte(secretKey))
 	if err != nil {
@@ -17,11 +23,15 @@ func Decrypt(ciphertext, secretKey string) (string, error) {
 	}
 
 	nonceSize := gcm.NonceSize()
+	if len(ciphertext) < nonceSize {
+		return "", fmt.Errorf("%w: ciphertext too short", ErrDecrypt)
+	}
+
 	nonce, ciphertext := ciphertext[:nonceSize], ciphertext[nonceSize:]
 
 	plaintext, err := gcm.Open(nil, []byte(nonce), []byte(ciphertext), nil)
 	if err != nil {
-		panic(err)
+		return "", fmt.Errorf("%w: %v", ErrDecrypt, err)
 	}
 
 	return string(plaintext), nil
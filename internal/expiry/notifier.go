@@ -0,0 +1,153 @@
+// Package expiry reports entries whose password expired, so rotations are not
+// missed.
+package expiry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"personal-vault/internal/db"
+	"time"
+)
+
+// EventExpired is the event name of the webhook payload.
+const EventExpired = "entry.expired"
+
+// Store is implemented by db.DynamoDBClient.
+type Store interface {
+	ExpiringItems(ctx context.Context, before time.Time) ([]db.VaultMetadata, error)
+	ExpiryCheckpoint(ctx context.Context) (time.Time, error)
+	PutExpiryCheckpoint(ctx context.Context, previous, until time.Time) error
+}
+
+// Event is logged and POSTed as JSON to the webhook for every entry that
+// crossed its expiry since the previous check.
+type Event struct {
+	Event     string    `json:"event"`
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Notifier checks for expired entries on every tick. The first check of a
+// vault reports every entry that is already expired, later checks only the
+// entries whose expiry passed since the checkpoint in Store. Restarted and
+// other instances continue from the same checkpoint.
+type Notifier struct {
+	Store    Store
+	Interval time.Duration
+	// WebhookURL is optional.
+	WebhookURL string
+	HTTPClient *http.Client
+}
+
+// Run checks immediately and then on every interval until ctx is done.
+func (n *Notifier) Run(ctx context.Context) {
+	ticker := time.NewTicker(n.Interval)
+	defer ticker.Stop()
+
+	for {
+		err := n.Check(ctx, time.Now())
+		if err != nil {
+			slog.ErrorContext(ctx, "unable to check expiring entries", slog.Any("error", err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check reports the entries that expired after the checkpoint and not after
+// now, and then moves the checkpoint to now. When a webhook fails the same
+// period is checked again next time, so delivery is at least once; two
+// instances checking at the same time may both report an entry.
+func (n *Notifier) Check(ctx context.Context, now time.Time) error {
+	last, err := n.Store.ExpiryCheckpoint(ctx)
+	if err != nil {
+		return err
+	}
+
+	// another instance already checked past now
+	if !now.After(last) {
+		return nil
+	}
+
+	items, err := n.Store.ExpiringItems(ctx, now)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, item := range items {
+		if !item.ExpiresAt.After(last) {
+			continue
+		}
+
+		event := Event{Event: EventExpired, ID: item.ID, Name: item.Name, ExpiresAt: *item.ExpiresAt}
+
+		slog.WarnContext(ctx, "entry expired",
+			slog.String("event", event.Event),
+			slog.String("id", event.ID),
+			slog.String("name", event.Name),
+			slog.Time("expires_at", event.ExpiresAt))
+
+		err = n.post(ctx, event)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("webhook for %s: %w", item.ID, err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	// a conflict means another instance reported the same period
+	err = n.Store.PutExpiryCheckpoint(ctx, last, now)
+	if errors.Is(err, db.ErrConflict) {
+		return nil
+	}
+
+	return err
+}
+
+func (n *Notifier) post(ctx context.Context, event Event) error {
+	if n.WebhookURL == "" {
+		return nil
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	client := n.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,138 @@
+package expiry
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"personal-vault/internal/db"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type storeMock struct {
+	items []db.VaultMetadata
+
+	mu         sync.Mutex
+	checkpoint time.Time
+}
+
+func (m *storeMock) ExpiryCheckpoint(ctx context.Context) (time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.checkpoint, nil
+}
+
+func (m *storeMock) PutExpiryCheckpoint(ctx context.Context, previous, until time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.checkpoint.Equal(previous) {
+		return db.ErrConflict
+	}
+
+	m.checkpoint = until
+
+	return nil
+}
+
+func (m *storeMock) ExpiringItems(ctx context.Context, before time.Time) ([]db.VaultMetadata, error) {
+	var expiring []db.VaultMetadata
+	for _, item := range m.items {
+		if !item.ExpiresAt.After(before) {
+			expiring = append(expiring, item)
+		}
+	}
+
+	return expiring, nil
+}
+
+func TestNotifier_Check(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		t := start.Add(d)
+		return &t
+	}
+
+	var (
+		mu     sync.Mutex
+		events []Event
+		fail   bool
+	)
+
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+		if fail {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+
+		var event Event
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&event))
+		events = append(events, event)
+	}))
+	defer webhook.Close()
+
+	store := &storeMock{items: []db.VaultMetadata{
+		{ID: "001", Name: "old", ExpiresAt: at(-time.Hour)},
+		{ID: "002", Name: "soon", ExpiresAt: at(30 * time.Minute)},
+		{ID: "003", Name: "later", ExpiresAt: at(90 * time.Minute)},
+	}}
+	notifier := &Notifier{Store: store, WebhookURL: webhook.URL}
+
+	received := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+
+		var ids []string
+		for _, event := range events {
+			assert.Equal(t, EventExpired, event.Event)
+			ids = append(ids, event.ID)
+		}
+		events = nil
+
+		return ids
+	}
+
+	// the first check reports what is already expired
+	assert.NoError(t, notifier.Check(context.Background(), start))
+	assert.Equal(t, []string{"001"}, received())
+
+	// nothing crossed its expiry since
+	assert.NoError(t, notifier.Check(context.Background(), start.Add(10*time.Minute)))
+	assert.Empty(t, received())
+
+	// a restarted or another instance continues from the stored checkpoint
+	notifier = &Notifier{Store: store, WebhookURL: webhook.URL}
+	assert.NoError(t, notifier.Check(context.Background(), start.Add(20*time.Minute)))
+	assert.Empty(t, received())
+
+	// and never moves it back
+	assert.NoError(t, notifier.Check(context.Background(), start.Add(15*time.Minute)))
+	assert.Equal(t, start.Add(20*time.Minute), store.checkpoint)
+
+	assert.NoError(t, notifier.Check(context.Background(), start.Add(time.Hour)))
+	assert.Equal(t, []string{"002"}, received())
+
+	// a failed webhook is retried on the next check
+	mu.Lock()
+	fail = true
+	mu.Unlock()
+	assert.Error(t, notifier.Check(context.Background(), start.Add(2*time.Hour)))
+
+	mu.Lock()
+	fail = false
+	mu.Unlock()
+	assert.NoError(t, notifier.Check(context.Background(), start.Add(3*time.Hour)))
+	assert.Equal(t, []string{"003"}, received())
+}
@@ -0,0 +1,220 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"personal-vault/internal/apierror"
+	"personal-vault/internal/db"
+	"personal-vault/internal/decryption"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// CollectionEntryRequest is the body of POST /collections/:id/entries.
+type CollectionEntryRequest struct {
+	Name        string `json:"name" validate:"required"`
+	Description string `json:"description"`
+	Password    string `json:"password" validate:"required"`
+}
+
+// CollectionEntryResponse describes an entry of a collection. Password is only
+// set when a single entry is read.
+type CollectionEntryResponse struct {
+	ID           string    `json:"id"`
+	CollectionID string    `json:"collection_id"`
+	Name         string    `json:"name"`
+	Description  string    `json:"description"`
+	Password     string    `json:"password,omitempty"`
+	KeyVersion   int       `json:"key_version"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+func collectionEntryResponse(entry db.CollectionEntry) CollectionEntryResponse {
+	return CollectionEntryResponse{
+		ID:           entry.ID,
+		CollectionID: entry.CollectionID,
+		Name:         entry.Name,
+		Description:  entry.Description,
+		KeyVersion:   entry.KeyVersion,
+		CreatedAt:    entry.CreatedAt,
+		UpdatedAt:    entry.UpdatedAt,
+	}
+}
+
+// CreateEntry stores an entry encrypted under the collection key.
+func (h CollectionHandler) CreateEntry(c *gin.Context) {
+	slog.DebugContext(c, "enter create collection entry")
+
+	if h.ClientSide {
+		respondClientSideCollection(c)
+		return
+	}
+
+	collection, ok := h.open(c)
+	if !ok {
+		return
+	}
+
+	var request CollectionEntryRequest
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		slog.WarnContext(c, "unable to bind request", slog.Any("error", err))
+		apierror.Respond(c, apierror.BadRequest("request body must be valid JSON").Wrap(err))
+		return
+	}
+
+	err := h.Validate.Struct(request)
+	if err != nil {
+		slog.WarnContext(c, "request validation failed", slog.Any("error", err))
+		apierror.Respond(c, apierror.Validation(err))
+		return
+	}
+
+	version := collection.writeVersion()
+
+	password, err := sealPassword(request.Password, collection.keys[version])
+	if err != nil {
+		slog.ErrorContext(c, "unable to encrypt password", slog.Any("error", err))
+		apierror.Respond(c, err)
+		return
+	}
+
+	entry, err := h.Client.PutCollectionEntry(c, db.CollectionEntry{
+		ID:           uuid.NewString(),
+		CollectionID: collection.ID,
+		Name:         request.Name,
+		Description:  request.Description,
+		Password:     password,
+		KeyVersion:   version,
+	})
+	if err != nil {
+		slog.ErrorContext(c, "unable to save collection entry", slog.String("collection_id", collection.ID), slog.Any("error", err))
+		apierror.Respond(c, err)
+		return
+	}
+
+	rekeyed, err := h.rekeyedSince(c, collection.ID, entry)
+	if err != nil {
+		slog.ErrorContext(c, "unable to check collection key", slog.String("collection_id", collection.ID), slog.Any("error", err))
+		apierror.Respond(c, err)
+		return
+	}
+	if rekeyed {
+		slog.WarnContext(c, "collection re-keyed while saving entry", slog.String("collection_id", collection.ID))
+		apierror.Respond(c, apierror.New(http.StatusConflict, apierror.CodeConflict, "the collection was re-keyed while the entry was saved, send it again"))
+		return
+	}
+
+	c.Header("Location", "/collections/"+collection.ID+"/entries/"+entry.ID)
+	c.IndentedJSON(http.StatusCreated, collectionEntryResponse(entry))
+}
+
+// rekeyedSince checks a new entry against the collection as it is now. A
+// re-key that started after the collection was opened may have moved the
+// entries before this one was stored, and then drops the key it is under. An
+// entry under a key the collection no longer writes with is removed, unless
+// the re-key moved it after all, and rekeyedSince reports true.
+func (h CollectionHandler) rekeyedSince(c *gin.Context, collectionID string, entry db.CollectionEntry) (bool, error) {
+	current, err := h.Client.GetCollection(c, collectionID)
+	if err != nil {
+		return false, err
+	}
+
+	if (openCollection{Collection: current}).writeVersion() == entry.KeyVersion {
+		return false, nil
+	}
+
+	err = h.Client.DeleteCollectionEntry(c, collectionID, entry.ID, entry.KeyVersion)
+	if errors.Is(err, db.ErrConflict) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// GetEntries lists the entries of a collection, without passwords.
+func (h CollectionHandler) GetEntries(c *gin.Context) {
+	slog.DebugContext(c, "enter get collection entries")
+
+	collection, ok := h.open(c)
+	if !ok {
+		return
+	}
+
+	entries, err := h.Client.ScanCollectionEntries(c, collection.ID)
+	if err != nil {
+		slog.ErrorContext(c, "unable to scan collection entries", slog.String("collection_id", collection.ID), slog.Any("error", err))
+		apierror.Respond(c, err)
+		return
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+
+	responses := make([]CollectionEntryResponse, 0, len(entries))
+	for _, entry := range entries {
+		responses = append(responses, collectionEntryResponse(entry))
+	}
+
+	c.IndentedJSON(http.StatusOK, responses)
+}
+
+// GetEntry decrypts an entry of a collection.
+func (h CollectionHandler) GetEntry(c *gin.Context) {
+	slog.DebugContext(c, "enter get collection entry")
+
+	if h.ClientSide {
+		respondClientSideCollection(c)
+		return
+	}
+
+	collection, ok := h.open(c)
+	if !ok {
+		return
+	}
+
+	id := c.Param("entryId")
+
+	if !isValidUUID(id) {
+		slog.WarnContext(c, "invalid id", slog.String("id", id))
+		apierror.Respond(c, apierror.BadRequest("entry id must be a valid UUID"))
+		return
+	}
+
+	entry, err := h.Client.GetCollectionEntry(c, collection.ID, id)
+	if err != nil {
+		slog.ErrorContext(c, "unable to get collection entry", slog.String("collection_id", collection.ID), slog.String("id", id), slog.Any("error", err))
+		apierror.Respond(c, err)
+		return
+	}
+
+	key, ok := collection.keys[entry.KeyVersion]
+	if !ok {
+		err = errors.New("no key for the entry's key version")
+		slog.ErrorContext(c, "unable to decrypt password", slog.String("id", id), slog.Int("key_version", entry.KeyVersion), slog.Any("error", err))
+		apierror.Respond(c, errors.Join(decryption.ErrDecrypt, err))
+		return
+	}
+
+	password, err := openPassword(entry.Password, key)
+	if err != nil {
+		slog.ErrorContext(c, "unable to decrypt password", slog.String("id", id), slog.Any("error", err))
+		apierror.Respond(c, err)
+		return
+	}
+
+	response := collectionEntryResponse(entry)
+	response.Password = password
+
+	c.IndentedJSON(http.StatusOK, response)
+}
+
+// respondClientSideCollection refuses a collection entry before its password
+// is read from the request or decrypted.
+func respondClientSideCollection(c *gin.Context) {
+	apierror.Respond(c, apierror.New(http.StatusConflict, apierror.CodeConflict, "collection entries are not available in client-side encryption mode"))
+}
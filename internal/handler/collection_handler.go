@@ -0,0 +1,502 @@
+package handler
+
+import (
+	b64 "encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"personal-vault/internal/apierror"
+	"personal-vault/internal/db"
+	"personal-vault/internal/decryption"
+	"personal-vault/internal/encryption"
+	"personal-vault/internal/keyring"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+// CollectionHandler serves team collections. Every request is authenticated
+// with the HTTP Basic credentials of a member, whose private key opens the
+// collection key for the duration of the request.
+type CollectionHandler struct {
+	Client   db.DynamoDBClient
+	Validate *validator.Validate
+	// ClientSide vaults refuse to store or reveal collection entries, whose
+	// passwords the server encrypts and decrypts.
+	ClientSide bool
+}
+
+// CollectionRequest is the body of POST /collections.
+type CollectionRequest struct {
+	Name string `json:"name" validate:"required"`
+}
+
+// MemberRequest is the body of POST /collections/:id/members.
+type MemberRequest struct {
+	Name string `json:"name" validate:"required"`
+}
+
+// CollectionResponse describes a collection. RekeyPending is set while a
+// re-key was interrupted, POST /collections/:id/rekey completes it.
+type CollectionResponse struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	Members      []string  `json:"members"`
+	KeyVersion   int       `json:"key_version"`
+	RekeyPending bool      `json:"rekey_pending"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+func collectionResponse(collection db.Collection) CollectionResponse {
+	return CollectionResponse{
+		ID:           collection.ID,
+		Name:         collection.Name,
+		Members:      collection.Members(),
+		KeyVersion:   collection.KeyVersion,
+		RekeyPending: collection.PendingKeys != nil,
+		CreatedAt:    collection.CreatedAt,
+		UpdatedAt:    collection.UpdatedAt,
+	}
+}
+
+// openCollection is a collection with the keys its requesting member
+// unwrapped, by key version.
+type openCollection struct {
+	db.Collection
+	keys map[int][]byte
+}
+
+// writeVersion is the key version new ciphertext is written under. During a
+// re-key it is the pending version, so nothing new is left behind.
+func (o openCollection) writeVersion() int {
+	if o.PendingKeys != nil {
+		return o.KeyVersion + 1
+	}
+
+	return o.KeyVersion
+}
+
+func collectionNotFound() *apierror.Error {
+	return apierror.New(http.StatusNotFound, apierror.CodeNotFound, "collection not found")
+}
+
+// respondCollection maps the errors of collection writes.
+func respondCollection(c *gin.Context, err error) {
+	if errors.Is(err, db.ErrConflict) {
+		apierror.Respond(c, apierror.New(http.StatusConflict, apierror.CodeConflict, "the collection was modified concurrently, retry").Wrap(err))
+		return
+	}
+
+	apierror.Respond(c, err)
+}
+
+// CreateCollection creates a collection with a new key and the requesting
+// user as its only member.
+func (h CollectionHandler) CreateCollection(c *gin.Context) {
+	slog.DebugContext(c, "enter create collection")
+
+	user, _, ok := authenticate(c, h.Client)
+	if !ok {
+		return
+	}
+
+	var request CollectionRequest
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		slog.WarnContext(c, "unable to bind request", slog.Any("error", err))
+		apierror.Respond(c, apierror.BadRequest("request body must be valid JSON").Wrap(err))
+		return
+	}
+
+	err := h.Validate.Struct(request)
+	if err != nil {
+		slog.WarnContext(c, "request validation failed", slog.Any("error", err))
+		apierror.Respond(c, apierror.Validation(err))
+		return
+	}
+
+	key, err := keyring.NewCollectionKey()
+	if err != nil {
+		slog.ErrorContext(c, "unable to generate collection key", slog.Any("error", err))
+		apierror.Respond(c, err)
+		return
+	}
+
+	wrapped, err := keyring.Wrap(key, user.PublicKey)
+	if err != nil {
+		slog.ErrorContext(c, "unable to wrap collection key", slog.String("user", user.Name), slog.Any("error", err))
+		apierror.Respond(c, err)
+		return
+	}
+
+	collection, err := h.Client.CreateCollection(c, db.Collection{
+		ID:         uuid.NewString(),
+		Name:       request.Name,
+		KeyVersion: 1,
+		Keys:       map[string]db.WrappedKey{user.Name: db.WrappedKey(wrapped)},
+	})
+	if err != nil {
+		slog.ErrorContext(c, "unable to save collection", slog.Any("error", err))
+		respondCollection(c, err)
+		return
+	}
+
+	slog.InfoContext(c, "collection created", slog.String("collection_id", collection.ID), slog.String("user", user.Name))
+
+	c.Header("Location", "/collections/"+collection.ID)
+	c.IndentedJSON(http.StatusCreated, collectionResponse(collection))
+}
+
+// GetCollections lists the collections the requesting user is a member of.
+func (h CollectionHandler) GetCollections(c *gin.Context) {
+	slog.DebugContext(c, "enter get collections")
+
+	user, _, ok := authenticate(c, h.Client)
+	if !ok {
+		return
+	}
+
+	collections, err := h.Client.ScanCollections(c)
+	if err != nil {
+		slog.ErrorContext(c, "unable to scan collections", slog.Any("error", err))
+		apierror.Respond(c, err)
+		return
+	}
+
+	responses := []CollectionResponse{}
+	for _, collection := range collections {
+		if _, member := collection.Keys[user.Name]; member {
+			responses = append(responses, collectionResponse(collection))
+		}
+	}
+
+	sort.SliceStable(responses, func(i, j int) bool { return responses[i].Name < responses[j].Name })
+
+	c.IndentedJSON(http.StatusOK, responses)
+}
+
+func (h CollectionHandler) GetCollection(c *gin.Context) {
+	slog.DebugContext(c, "enter get collection")
+
+	collection, ok := h.open(c)
+	if !ok {
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, collectionResponse(collection.Collection))
+}
+
+// AddMember wraps the collection key to another user. Adding a member again
+// is harmless.
+func (h CollectionHandler) AddMember(c *gin.Context) {
+	slog.DebugContext(c, "enter add member")
+
+	collection, ok := h.open(c)
+	if !ok {
+		return
+	}
+
+	var request MemberRequest
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		slog.WarnContext(c, "unable to bind request", slog.Any("error", err))
+		apierror.Respond(c, apierror.BadRequest("request body must be valid JSON").Wrap(err))
+		return
+	}
+
+	err := h.Validate.Struct(request)
+	if err != nil {
+		slog.WarnContext(c, "request validation failed", slog.Any("error", err))
+		apierror.Respond(c, apierror.Validation(err))
+		return
+	}
+
+	member, err := h.Client.GetUser(c, request.Name)
+	if errors.Is(err, db.ErrNotFound) {
+		apierror.Respond(c, apierror.New(http.StatusNotFound, apierror.CodeNotFound, "user not found").Wrap(err))
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(c, "unable to get user", slog.String("user", request.Name), slog.Any("error", err))
+		apierror.Respond(c, err)
+		return
+	}
+
+	err = collection.wrapTo(member)
+	if err != nil {
+		slog.ErrorContext(c, "unable to wrap collection key", slog.String("user", member.Name), slog.Any("error", err))
+		apierror.Respond(c, err)
+		return
+	}
+
+	updated, err := h.Client.UpdateCollection(c, collection.Collection)
+	if err != nil {
+		slog.ErrorContext(c, "unable to update collection", slog.String("collection_id", collection.ID), slog.Any("error", err))
+		respondCollection(c, err)
+		return
+	}
+
+	slog.InfoContext(c, "member added", slog.String("collection_id", collection.ID), slog.String("user", member.Name))
+
+	c.IndentedJSON(http.StatusOK, collectionResponse(updated))
+}
+
+// RemoveMember removes a member and re-keys the collection, so the removed
+// member's copy of the key no longer opens any entry.
+func (h CollectionHandler) RemoveMember(c *gin.Context) {
+	slog.DebugContext(c, "enter remove member")
+
+	collection, ok := h.open(c)
+	if !ok {
+		return
+	}
+
+	name := c.Param("name")
+
+	if _, member := collection.Keys[name]; !member {
+		apierror.Respond(c, apierror.New(http.StatusNotFound, apierror.CodeNotFound, "not a member of the collection"))
+		return
+	}
+
+	if len(collection.Keys) == 1 {
+		apierror.Respond(c, apierror.BadRequest("the last member cannot be removed"))
+		return
+	}
+
+	// a re-key the removed member could have seen is completed first, the
+	// next key is then wrapped to the remaining members only
+	if collection.PendingKeys != nil {
+		err := h.finishRekey(c, &collection)
+		if err != nil {
+			slog.ErrorContext(c, "unable to complete re-key", slog.String("collection_id", collection.ID), slog.Any("error", err))
+			respondCollection(c, err)
+			return
+		}
+	}
+
+	delete(collection.Keys, name)
+
+	err := h.rekey(c, &collection)
+	if err != nil {
+		slog.ErrorContext(c, "unable to re-key collection", slog.String("collection_id", collection.ID), slog.Any("error", err))
+		respondCollection(c, err)
+		return
+	}
+
+	slog.InfoContext(c, "member removed", slog.String("collection_id", collection.ID), slog.String("user", name))
+
+	c.IndentedJSON(http.StatusOK, collectionResponse(collection.Collection))
+}
+
+// Rekey completes an interrupted re-key, or else moves the collection to a
+// new key.
+func (h CollectionHandler) Rekey(c *gin.Context) {
+	slog.DebugContext(c, "enter rekey")
+
+	collection, ok := h.open(c)
+	if !ok {
+		return
+	}
+
+	var err error
+	if collection.PendingKeys != nil {
+		err = h.finishRekey(c, &collection)
+	} else {
+		err = h.rekey(c, &collection)
+	}
+	if err != nil {
+		slog.ErrorContext(c, "unable to re-key collection", slog.String("collection_id", collection.ID), slog.Any("error", err))
+		respondCollection(c, err)
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, collectionResponse(collection.Collection))
+}
+
+// open authenticates the request and opens the collection of the id
+// parameter. A collection the user is not a member of is not found.
+func (h CollectionHandler) open(c *gin.Context) (openCollection, bool) {
+	user, private, ok := authenticate(c, h.Client)
+	if !ok {
+		return openCollection{}, false
+	}
+
+	id := c.Param("id")
+
+	if !isValidUUID(id) {
+		slog.WarnContext(c, "invalid id", slog.String("id", id))
+		apierror.Respond(c, apierror.BadRequest("id must be a valid UUID"))
+		return openCollection{}, false
+	}
+
+	collection, err := h.Client.GetCollection(c, id)
+	if errors.Is(err, db.ErrNotFound) {
+		apierror.Respond(c, collectionNotFound().Wrap(err))
+		return openCollection{}, false
+	}
+	if err != nil {
+		slog.ErrorContext(c, "unable to get collection", slog.String("collection_id", id), slog.Any("error", err))
+		apierror.Respond(c, err)
+		return openCollection{}, false
+	}
+
+	wrapped, member := collection.Keys[user.Name]
+	if !member {
+		slog.WarnContext(c, "not a member", slog.String("collection_id", id), slog.String("user", user.Name))
+		apierror.Respond(c, collectionNotFound())
+		return openCollection{}, false
+	}
+
+	opened := openCollection{Collection: collection, keys: map[int][]byte{}}
+
+	opened.keys[collection.KeyVersion], err = keyring.Unwrap(keyring.WrappedKey(wrapped), private)
+	if err == nil && collection.PendingKeys != nil {
+		opened.keys[collection.KeyVersion+1], err = keyring.Unwrap(keyring.WrappedKey(collection.PendingKeys[user.Name]), private)
+	}
+	if err != nil {
+		slog.ErrorContext(c, "unable to unwrap collection key", slog.String("collection_id", id), slog.String("user", user.Name), slog.Any("error", err))
+		apierror.Respond(c, err)
+		return openCollection{}, false
+	}
+
+	return opened, true
+}
+
+// wrapTo wraps the open keys to user.
+func (o *openCollection) wrapTo(user db.User) error {
+	wrapped, err := keyring.Wrap(o.keys[o.KeyVersion], user.PublicKey)
+	if err != nil {
+		return err
+	}
+
+	o.Keys[user.Name] = db.WrappedKey(wrapped)
+
+	if o.PendingKeys != nil {
+		wrapped, err = keyring.Wrap(o.keys[o.KeyVersion+1], user.PublicKey)
+		if err != nil {
+			return err
+		}
+
+		o.PendingKeys[user.Name] = db.WrappedKey(wrapped)
+	}
+
+	return nil
+}
+
+// rekey wraps a new key to the current members and moves every entry to it.
+// The new key is stored as pending before any entry moves, so an interrupted
+// re-key loses nothing and is completed by finishRekey.
+func (h CollectionHandler) rekey(c *gin.Context, collection *openCollection) error {
+	key, err := keyring.NewCollectionKey()
+	if err != nil {
+		return err
+	}
+
+	collection.keys[collection.KeyVersion+1] = key
+	collection.PendingKeys = map[string]db.WrappedKey{}
+
+	for _, name := range collection.Members() {
+		member, err := h.Client.GetUser(c, name)
+		if err != nil {
+			return fmt.Errorf("member %s: %w", name, err)
+		}
+
+		wrapped, err := keyring.Wrap(key, member.PublicKey)
+		if err != nil {
+			return fmt.Errorf("member %s: %w", name, err)
+		}
+
+		collection.PendingKeys[name] = db.WrappedKey(wrapped)
+	}
+
+	collection.Collection, err = h.Client.UpdateCollection(c, collection.Collection)
+	if err != nil {
+		return err
+	}
+
+	return h.finishRekey(c, collection)
+}
+
+// finishRekey moves the entries still under the current key to the pending
+// key and then makes the pending key current.
+func (h CollectionHandler) finishRekey(c *gin.Context, collection *openCollection) error {
+	from, to := collection.KeyVersion, collection.KeyVersion+1
+
+	entries, err := h.Client.ScanCollectionEntries(c, collection.ID)
+	if err != nil {
+		return err
+	}
+
+	moved := 0
+	for _, entry := range entries {
+		if entry.KeyVersion != from {
+			continue
+		}
+
+		password, err := openPassword(entry.Password, collection.keys[from])
+		if err != nil {
+			return fmt.Errorf("entry %s: %w", entry.ID, err)
+		}
+
+		entry.Password, err = sealPassword(password, collection.keys[to])
+		if err != nil {
+			return fmt.Errorf("entry %s: %w", entry.ID, err)
+		}
+
+		entry.KeyVersion = to
+
+		err = h.Client.RekeyCollectionEntry(c, entry, from)
+		if errors.Is(err, db.ErrConflict) {
+			// moved by a concurrent re-key
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("entry %s: %w", entry.ID, err)
+		}
+
+		moved++
+	}
+
+	collection.Keys = collection.PendingKeys
+	collection.PendingKeys = nil
+	collection.KeyVersion = to
+
+	collection.Collection, err = h.Client.UpdateCollection(c, collection.Collection)
+	if err != nil {
+		return err
+	}
+
+	delete(collection.keys, from)
+
+	slog.InfoContext(c, "collection re-keyed",
+		slog.String("collection_id", collection.ID),
+		slog.Int("key_version", collection.KeyVersion),
+		slog.Int("entries", moved))
+
+	return nil
+}
+
+// sealPassword encrypts a password under a collection key the same way
+// entries are encrypted under the master key.
+func sealPassword(password string, key []byte) (string, error) {
+	encrypted, err := encryption.Encrypt(password, string(key))
+	if err != nil {
+		return "", err
+	}
+
+	return b64.StdEncoding.EncodeToString([]byte(encrypted)), nil
+}
+
+func openPassword(sealed string, key []byte) (string, error) {
+	decoded, err := b64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return "", err
+	}
+
+	return decryption.Decrypt(string(decoded), string(key))
+}
@@ -0,0 +1,253 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"personal-vault/internal/apierror"
+	"personal-vault/internal/db"
+	"personal-vault/internal/dbtest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// entryFailingAPI fails the writes of collection entries while failEntries is
+// set, to interrupt a re-key. beforeNewEntry, when set, runs once before the
+// next new collection entry is stored.
+type entryFailingAPI struct {
+	*dbtest.MemoryAPI
+	failEntries    atomic.Bool
+	beforeNewEntry atomic.Pointer[func()]
+}
+
+func (m *entryFailingAPI) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	condition := aws.ToString(params.ConditionExpression)
+
+	if m.failEntries.Load() && strings.Contains(condition, "key_version") {
+		return nil, errors.New("this is mock error")
+	}
+
+	id, _ := params.Item["id"].(*types.AttributeValueMemberS)
+	if id != nil && strings.HasPrefix(id.Value, "_collection_entry#") && condition == "attribute_not_exists(id)" {
+		if hook := m.beforeNewEntry.Swap(nil); hook != nil {
+			(*hook)()
+		}
+	}
+
+	return m.MemoryAPI.PutItem(ctx, params, optFns...)
+}
+
+func TestCollectionHandler(t *testing.T) {
+	t.Parallel()
+
+	api := &entryFailingAPI{MemoryAPI: dbtest.NewMemoryAPI()}
+	client := db.DynamoDBClient{API: api, TableName: "vault"}
+	validate := NewValidator()
+
+	userHandler := UserHandler{Client: client, Validate: validate, Iterations: 1000}
+	collectionHandler := CollectionHandler{Client: client, Validate: validate}
+
+	router := gin.New()
+	router.GET("/users", userHandler.GetUsers)
+	router.POST("/users", userHandler.CreateUser)
+	router.GET("/collections", collectionHandler.GetCollections)
+	router.POST("/collections", collectionHandler.CreateCollection)
+	router.GET("/collections/:id", collectionHandler.GetCollection)
+	router.POST("/collections/:id/members", collectionHandler.AddMember)
+	router.DELETE("/collections/:id/members/:name", collectionHandler.RemoveMember)
+	router.POST("/collections/:id/rekey", collectionHandler.Rekey)
+	router.GET("/collections/:id/entries", collectionHandler.GetEntries)
+	router.POST("/collections/:id/entries", collectionHandler.CreateEntry)
+	router.GET("/collections/:id/entries/:entryId", collectionHandler.GetEntry)
+
+	passphrases := map[string]string{"alice": "alice passphrase", "bob": "bob passphrase", "carol": "carol passphrase"}
+
+	do := func(method, path, user, body string, out any) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if user != "" {
+			req.SetBasicAuth(user, passphrases[user])
+		}
+
+		router.ServeHTTP(w, req)
+
+		if out != nil && w.Code < http.StatusBadRequest {
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), out), w.Body.String())
+		}
+
+		return w.Code
+	}
+
+	for name, passphrase := range passphrases {
+		assert.Equal(t, http.StatusCreated, do(http.MethodPost, "/users", "", `{"name":"`+name+`","passphrase":"`+passphrase+`"}`, nil))
+	}
+	assert.Equal(t, http.StatusConflict, do(http.MethodPost, "/users", "", `{"name":"alice","passphrase":"another passphrase"}`, nil))
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/users", "", `{"name":"a:b","passphrase":"long enough"}`, nil))
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/users", "", `{"name":"dave","passphrase":"short"}`, nil))
+
+	// only registered users see the others
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/users", "", "", nil))
+
+	var users []UserResponse
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/users", "bob", "", &users))
+	assert.Len(t, users, 3)
+
+	// authentication
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodPost, "/collections", "", `{"name":"team"}`, nil))
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodPost, "/collections", "mallory", `{"name":"team"}`, nil))
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/collections", nil)
+	req.SetBasicAuth("alice", "wrong passphrase")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.NotEmpty(t, w.Header().Get("WWW-Authenticate"))
+
+	var apiErr apierror.Error
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &apiErr))
+	assert.Equal(t, apierror.CodeUnauthorized, apiErr.Code)
+
+	var collection CollectionResponse
+	assert.Equal(t, http.StatusCreated, do(http.MethodPost, "/collections", "alice", `{"name":"team"}`, &collection))
+	assert.Equal(t, []string{"alice"}, collection.Members)
+	assert.Equal(t, 1, collection.KeyVersion)
+
+	path := "/collections/" + collection.ID
+
+	var entry CollectionEntryResponse
+	assert.Equal(t, http.StatusCreated, do(http.MethodPost, path+"/entries", "alice", `{"name":"db","password":"s3cret"}`, &entry))
+	assert.Empty(t, entry.Password)
+
+	// members only
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, path+"/entries/"+entry.ID, "bob", "", nil))
+	assert.Equal(t, http.StatusNotFound, do(http.MethodPost, path+"/members", "bob", `{"name":"bob"}`, nil))
+
+	assert.Equal(t, http.StatusNotFound, do(http.MethodPost, path+"/members", "alice", `{"name":"mallory"}`, nil))
+	assert.Equal(t, http.StatusOK, do(http.MethodPost, path+"/members", "alice", `{"name":"bob"}`, &collection))
+	assert.Equal(t, http.StatusOK, do(http.MethodPost, path+"/members", "bob", `{"name":"carol"}`, &collection))
+	assert.Equal(t, []string{"alice", "bob", "carol"}, collection.Members)
+
+	var read CollectionEntryResponse
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, path+"/entries/"+entry.ID, "bob", "", &read))
+	assert.Equal(t, "s3cret", read.Password)
+
+	var collections []CollectionResponse
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/collections", "carol", "", &collections))
+	assert.Len(t, collections, 1)
+
+	// removing a member re-keys every entry
+	assert.Equal(t, http.StatusOK, do(http.MethodDelete, path+"/members/bob", "alice", "", &collection))
+	assert.Equal(t, []string{"alice", "carol"}, collection.Members)
+	assert.Equal(t, 2, collection.KeyVersion)
+	assert.False(t, collection.RekeyPending)
+
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, path+"/entries/"+entry.ID, "bob", "", nil))
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, path+"/entries/"+entry.ID, "carol", "", &read))
+	assert.Equal(t, "s3cret", read.Password)
+	assert.Equal(t, 2, read.KeyVersion)
+
+	// an interrupted re-key leaves every entry readable and is completed later
+	var second CollectionEntryResponse
+	assert.Equal(t, http.StatusCreated, do(http.MethodPost, path+"/entries", "carol", `{"name":"api","password":"t0ken"}`, &second))
+
+	api.failEntries.Store(true)
+	assert.Equal(t, http.StatusInternalServerError, do(http.MethodPost, path+"/rekey", "alice", "", nil))
+	api.failEntries.Store(false)
+
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, path, "alice", "", &collection))
+	assert.True(t, collection.RekeyPending)
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, path+"/entries/"+second.ID, "carol", "", &read))
+	assert.Equal(t, "t0ken", read.Password)
+
+	var third CollectionEntryResponse
+	assert.Equal(t, http.StatusCreated, do(http.MethodPost, path+"/entries", "carol", `{"name":"ci","password":"k3y"}`, &third))
+	assert.Equal(t, 3, third.KeyVersion, "written under the pending key")
+
+	assert.Equal(t, http.StatusOK, do(http.MethodPost, path+"/rekey", "alice", "", &collection))
+	assert.False(t, collection.RekeyPending)
+	assert.Equal(t, 3, collection.KeyVersion)
+
+	var entries []CollectionEntryResponse
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, path+"/entries", "alice", "", &entries))
+	assert.Len(t, entries, 3)
+	for _, e := range entries {
+		assert.Equal(t, 3, e.KeyVersion, e.Name)
+		assert.Empty(t, e.Password)
+
+		assert.Equal(t, http.StatusOK, do(http.MethodGet, path+"/entries/"+e.ID, "alice", "", &read))
+		assert.NotEmpty(t, read.Password)
+	}
+
+	assert.Equal(t, http.StatusOK, do(http.MethodDelete, path+"/members/carol", "carol", "", &collection))
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodDelete, path+"/members/alice", "alice", "", nil))
+	assert.Equal(t, http.StatusNotFound, do(http.MethodDelete, path+"/members/bob", "alice", "", nil))
+}
+
+// TestCollectionHandler_EntryDuringRekey stores an entry under the key the
+// collection was opened with, after a re-key has moved every entry and
+// dropped that key.
+func TestCollectionHandler_EntryDuringRekey(t *testing.T) {
+	t.Parallel()
+
+	api := &entryFailingAPI{MemoryAPI: dbtest.NewMemoryAPI()}
+	client := db.DynamoDBClient{API: api, TableName: "vault"}
+	validate := NewValidator()
+
+	userHandler := UserHandler{Client: client, Validate: validate, Iterations: 1000}
+	collectionHandler := CollectionHandler{Client: client, Validate: validate}
+
+	router := gin.New()
+	router.POST("/users", userHandler.CreateUser)
+	router.POST("/collections", collectionHandler.CreateCollection)
+	router.POST("/collections/:id/rekey", collectionHandler.Rekey)
+	router.GET("/collections/:id/entries", collectionHandler.GetEntries)
+	router.POST("/collections/:id/entries", collectionHandler.CreateEntry)
+	router.GET("/collections/:id/entries/:entryId", collectionHandler.GetEntry)
+
+	do := func(method, path, body string, out any) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.SetBasicAuth("alice", "alice passphrase")
+
+		router.ServeHTTP(w, req)
+
+		if out != nil && w.Code < http.StatusBadRequest {
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), out), w.Body.String())
+		}
+
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusCreated, do(http.MethodPost, "/users", `{"name":"alice","passphrase":"alice passphrase"}`, nil))
+
+	var collection CollectionResponse
+	assert.Equal(t, http.StatusCreated, do(http.MethodPost, "/collections", `{"name":"team"}`, &collection))
+	path := "/collections/" + collection.ID
+
+	assert.Equal(t, http.StatusCreated, do(http.MethodPost, path+"/entries", `{"name":"db","password":"s3cret"}`, nil))
+
+	rekey := func() {
+		assert.Equal(t, http.StatusOK, do(http.MethodPost, path+"/rekey", "", nil))
+	}
+	api.beforeNewEntry.Store(&rekey)
+
+	assert.Equal(t, http.StatusConflict, do(http.MethodPost, path+"/entries", `{"name":"api","password":"t0ken"}`, nil))
+
+	var entries []CollectionEntryResponse
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, path+"/entries", "", &entries))
+	assert.Len(t, entries, 1, "no entry is left under the dropped key")
+
+	var entry, read CollectionEntryResponse
+	assert.Equal(t, http.StatusCreated, do(http.MethodPost, path+"/entries", `{"name":"api","password":"t0ken"}`, &entry))
+	assert.Equal(t, 2, entry.KeyVersion)
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, path+"/entries/"+entry.ID, "", &read))
+	assert.Equal(t, "t0ken", read.Password)
+}
@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+	"personal-vault/internal/apierror"
+	"personal-vault/internal/db"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DeleteHandler moves entries to the trash and back. Retention is how long an
+// entry stays in the trash before it is purged.
+type DeleteHandler struct {
+	Client    db.DynamoDBClient
+	Retention time.Duration
+}
+
+func (h DeleteHandler) DeleteItem(c *gin.Context) {
+	slog.DebugContext(c, "enter delete")
+
+	id := c.Param("id")
+
+	if !isValidUUID(id) {
+		slog.WarnContext(c, "invalid id", slog.String("id", id))
+		apierror.Respond(c, apierror.BadRequest("id must be a valid UUID"))
+		return
+	}
+
+	err := h.Client.DeleteItem(c, id, h.Retention)
+	if err != nil {
+		slog.ErrorContext(c, "unable to delete item", slog.String("id", id), slog.Any("error", err))
+		apierror.Respond(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
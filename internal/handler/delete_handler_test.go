@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"personal-vault/internal/apierror"
+	"personal-vault/internal/db"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeleteHandler_DeleteItem(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name           string
+		testId         string
+		updateItem     func(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+		expectedStatus int
+		expectedCode   string
+	}{
+		{
+			name:   "success case",
+			testId: "6b2bfbc0-8c23-414b-9c39-cf9b76520b39",
+			updateItem: func(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+				return &dynamodb.UpdateItemOutput{}, nil
+			},
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "invalid id case",
+			testId:         "001",
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   apierror.CodeInvalidRequest,
+		},
+		{
+			name:   "not found case",
+			testId: "6b2bfbc0-8c23-414b-9c39-cf9b76520b39",
+			updateItem: func(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+				return nil, &types.ConditionalCheckFailedException{Message: aws.String("mock")}
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedCode:   apierror.CodeNotFound,
+		},
+		{
+			name:   "db error case",
+			testId: "6b2bfbc0-8c23-414b-9c39-cf9b76520b39",
+			updateItem: func(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+				return nil, errors.New("this is mock error")
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedCode:   apierror.CodeInternal,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			dynamdbMockClient := db.DynamoDBClient{
+				API: &dynamoDBMockAPI{
+					updateItem: tt.updateItem,
+				}}
+
+			deleteHandler := DeleteHandler{Client: dynamdbMockClient, Retention: time.Hour}
+
+			w := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(w)
+			ctx.Params = []gin.Param{{Key: "id", Value: tt.testId}}
+
+			deleteHandler.DeleteItem(ctx)
+			ctx.Writer.WriteHeaderNow()
+			assert.Equal(t, tt.expectedStatus, w.Code)
+
+			if tt.expectedCode != "" {
+				var apiErr apierror.Error
+				err := json.Unmarshal(w.Body.Bytes(), &apiErr)
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectedCode, apiErr.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"personal-vault/internal/apierror"
+	"personal-vault/internal/db"
+	"personal-vault/internal/rbac"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultExpiringWithin is used when GET /entries/expiring has no within
+// parameter.
+const DefaultExpiringWithin = "14d"
+
+type ExpiryHandler struct {
+	Client db.DynamoDBClient
+}
+
+// GetExpiring lists the entries that expire within the given period,
+// including those that already expired.
+func (h ExpiryHandler) GetExpiring(c *gin.Context) {
+	slog.DebugContext(c, "enter get expiring")
+
+	within, err := ParseWithin(c.DefaultQuery("within", DefaultExpiringWithin))
+	if err != nil {
+		slog.WarnContext(c, "invalid within", slog.Any("error", err))
+		apierror.Respond(c, apierror.BadRequest("within must be a duration such as 14d or 36h").Wrap(err))
+		return
+	}
+
+	items, err := h.Client.ExpiringItems(c, time.Now().Add(within))
+	if err != nil {
+		slog.ErrorContext(c, "unable to scan items", slog.Any("error", err))
+		apierror.Respond(c, err)
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, rbac.Visible(c, items, metadataName))
+}
+
+// ParseWithin parses a non-negative Go duration, or a number of days such as
+// 14d.
+func ParseWithin(s string) (time.Duration, error) {
+	var within time.Duration
+
+	if days, found := strings.CutSuffix(s, "d"); found {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, err
+		}
+
+		within = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+		within, err = time.ParseDuration(s)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if within < 0 {
+		return 0, errors.New("duration must not be negative")
+	}
+
+	return within, nil
+}
@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"personal-vault/internal/apierror"
+	"personal-vault/internal/db"
+	"testing"
+	"time"
+)
+
+func TestParseWithin(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		input       string
+		expected    time.Duration
+		expectedErr bool
+	}{
+		{input: "14d", expected: 14 * 24 * time.Hour},
+		{input: "0d", expected: 0},
+		{input: "36h", expected: 36 * time.Hour},
+		{input: "-1d", expectedErr: true},
+		{input: "-1h", expectedErr: true},
+		{input: "two weeks", expectedErr: true},
+		{input: "d", expectedErr: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.input, func(t *testing.T) {
+			t.Parallel()
+
+			within, err := ParseWithin(tt.input)
+			if tt.expectedErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, within)
+			}
+		})
+	}
+}
+
+func TestExpiryHandler_GetExpiring(t *testing.T) {
+	t.Parallel()
+
+	soon := time.Now().Add(3 * 24 * time.Hour).UTC().Format(time.RFC3339)
+	later := time.Now().Add(30 * 24 * time.Hour).UTC().Format(time.RFC3339)
+
+	items := []map[string]types.AttributeValue{
+		{"id": &types.AttributeValueMemberS{Value: "001"}, "expires_at": &types.AttributeValueMemberS{Value: later}},
+		{"id": &types.AttributeValueMemberS{Value: "002"}, "expires_at": &types.AttributeValueMemberS{Value: soon}},
+		{"id": &types.AttributeValueMemberS{Value: "003"}},
+	}
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expectedIDs    []string
+	}{
+		{
+			name:           "default period",
+			expectedStatus: http.StatusOK,
+			expectedIDs:    []string{"002"},
+		},
+		{
+			name:           "longer period, soonest first",
+			query:          "?within=60d",
+			expectedStatus: http.StatusOK,
+			expectedIDs:    []string{"002", "001"},
+		},
+		{
+			name:           "nothing expiring",
+			query:          "?within=1h",
+			expectedStatus: http.StatusOK,
+			expectedIDs:    []string{},
+		},
+		{
+			name:           "invalid period",
+			query:          "?within=soon",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			expiryHandler := ExpiryHandler{Client: db.DynamoDBClient{
+				API: &dynamoDBMockAPI{
+					scan: func(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+						return &dynamodb.ScanOutput{Items: items}, nil
+					},
+				}}}
+
+			w := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(w)
+			ctx.Request = httptest.NewRequest(http.MethodGet, "/entries/expiring"+tt.query, nil)
+
+			expiryHandler.GetExpiring(ctx)
+			assert.Equal(t, tt.expectedStatus, w.Code)
+
+			if tt.expectedStatus == http.StatusOK {
+				var responses []db.VaultMetadata
+				err := json.Unmarshal(w.Body.Bytes(), &responses)
+				assert.NoError(t, err)
+
+				ids := []string{}
+				for _, response := range responses {
+					ids = append(ids, response.ID)
+				}
+				assert.Equal(t, tt.expectedIDs, ids)
+			} else {
+				var apiErr apierror.Error
+				err := json.Unmarshal(w.Body.Bytes(), &apiErr)
+				assert.NoError(t, err)
+				assert.Equal(t, apierror.CodeInvalidRequest, apiErr.Code)
+			}
+		})
+	}
+}
@@ -2,69 +2,210 @@ package handler
 
 import (
 	b64 "encoding/base64"
+	"encoding/json"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"log/slog"
 	"net/http"
+	"personal-vault/internal/apierror"
 	"personal-vault/internal/db"
 	"personal-vault/internal/decryption"
+	"personal-vault/internal/metrics"
+	"personal-vault/internal/rbac"
+	"personal-vault/internal/tracing"
+	"personal-vault/pkg/clientcrypto"
+	"sort"
+	"strings"
+	"time"
 )
 
+// RetrieveHandler decrypts passwords with Key. With ClientSide set it never
+// decrypts and only returns the secrets sealed by clients.
 type RetrieveHandler struct {
-	Client db.DynamoDBClient
-	Key    string
+	Client     db.DynamoDBClient
+	Key        string
+	ClientSide bool
+}
+
+// EntryResponse is the JSON representation of GET /retrieve/:id, returned
+// when the client accepts application/json. The access stats do not include
+// the current read. An entry sealed by the client has a secret instead of a
+// password, and is always returned as JSON.
+type EntryResponse struct {
+	ID             string               `json:"id"`
+	Name           string               `json:"name"`
+	Description    string               `json:"description"`
+	Password       string               `json:"password,omitempty"`
+	Secret         *clientcrypto.Secret `json:"secret,omitempty"`
+	CreatedAt      time.Time            `json:"created_at"`
+	UpdatedAt      time.Time            `json:"updated_at"`
+	LastAccessedAt *time.Time           `json:"last_accessed_at"`
+	AccessCount    int                  `json:"access_count"`
+	ExpiresAt      *time.Time           `json:"expires_at"`
+	RotationDays   int                  `json:"rotation_days"`
+	Version        int                  `json:"version"`
+}
+
+// listSorts are the orderings accepted by GET /retrieve/all?sort=. Entries
+// never accessed sort as the least recently accessed.
+var listSorts = map[string]func(a, b db.VaultMetadata) bool{
+	"name":       func(a, b db.VaultMetadata) bool { return a.Name < b.Name },
+	"created_at": func(a, b db.VaultMetadata) bool { return a.CreatedAt.Before(b.CreatedAt) },
+	"updated_at": func(a, b db.VaultMetadata) bool { return a.UpdatedAt.Before(b.UpdatedAt) },
+	"last_accessed_at": func(a, b db.VaultMetadata) bool {
+		return b.LastAccessedAt != nil && (a.LastAccessedAt == nil || a.LastAccessedAt.Before(*b.LastAccessedAt))
+	},
+	"access_count": func(a, b db.VaultMetadata) bool { return a.AccessCount < b.AccessCount },
+}
+
+// ListSortKeys returns the values accepted by the sort query parameter.
+func ListSortKeys() []string {
+	keys := make([]string, 0, len(listSorts))
+	for key := range listSorts {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
 }
 
 func (h RetrieveHandler) GetAll(c *gin.Context) {
-	slog.Info("enter get all")
+	defer tracing.Handler(c, "RetrieveHandler.GetAll")()
+	slog.DebugContext(c, "enter get all")
+
+	sortKey := c.Query("sort")
+	less, ok := listSorts[sortKey]
+	if sortKey != "" && !ok {
+		apierror.Respond(c, apierror.BadRequest("sort must be one of "+strings.Join(ListSortKeys(), ", ")))
+		return
+	}
+
+	order := c.DefaultQuery("order", "asc")
+	if order != "asc" && order != "desc" {
+		apierror.Respond(c, apierror.BadRequest("order must be asc or desc"))
+		return
+	}
 
 	items, err := h.Client.ScanItems(c)
 	if err != nil {
-		slog.Error("error", err)
-		c.JSON(http.StatusInternalServerError, errorMessage)
+		slog.ErrorContext(c, "unable to scan items", slog.Any("error", err))
+		apierror.Respond(c, err)
 		return
 	}
 
+	items = rbac.Visible(c, items, metadataName)
+
+	// without a sort key the scan order is kept
+	if less != nil {
+		sort.SliceStable(items, func(i, j int) bool {
+			if order == "desc" {
+				return less(items[j], items[i])
+			}
+			return less(items[i], items[j])
+		})
+	}
+
 	c.IndentedJSON(http.StatusOK, items)
 
 }
 
 func (h RetrieveHandler) GetByID(c *gin.Context) {
-	slog.Info("enter get by id")
+	defer tracing.Handler(c, "RetrieveHandler.GetByID")()
+	slog.DebugContext(c, "enter get by id")
 
 	id := c.Param("id")
 
 	if !isValidUUID(id) {
-		slog.Error("error", slog.String("validation error", "invalid id"))
-		c.JSON(http.StatusBadRequest, errorMessage)
+		slog.WarnContext(c, "invalid id", slog.String("id", id))
+		apierror.Respond(c, apierror.BadRequest("id must be a valid UUID"))
 		return
 	}
 
-	item, err := h.Client.GetItem(c, id)
+	item, err := h.Client.GetEntity(c, id)
 	if err != nil {
-		slog.Error("error", err)
-		c.JSON(http.StatusInternalServerError, errorMessage)
+		slog.ErrorContext(c, "unable to get item", slog.String("id", id), slog.Any("error", err))
+		apierror.Respond(c, err)
 		return
 	}
 
-	decodedPassword, err := b64.StdEncoding.DecodeString(item)
+	if item.Encryption == db.EncryptionClient {
+		var secret clientcrypto.Secret
+
+		err = json.Unmarshal([]byte(item.Password), &secret)
+		if err != nil {
+			slog.ErrorContext(c, "unable to decode secret", slog.String("id", id), slog.Any("error", err))
+			apierror.Respond(c, err)
+			return
+		}
+
+		h.Client.RecordAccessAsync(c.Request.Context(), id)
+
+		response := entryResponse(item)
+		response.Secret = &secret
+		c.IndentedJSON(http.StatusOK, response)
+		return
+	}
+
+	if h.ClientSide {
+		slog.WarnContext(c, "entry is encrypted with the server key", slog.String("id", id))
+		apierror.Respond(c, apierror.New(http.StatusConflict, apierror.CodeConflict, "the entry is encrypted with the server key, which this vault does not use"))
+		return
+	}
+
+	decodedPassword, err := b64.StdEncoding.DecodeString(item.Password)
 	if err != nil {
-		slog.Error("error", err)
-		c.JSON(http.StatusInternalServerError, errorMessage)
+		slog.ErrorContext(c, "unable to decode password", slog.String("id", id), slog.Any("error", err))
+		apierror.Respond(c, err)
 		return
 	}
 
+	_, span := tracing.Start(c, "decrypt")
+	start := time.Now()
 	password, err := decryption.Decrypt(string(decodedPassword), h.Key)
+	metrics.ObserveCrypto("decrypt", time.Since(start), err)
+	tracing.End(span, err)
 	if err != nil {
-		slog.Error("error", err)
-		c.JSON(http.StatusInternalServerError, errorMessage)
+		slog.ErrorContext(c, "unable to decrypt password", slog.String("id", id), slog.Any("error", err))
+		apierror.Respond(c, err)
 		return
 	}
 
-	c.String(http.StatusOK, password)
+	h.Client.RecordAccessAsync(c.Request.Context(), id)
+
+	// text/plain stays the default so existing clients keep getting the bare
+	// password
+	switch c.NegotiateFormat(gin.MIMEPlain, gin.MIMEJSON) {
+	case gin.MIMEJSON:
+		response := entryResponse(item)
+		response.Password = password
+		c.IndentedJSON(http.StatusOK, response)
+	default:
+		c.String(http.StatusOK, password)
+	}
+}
+
+// entryResponse returns the fields of an entry other than its password.
+func entryResponse(item db.VaultEntity) EntryResponse {
+	return EntryResponse{
+		ID:             item.ID,
+		Name:           item.Name,
+		Description:    item.Description,
+		CreatedAt:      item.CreatedAt,
+		UpdatedAt:      item.UpdatedAt,
+		LastAccessedAt: item.LastAccessedAt,
+		AccessCount:    item.AccessCount,
+		ExpiresAt:      item.ExpiresAt,
+		RotationDays:   item.RotationDays,
+		Version:        item.Version,
+	}
 }
 
 func isValidUUID(u string) bool {
 	_, err := uuid.Parse(u)
 	return err == nil
 }
+
+func metadataName(item db.VaultMetadata) string {
+	return item.Name
+}
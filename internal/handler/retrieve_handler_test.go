@@ -5,6 +5,7 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/gin-gonic/gin"
@@ -12,14 +13,19 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"personal-vault/internal/apierror"
 	"personal-vault/internal/db"
 	"testing"
+	"time"
 )
 
 type dynamoDBMockAPI struct {
-	getItem func(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
-	putItem func(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
-	scan    func(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+	getItem       func(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	putItem       func(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	updateItem    func(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+	scan          func(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+	deleteItem    func(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+	describeTable func(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error)
 }
 
 func (m *dynamoDBMockAPI) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
@@ -30,10 +36,22 @@ func (m *dynamoDBMockAPI) PutItem(ctx context.Context, params *dynamodb.PutItemI
 	return m.putItem(ctx, params, optFns...)
 }
 
+func (m *dynamoDBMockAPI) UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	return m.updateItem(ctx, params, optFns...)
+}
+
 func (m *dynamoDBMockAPI) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
 	return m.scan(ctx, params, optFns...)
 }
 
+func (m *dynamoDBMockAPI) DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	return m.deleteItem(ctx, params, optFns...)
+}
+
+func (m *dynamoDBMockAPI) DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error) {
+	return m.describeTable(ctx, params, optFns...)
+}
+
 func TestRetrieveHandler_GetAll(t *testing.T) {
 	t.Parallel()
 
@@ -117,22 +135,151 @@ func TestRetrieveHandler_GetAll(t *testing.T) {
 	}
 }
 
+func TestRetrieveHandler_GetAll_Sort(t *testing.T) {
+	t.Parallel()
+
+	items := []map[string]types.AttributeValue{
+		{
+			"id":               &types.AttributeValueMemberS{Value: "001"},
+			"name":             &types.AttributeValueMemberS{Value: "b"},
+			"created_at":       &types.AttributeValueMemberS{Value: "2024-05-01T10:00:00Z"},
+			"last_accessed_at": &types.AttributeValueMemberS{Value: "2024-05-03T10:00:00Z"},
+			"access_count":     &types.AttributeValueMemberN{Value: "5"},
+		},
+		{
+			"id":           &types.AttributeValueMemberS{Value: "002"},
+			"name":         &types.AttributeValueMemberS{Value: "c"},
+			"created_at":   &types.AttributeValueMemberS{Value: "2024-05-02T10:00:00Z"},
+			"access_count": &types.AttributeValueMemberN{Value: "0"},
+		},
+		{
+			"id":               &types.AttributeValueMemberS{Value: "003"},
+			"name":             &types.AttributeValueMemberS{Value: "a"},
+			"created_at":       &types.AttributeValueMemberS{Value: "2024-04-01T10:00:00Z"},
+			"last_accessed_at": &types.AttributeValueMemberS{Value: "2024-05-04T10:00:00Z"},
+			"access_count":     &types.AttributeValueMemberN{Value: "2"},
+		},
+	}
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expectedIDs    []string
+	}{
+		{
+			name:           "scan order by default",
+			query:          "",
+			expectedStatus: http.StatusOK,
+			expectedIDs:    []string{"001", "002", "003"},
+		},
+		{
+			name:           "name",
+			query:          "?sort=name",
+			expectedStatus: http.StatusOK,
+			expectedIDs:    []string{"003", "001", "002"},
+		},
+		{
+			name:           "newest first",
+			query:          "?sort=created_at&order=desc",
+			expectedStatus: http.StatusOK,
+			expectedIDs:    []string{"002", "001", "003"},
+		},
+		{
+			name:           "never accessed first",
+			query:          "?sort=last_accessed_at",
+			expectedStatus: http.StatusOK,
+			expectedIDs:    []string{"002", "001", "003"},
+		},
+		{
+			name:           "most used first",
+			query:          "?sort=access_count&order=desc",
+			expectedStatus: http.StatusOK,
+			expectedIDs:    []string{"001", "003", "002"},
+		},
+		{
+			name:           "unknown sort",
+			query:          "?sort=password",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "unknown order",
+			query:          "?sort=name&order=up",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			dynamdbMockClient := db.DynamoDBClient{
+				API: &dynamoDBMockAPI{
+					scan: func(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+						return &dynamodb.ScanOutput{Items: items}, nil
+					},
+				}}
+
+			retrieveHandler := RetrieveHandler{Client: dynamdbMockClient}
+
+			w := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(w)
+			ctx.Request = httptest.NewRequest(http.MethodGet, "/retrieve/all"+tt.query, nil)
+
+			retrieveHandler.GetAll(ctx)
+			assert.Equal(t, tt.expectedStatus, w.Code)
+
+			if tt.expectedStatus == http.StatusOK {
+				var responses []db.VaultMetadata
+				err := json.Unmarshal(w.Body.Bytes(), &responses)
+				assert.NoError(t, err)
+
+				var ids []string
+				for _, response := range responses {
+					ids = append(ids, response.ID)
+				}
+				assert.Equal(t, tt.expectedIDs, ids)
+
+				// the keys stay the Go names existing clients read
+				var fields []map[string]any
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &fields))
+				var names []string
+				for name := range fields[0] {
+					names = append(names, name)
+				}
+				assert.ElementsMatch(t, []string{
+					"ID", "Name", "CreatedAt", "UpdatedAt", "LastAccessedAt", "AccessCount", "ExpiresAt", "RotationDays", "DeletedAt",
+				}, names)
+			}
+		})
+	}
+}
+
 func TestRetrieveHandler_GetByID(t *testing.T) {
 	t.Parallel()
 
 	item := map[string]types.AttributeValue{
-		"ID":          &types.AttributeValueMemberS{Value: "001"},
-		"Name":        &types.AttributeValueMemberS{Value: "TestName"},
-		"Description": &types.AttributeValueMemberS{Value: "TestDescr."},
-		"Password":    &types.AttributeValueMemberS{Value: "gA8vgNGMxa3W0M0t7059MhLqYruaVgFRaVzuGcTAIXzIhY2mKAVqbw=="},
+		"ID":           &types.AttributeValueMemberS{Value: "001"},
+		"Name":         &types.AttributeValueMemberS{Value: "TestName"},
+		"Description":  &types.AttributeValueMemberS{Value: "TestDescr."},
+		"Password":     &types.AttributeValueMemberS{Value: "gA8vgNGMxa3W0M0t7059MhLqYruaVgFRaVzuGcTAIXzIhY2mKAVqbw=="},
+		"created_at":   &types.AttributeValueMemberS{Value: "2024-05-01T10:00:00Z"},
+		"updated_at":   &types.AttributeValueMemberS{Value: "2024-05-02T10:00:00Z"},
+		"access_count": &types.AttributeValueMemberN{Value: "4"},
+		"version":      &types.AttributeValueMemberN{Value: "3"},
 	}
 
 	tests := []struct {
-		name             string
-		testId           string
-		getItem          func(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
-		expectedStatus   int
-		expectedResponse string
+		name                string
+		testId              string
+		accept              string
+		getItem             func(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+		key                 string
+		expectedStatus      int
+		expectedCode        string
+		expectedContentType string
+		expectedResponse    string
 	}{
 		{
 			name:   "success case",
@@ -142,8 +289,47 @@ func TestRetrieveHandler_GetByID(t *testing.T) {
 					Item: item,
 				}, nil
 			},
-			expectedStatus:   http.StatusOK,
-			expectedResponse: "testPassword",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "text/plain; charset=utf-8",
+			expectedResponse:    "testPassword",
+		},
+		{
+			name:   "json success case",
+			testId: "6b2bfbc0-8c23-414b-9c39-cf9b76520b39",
+			accept: "application/json",
+			getItem: func(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+				return &dynamodb.GetItemOutput{
+					Item: item,
+				}, nil
+			},
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/json; charset=utf-8",
+			expectedResponse: `{
+    "id": "001",
+    "name": "TestName",
+    "description": "TestDescr.",
+    "password": "testPassword",
+    "created_at": "2024-05-01T10:00:00Z",
+    "updated_at": "2024-05-02T10:00:00Z",
+    "last_accessed_at": null,
+    "access_count": 4,
+    "expires_at": null,
+    "rotation_days": 0,
+    "version": 3
+}`,
+		},
+		{
+			name:   "wildcard accept case",
+			testId: "6b2bfbc0-8c23-414b-9c39-cf9b76520b39",
+			accept: "*/*",
+			getItem: func(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+				return &dynamodb.GetItemOutput{
+					Item: item,
+				}, nil
+			},
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "text/plain; charset=utf-8",
+			expectedResponse:    "testPassword",
 		},
 		{
 			name:   "invalid id case",
@@ -154,6 +340,7 @@ func TestRetrieveHandler_GetByID(t *testing.T) {
 				}, nil
 			},
 			expectedStatus: http.StatusBadRequest,
+			expectedCode:   apierror.CodeInvalidRequest,
 		},
 		{
 			name:   "db error case",
@@ -162,6 +349,37 @@ func TestRetrieveHandler_GetByID(t *testing.T) {
 				return nil, errors.New("this is mock error")
 			},
 			expectedStatus: http.StatusInternalServerError,
+			expectedCode:   apierror.CodeInternal,
+		},
+		{
+			name:   "not found case",
+			testId: "6b2bfbc0-8c23-414b-9c39-cf9b76520b39",
+			getItem: func(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+				return &dynamodb.GetItemOutput{}, nil
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedCode:   apierror.CodeNotFound,
+		},
+		{
+			name:   "db throttled case",
+			testId: "6b2bfbc0-8c23-414b-9c39-cf9b76520b39",
+			getItem: func(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+				return nil, &types.ProvisionedThroughputExceededException{Message: aws.String("mock throttle")}
+			},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedCode:   apierror.CodeServiceUnavailable,
+		},
+		{
+			name:   "wrong key case",
+			testId: "6b2bfbc0-8c23-414b-9c39-cf9b76520b39",
+			getItem: func(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+				return &dynamodb.GetItemOutput{
+					Item: item,
+				}, nil
+			},
+			key:            "1f6f8edf954592d7523b475bb56fd0486b7a049d67c1e5aa522bbc8bfe961971",
+			expectedStatus: http.StatusInternalServerError,
+			expectedCode:   apierror.CodeDecryptionFailed,
 		},
 	}
 
@@ -170,13 +388,30 @@ func TestRetrieveHandler_GetByID(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
 
+			accessed := make(chan string, 1)
+
 			dynamdbMockClient := db.DynamoDBClient{
 				API: &dynamoDBMockAPI{
-					getItem: tt.getItem,
+					getItem: func(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+						// no access stats were stored yet
+						if db.IsReserved(params.Key["id"].(*types.AttributeValueMemberS).Value) {
+							return &dynamodb.GetItemOutput{}, nil
+						}
+						return tt.getItem(ctx, params, optFns...)
+					},
+					updateItem: func(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+						accessed <- params.Key["id"].(*types.AttributeValueMemberS).Value
+						return &dynamodb.UpdateItemOutput{}, nil
+					},
 				}}
 
+			hexKey := tt.key
+			if hexKey == "" {
+				hexKey = "0f6f8edf954592d7523b475bb56fd0486b7a049d67c1e5aa522bbc8bfe961971"
+			}
+
 			// secret is for testing only
-			secret, err := hex.DecodeString("0f6f8edf954592d7523b475bb56fd0486b7a049d67c1e5aa522bbc8bfe961971")
+			secret, err := hex.DecodeString(hexKey)
 			assert.NoError(t, err)
 
 			key := string(secret)
@@ -194,6 +429,10 @@ func TestRetrieveHandler_GetByID(t *testing.T) {
 			}
 
 			ctx.Params = params
+			ctx.Request = httptest.NewRequest(http.MethodGet, "/retrieve/"+tt.testId, nil)
+			if tt.accept != "" {
+				ctx.Request.Header.Set("Accept", tt.accept)
+			}
 
 			retrieveHandler.GetByID(ctx)
 			assert.Equal(t, tt.expectedStatus, w.Code)
@@ -202,7 +441,21 @@ func TestRetrieveHandler_GetByID(t *testing.T) {
 				body, err := io.ReadAll(w.Body)
 				assert.NoError(t, err)
 
+				assert.Equal(t, tt.expectedContentType, w.Header().Get("Content-Type"))
 				assert.Equal(t, tt.expectedResponse, string(body))
+
+				// access stats are written in the background, next to the entry
+				select {
+				case accessedID := <-accessed:
+					assert.Equal(t, "_access#"+tt.testId, accessedID)
+				case <-time.After(time.Second):
+					t.Error("access was not recorded")
+				}
+			} else {
+				var apiErr apierror.Error
+				err = json.Unmarshal(w.Body.Bytes(), &apiErr)
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectedCode, apiErr.Code)
 			}
 
 		})
@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"personal-vault/internal/apierror"
+	"personal-vault/internal/db"
+	"personal-vault/internal/rbac"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// RoleHandler manages the role assignments. Who may grant what is checked by
+// the rbac middleware in front of it.
+type RoleHandler struct {
+	Client   db.DynamoDBClient
+	Validate *validator.Validate
+}
+
+// RoleRequest is the body of PUT /roles. Roles on the whole vault come only
+// from the admins in the config, so the prefix is required.
+type RoleRequest struct {
+	Principal string `json:"principal" validate:"required,max=64,excludesall=#"`
+	Prefix    string `json:"prefix" validate:"required,max=256"`
+	Role      string `json:"role" validate:"required,oneof=owner editor viewer use"`
+}
+
+type RoleResponse struct {
+	Principal string    `json:"principal"`
+	Prefix    string    `json:"prefix"`
+	Role      string    `json:"role"`
+	GrantedBy string    `json:"granted_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func roleResponse(assignment db.RoleAssignment) RoleResponse {
+	return RoleResponse{
+		Principal: assignment.Principal,
+		Prefix:    assignment.Prefix,
+		Role:      assignment.Role,
+		GrantedBy: assignment.GrantedBy,
+		CreatedAt: assignment.CreatedAt,
+	}
+}
+
+// GetRoles lists the caller's own roles and the roles on the prefixes the
+// caller may grant.
+func (h RoleHandler) GetRoles(c *gin.Context) {
+	slog.DebugContext(c, "enter get roles")
+
+	assignments, err := h.Client.ScanRoleAssignments(c)
+	if err != nil {
+		slog.ErrorContext(c, "unable to scan role assignments", slog.Any("error", err))
+		apierror.Respond(c, err)
+		return
+	}
+
+	principal, restricted := rbac.PrincipalFrom(c)
+
+	responses := make([]RoleResponse, 0, len(assignments))
+	for _, assignment := range assignments {
+		if restricted && assignment.Principal != principal.Name && !principal.Can(rbac.ActionGrant, assignment.Prefix) {
+			continue
+		}
+
+		responses = append(responses, roleResponse(assignment))
+	}
+
+	c.IndentedJSON(http.StatusOK, responses)
+}
+
+// PutRole grants a role, replacing the principal's role on the same prefix.
+func (h RoleHandler) PutRole(c *gin.Context) {
+	slog.DebugContext(c, "enter put role")
+
+	var request RoleRequest
+
+	if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil {
+		slog.WarnContext(c, "unable to bind request", slog.Any("error", err))
+		apierror.Respond(c, apierror.BadRequest("request body must be valid JSON").Wrap(err))
+		return
+	}
+
+	err := h.Validate.Struct(request)
+	if err != nil {
+		slog.WarnContext(c, "request validation failed", slog.Any("error", err))
+		apierror.Respond(c, apierror.Validation(err))
+		return
+	}
+
+	principal, _ := rbac.PrincipalFrom(c)
+
+	assignment, err := h.Client.PutRoleAssignment(c, db.RoleAssignment{
+		Principal: request.Principal,
+		Prefix:    request.Prefix,
+		Role:      request.Role,
+		GrantedBy: principal.Name,
+	})
+	if err != nil {
+		slog.ErrorContext(c, "unable to put role assignment", slog.Any("error", err))
+		apierror.Respond(c, err)
+		return
+	}
+
+	slog.InfoContext(c, "role granted",
+		slog.String("principal", assignment.Principal),
+		slog.String("prefix", assignment.Prefix),
+		slog.String("role", assignment.Role),
+		slog.String("granted_by", assignment.GrantedBy),
+	)
+
+	c.IndentedJSON(http.StatusOK, roleResponse(assignment))
+}
+
+// DeleteRole revokes the role named by the principal and prefix query
+// parameters.
+func (h RoleHandler) DeleteRole(c *gin.Context) {
+	slog.DebugContext(c, "enter delete role")
+
+	name, prefix := c.Query("principal"), c.Query("prefix")
+	if name == "" || prefix == "" {
+		apierror.Respond(c, apierror.BadRequest("principal and prefix are required"))
+		return
+	}
+
+	err := h.Client.DeleteRoleAssignment(c, name, prefix)
+	if errors.Is(err, db.ErrNotFound) {
+		apierror.Respond(c, apierror.New(http.StatusNotFound, apierror.CodeNotFound, "role assignment not found").Wrap(err))
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(c, "unable to delete role assignment", slog.Any("error", err))
+		apierror.Respond(c, err)
+		return
+	}
+
+	principal, _ := rbac.PrincipalFrom(c)
+	slog.InfoContext(c, "role revoked", slog.String("principal", name), slog.String("prefix", prefix), slog.String("revoked_by", principal.Name))
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"personal-vault/internal/apierror"
+	"personal-vault/internal/db"
+	"personal-vault/internal/dbtest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoleHandler(t *testing.T) {
+	t.Parallel()
+
+	client := db.DynamoDBClient{API: dbtest.NewMemoryAPI(), TableName: "vault"}
+	roleHandler := RoleHandler{Client: client, Validate: NewValidator()}
+
+	serve := func(handle gin.HandlerFunc, method, target, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(w)
+		ctx.Request = httptest.NewRequest(method, target, strings.NewReader(body))
+
+		handle(ctx)
+		ctx.Writer.WriteHeaderNow()
+
+		return w
+	}
+
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+		expectedCode   string
+	}{
+		{name: "success case", body: `{"principal":"bob","prefix":"prod/","role":"use"}`, expectedStatus: http.StatusOK},
+		{name: "invalid json case", body: `{`, expectedStatus: http.StatusBadRequest, expectedCode: apierror.CodeInvalidRequest},
+		{name: "unknown role case", body: `{"principal":"bob","prefix":"prod/","role":"admin"}`, expectedStatus: http.StatusBadRequest, expectedCode: apierror.CodeValidationFailed},
+		{name: "whole vault case", body: `{"principal":"bob","prefix":"","role":"owner"}`, expectedStatus: http.StatusBadRequest, expectedCode: apierror.CodeValidationFailed},
+		{name: "invalid principal case", body: `{"principal":"bob#1","prefix":"prod/","role":"owner"}`, expectedStatus: http.StatusBadRequest, expectedCode: apierror.CodeValidationFailed},
+	}
+
+	for _, tt := range tests {
+		w := serve(roleHandler.PutRole, http.MethodPut, "/roles", tt.body)
+		assert.Equal(t, tt.expectedStatus, w.Code, tt.name)
+
+		if tt.expectedCode != "" {
+			var apiErr apierror.Error
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &apiErr))
+			assert.Equal(t, tt.expectedCode, apiErr.Code, tt.name)
+		}
+	}
+
+	w := serve(roleHandler.GetRoles, http.MethodGet, "/roles", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var roles []RoleResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &roles))
+	assert.Len(t, roles, 1)
+	assert.Equal(t, "use", roles[0].Role)
+
+	assignments, err := client.RoleAssignmentsFor(context.Background(), "bob")
+	assert.NoError(t, err)
+	assert.Len(t, assignments, 1)
+
+	w = serve(roleHandler.DeleteRole, http.MethodDelete, "/roles?principal=bob", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = serve(roleHandler.DeleteRole, http.MethodDelete, "/roles?principal=bob&prefix=prod/", "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	w = serve(roleHandler.DeleteRole, http.MethodDelete, "/roles?principal=bob&prefix=prod/", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
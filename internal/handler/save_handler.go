@@ -1,77 +1,152 @@
 package handler
 
 import (
+	"context"
 	b64 "encoding/base64"
-	"fmt"
+	"encoding/json"
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 	"log/slog"
 	"net/http"
+	"personal-vault/internal/apierror"
 	"personal-vault/internal/db"
 	"personal-vault/internal/encryption"
+	"personal-vault/internal/metrics"
+	"personal-vault/internal/tracing"
+	"personal-vault/pkg/clientcrypto"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 )
 
-const errorMessage = "there is error"
-
+// SaveHandler encrypts passwords with Key. With ClientSide set it instead
+// stores the secrets sealed by clients as they are and rejects plaintext.
 type SaveHandler struct {
-	Client   db.DynamoDBClient
-	Validate *validator.Validate
-	Key      string
+	Client     db.DynamoDBClient
+	Validate   *validator.Validate
+	Key        string
+	ClientSide bool
 }
 
+// Request is the body of POST /save. An entry with rotation_days and no
+// expires_at expires rotation_days after it is saved. A vault in client-side
+// mode takes a sealed secret instead of the password.
 type Request struct {
-	Name        string `json:"name" validate:"required"`
-	Description string `json:"description"`
-	Password    string `json:"password" validate:"required"`
+	Name         string               `json:"name" validate:"required"`
+	Description  string               `json:"description"`
+	Password     string               `json:"password" validate:"required"`
+	Secret       *clientcrypto.Secret `json:"secret"`
+	ExpiresAt    *time.Time           `json:"expires_at"`
+	RotationDays int                  `json:"rotation_days" validate:"min=0"`
+}
+
+// SaveResponse is the 201 body of POST /save. The Location header points at
+// the new entry.
+type SaveResponse struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Version   int        `json:"version"`
 }
 
 func (h SaveHandler) AddItem(c *gin.Context) {
-	slog.Info("enter save")
+	defer tracing.Handler(c, "SaveHandler.AddItem")()
+	slog.DebugContext(c, "enter save")
 
 	var request Request
 
-	// call BindJSON to bind the received JSON to request
-	if err := c.BindJSON(&request); err != nil {
-		slog.Error("error", err)
-		c.JSON(http.StatusBadRequest, errorMessage)
+	// bind the received JSON to request, the same way the rbac middleware
+	// did if it read the body first
+	if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil {
+		slog.WarnContext(c, "unable to bind request", slog.Any("error", err))
+		apierror.Respond(c, apierror.BadRequest("request body must be valid JSON").Wrap(err))
 		return
 	}
 
+	// in client-side mode the password is replaced by the secret, which seal
+	// checks
 	err := h.Validate.Struct(request)
+	if h.ClientSide {
+		err = h.Validate.StructExcept(request, "Password")
+	}
 	if err != nil {
-		slog.Error("error", err)
-		c.JSON(http.StatusBadRequest, errorMessage)
+		slog.WarnContext(c, "request validation failed", slog.Any("error", err))
+		apierror.Respond(c, apierror.Validation(err))
 		return
 	}
 
 	id := uuid.NewString()
 
-	encryptedPassword, err := encryption.Encrypt(request.Password, h.Key)
+	password, encryptionMode, err := h.seal(c, request.Password, request.Secret)
 	if err != nil {
-		slog.Error("error", err)
-		c.JSON(http.StatusInternalServerError, errorMessage)
+		slog.WarnContext(c, "unable to seal password", slog.Any("error", err))
+		apierror.Respond(c, err)
 		return
 	}
 
-	encodedPassword := b64.StdEncoding.EncodeToString([]byte(encryptedPassword))
-
 	vaultEntity := db.VaultEntity{
-		ID:          id,
-		Name:        request.Name,
-		Description: request.Description,
-		Password:    encodedPassword,
+		ID:           id,
+		Name:         request.Name,
+		Description:  request.Description,
+		Password:     password,
+		Encryption:   encryptionMode,
+		ExpiresAt:    request.ExpiresAt,
+		RotationDays: request.RotationDays,
 	}
 
-	err = h.Client.PutItem(c, vaultEntity)
+	vaultEntity, err = h.Client.PutItem(c, vaultEntity)
 	if err != nil {
-		slog.Error("error", err)
-		c.JSON(http.StatusInternalServerError, errorMessage)
+		slog.ErrorContext(c, "unable to save item", slog.Any("error", err))
+		apierror.Respond(c, err)
 		return
 	}
 
-	response := fmt.Sprintf("path: %s", id)
+	c.Header("Location", "/retrieve/"+id)
+	c.IndentedJSON(http.StatusCreated, SaveResponse{
+		ID:        vaultEntity.ID,
+		Name:      vaultEntity.Name,
+		CreatedAt: vaultEntity.CreatedAt,
+		ExpiresAt: vaultEntity.ExpiresAt,
+		Version:   vaultEntity.Version,
+	})
+}
+
+// seal returns the stored form of a password, and the db encryption marker
+// that goes with it, in the mode of the vault. Exactly one of password and
+// secret is set.
+func (h SaveHandler) seal(ctx context.Context, password string, secret *clientcrypto.Secret) (string, string, error) {
+	if h.ClientSide {
+		if secret == nil || password != "" {
+			return "", "", apierror.BadRequest("this vault only stores passwords sealed by the client: send secret instead of password")
+		}
+
+		err := secret.Validate()
+		if err != nil {
+			return "", "", apierror.BadRequest("secret is invalid: " + err.Error()).Wrap(err)
+		}
+
+		data, err := json.Marshal(secret)
+		if err != nil {
+			return "", "", err
+		}
+
+		return string(data), db.EncryptionClient, nil
+	}
+
+	if secret != nil {
+		return "", "", apierror.BadRequest("this vault encrypts on the server: send password instead of secret")
+	}
+
+	_, span := tracing.Start(ctx, "encrypt")
+	start := time.Now()
+	encryptedPassword, err := encryption.Encrypt(password, h.Key)
+	metrics.ObserveCrypto("encrypt", time.Since(start), err)
+	tracing.End(span, err)
+	if err != nil {
+		return "", "", err
+	}
 
-	c.IndentedJSON(http.StatusCreated, response)
+	return b64.StdEncoding.EncodeToString([]byte(encryptedPassword)), "", nil
 }
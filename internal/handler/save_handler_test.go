@@ -6,26 +6,29 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/gin-gonic/gin"
-	"github.com/go-playground/validator/v10"
 	"github.com/stretchr/testify/assert"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"personal-vault/internal/apierror"
 	"personal-vault/internal/db"
 	"testing"
+	"time"
 )
 
 func TestSaveHandler_AddItem(t *testing.T) {
 	t.Parallel()
 
 	tests := []struct {
-		name               string
-		requestBody        Request
-		putItem            func(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
-		expectedStatus     int
-		expectedResponseID string
+		name           string
+		requestBody    Request
+		putItem        func(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+		expectedStatus int
+		expectedCode   string
 	}{
 		{
 			name: "success case",
@@ -37,8 +40,7 @@ func TestSaveHandler_AddItem(t *testing.T) {
 			putItem: func(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
 				return &dynamodb.PutItemOutput{}, nil
 			},
-			expectedStatus:     http.StatusCreated,
-			expectedResponseID: "001",
+			expectedStatus: http.StatusCreated,
 		},
 		{
 			name: "validation error case - missing name",
@@ -51,6 +53,7 @@ func TestSaveHandler_AddItem(t *testing.T) {
 				return &dynamodb.PutItemOutput{}, nil
 			},
 			expectedStatus: http.StatusBadRequest,
+			expectedCode:   apierror.CodeValidationFailed,
 		},
 		{
 			name: "validation error case - missing password",
@@ -63,6 +66,7 @@ func TestSaveHandler_AddItem(t *testing.T) {
 				return &dynamodb.PutItemOutput{}, nil
 			},
 			expectedStatus: http.StatusBadRequest,
+			expectedCode:   apierror.CodeValidationFailed,
 		},
 		{
 			name: "db error case",
@@ -75,6 +79,33 @@ func TestSaveHandler_AddItem(t *testing.T) {
 				return nil, errors.New("this is mock error")
 			},
 			expectedStatus: http.StatusInternalServerError,
+			expectedCode:   apierror.CodeInternal,
+		},
+		{
+			name: "db throttled case",
+			requestBody: Request{
+				Name:        "testName",
+				Description: "",
+				Password:    "testPassword",
+			},
+			putItem: func(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+				return nil, &types.ProvisionedThroughputExceededException{Message: aws.String("mock throttle")}
+			},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedCode:   apierror.CodeServiceUnavailable,
+		},
+		{
+			name: "db conflict case",
+			requestBody: Request{
+				Name:        "testName",
+				Description: "",
+				Password:    "testPassword",
+			},
+			putItem: func(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+				return nil, &types.ConditionalCheckFailedException{Message: aws.String("mock conflict")}
+			},
+			expectedStatus: http.StatusConflict,
+			expectedCode:   apierror.CodeConflict,
 		},
 	}
 
@@ -94,7 +125,7 @@ func TestSaveHandler_AddItem(t *testing.T) {
 
 			key := string(secret)
 
-			saveHandler := SaveHandler{Client: dynamdbMockClient, Validate: validator.New(), Key: key}
+			saveHandler := SaveHandler{Client: dynamdbMockClient, Validate: NewValidator(), Key: key}
 
 			w := httptest.NewRecorder()
 			ctx, _ := gin.CreateTestContext(w)
@@ -112,11 +143,43 @@ func TestSaveHandler_AddItem(t *testing.T) {
 			assert.Equal(t, tt.expectedStatus, w.Code)
 
 			if tt.expectedStatus == http.StatusCreated {
-				body, err := io.ReadAll(w.Body)
+				var response SaveResponse
+				err = json.Unmarshal(w.Body.Bytes(), &response)
+				assert.NoError(t, err)
+				assert.True(t, isValidUUID(response.ID))
+				assert.Equal(t, tt.requestBody.Name, response.Name)
+				assert.Equal(t, 1, response.Version)
+				assert.WithinDuration(t, time.Now(), response.CreatedAt, time.Minute)
+				assert.Equal(t, "/retrieve/"+response.ID, w.Header().Get("Location"))
+			} else {
+				var apiErr apierror.Error
+				err = json.Unmarshal(w.Body.Bytes(), &apiErr)
 				assert.NoError(t, err)
-				assert.NotEmpty(t, body)
+				assert.Equal(t, tt.expectedCode, apiErr.Code)
 			}
 
 		})
 	}
 }
+
+func TestSaveHandler_AddItem_ValidationDetails(t *testing.T) {
+	t.Parallel()
+
+	saveHandler := SaveHandler{Validate: NewValidator()}
+
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Request = httptest.NewRequest(http.MethodPost, "/save", bytes.NewBufferString(`{"description":"only"}`))
+
+	saveHandler.AddItem(ctx)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var apiErr apierror.Error
+	err := json.Unmarshal(w.Body.Bytes(), &apiErr)
+	assert.NoError(t, err)
+	assert.Equal(t, apierror.CodeValidationFailed, apiErr.Code)
+	assert.ElementsMatch(t, []apierror.FieldError{
+		{Field: "name", Rule: "required", Message: "name is required"},
+		{Field: "password", Rule: "required", Message: "password is required"},
+	}, apiErr.Details)
+}
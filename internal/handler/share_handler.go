@@ -0,0 +1,250 @@
+package handler
+
+import (
+	"crypto/rand"
+	_ "embed"
+	b64 "encoding/base64"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"personal-vault/internal/apierror"
+	"personal-vault/internal/db"
+	"personal-vault/internal/decryption"
+	"personal-vault/internal/encryption"
+	"personal-vault/internal/metrics"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultShareExpiresIn is used when POST /entries/:id/share has no
+	// expires_in.
+	DefaultShareExpiresIn = "24h"
+	// MaxShareExpiresIn bounds how long a link stays usable.
+	MaxShareExpiresIn = 30 * 24 * time.Hour
+
+	shareKeyLength = 32
+)
+
+//go:embed share_page.html
+var sharePage []byte
+
+// ShareHandler hands out links to a single entry. The password is encrypted
+// under a fresh key that only travels in the fragment of the link, which
+// browsers never send, so the server stores and serves ciphertext it cannot
+// read.
+type ShareHandler struct {
+	Client   db.DynamoDBClient
+	Validate *validator.Validate
+	Key      string
+	// BaseURL prefixes the links. It defaults to the scheme and host of the
+	// request, which is wrong behind a proxy that rewrites the path.
+	BaseURL string
+	// ClientSide vaults cannot create links, the server never holds the
+	// password to encrypt under the link key.
+	ClientSide bool
+}
+
+// ShareRequest is the optional body of POST /entries/:id/share. expires_in
+// is a duration such as 24h or 7d.
+type ShareRequest struct {
+	ExpiresIn string `json:"expires_in"`
+	MaxViews  int    `json:"max_views" validate:"min=0,max=100"`
+}
+
+// ShareResponse is the 201 body of POST /entries/:id/share.
+type ShareResponse struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+	MaxViews  int       `json:"max_views"`
+}
+
+// ShareContent is what a link serves as JSON. Ciphertext is the base64
+// encoded AES-256-GCM nonce and sealed password.
+type ShareContent struct {
+	Ciphertext string    `json:"ciphertext"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	ViewsLeft  int       `json:"views_left"`
+}
+
+// CreateShare encrypts the password of an entry under a new key, stores it
+// for a limited time and number of views and returns the link.
+func (h ShareHandler) CreateShare(c *gin.Context) {
+	slog.DebugContext(c, "enter create share")
+
+	id := c.Param("id")
+
+	if !isValidUUID(id) {
+		slog.WarnContext(c, "invalid id", slog.String("id", id))
+		apierror.Respond(c, apierror.BadRequest("id must be a valid UUID"))
+		return
+	}
+
+	var request ShareRequest
+
+	// the body is optional
+	if err := c.ShouldBindJSON(&request); err != nil && !errors.Is(err, io.EOF) {
+		slog.WarnContext(c, "unable to bind request", slog.Any("error", err))
+		apierror.Respond(c, apierror.BadRequest("request body must be valid JSON").Wrap(err))
+		return
+	}
+
+	err := h.Validate.Struct(request)
+	if err != nil {
+		slog.WarnContext(c, "request validation failed", slog.Any("error", err))
+		apierror.Respond(c, apierror.Validation(err))
+		return
+	}
+
+	if request.ExpiresIn == "" {
+		request.ExpiresIn = DefaultShareExpiresIn
+	}
+
+	expiresIn, err := ParseWithin(request.ExpiresIn)
+	if err != nil || expiresIn == 0 || expiresIn > MaxShareExpiresIn {
+		slog.WarnContext(c, "invalid expires_in", slog.String("expires_in", request.ExpiresIn))
+		apierror.Respond(c, apierror.BadRequest("expires_in must be a duration such as 24h or 7d, at most 30d"))
+		return
+	}
+
+	if request.MaxViews == 0 {
+		request.MaxViews = 1
+	}
+
+	item, err := h.Client.GetEntity(c, id)
+	if err != nil {
+		slog.ErrorContext(c, "unable to get item", slog.String("id", id), slog.Any("error", err))
+		apierror.Respond(c, err)
+		return
+	}
+
+	if h.ClientSide || item.Encryption == db.EncryptionClient {
+		apierror.Respond(c, apierror.New(http.StatusConflict, apierror.CodeConflict, "the server cannot decrypt this entry to share it"))
+		return
+	}
+
+	decodedPassword, err := b64.StdEncoding.DecodeString(item.Password)
+	if err != nil {
+		slog.ErrorContext(c, "unable to decode password", slog.String("id", id), slog.Any("error", err))
+		apierror.Respond(c, err)
+		return
+	}
+
+	start := time.Now()
+	password, err := decryption.Decrypt(string(decodedPassword), h.Key)
+	metrics.ObserveCrypto("decrypt", time.Since(start), err)
+	if err != nil {
+		slog.ErrorContext(c, "unable to decrypt password", slog.String("id", id), slog.Any("error", err))
+		apierror.Respond(c, err)
+		return
+	}
+
+	key := make([]byte, shareKeyLength)
+	_, err = rand.Read(key)
+	if err != nil {
+		slog.ErrorContext(c, "unable to generate share key", slog.Any("error", err))
+		apierror.Respond(c, err)
+		return
+	}
+
+	ciphertext, err := encryption.Encrypt(password, string(key))
+	if err != nil {
+		slog.ErrorContext(c, "unable to encrypt password", slog.Any("error", err))
+		apierror.Respond(c, err)
+		return
+	}
+
+	now := time.Now().UTC()
+	share := db.Share{
+		ID:         uuid.NewString(),
+		EntryID:    id,
+		Ciphertext: b64.StdEncoding.EncodeToString([]byte(ciphertext)),
+		CreatedAt:  now,
+		ExpiresAt:  now.Add(expiresIn),
+		ViewsLeft:  request.MaxViews,
+	}
+
+	err = h.Client.CreateShare(c, share)
+	if err != nil {
+		slog.ErrorContext(c, "unable to save share", slog.String("id", id), slog.Any("error", err))
+		apierror.Respond(c, err)
+		return
+	}
+
+	h.Client.RecordAccessAsync(c.Request.Context(), id)
+
+	slog.InfoContext(c, "entry shared",
+		slog.String("id", id),
+		slog.String("share_id", share.ID),
+		slog.Time("expires_at", share.ExpiresAt),
+		slog.Int("max_views", share.ViewsLeft))
+
+	c.Header("Location", "/s/"+share.ID)
+	c.IndentedJSON(http.StatusCreated, ShareResponse{
+		ID:        share.ID,
+		URL:       h.baseURL(c) + "/s/" + share.ID + "#" + b64.RawURLEncoding.EncodeToString(key),
+		ExpiresAt: share.ExpiresAt,
+		MaxViews:  share.ViewsLeft,
+	})
+}
+
+// GetShare serves a link. Only a request that accepts application/json, as
+// sent by the page itself, uses up a view; anything else, such as a browser
+// or a link preview, gets the page that fetches and decrypts the share.
+func (h ShareHandler) GetShare(c *gin.Context) {
+	slog.DebugContext(c, "enter get share")
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Referrer-Policy", "no-referrer")
+	c.Header("X-Robots-Tag", "noindex")
+
+	shareID := c.Param("shareId")
+
+	if !isValidUUID(shareID) {
+		slog.WarnContext(c, "invalid share id", slog.String("share_id", shareID))
+		apierror.Respond(c, apierror.BadRequest("share id must be a valid UUID"))
+		return
+	}
+
+	if c.NegotiateFormat(gin.MIMEHTML, gin.MIMEJSON) != gin.MIMEJSON {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", sharePage)
+		return
+	}
+
+	share, err := h.Client.ConsumeShare(c, shareID, time.Now())
+	if err != nil {
+		slog.WarnContext(c, "unable to consume share", slog.String("share_id", shareID), slog.Any("error", err))
+		apierror.Respond(c, err)
+		return
+	}
+
+	slog.InfoContext(c, "share viewed",
+		slog.String("id", share.EntryID),
+		slog.String("share_id", shareID),
+		slog.Int("views_left", share.ViewsLeft))
+
+	c.IndentedJSON(http.StatusOK, ShareContent{
+		Ciphertext: share.Ciphertext,
+		ExpiresAt:  share.ExpiresAt,
+		ViewsLeft:  share.ViewsLeft,
+	})
+}
+
+func (h ShareHandler) baseURL(c *gin.Context) string {
+	if h.BaseURL != "" {
+		return strings.TrimRight(h.BaseURL, "/")
+	}
+
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+
+	return scheme + "://" + c.Request.Host
+}
@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	b64 "encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"personal-vault/internal/apierror"
+	"personal-vault/internal/db"
+	"personal-vault/internal/dbtest"
+	"personal-vault/internal/decryption"
+	"personal-vault/internal/encryption"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShareHandler(t *testing.T) {
+	t.Parallel()
+
+	// secret is for testing only
+	secret, err := hex.DecodeString("0f6f8edf954592d7523b475bb56fd0486b7a049d67c1e5aa522bbc8bfe961971")
+	assert.NoError(t, err)
+
+	client := db.DynamoDBClient{API: dbtest.NewMemoryAPI(), TableName: "vault"}
+	shareHandler := ShareHandler{Client: client, Validate: NewValidator(), Key: string(secret)}
+
+	encrypted, err := encryption.Encrypt("s3cret", string(secret))
+	assert.NoError(t, err)
+
+	entity, err := client.PutItem(context.Background(), db.VaultEntity{
+		ID:       "6b2bfbc0-8c23-414b-9c39-cf9b76520b39",
+		Name:     "TestName",
+		Password: b64.StdEncoding.EncodeToString([]byte(encrypted)),
+	})
+	assert.NoError(t, err)
+
+	create := func(id, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(w)
+		ctx.Request = httptest.NewRequest(http.MethodPost, "/entries/"+id+"/share", strings.NewReader(body))
+		ctx.Request.Host = "vault.example.com"
+		ctx.Params = []gin.Param{{Key: "id", Value: id}}
+
+		shareHandler.CreateShare(ctx)
+
+		return w
+	}
+
+	open := func(shareID, accept string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(w)
+		ctx.Request = httptest.NewRequest(http.MethodGet, "/s/"+shareID, nil)
+		ctx.Request.Header.Set("Accept", accept)
+		ctx.Params = []gin.Param{{Key: "shareId", Value: shareID}}
+
+		shareHandler.GetShare(ctx)
+
+		return w
+	}
+
+	t.Run("invalid requests", func(t *testing.T) {
+		tests := []struct {
+			name           string
+			id             string
+			body           string
+			expectedStatus int
+			expectedCode   string
+		}{
+			{name: "invalid id", id: "001", expectedStatus: http.StatusBadRequest, expectedCode: apierror.CodeInvalidRequest},
+			{name: "invalid json", id: entity.ID, body: "{", expectedStatus: http.StatusBadRequest, expectedCode: apierror.CodeInvalidRequest},
+			{name: "too many views", id: entity.ID, body: `{"max_views":1000}`, expectedStatus: http.StatusBadRequest, expectedCode: apierror.CodeValidationFailed},
+			{name: "too long", id: entity.ID, body: `{"expires_in":"90d"}`, expectedStatus: http.StatusBadRequest, expectedCode: apierror.CodeInvalidRequest},
+			{name: "unknown entry", id: "0c4f1a8e-3f0e-4c57-9f0c-5b0f0a1e2d3c", expectedStatus: http.StatusNotFound, expectedCode: apierror.CodeNotFound},
+		}
+
+		for _, tt := range tests {
+			w := create(tt.id, tt.body)
+			assert.Equal(t, tt.expectedStatus, w.Code, tt.name)
+
+			var apiErr apierror.Error
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &apiErr), tt.name)
+			assert.Equal(t, tt.expectedCode, apiErr.Code, tt.name)
+		}
+	})
+
+	t.Run("one view", func(t *testing.T) {
+		w := create(entity.ID, "")
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		var response ShareResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, 1, response.MaxViews)
+		assert.WithinDuration(t, time.Now().Add(24*time.Hour), response.ExpiresAt, time.Minute)
+		assert.Equal(t, "/s/"+response.ID, w.Header().Get("Location"))
+
+		link, err := url.Parse(response.URL)
+		assert.NoError(t, err)
+		assert.Equal(t, "http://vault.example.com/s/"+response.ID, link.Scheme+"://"+link.Host+link.Path)
+
+		// opening the page does not use up the view
+		w = open(response.ID, "text/html,*/*")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+		assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+
+		w = open(response.ID, "application/json")
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var content ShareContent
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &content))
+		assert.Equal(t, 0, content.ViewsLeft)
+
+		// the key from the fragment opens the ciphertext, the vault key does not
+		key, err := b64.RawURLEncoding.DecodeString(link.Fragment)
+		assert.NoError(t, err)
+		ciphertext, err := b64.StdEncoding.DecodeString(content.Ciphertext)
+		assert.NoError(t, err)
+
+		password, err := decryption.Decrypt(string(ciphertext), string(key))
+		assert.NoError(t, err)
+		assert.Equal(t, "s3cret", password)
+
+		_, err = decryption.Decrypt(string(ciphertext), string(secret))
+		assert.Error(t, err)
+
+		w = open(response.ID, "application/json")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("several views", func(t *testing.T) {
+		w := create(entity.ID, `{"expires_in":"7d","max_views":2}`)
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		var response ShareResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.WithinDuration(t, time.Now().Add(7*24*time.Hour), response.ExpiresAt, time.Minute)
+
+		for _, expected := range []int{http.StatusOK, http.StatusOK, http.StatusNotFound} {
+			w = open(response.ID, "application/json")
+			assert.Equal(t, expected, w.Code)
+		}
+	})
+
+	t.Run("base url", func(t *testing.T) {
+		shareHandler := shareHandler
+		shareHandler.BaseURL = "https://vault.example.com/prod/"
+
+		w := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(w)
+		ctx.Request = httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(nil))
+		ctx.Params = []gin.Param{{Key: "id", Value: entity.ID}}
+
+		shareHandler.CreateShare(ctx)
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		var response ShareResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.True(t, strings.HasPrefix(response.URL, "https://vault.example.com/prod/s/"+response.ID+"#"), response.URL)
+	})
+
+	w := open("001", "application/json")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
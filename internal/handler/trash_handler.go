@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+	"personal-vault/internal/apierror"
+	"personal-vault/internal/rbac"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetTrash lists the entries in the trash.
+func (h DeleteHandler) GetTrash(c *gin.Context) {
+	slog.DebugContext(c, "enter get trash")
+
+	items, err := h.Client.ScanTrash(c)
+	if err != nil {
+		slog.ErrorContext(c, "unable to scan trash", slog.Any("error", err))
+		apierror.Respond(c, err)
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, rbac.Visible(c, items, metadataName))
+}
+
+// RestoreItem takes an entry out of the trash and returns its metadata.
+func (h DeleteHandler) RestoreItem(c *gin.Context) {
+	slog.DebugContext(c, "enter restore")
+
+	id := c.Param("id")
+
+	if !isValidUUID(id) {
+		slog.WarnContext(c, "invalid id", slog.String("id", id))
+		apierror.Respond(c, apierror.BadRequest("id must be a valid UUID"))
+		return
+	}
+
+	err := h.Client.RestoreItem(c, id)
+	if err != nil {
+		slog.ErrorContext(c, "unable to restore item", slog.String("id", id), slog.Any("error", err))
+		apierror.Respond(c, err)
+		return
+	}
+
+	vaultEntity, err := h.Client.GetEntity(c, id)
+	if err != nil {
+		slog.ErrorContext(c, "unable to get item", slog.String("id", id), slog.Any("error", err))
+		apierror.Respond(c, err)
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, vaultEntity.Metadata())
+}
@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"personal-vault/internal/apierror"
+	"personal-vault/internal/db"
+	"personal-vault/internal/dbtest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeleteHandler_Trash(t *testing.T) {
+	t.Parallel()
+
+	client := db.DynamoDBClient{API: dbtest.NewMemoryAPI(), TableName: "vault"}
+	deleteHandler := DeleteHandler{Client: client, Retention: time.Hour}
+
+	entity, err := client.PutItem(context.Background(), db.VaultEntity{
+		ID:       "6b2bfbc0-8c23-414b-9c39-cf9b76520b39",
+		Name:     "TestName",
+		Password: "sealed",
+	})
+	assert.NoError(t, err)
+
+	serve := func(handle gin.HandlerFunc, id string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(w)
+		ctx.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+		if id != "" {
+			ctx.Params = []gin.Param{{Key: "id", Value: id}}
+		}
+
+		handle(ctx)
+		ctx.Writer.WriteHeaderNow()
+
+		return w
+	}
+
+	trash := func() []db.VaultMetadata {
+		w := serve(deleteHandler.GetTrash, "")
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var items []db.VaultMetadata
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &items))
+
+		return items
+	}
+
+	assert.Empty(t, trash())
+
+	w := serve(deleteHandler.RestoreItem, entity.ID)
+	assert.Equal(t, http.StatusNotFound, w.Code, "restore of a live entry")
+
+	w = serve(deleteHandler.DeleteItem, entity.ID)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	items := trash()
+	if assert.Len(t, items, 1) {
+		assert.Equal(t, entity.ID, items[0].ID)
+		assert.NotNil(t, items[0].DeletedAt)
+	}
+
+	w = serve(deleteHandler.DeleteItem, entity.ID)
+	assert.Equal(t, http.StatusNotFound, w.Code, "delete of a trashed entry")
+
+	w = serve(deleteHandler.RestoreItem, entity.ID)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var restored db.VaultMetadata
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &restored))
+	assert.Equal(t, entity.ID, restored.ID)
+	assert.Nil(t, restored.DeletedAt)
+	assert.Empty(t, trash())
+
+	w = serve(deleteHandler.RestoreItem, "001")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var apiErr apierror.Error
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &apiErr))
+	assert.Equal(t, apierror.CodeInvalidRequest, apiErr.Code)
+}
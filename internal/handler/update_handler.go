@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+	"personal-vault/internal/apierror"
+	"personal-vault/internal/tracing"
+	"personal-vault/pkg/clientcrypto"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// UpdateRequest changes only the fields that are present. Unless expires_at
+// is given, a new password or rotation interval restarts the rotation period.
+type UpdateRequest struct {
+	Name         *string              `json:"name" validate:"omitempty,min=1"`
+	Description  *string              `json:"description"`
+	Password     *string              `json:"password" validate:"omitempty,min=1"`
+	Secret       *clientcrypto.Secret `json:"secret"`
+	ExpiresAt    *time.Time           `json:"expires_at"`
+	RotationDays *int                 `json:"rotation_days" validate:"omitempty,min=0"`
+}
+
+func (h SaveHandler) UpdateItem(c *gin.Context) {
+	defer tracing.Handler(c, "SaveHandler.UpdateItem")()
+	slog.DebugContext(c, "enter update")
+
+	id := c.Param("id")
+
+	if !isValidUUID(id) {
+		slog.WarnContext(c, "invalid id", slog.String("id", id))
+		apierror.Respond(c, apierror.BadRequest("id must be a valid UUID"))
+		return
+	}
+
+	var request UpdateRequest
+
+	if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil {
+		slog.WarnContext(c, "unable to bind request", slog.Any("error", err))
+		apierror.Respond(c, apierror.BadRequest("request body must be valid JSON").Wrap(err))
+		return
+	}
+
+	err := h.Validate.Struct(request)
+	if err != nil {
+		slog.WarnContext(c, "request validation failed", slog.Any("error", err))
+		apierror.Respond(c, apierror.Validation(err))
+		return
+	}
+
+	newPassword := request.Password != nil || request.Secret != nil
+
+	if request.Name == nil && request.Description == nil && !newPassword &&
+		request.ExpiresAt == nil && request.RotationDays == nil {
+		apierror.Respond(c, apierror.BadRequest("at least one of name, description, password, secret, expires_at or rotation_days is required"))
+		return
+	}
+
+	vaultEntity, err := h.Client.GetEntity(c, id)
+	if err != nil {
+		slog.ErrorContext(c, "unable to get item", slog.String("id", id), slog.Any("error", err))
+		apierror.Respond(c, err)
+		return
+	}
+
+	if request.Name != nil {
+		vaultEntity.Name = *request.Name
+	}
+
+	if request.Description != nil {
+		vaultEntity.Description = *request.Description
+	}
+
+	if newPassword {
+		var password string
+		if request.Password != nil {
+			password = *request.Password
+		}
+
+		vaultEntity.Password, vaultEntity.Encryption, err = h.seal(c, password, request.Secret)
+		if err != nil {
+			slog.WarnContext(c, "unable to seal password", slog.String("id", id), slog.Any("error", err))
+			apierror.Respond(c, err)
+			return
+		}
+	}
+
+	if request.RotationDays != nil {
+		vaultEntity.RotationDays = *request.RotationDays
+	}
+
+	switch {
+	case request.ExpiresAt != nil:
+		vaultEntity.ExpiresAt = request.ExpiresAt
+	case newPassword || request.RotationDays != nil:
+		vaultEntity.ScheduleRotation(time.Now())
+	}
+
+	vaultEntity, err = h.Client.UpdateEntity(c, vaultEntity)
+	if err != nil {
+		slog.ErrorContext(c, "unable to update item", slog.String("id", id), slog.Any("error", err))
+		apierror.Respond(c, err)
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, vaultEntity.Metadata())
+}
@@ -0,0 +1,221 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	b64 "encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"personal-vault/internal/apierror"
+	"personal-vault/internal/db"
+	"personal-vault/internal/decryption"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSaveHandler_UpdateItem(t *testing.T) {
+	t.Parallel()
+
+	const id = "6b2bfbc0-8c23-414b-9c39-cf9b76520b39"
+
+	stored := map[string]types.AttributeValue{
+		"id":          &types.AttributeValueMemberS{Value: id},
+		"name":        &types.AttributeValueMemberS{Value: "TestName"},
+		"description": &types.AttributeValueMemberS{Value: "TestDescr."},
+		"password":    &types.AttributeValueMemberS{Value: "gA8vgNGMxa3W0M0t7059MhLqYruaVgFRaVzuGcTAIXzIhY2mKAVqbw=="},
+		"version":     &types.AttributeValueMemberN{Value: "2"},
+	}
+
+	found := func(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+		return &dynamodb.GetItemOutput{Item: stored}, nil
+	}
+
+	tests := []struct {
+		name           string
+		testId         string
+		body           string
+		getItem        func(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+		updateItem     func(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+		expectedStatus int
+		expectedCode   string
+		expectedEntity *db.VaultEntity
+		expectedSecret string
+		expectedExpiry time.Duration
+	}{
+		{
+			name:    "rename keeps other fields",
+			testId:  id,
+			body:    `{"name":"NewName"}`,
+			getItem: found,
+			updateItem: func(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+				return &dynamodb.UpdateItemOutput{}, nil
+			},
+			expectedStatus: http.StatusOK,
+			expectedEntity: &db.VaultEntity{ID: id, Name: "NewName", Description: "TestDescr."},
+			expectedSecret: "testPassword",
+		},
+		{
+			name:    "new password is encrypted",
+			testId:  id,
+			body:    `{"password":"new password"}`,
+			getItem: found,
+			updateItem: func(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+				return &dynamodb.UpdateItemOutput{}, nil
+			},
+			expectedStatus: http.StatusOK,
+			expectedEntity: &db.VaultEntity{ID: id, Name: "TestName", Description: "TestDescr."},
+			expectedSecret: "new password",
+		},
+		{
+			name:    "rotation interval restarts the period",
+			testId:  id,
+			body:    `{"rotation_days":30}`,
+			getItem: found,
+			updateItem: func(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+				assert.Contains(t, aws.ToString(params.UpdateExpression), "expires_at = :expires_at")
+				return &dynamodb.UpdateItemOutput{}, nil
+			},
+			expectedStatus: http.StatusOK,
+			expectedEntity: &db.VaultEntity{ID: id, Name: "TestName", Description: "TestDescr."},
+			expectedSecret: "testPassword",
+			expectedExpiry: 30 * 24 * time.Hour,
+		},
+		{
+			name:           "negative rotation interval",
+			testId:         id,
+			body:           `{"rotation_days":-1}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   apierror.CodeValidationFailed,
+		},
+		{
+			name:           "empty update",
+			testId:         id,
+			body:           `{}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   apierror.CodeInvalidRequest,
+		},
+		{
+			name:           "empty name",
+			testId:         id,
+			body:           `{"name":""}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   apierror.CodeValidationFailed,
+		},
+		{
+			name:           "invalid id",
+			testId:         "001",
+			body:           `{"name":"NewName"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   apierror.CodeInvalidRequest,
+		},
+		{
+			name:   "not found",
+			testId: id,
+			body:   `{"name":"NewName"}`,
+			getItem: func(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+				return &dynamodb.GetItemOutput{}, nil
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedCode:   apierror.CodeNotFound,
+		},
+		{
+			name:    "deleted while editing",
+			testId:  id,
+			body:    `{"name":"NewName"}`,
+			getItem: found,
+			updateItem: func(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+				return nil, &types.ConditionalCheckFailedException{Message: aws.String("mock")}
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedCode:   apierror.CodeNotFound,
+		},
+		{
+			name:    "edited concurrently",
+			testId:  id,
+			body:    `{"name":"NewName"}`,
+			getItem: found,
+			updateItem: func(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+				return nil, &types.ConditionalCheckFailedException{Message: aws.String("mock"), Item: stored}
+			},
+			expectedStatus: http.StatusConflict,
+			expectedCode:   apierror.CodeConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var written struct {
+				Name        string     `dynamodbav:":name"`
+				Description string     `dynamodbav:":description"`
+				Password    string     `dynamodbav:":password"`
+				Version     int        `dynamodbav:":version"`
+				ExpiresAt   *time.Time `dynamodbav:":expires_at"`
+			}
+			updateItem := tt.updateItem
+			if updateItem != nil {
+				updateItem = func(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+					err := attributevalue.UnmarshalMap(params.ExpressionAttributeValues, &written)
+					assert.NoError(t, err)
+					return tt.updateItem(ctx, params, optFns...)
+				}
+			}
+
+			dynamdbMockClient := db.DynamoDBClient{
+				API: &dynamoDBMockAPI{
+					getItem:    tt.getItem,
+					updateItem: updateItem,
+				}}
+
+			// secret is for testing only
+			secret, err := hex.DecodeString("0f6f8edf954592d7523b475bb56fd0486b7a049d67c1e5aa522bbc8bfe961971")
+			assert.NoError(t, err)
+
+			key := string(secret)
+
+			saveHandler := SaveHandler{Client: dynamdbMockClient, Validate: NewValidator(), Key: key}
+
+			w := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(w)
+			ctx.Request = httptest.NewRequest(http.MethodPatch, "/entries/"+tt.testId, bytes.NewBufferString(tt.body))
+			ctx.Params = []gin.Param{{Key: "id", Value: tt.testId}}
+
+			saveHandler.UpdateItem(ctx)
+			assert.Equal(t, tt.expectedStatus, w.Code)
+
+			if tt.expectedEntity != nil {
+				assert.Equal(t, tt.expectedEntity.Name, written.Name)
+				assert.Equal(t, tt.expectedEntity.Description, written.Description)
+				assert.Equal(t, 3, written.Version)
+
+				if tt.expectedExpiry > 0 {
+					assert.WithinDuration(t, time.Now().Add(tt.expectedExpiry), *written.ExpiresAt, time.Minute)
+				} else {
+					assert.Nil(t, written.ExpiresAt)
+				}
+
+				decoded, err := b64.StdEncoding.DecodeString(written.Password)
+				assert.NoError(t, err)
+				password, err := decryption.Decrypt(string(decoded), key)
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectedSecret, password)
+			} else {
+				var apiErr apierror.Error
+				err = json.Unmarshal(w.Body.Bytes(), &apiErr)
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectedCode, apiErr.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"crypto/ecdh"
+	"errors"
+	"log/slog"
+	"net/http"
+	"personal-vault/internal/apierror"
+	"personal-vault/internal/db"
+	"personal-vault/internal/keyring"
+	"personal-vault/internal/rbac"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// UserHandler registers the users that can be members of collections.
+// Iterations is the PBKDF2 work factor for new passphrases.
+type UserHandler struct {
+	Client     db.DynamoDBClient
+	Validate   *validator.Validate
+	Iterations int
+}
+
+// UserRequest is the body of POST /users. The name is the user part of the
+// HTTP Basic credentials, so it cannot contain a colon.
+type UserRequest struct {
+	Name       string `json:"name" validate:"required,max=64,printascii,excludesall=:"`
+	Passphrase string `json:"passphrase" validate:"required,min=8"`
+}
+
+// UserResponse is the public part of a user.
+type UserResponse struct {
+	Name      string    `json:"name"`
+	PublicKey []byte    `json:"public_key"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func userResponse(user db.User) UserResponse {
+	return UserResponse{Name: user.Name, PublicKey: user.PublicKey, CreatedAt: user.CreatedAt}
+}
+
+// CreateUser generates a key pair for a new user and seals the private key
+// under the user's passphrase.
+func (h UserHandler) CreateUser(c *gin.Context) {
+	slog.DebugContext(c, "enter create user")
+
+	var request UserRequest
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		slog.WarnContext(c, "unable to bind request", slog.Any("error", err))
+		apierror.Respond(c, apierror.BadRequest("request body must be valid JSON").Wrap(err))
+		return
+	}
+
+	err := h.Validate.Struct(request)
+	if err != nil {
+		slog.WarnContext(c, "request validation failed", slog.Any("error", err))
+		apierror.Respond(c, apierror.Validation(err))
+		return
+	}
+
+	userKey, err := keyring.NewUserKey(request.Passphrase, h.Iterations)
+	if err != nil {
+		slog.ErrorContext(c, "unable to generate user key", slog.Any("error", err))
+		apierror.Respond(c, err)
+		return
+	}
+
+	user, err := h.Client.CreateUser(c, db.User{
+		Name:             request.Name,
+		PublicKey:        userKey.PublicKey,
+		SealedPrivateKey: userKey.SealedPrivateKey,
+		KDFSalt:          userKey.Salt,
+		KDFIterations:    userKey.Iterations,
+	})
+	if errors.Is(err, db.ErrConflict) {
+		apierror.Respond(c, apierror.New(http.StatusConflict, apierror.CodeConflict, "the user name is taken").Wrap(err))
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(c, "unable to save user", slog.Any("error", err))
+		apierror.Respond(c, err)
+		return
+	}
+
+	slog.InfoContext(c, "user created", slog.String("user", user.Name))
+
+	c.IndentedJSON(http.StatusCreated, userResponse(user))
+}
+
+// GetUsers lists the users and their public keys to a bearer token
+// principal or, without tokens, to a registered user.
+func (h UserHandler) GetUsers(c *gin.Context) {
+	slog.DebugContext(c, "enter get users")
+
+	if _, ok := rbac.PrincipalFrom(c); !ok {
+		if _, _, ok := authenticate(c, h.Client); !ok {
+			return
+		}
+	}
+
+	users, err := h.Client.ScanUsers(c)
+	if err != nil {
+		slog.ErrorContext(c, "unable to scan users", slog.Any("error", err))
+		apierror.Respond(c, err)
+		return
+	}
+
+	responses := make([]UserResponse, 0, len(users))
+	for _, user := range users {
+		responses = append(responses, userResponse(user))
+	}
+
+	c.IndentedJSON(http.StatusOK, responses)
+}
+
+// authenticate checks the HTTP Basic credentials of the request, a user name
+// and passphrase, and opens the user's private key. It responds 401 and
+// returns false when they do not match.
+func authenticate(c *gin.Context, client db.DynamoDBClient) (db.User, *ecdh.PrivateKey, bool) {
+	unauthorized := func(err error) {
+		c.Header("WWW-Authenticate", `Basic realm="personal-vault", charset="UTF-8"`)
+		apierror.Respond(c, apierror.New(http.StatusUnauthorized, apierror.CodeUnauthorized, "invalid user name or passphrase").Wrap(err))
+	}
+
+	name, passphrase, ok := c.Request.BasicAuth()
+	if !ok {
+		unauthorized(errors.New("no basic credentials"))
+		return db.User{}, nil, false
+	}
+
+	user, err := client.GetUser(c, name)
+	if errors.Is(err, db.ErrNotFound) {
+		slog.WarnContext(c, "unknown user", slog.String("user", name))
+		unauthorized(err)
+		return db.User{}, nil, false
+	}
+	if err != nil {
+		slog.ErrorContext(c, "unable to get user", slog.String("user", name), slog.Any("error", err))
+		apierror.Respond(c, err)
+		return db.User{}, nil, false
+	}
+
+	userKey := keyring.UserKey{
+		PublicKey:        user.PublicKey,
+		SealedPrivateKey: user.SealedPrivateKey,
+		Salt:             user.KDFSalt,
+		Iterations:       user.KDFIterations,
+	}
+
+	private, err := userKey.Open(passphrase)
+	if err != nil {
+		slog.WarnContext(c, "wrong passphrase", slog.String("user", name))
+		unauthorized(err)
+		return db.User{}, nil, false
+	}
+
+	return user, private, true
+}
@@ -0,0 +1,28 @@
+package health
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Livez answers as long as the process serves HTTP, without touching any
+// dependency.
+func Livez(c *gin.Context) {
+	c.IndentedJSON(http.StatusOK, Report{Status: StatusOK})
+}
+
+// Readyz answers 200 while every check passes and 503 otherwise, with the
+// status of each check.
+func (h Checker) Readyz(c *gin.Context) {
+	report := h.Report(c, time.Now())
+
+	status := http.StatusOK
+	if !report.OK() {
+		status = http.StatusServiceUnavailable
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.IndentedJSON(status, report)
+}
@@ -0,0 +1,115 @@
+// Package health reports whether the server can serve requests: /livez that
+// the process is up, /readyz that the table answers and the master key works.
+package health
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// Check is a dependency of the server. Run returns nil while it is usable.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// Report is the body of /livez and /readyz. Checks holds the status of each
+// dependency. The errors are only logged, the endpoints need no token.
+type Report struct {
+	Status    string            `json:"status"`
+	Checks    map[string]string `json:"checks,omitempty"`
+	CheckedAt *time.Time        `json:"checked_at,omitempty"`
+}
+
+func (r Report) OK() bool {
+	return r.Status == StatusOK
+}
+
+// Checker runs the checks of /readyz. A report is reused for TTL so the
+// endpoint is cheap to poll, and every check is bounded by Timeout. The zero
+// value has no checks and is always ready.
+type Checker struct {
+	Checks  []Check
+	TTL     time.Duration
+	Timeout time.Duration
+
+	// cache is shared by the copies of the Checker
+	cache *cache
+}
+
+type cache struct {
+	mu      sync.Mutex
+	report  Report
+	expires time.Time
+}
+
+func NewChecker(ttl, timeout time.Duration, checks ...Check) Checker {
+	return Checker{Checks: checks, TTL: ttl, Timeout: timeout, cache: &cache{}}
+}
+
+// Report returns the last report until it expires, then runs the checks in
+// parallel. Callers that arrive meanwhile wait for the new report rather than
+// start checks of their own.
+func (h Checker) Report(ctx context.Context, now time.Time) Report {
+	if h.cache == nil {
+		return h.run(ctx, now)
+	}
+
+	h.cache.mu.Lock()
+	defer h.cache.mu.Unlock()
+
+	if now.Before(h.cache.expires) {
+		return h.cache.report
+	}
+
+	report := h.run(ctx, now)
+	h.cache.report, h.cache.expires = report, now.Add(h.TTL)
+
+	return report
+}
+
+func (h Checker) run(ctx context.Context, now time.Time) Report {
+	report := Report{Status: StatusOK, Checks: make(map[string]string, len(h.Checks)), CheckedAt: &now}
+
+	// the report is shared by later callers, so a caller that goes away must
+	// not fail the checks
+	ctx = context.WithoutCancel(ctx)
+
+	errs := make([]error, len(h.Checks))
+
+	var wg sync.WaitGroup
+	for i, check := range h.Checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+
+			ctx := ctx
+			if h.Timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, h.Timeout)
+				defer cancel()
+			}
+
+			errs[i] = check.Run(ctx)
+		}(i, check)
+	}
+	wg.Wait()
+
+	for i, check := range h.Checks {
+		report.Checks[check.Name] = StatusOK
+		if errs[i] != nil {
+			slog.WarnContext(ctx, "readiness check failed", slog.String("check", check.Name), slog.Any("error", errs[i]))
+			report.Checks[check.Name] = StatusFail
+			report.Status = StatusFail
+		}
+	}
+
+	return report
+}
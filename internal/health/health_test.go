@@ -0,0 +1,160 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChecker_Report(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name           string
+		checks         []Check
+		expectedStatus string
+		expectedChecks map[string]string
+	}{
+		{
+			name:           "no checks",
+			expectedStatus: StatusOK,
+			expectedChecks: map[string]string{},
+		},
+		{
+			name: "all pass",
+			checks: []Check{
+				{Name: "dynamodb", Run: func(ctx context.Context) error { return nil }},
+				{Name: "key", Run: func(ctx context.Context) error { return nil }},
+			},
+			expectedStatus: StatusOK,
+			expectedChecks: map[string]string{"dynamodb": StatusOK, "key": StatusOK},
+		},
+		{
+			name: "one fails",
+			checks: []Check{
+				{Name: "dynamodb", Run: func(ctx context.Context) error { return errors.New("mock") }},
+				{Name: "key", Run: func(ctx context.Context) error { return nil }},
+			},
+			expectedStatus: StatusFail,
+			expectedChecks: map[string]string{"dynamodb": StatusFail, "key": StatusOK},
+		},
+		{
+			name: "timeout",
+			checks: []Check{
+				{Name: "dynamodb", Run: func(ctx context.Context) error {
+					<-ctx.Done()
+					return ctx.Err()
+				}},
+			},
+			expectedStatus: StatusFail,
+			expectedChecks: map[string]string{"dynamodb": StatusFail},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			checker := NewChecker(time.Second, 10*time.Millisecond, tt.checks...)
+
+			report := checker.Report(context.Background(), time.Now())
+			assert.Equal(t, tt.expectedStatus, report.Status)
+			assert.Equal(t, tt.expectedChecks, report.Checks)
+		})
+	}
+}
+
+func TestChecker_ReportCache(t *testing.T) {
+	t.Parallel()
+
+	var runs atomic.Int32
+	checker := NewChecker(5*time.Second, time.Second, Check{Name: "dynamodb", Run: func(ctx context.Context) error {
+		runs.Add(1)
+		return nil
+	}})
+
+	now := time.Now()
+
+	// concurrent callers share one run
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			checker.Report(context.Background(), now)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), runs.Load())
+
+	report := checker.Report(context.Background(), now.Add(4*time.Second))
+	assert.Equal(t, int32(1), runs.Load())
+	assert.Equal(t, now, *report.CheckedAt)
+
+	report = checker.Report(context.Background(), now.Add(5*time.Second))
+	assert.Equal(t, int32(2), runs.Load())
+	assert.Equal(t, now.Add(5*time.Second), *report.CheckedAt)
+
+	// a caller that went away does not fail the checks
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	checker = NewChecker(time.Second, time.Second, Check{Name: "dynamodb", Run: func(ctx context.Context) error {
+		return ctx.Err()
+	}})
+	assert.True(t, checker.Report(ctx, now).OK())
+}
+
+func TestReadyz(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name           string
+		err            error
+		expectedStatus int
+	}{
+		{name: "ready", expectedStatus: http.StatusOK},
+		{name: "not ready", err: errors.New("table vault is DELETING"), expectedStatus: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			checker := NewChecker(time.Second, time.Second, Check{Name: "dynamodb", Run: func(ctx context.Context) error {
+				return tt.err
+			}})
+
+			router := gin.New()
+			router.GET("/readyz", checker.Readyz)
+			router.GET("/livez", Livez)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+			assert.NotContains(t, w.Body.String(), "DELETING", "errors are not exposed")
+
+			var report Report
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+			assert.Equal(t, tt.err == nil, report.OK())
+			assert.Contains(t, report.Checks, "dynamodb")
+
+			// liveness never depends on the checks
+			w = httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/livez", nil))
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.JSONEq(t, `{"status":"ok"}`, w.Body.String())
+		})
+	}
+}
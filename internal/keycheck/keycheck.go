@@ -0,0 +1,90 @@
+package keycheck
+
+import (
+	"context"
+	b64 "encoding/base64"
+	"errors"
+	"fmt"
+	"personal-vault/internal/db"
+	"personal-vault/internal/decryption"
+	"personal-vault/internal/encryption"
+)
+
+// plaintext is the known value encrypted under the master key. Only its
+// ciphertext is stored; it proves nothing about any entry.
+const plaintext = "personal-vault key check v1"
+
+var (
+	ErrWrongKey   = errors.New("the configured master key does not match this vault")
+	ErrNoKeyCheck = errors.New("the vault has no key check value, the master key cannot be verified")
+)
+
+type MetaReader interface {
+	GetVaultMeta(ctx context.Context) (db.VaultMeta, error)
+}
+
+// New encrypts the known plaintext with key. The result is stored in the
+// vault metadata at init.
+func New(key string) (string, error) {
+	ciphertext, err := encryption.Encrypt(plaintext, key)
+	if err != nil {
+		return "", err
+	}
+
+	return b64.StdEncoding.EncodeToString([]byte(ciphertext)), nil
+}
+
+// Verify reports ErrWrongKey when check was not produced by New with key.
+func Verify(check, key string) error {
+	ciphertext, err := b64.StdEncoding.DecodeString(check)
+	if err != nil {
+		return err
+	}
+
+	decrypted, err := decryption.Decrypt(string(ciphertext), key)
+	if errors.Is(err, decryption.ErrDecrypt) || (err == nil && decrypted != plaintext) {
+		return ErrWrongKey
+	}
+
+	return err
+}
+
+// VerifyVault checks key against the key check value stored at init. Vaults
+// created before key checks existed report ErrNoKeyCheck.
+func VerifyVault(ctx context.Context, vault MetaReader, key string) error {
+	meta, err := vault.GetVaultMeta(ctx)
+	if errors.Is(err, db.ErrNotFound) {
+		return ErrNoKeyCheck
+	}
+	if err != nil {
+		return fmt.Errorf("unable to read vault metadata: %w", err)
+	}
+
+	if meta.KeyCheck == "" {
+		return ErrNoKeyCheck
+	}
+
+	return Verify(meta.KeyCheck, key)
+}
+
+// Probe checks that key works and belongs to the vault: it encrypts and
+// decrypts the known plaintext, then verifies the stored key check value if
+// the vault has one.
+func Probe(ctx context.Context, vault MetaReader, key string) error {
+	check, err := New(key)
+	if err != nil {
+		return err
+	}
+
+	err = Verify(check, key)
+	if err != nil {
+		return err
+	}
+
+	err = VerifyVault(ctx, vault, key)
+	if errors.Is(err, ErrNoKeyCheck) {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,125 @@
+package keycheck
+
+import (
+	"context"
+	"encoding/hex"
+	"personal-vault/internal/db"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testKey(t *testing.T, hexKey string) string {
+	t.Helper()
+
+	// keys are for testing only
+	key, err := hex.DecodeString(hexKey)
+	assert.NoError(t, err)
+
+	return string(key)
+}
+
+func TestVerify(t *testing.T) {
+	t.Parallel()
+
+	key := testKey(t, "0f6f8edf954592d7523b475bb56fd0486b7a049d67c1e5aa522bbc8bfe961971")
+	otherKey := testKey(t, "1f6f8edf954592d7523b475bb56fd0486b7a049d67c1e5aa522bbc8bfe961971")
+
+	check, err := New(key)
+	assert.NoError(t, err)
+
+	assert.NoError(t, Verify(check, key))
+	assert.ErrorIs(t, Verify(check, otherKey), ErrWrongKey)
+	assert.Error(t, Verify("not base64!", key))
+}
+
+type metaReaderFunc func(ctx context.Context) (db.VaultMeta, error)
+
+func (f metaReaderFunc) GetVaultMeta(ctx context.Context) (db.VaultMeta, error) {
+	return f(ctx)
+}
+
+func TestVerifyVault(t *testing.T) {
+	t.Parallel()
+
+	key := testKey(t, "0f6f8edf954592d7523b475bb56fd0486b7a049d67c1e5aa522bbc8bfe961971")
+	otherKey := testKey(t, "1f6f8edf954592d7523b475bb56fd0486b7a049d67c1e5aa522bbc8bfe961971")
+
+	check, err := New(key)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		meta        db.VaultMeta
+		metaErr     error
+		key         string
+		expectedErr error
+	}{
+		{name: "matching key", meta: db.VaultMeta{KeyCheck: check}, key: key},
+		{name: "wrong key", meta: db.VaultMeta{KeyCheck: check}, key: otherKey, expectedErr: ErrWrongKey},
+		{name: "no metadata", metaErr: db.ErrNotFound, key: key, expectedErr: ErrNoKeyCheck},
+		{name: "no key check", meta: db.VaultMeta{}, key: key, expectedErr: ErrNoKeyCheck},
+		{name: "db error", metaErr: db.ErrThrottled, key: key, expectedErr: db.ErrThrottled},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			reader := metaReaderFunc(func(ctx context.Context) (db.VaultMeta, error) {
+				return tt.meta, tt.metaErr
+			})
+
+			err := VerifyVault(context.Background(), reader, tt.key)
+			assert.ErrorIs(t, err, tt.expectedErr)
+			if tt.expectedErr == nil {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestProbe(t *testing.T) {
+	t.Parallel()
+
+	key := testKey(t, "0f6f8edf954592d7523b475bb56fd0486b7a049d67c1e5aa522bbc8bfe961971")
+	otherKey := testKey(t, "1f6f8edf954592d7523b475bb56fd0486b7a049d67c1e5aa522bbc8bfe961971")
+
+	check, err := New(key)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		meta        db.VaultMeta
+		metaErr     error
+		key         string
+		expectedErr error
+	}{
+		{name: "matching key", meta: db.VaultMeta{KeyCheck: check}, key: key},
+		{name: "wrong key", meta: db.VaultMeta{KeyCheck: check}, key: otherKey, expectedErr: ErrWrongKey},
+		{name: "no key check", metaErr: db.ErrNotFound, key: key},
+		{name: "db error", metaErr: db.ErrThrottled, key: key, expectedErr: db.ErrThrottled},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			reader := metaReaderFunc(func(ctx context.Context) (db.VaultMeta, error) {
+				return tt.meta, tt.metaErr
+			})
+
+			err := Probe(context.Background(), reader, tt.key)
+			assert.ErrorIs(t, err, tt.expectedErr)
+			if tt.expectedErr == nil {
+				assert.NoError(t, err)
+			}
+		})
+	}
+
+	// a key of the wrong length fails before the vault is read
+	err = Probe(context.Background(), nil, "short")
+	assert.Error(t, err)
+}
@@ -0,0 +1,175 @@
+// Package keyring holds the X25519 key pairs of users and wraps collection
+// keys to them. A collection key is stored once per member, encrypted to that
+// member's public key, and each private key is sealed under the member's own
+// passphrase, so the server can only open a collection while a member is
+// making the request.
+package keyring
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"personal-vault/internal/decryption"
+	"personal-vault/internal/encryption"
+
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	// KeyLength is the length of collection keys and of the keys derived to
+	// seal private keys and wrap collection keys, for AES-256-GCM.
+	KeyLength = 32
+
+	saltLength = 32
+	wrapInfo   = "personal-vault collection key"
+)
+
+// ErrPassphrase is returned when a private key cannot be opened, which almost
+// always means the passphrase is wrong.
+var ErrPassphrase = errors.New("wrong passphrase")
+
+// UserKey is the stored half of a user's key pair: the public key and the
+// private key sealed under a PBKDF2-SHA256 key derived from the passphrase.
+type UserKey struct {
+	PublicKey        []byte
+	SealedPrivateKey []byte
+	Salt             []byte
+	Iterations       int
+}
+
+// WrappedKey is a collection key encrypted to one member. The ephemeral public
+// key and the member's private key agree on the key that seals it.
+type WrappedKey struct {
+	EphemeralPublicKey []byte
+	Ciphertext         []byte
+}
+
+// NewUserKey generates a key pair and seals the private key under passphrase.
+func NewUserKey(passphrase string, iterations int) (UserKey, error) {
+	private, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return UserKey{}, err
+	}
+
+	salt := make([]byte, saltLength)
+	_, err = rand.Read(salt)
+	if err != nil {
+		return UserKey{}, err
+	}
+
+	sealed, err := encryption.Encrypt(string(private.Bytes()), string(passphraseKey(passphrase, salt, iterations)))
+	if err != nil {
+		return UserKey{}, err
+	}
+
+	return UserKey{
+		PublicKey:        private.PublicKey().Bytes(),
+		SealedPrivateKey: []byte(sealed),
+		Salt:             salt,
+		Iterations:       iterations,
+	}, nil
+}
+
+// Open returns the private key. It returns ErrPassphrase when the passphrase
+// does not open it.
+func (k UserKey) Open(passphrase string) (*ecdh.PrivateKey, error) {
+	opened, err := decryption.Decrypt(string(k.SealedPrivateKey), string(passphraseKey(passphrase, k.Salt, k.Iterations)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrPassphrase, err)
+	}
+
+	return ecdh.X25519().NewPrivateKey([]byte(opened))
+}
+
+// NewCollectionKey returns a random AES-256 key.
+func NewCollectionKey() ([]byte, error) {
+	key := make([]byte, KeyLength)
+	_, err := rand.Read(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// Wrap encrypts key to the owner of publicKey.
+func Wrap(key, publicKey []byte) (WrappedKey, error) {
+	recipient, err := ecdh.X25519().NewPublicKey(publicKey)
+	if err != nil {
+		return WrappedKey{}, err
+	}
+
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return WrappedKey{}, err
+	}
+
+	shared, err := ephemeral.ECDH(recipient)
+	if err != nil {
+		return WrappedKey{}, err
+	}
+
+	wrapKey, err := wrappingKey(shared, ephemeral.PublicKey(), recipient)
+	if err != nil {
+		return WrappedKey{}, err
+	}
+
+	ciphertext, err := encryption.Encrypt(string(key), string(wrapKey))
+	if err != nil {
+		return WrappedKey{}, err
+	}
+
+	return WrappedKey{
+		EphemeralPublicKey: ephemeral.PublicKey().Bytes(),
+		Ciphertext:         []byte(ciphertext),
+	}, nil
+}
+
+// Unwrap decrypts a key wrapped to the public key of private.
+func Unwrap(wrapped WrappedKey, private *ecdh.PrivateKey) ([]byte, error) {
+	ephemeral, err := ecdh.X25519().NewPublicKey(wrapped.EphemeralPublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	shared, err := private.ECDH(ephemeral)
+	if err != nil {
+		return nil, err
+	}
+
+	wrapKey, err := wrappingKey(shared, ephemeral, private.PublicKey())
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := decryption.Decrypt(string(wrapped.Ciphertext), string(wrapKey))
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(key), nil
+}
+
+// wrappingKey derives the key that seals a wrapped key from the shared
+// secret. Both public keys go into the derivation so a wrapped key is bound to
+// its recipient.
+func wrappingKey(shared []byte, ephemeral, recipient *ecdh.PublicKey) ([]byte, error) {
+	info := append([]byte(wrapInfo), ephemeral.Bytes()...)
+	info = append(info, recipient.Bytes()...)
+
+	key := make([]byte, KeyLength)
+	_, err := io.ReadFull(hkdf.New(sha256.New, shared, nil, info), key)
+	if err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+func passphraseKey(passphrase string, salt []byte, iterations int) []byte {
+	return pbkdf2.Key([]byte(passphrase), salt, iterations, KeyLength, sha256.New)
+}
@@ -0,0 +1,65 @@
+package keyring
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testIterations keeps the tests fast, real users get the configured KDF
+// iterations.
+const testIterations = 1000
+
+func TestUserKey_Open(t *testing.T) {
+	t.Parallel()
+
+	userKey, err := NewUserKey("correct horse", testIterations)
+	assert.NoError(t, err)
+	assert.Len(t, userKey.PublicKey, 32)
+	assert.NotContains(t, string(userKey.SealedPrivateKey), "correct horse")
+
+	private, err := userKey.Open("correct horse")
+	assert.NoError(t, err)
+	assert.Equal(t, userKey.PublicKey, private.PublicKey().Bytes())
+
+	_, err = userKey.Open("wrong horse")
+	assert.ErrorIs(t, err, ErrPassphrase)
+}
+
+func TestWrap(t *testing.T) {
+	t.Parallel()
+
+	alice, err := NewUserKey("alice", testIterations)
+	assert.NoError(t, err)
+	bob, err := NewUserKey("bob", testIterations)
+	assert.NoError(t, err)
+
+	alicePrivate, err := alice.Open("alice")
+	assert.NoError(t, err)
+	bobPrivate, err := bob.Open("bob")
+	assert.NoError(t, err)
+
+	key, err := NewCollectionKey()
+	assert.NoError(t, err)
+
+	toAlice, err := Wrap(key, alice.PublicKey)
+	assert.NoError(t, err)
+	toBob, err := Wrap(key, bob.PublicKey)
+	assert.NoError(t, err)
+	assert.NotEqual(t, toAlice.Ciphertext, toBob.Ciphertext)
+
+	unwrapped, err := Unwrap(toAlice, alicePrivate)
+	assert.NoError(t, err)
+	assert.Equal(t, key, unwrapped)
+
+	unwrapped, err = Unwrap(toBob, bobPrivate)
+	assert.NoError(t, err)
+	assert.Equal(t, key, unwrapped)
+
+	// a key wrapped to one member cannot be opened by another
+	_, err = Unwrap(toAlice, bobPrivate)
+	assert.Error(t, err)
+
+	_, err = Wrap(key, []byte("not a public key"))
+	assert.Error(t, err)
+}
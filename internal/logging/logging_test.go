@@ -0,0 +1,145 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactHandler(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		log  func(logger *slog.Logger)
+	}{
+		{
+			name: "top level attribute",
+			log: func(logger *slog.Logger) {
+				logger.Info("msg", slog.String("password", "hunter2"))
+			},
+		},
+		{
+			name: "upper case key",
+			log: func(logger *slog.Logger) {
+				logger.Info("msg", slog.String("SECRET", "hunter2"))
+			},
+		},
+		{
+			name: "nested group",
+			log: func(logger *slog.Logger) {
+				logger.Info("msg", slog.Group("request", slog.String("db_password", "hunter2")))
+			},
+		},
+		{
+			name: "with attrs",
+			log: func(logger *slog.Logger) {
+				logger.With("secret", "hunter2").Info("msg")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			logger, err := NewLogger(&buf, FormatJSON, "info")
+			assert.NoError(t, err)
+
+			tt.log(logger)
+
+			assert.NotContains(t, buf.String(), "hunter2")
+			assert.Contains(t, buf.String(), redacted)
+		})
+	}
+}
+
+func TestNewLogger(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		format      string
+		level       string
+		expectedErr bool
+	}{
+		{name: "json", format: "json", level: "debug"},
+		{name: "text", format: "text", level: "warn"},
+		{name: "invalid format", format: "xml", level: "info", expectedErr: true},
+		{name: "invalid level", format: "json", level: "loud", expectedErr: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			logger, err := NewLogger(&bytes.Buffer{}, tt.format, tt.level)
+			if tt.expectedErr {
+				assert.Error(t, err)
+				assert.Nil(t, logger)
+			} else {
+				assert.NoError(t, err)
+				assert.NotNil(t, logger)
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	logger, err := NewLogger(&buf, FormatJSON, "info")
+	assert.NoError(t, err)
+
+	var seenID string
+
+	router := gin.New()
+	router.Use(Middleware(logger))
+	router.GET("/retrieve/:id", func(c *gin.Context) {
+		seenID = RequestIDFromContext(c.Request.Context())
+		c.Status(http.StatusNotFound)
+	})
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/retrieve/6b2bfbc0-8c23-414b-9c39-cf9b76520b39", nil)
+	router.ServeHTTP(w, req)
+
+	assert.NotEmpty(t, seenID)
+	assert.Equal(t, seenID, w.Header().Get(RequestIDHeader))
+
+	var line map[string]any
+	err = json.Unmarshal(buf.Bytes(), &line)
+	assert.NoError(t, err)
+	assert.Equal(t, "GET", line["method"])
+	assert.Equal(t, "/retrieve/:id", line["route"])
+	assert.Equal(t, float64(http.StatusNotFound), line["status"])
+	assert.Equal(t, seenID, line["request_id"])
+	assert.Contains(t, line, "latency")
+}
+
+func TestContextHandler(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	logger, err := NewLogger(&buf, FormatJSON, "info")
+	assert.NoError(t, err)
+
+	ctx := WithRequestID(context.Background(), "abc")
+	logger.InfoContext(ctx, "msg")
+
+	var line map[string]any
+	err = json.Unmarshal(buf.Bytes(), &line)
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", line["request_id"])
+}
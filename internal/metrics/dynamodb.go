@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"context"
+	"personal-vault/internal/db"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+// DynamoDBAPI counts and times every call to API by operation.
+type DynamoDBAPI struct {
+	API db.DynamoDBAPI
+}
+
+func (d DynamoDBAPI) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	start := time.Now()
+	output, err := d.API.GetItem(ctx, params, optFns...)
+	observeDynamoDB("GetItem", time.Since(start), err)
+
+	return output, err
+}
+
+func (d DynamoDBAPI) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	start := time.Now()
+	output, err := d.API.PutItem(ctx, params, optFns...)
+	observeDynamoDB("PutItem", time.Since(start), err)
+
+	return output, err
+}
+
+func (d DynamoDBAPI) UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	start := time.Now()
+	output, err := d.API.UpdateItem(ctx, params, optFns...)
+	observeDynamoDB("UpdateItem", time.Since(start), err)
+
+	return output, err
+}
+
+func (d DynamoDBAPI) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	start := time.Now()
+	output, err := d.API.Scan(ctx, params, optFns...)
+	observeDynamoDB("Scan", time.Since(start), err)
+
+	return output, err
+}
+
+func (d DynamoDBAPI) DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	start := time.Now()
+	output, err := d.API.DeleteItem(ctx, params, optFns...)
+	observeDynamoDB("DeleteItem", time.Since(start), err)
+
+	return output, err
+}
+
+func (d DynamoDBAPI) DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error) {
+	start := time.Now()
+	output, err := d.API.DescribeTable(ctx, params, optFns...)
+	observeDynamoDB("DescribeTable", time.Since(start), err)
+
+	return output, err
+}
+
+func observeDynamoDB(operation string, duration time.Duration, err error) {
+	dynamoDBRequests.WithLabelValues(operation, db.Outcome(err)).Inc()
+	dynamoDBDuration.WithLabelValues(operation).Observe(duration.Seconds())
+}
@@ -0,0 +1,153 @@
+// Package metrics collects the Prometheus metrics served on /metrics. Labels
+// only ever hold routes, operations and outcomes, never entry ids or names,
+// so they cannot leak what the vault holds or grow without bound.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "vault"
+
+// Registry holds every metric of the server, with the Go runtime and process
+// metrics.
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route and status.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method and route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	cryptoDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "crypto_duration_seconds",
+		Help:      "Duration of password encryption and decryption with the master key.",
+		// AES-GCM of a password takes microseconds
+		Buckets: prometheus.ExponentialBuckets(0.000_005, 4, 8),
+	}, []string{"operation"})
+
+	cryptoFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "crypto_failures_total",
+		Help:      "Failed password encryptions and decryptions.",
+	}, []string{"operation"})
+
+	dynamoDBRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "dynamodb_requests_total",
+		Help:      "DynamoDB calls by operation and outcome: ok, conditional_check_failed, throttled or error. Every retry is a call.",
+	}, []string{"operation", "outcome"})
+
+	dynamoDBDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "dynamodb_request_duration_seconds",
+		Help:      "DynamoDB call latency by operation. Every retry is a call.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation"})
+
+	dynamoDBRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "dynamodb_retries_total",
+		Help:      "Retried DynamoDB calls by operation and reason: throttled, transient or timeout.",
+	}, []string{"operation", "reason"})
+
+	dynamoDBRejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "dynamodb_circuit_rejected_total",
+		Help:      "DynamoDB calls failed fast by the open circuit breaker, by operation.",
+	}, []string{"operation"})
+
+	dynamoDBCircuitOpen = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "dynamodb_circuit_open",
+		Help:      "1 while the DynamoDB circuit breaker is open or half-open, 0 while it is closed.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests,
+		httpDuration,
+		cryptoDuration,
+		cryptoFailures,
+		dynamoDBRequests,
+		dynamoDBDuration,
+		dynamoDBRetries,
+		dynamoDBRejected,
+		dynamoDBCircuitOpen,
+	)
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// Middleware counts and times every request by its route template, such as
+// /retrieve/:id, never by the requested path.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		c.Next()
+
+		route := c.FullPath()
+		method := c.Request.Method
+		if route == "" {
+			route = "unmatched"
+			// clients choose the method of unmatched requests freely
+			method = "other"
+		}
+
+		httpRequests.WithLabelValues(method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		httpDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// ObserveCrypto records an encryption or decryption with the master key. The
+// handlers measure it, so the encryption packages stay free of metrics.
+func ObserveCrypto(operation string, duration time.Duration, err error) {
+	cryptoDuration.WithLabelValues(operation).Observe(duration.Seconds())
+
+	if err != nil {
+		cryptoFailures.WithLabelValues(operation).Inc()
+	}
+}
+
+// ObserveRetry records a DynamoDB call that is tried again.
+func ObserveRetry(operation, reason string) {
+	dynamoDBRetries.WithLabelValues(operation, reason).Inc()
+}
+
+// ObserveRejected records a DynamoDB call failed fast by the circuit breaker.
+func ObserveRejected(operation string) {
+	dynamoDBRejected.WithLabelValues(operation).Inc()
+}
+
+// SetCircuitOpen records the state of the DynamoDB circuit breaker.
+func SetCircuitOpen(open bool) {
+	value := 0.0
+	if open {
+		value = 1
+	}
+
+	dynamoDBCircuitOpen.Set(value)
+}
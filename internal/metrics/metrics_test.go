@@ -0,0 +1,158 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockAPI struct {
+	err error
+}
+
+func (m mockAPI) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	return &dynamodb.GetItemOutput{}, m.err
+}
+
+func (m mockAPI) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	return &dynamodb.PutItemOutput{}, m.err
+}
+
+func (m mockAPI) UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	return &dynamodb.UpdateItemOutput{}, m.err
+}
+
+func (m mockAPI) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	return &dynamodb.ScanOutput{}, m.err
+}
+
+func (m mockAPI) DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	return &dynamodb.DeleteItemOutput{}, m.err
+}
+
+func (m mockAPI) DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error) {
+	return &dynamodb.DescribeTableOutput{}, m.err
+}
+
+func TestDynamoDBAPI(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		err     error
+		call    func(api DynamoDBAPI) error
+		op      string
+		outcome string
+	}{
+		{
+			name: "ok",
+			call: func(api DynamoDBAPI) error {
+				_, err := api.GetItem(context.Background(), &dynamodb.GetItemInput{})
+				return err
+			},
+			op:      "GetItem",
+			outcome: "ok",
+		},
+		{
+			name: "failed condition",
+			err:  &types.ConditionalCheckFailedException{Message: aws.String("mock")},
+			call: func(api DynamoDBAPI) error {
+				_, err := api.PutItem(context.Background(), &dynamodb.PutItemInput{})
+				return err
+			},
+			op:      "PutItem",
+			outcome: "conditional_check_failed",
+		},
+		{
+			name: "throttled",
+			err:  &smithy.GenericAPIError{Code: "ThrottlingException", Message: "mock"},
+			call: func(api DynamoDBAPI) error {
+				_, err := api.UpdateItem(context.Background(), &dynamodb.UpdateItemInput{})
+				return err
+			},
+			op:      "UpdateItem",
+			outcome: "throttled",
+		},
+		{
+			name: "throughput exceeded",
+			err:  &types.ProvisionedThroughputExceededException{Message: aws.String("mock")},
+			call: func(api DynamoDBAPI) error {
+				_, err := api.Scan(context.Background(), &dynamodb.ScanInput{})
+				return err
+			},
+			op:      "Scan",
+			outcome: "throttled",
+		},
+		{
+			name: "error",
+			err:  errors.New("connection reset"),
+			call: func(api DynamoDBAPI) error {
+				_, err := api.DeleteItem(context.Background(), &dynamodb.DeleteItemInput{})
+				return err
+			},
+			op:      "DeleteItem",
+			outcome: "error",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// the counters are shared by the whole package, so the cases run
+			// one after the other
+			counter := dynamoDBRequests.WithLabelValues(tt.op, tt.outcome)
+			before := testutil.ToFloat64(counter)
+
+			err := tt.call(DynamoDBAPI{API: mockAPI{err: tt.err}})
+			assert.Equal(t, tt.err, err, "the error is passed on unchanged")
+			assert.Equal(t, before+1, testutil.ToFloat64(counter))
+		})
+	}
+}
+
+func TestObserveCrypto(t *testing.T) {
+	t.Parallel()
+
+	failures := cryptoFailures.WithLabelValues("decrypt")
+	before := testutil.ToFloat64(failures)
+
+	ObserveCrypto("decrypt", time.Millisecond, nil)
+	assert.Equal(t, before, testutil.ToFloat64(failures))
+
+	ObserveCrypto("decrypt", time.Millisecond, errors.New("message authentication failed"))
+	assert.Equal(t, before+1, testutil.ToFloat64(failures))
+}
+
+func TestMiddleware(t *testing.T) {
+	t.Parallel()
+
+	router := gin.New()
+	router.Use(Middleware())
+	router.GET("/retrieve/:id", func(c *gin.Context) { c.Status(http.StatusNotFound) })
+
+	route := httpRequests.WithLabelValues(http.MethodGet, "/retrieve/:id", "404")
+	unmatched := httpRequests.WithLabelValues("other", "unmatched", "404")
+	beforeRoute, beforeUnmatched := testutil.ToFloat64(route), testutil.ToFloat64(unmatched)
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/retrieve/6b2bfbc0-8c23-414b-9c39-cf9b76520b39", nil),
+		httptest.NewRequest(http.MethodGet, "/retrieve/prod-db", nil),
+		httptest.NewRequest("BREW", "/coffee", nil),
+	} {
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	assert.Equal(t, beforeRoute+2, testutil.ToFloat64(route))
+	assert.Equal(t, beforeUnmatched+1, testutil.ToFloat64(unmatched))
+}
@@ -0,0 +1,228 @@
+// Package openapi builds an OpenAPI 3 document from the Go request and
+// response types, so the spec cannot drift from what the handlers bind and
+// render.
+package openapi
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const Version = "3.0.3"
+
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// PathItem maps a lower case HTTP method to its operation.
+type PathItem map[string]Operation
+
+type Operation struct {
+	OperationID string              `json:"operationId"`
+	Summary     string              `json:"summary,omitempty"`
+	Description string              `json:"description,omitempty"`
+	Parameters  []Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]Response `json:"responses"`
+	// Security lists the alternative schemes that authenticate the
+	// operation, by name with their scopes.
+	Security []map[string][]string `json:"security,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Headers     map[string]Header    `json:"headers,omitempty"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme is an HTTP authentication scheme such as basic or bearer.
+type SecurityScheme struct {
+	Type        string `json:"type"`
+	Scheme      string `json:"scheme,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// Builder collects operations and the component schemas they reference.
+type Builder struct {
+	doc   Document
+	types typeNames
+}
+
+func New(title, version string) *Builder {
+	return &Builder{
+		doc: Document{
+			OpenAPI:    Version,
+			Info:       Info{Title: title, Version: version},
+			Paths:      map[string]PathItem{},
+			Components: Components{Schemas: map[string]*Schema{}},
+		},
+		types: typeNames{},
+	}
+}
+
+// Add registers op under a gin style route such as /retrieve/:id. Path
+// parameters that op does not declare itself are added as required strings.
+func (b *Builder) Add(method, route string, op Operation) {
+	path, params := Path(route)
+
+	for _, name := range params {
+		if !hasParameter(op.Parameters, name) {
+			op.Parameters = append(op.Parameters, Parameter{
+				Name:     name,
+				In:       "path",
+				Required: true,
+				Schema:   &Schema{Type: "string"},
+			})
+		}
+	}
+
+	item, ok := b.doc.Paths[path]
+	if !ok {
+		item = PathItem{}
+		b.doc.Paths[path] = item
+	}
+
+	item[strings.ToLower(method)] = op
+}
+
+// Security declares an HTTP authentication scheme and returns the
+// requirement that operations reference it with.
+func (b *Builder) Security(name string, scheme SecurityScheme) []map[string][]string {
+	if b.doc.Components.SecuritySchemes == nil {
+		b.doc.Components.SecuritySchemes = map[string]SecurityScheme{}
+	}
+
+	b.doc.Components.SecuritySchemes[name] = scheme
+
+	return []map[string][]string{{name: {}}}
+}
+
+// JSONBody is a required JSON request body shaped like v.
+func (b *Builder) JSONBody(v any) *RequestBody {
+	return &RequestBody{
+		Required: true,
+		Content:  map[string]MediaType{"application/json": {Schema: b.Schema(v)}},
+	}
+}
+
+// JSON is a JSON response shaped like v.
+func (b *Builder) JSON(description string, v any) Response {
+	return Response{
+		Description: description,
+		Content:     map[string]MediaType{"application/json": {Schema: b.Schema(v)}},
+	}
+}
+
+// Text is a text/plain response.
+func Text(description string) Response {
+	return Response{
+		Description: description,
+		Content:     map[string]MediaType{"text/plain": {Schema: &Schema{Type: "string"}}},
+	}
+}
+
+// NoContent is a response without a body.
+func NoContent(description string) Response {
+	return Response{Description: description}
+}
+
+// Document returns the collected spec.
+func (b *Builder) Document() Document {
+	return b.doc
+}
+
+// Routes lists every operation as "METHOD /path", sorted, in OpenAPI path
+// syntax.
+func (d Document) Routes() []string {
+	var routes []string
+	for path, item := range d.Paths {
+		for method := range item {
+			routes = append(routes, strings.ToUpper(method)+" "+path)
+		}
+	}
+
+	sort.Strings(routes)
+
+	return routes
+}
+
+// Path converts a gin route to OpenAPI path syntax and returns its parameter
+// names.
+func Path(route string) (string, []string) {
+	var params []string
+
+	segments := strings.Split(route, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			name := segment[1:]
+			params = append(params, name)
+			segments[i] = "{" + name + "}"
+		}
+	}
+
+	return strings.Join(segments, "/"), params
+}
+
+// Status formats an HTTP status code as a responses key.
+func Status(code int) string {
+	return strconv.Itoa(code)
+}
+
+func hasParameter(params []Parameter, name string) bool {
+	for _, p := range params {
+		if p.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,101 @@
+package openapi
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testRequest struct {
+	Name        string   `json:"name" validate:"required"`
+	Description string   `json:"description"`
+	Count       *int     `json:"count" validate:"omitempty,min=1"`
+	Tags        []string `json:"tags"`
+}
+
+type testBase struct {
+	ID string `json:"id"`
+}
+
+type testResponse struct {
+	testBase
+	Name      string            `json:"name"`
+	CreatedAt time.Time         `json:"created_at"`
+	Secret    []byte            `json:"secret,omitempty"`
+	Labels    map[string]string `json:"labels"`
+	Next      *testResponse     `json:"next"`
+	Internal  string            `json:"-"`
+	Untagged  bool
+	hidden    string
+}
+
+func TestBuilder_Schema(t *testing.T) {
+	t.Parallel()
+
+	b := New("test", "1")
+
+	assert.Equal(t, &Schema{Ref: "#/components/schemas/testRequest"}, b.Schema(testRequest{}))
+	assert.Equal(t, &Schema{Type: "array", Items: &Schema{Ref: "#/components/schemas/testResponse"}}, b.Schema([]testResponse{}))
+
+	schemas := b.Document().Components.Schemas
+
+	assert.Equal(t, &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"name":        {Type: "string"},
+			"description": {Type: "string"},
+			"count":       {Type: "integer", Nullable: true},
+			"tags":        {Type: "array", Items: &Schema{Type: "string"}},
+		},
+		Required: []string{"name"},
+	}, schemas["testRequest"])
+
+	assert.Equal(t, &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"id":         {Type: "string"},
+			"name":       {Type: "string"},
+			"created_at": {Type: "string", Format: "date-time"},
+			"secret":     {Type: "string", Format: "byte"},
+			"labels":     {Type: "object", AdditionalProperties: &Schema{Type: "string"}},
+			"next":       {Ref: "#/components/schemas/testResponse"},
+			"Untagged":   {Type: "boolean"},
+		},
+		Required: []string{"Untagged", "created_at", "id", "labels", "name"},
+	}, schemas["testResponse"])
+}
+
+func TestBuilder_Add(t *testing.T) {
+	t.Parallel()
+
+	b := New("test", "1")
+	b.Add("GET", "/items/:id", Operation{OperationID: "getItem"})
+	b.Add("DELETE", "/items/:id", Operation{
+		OperationID: "deleteItem",
+		Parameters:  []Parameter{{Name: "id", In: "path", Required: true, Schema: &Schema{Type: "string", Format: "uuid"}}},
+	})
+	b.Add("GET", "/files/*path", Operation{OperationID: "getFile"})
+
+	doc := b.Document()
+	assert.Equal(t, []string{"DELETE /items/{id}", "GET /files/{path}", "GET /items/{id}"}, doc.Routes())
+
+	get := doc.Paths["/items/{id}"]["get"]
+	assert.Equal(t, []Parameter{{Name: "id", In: "path", Required: true, Schema: &Schema{Type: "string"}}}, get.Parameters)
+
+	del := doc.Paths["/items/{id}"]["delete"]
+	assert.Len(t, del.Parameters, 1)
+	assert.Equal(t, "uuid", del.Parameters[0].Schema.Format)
+}
+
+func TestBuilder_Security(t *testing.T) {
+	t.Parallel()
+
+	b := New("test", "1")
+	basic := b.Security("basic", SecurityScheme{Type: "http", Scheme: "basic"})
+	b.Add("GET", "/items", Operation{OperationID: "listItems", Security: basic})
+
+	doc := b.Document()
+	assert.Equal(t, SecurityScheme{Type: "http", Scheme: "basic"}, doc.Components.SecuritySchemes["basic"])
+	assert.Equal(t, []map[string][]string{{"basic": {}}}, doc.Paths["/items"]["get"].Security)
+}
@@ -0,0 +1,165 @@
+package openapi
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// typeNames remembers the component name chosen for each named struct type.
+type typeNames map[reflect.Type]string
+
+// Schema returns the schema for v's type. Named struct types are added to
+// the components and referenced with $ref.
+//
+// A struct field is required when its validate tag contains "required". In
+// structs without validate tags, i.e. response types, every field is required
+// unless it is a pointer or tagged omitempty.
+func (b *Builder) Schema(v any) *Schema {
+	return b.schemaOf(reflect.TypeOf(v))
+}
+
+func (b *Builder) schemaOf(t reflect.Type) *Schema {
+	switch t.Kind() {
+	case reflect.Pointer:
+		s := b.schemaOf(t.Elem())
+		if s.Ref == "" {
+			s.Nullable = true
+		}
+		return s
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: b.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: b.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t == timeType {
+			return &Schema{Type: "string", Format: "date-time"}
+		}
+		if t.Name() == "" {
+			return b.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + b.component(t)}
+	default:
+		// interfaces and the like accept anything
+		return &Schema{}
+	}
+}
+
+func (b *Builder) component(t reflect.Type) string {
+	if name, ok := b.types[t]; ok {
+		return name
+	}
+
+	name := t.Name()
+	if _, taken := b.doc.Components.Schemas[name]; taken {
+		// two packages export the same type name
+		pkg := t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+
+	// reserve the name before recursing so self references terminate
+	b.types[t] = name
+	b.doc.Components.Schemas[name] = &Schema{}
+	*b.doc.Components.Schemas[name] = *b.structSchema(t)
+
+	return name
+}
+
+func (b *Builder) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	validated := hasValidateTags(t)
+
+	b.addFields(s, t, validated)
+	sort.Strings(s.Required)
+
+	return s
+}
+
+func (b *Builder) addFields(s *Schema, t reflect.Type, validated bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				// encoding/json promotes the fields of embedded structs
+				b.addFields(s, embedded, validated)
+				continue
+			}
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		s.Properties[name] = b.schemaOf(field.Type)
+
+		if isRequired(field, opts, validated) {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+func isRequired(field reflect.StructField, jsonOpts string, validated bool) bool {
+	if validated {
+		for _, rule := range strings.Split(field.Tag.Get("validate"), ",") {
+			if rule == "required" {
+				return true
+			}
+		}
+		return false
+	}
+
+	if field.Type.Kind() == reflect.Pointer {
+		return false
+	}
+
+	for _, opt := range strings.Split(jsonOpts, ",") {
+		if opt == "omitempty" {
+			return false
+		}
+	}
+
+	return true
+}
+
+func hasValidateTags(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		if _, ok := t.Field(i).Tag.Lookup("validate"); ok {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,103 @@
+package prompt
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+const MinPasswordLength = 12
+
+var (
+	ErrNotTerminal      = errors.New("stdin is not a terminal")
+	ErrPasswordMismatch = errors.New("passwords do not match")
+	ErrPasswordTooShort = errors.New("password is too short")
+)
+
+// Terminal reads secrets from In without echoing them. Prompts go to Out so
+// they do not mix with output meant for pipes.
+type Terminal struct {
+	In  *os.File
+	Out io.Writer
+}
+
+func (t Terminal) Password(label string) (string, error) {
+	fd := int(t.In.Fd())
+	if !term.IsTerminal(fd) {
+		return "", ErrNotTerminal
+	}
+
+	fmt.Fprintf(t.Out, "%s: ", label)
+	password, err := term.ReadPassword(fd)
+	fmt.Fprintln(t.Out)
+	if err != nil {
+		return "", err
+	}
+
+	return string(password), nil
+}
+
+// Line reads a visible answer, e.g. for a confirmation question.
+func (t Terminal) Line(label string) (string, error) {
+	if !term.IsTerminal(int(t.In.Fd())) {
+		return "", ErrNotTerminal
+	}
+
+	fmt.Fprintf(t.Out, "%s ", label)
+
+	return ReadLine(t.In)
+}
+
+// NewPassword asks for a master password twice and checks both entries match
+// and are at least MinPasswordLength long.
+func (t Terminal) NewPassword(label string) (string, error) {
+	return newPassword(t.Password, label, MinPasswordLength)
+}
+
+// Confirmed asks for a non-empty secret twice and checks both entries match.
+func (t Terminal) Confirmed(label string) (string, error) {
+	return newPassword(t.Password, label, 1)
+}
+
+func newPassword(read func(label string) (string, error), label string, minLength int) (string, error) {
+	password, err := read(label)
+	if err != nil {
+		return "", err
+	}
+
+	if len(password) < minLength {
+		return "", fmt.Errorf("%w: at least %d characters are required", ErrPasswordTooShort, minLength)
+	}
+
+	confirmation, err := read("Confirm " + strings.ToLower(label))
+	if err != nil {
+		return "", err
+	}
+
+	if password != confirmation {
+		return "", ErrPasswordMismatch
+	}
+
+	return password, nil
+}
+
+// ReadLine reads a single secret from a pipe, e.g. for --password-stdin. The
+// whole line is used, so passwords may contain spaces.
+func ReadLine(in io.Reader) (string, error) {
+	line, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return "", errors.New("no secret on stdin")
+	}
+
+	return line, nil
+}
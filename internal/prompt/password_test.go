@@ -0,0 +1,80 @@
+package prompt
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewPassword(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		answers     []string
+		expected    string
+		expectedErr error
+	}{
+		{
+			name:     "matching passwords with spaces",
+			answers:  []string{"correct horse battery", "correct horse battery"},
+			expected: "correct horse battery",
+		},
+		{
+			name:        "mismatch",
+			answers:     []string{"correct horse battery", "correct horse battery!"},
+			expectedErr: ErrPasswordMismatch,
+		},
+		{
+			name:        "too short",
+			answers:     []string{"short", "short"},
+			expectedErr: ErrPasswordTooShort,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			answers := tt.answers
+			read := func(label string) (string, error) {
+				if len(answers) == 0 {
+					return "", errors.New("unexpected prompt")
+				}
+				answer := answers[0]
+				answers = answers[1:]
+				return answer, nil
+			}
+
+			password, err := newPassword(read, "Master password", MinPasswordLength)
+			assert.ErrorIs(t, err, tt.expectedErr)
+			assert.Equal(t, tt.expected, password)
+		})
+	}
+}
+
+func TestTerminal_PasswordNotTerminal(t *testing.T) {
+	t.Parallel()
+
+	f, err := os.CreateTemp(t.TempDir(), "stdin")
+	assert.NoError(t, err)
+	defer f.Close()
+
+	_, err = Terminal{In: f, Out: &strings.Builder{}}.Password("Master password")
+	assert.ErrorIs(t, err, ErrNotTerminal)
+}
+
+func TestReadLine(t *testing.T) {
+	t.Parallel()
+
+	line, err := ReadLine(strings.NewReader("pass with spaces\r\nignored\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, "pass with spaces", line)
+
+	_, err = ReadLine(strings.NewReader(""))
+	assert.Error(t, err)
+}
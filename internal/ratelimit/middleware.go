@@ -0,0 +1,89 @@
+package ratelimit
+
+import (
+	"log/slog"
+	"math"
+	"net/http"
+	"personal-vault/internal/apierror"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Middleware limits the requests of each client IP in the group that group
+// returns for the route. Routes in the empty group, or in a group without a
+// limit, are not limited. Responses with 400, 401 or 404 count as failures
+// towards the penalty.
+//
+// The limiter fails open: while the store is unavailable requests are served
+// and the error is logged.
+func (l Limiter) Middleware(group func(c *gin.Context) string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := group(c)
+		if _, ok := l.Limits[name]; !ok || !l.Enabled() {
+			c.Next()
+			return
+		}
+
+		client := clientIP(c)
+
+		wait, err := l.Allow(c, name, client, time.Now())
+		if err != nil {
+			slog.ErrorContext(c, "unable to rate limit request", slog.String("group", name), slog.Any("error", err))
+			c.Next()
+			return
+		}
+
+		if wait > 0 {
+			slog.WarnContext(c, "rate limited",
+				slog.String("client", client),
+				slog.String("group", name),
+				slog.Duration("retry_after", wait),
+			)
+			c.Header("Retry-After", retryAfter(wait))
+			apierror.Respond(c, apierror.New(http.StatusTooManyRequests, apierror.CodeTooManyRequests, "too many requests, retry later"))
+			return
+		}
+
+		c.Next()
+
+		switch c.Writer.Status() {
+		case http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound:
+		default:
+			return
+		}
+
+		blocked, err := l.Fail(c, name, client, time.Now())
+		if err != nil {
+			slog.ErrorContext(c, "unable to count failed request", slog.String("group", name), slog.Any("error", err))
+			return
+		}
+
+		if blocked > 0 {
+			slog.WarnContext(c, "client blocked after repeated failures",
+				slog.String("client", client),
+				slog.String("group", name),
+				slog.Duration("blocked", blocked),
+			)
+		}
+	}
+}
+
+// clientIP is the address gin resolves through the trusted proxies. The
+// Lambda adapter sets RemoteAddr to the source IP without a port, which gin
+// does not parse.
+func clientIP(c *gin.Context) string {
+	ip := c.ClientIP()
+	if ip == "" {
+		ip = c.Request.RemoteAddr
+	}
+
+	return ip
+}
+
+// retryAfter rounds up to whole seconds, so a client that waits as told is
+// never limited again right away.
+func retryAfter(wait time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(wait.Seconds())))
+}
@@ -0,0 +1,147 @@
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"personal-vault/internal/apierror"
+	"personal-vault/internal/db"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+type failingStore struct{}
+
+func (failingStore) UpdateRateLimit(ctx context.Context, key string, now time.Time, update func(*db.RateLimit)) (db.RateLimit, error) {
+	return db.RateLimit{}, errors.New("table unavailable")
+}
+
+func newTestRouter(limiter Limiter) *gin.Engine {
+	router := gin.New()
+	router.Use(limiter.Middleware(func(c *gin.Context) string {
+		if c.FullPath() == "/healthcheck" {
+			return ""
+		}
+		return "retrieve"
+	}))
+
+	router.GET("/healthcheck", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.GET("/retrieve/:id", func(c *gin.Context) {
+		if c.Param("id") != "known" {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+
+	return router
+}
+
+func serve(router *gin.Engine, path, remoteAddr string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.RemoteAddr = remoteAddr
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	return w
+}
+
+func TestMiddleware(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		limiter    Limiter
+		paths      []string
+		remoteAddr string
+		want       []int
+	}{
+		{
+			name:       "throttles after the burst",
+			limiter:    Limiter{Store: NewMemoryStore(), Limits: map[string]Limit{"retrieve": {Rate: 0.01, Burst: 2}}},
+			paths:      []string{"/retrieve/known", "/retrieve/known", "/retrieve/known"},
+			remoteAddr: "192.0.2.1:1234",
+			want:       []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests},
+		},
+		{
+			name:       "never limits the empty group",
+			limiter:    Limiter{Store: NewMemoryStore(), Limits: map[string]Limit{"retrieve": {Rate: 0.01, Burst: 1}}},
+			paths:      []string{"/healthcheck", "/healthcheck", "/healthcheck"},
+			remoteAddr: "192.0.2.1:1234",
+			want:       []int{http.StatusOK, http.StatusOK, http.StatusOK},
+		},
+		{
+			name: "blocks after repeated not found",
+			limiter: Limiter{
+				Store:   NewMemoryStore(),
+				Limits:  map[string]Limit{"retrieve": {Rate: 100, Burst: 100}},
+				Penalty: Penalty{Failures: 2, Window: time.Minute, Block: time.Minute, MaxBlock: time.Hour},
+			},
+			paths:      []string{"/retrieve/guess-1", "/retrieve/guess-2", "/retrieve/known"},
+			remoteAddr: "192.0.2.1:1234",
+			want:       []int{http.StatusNotFound, http.StatusNotFound, http.StatusTooManyRequests},
+		},
+		{
+			name:       "fails open",
+			limiter:    Limiter{Store: failingStore{}, Limits: map[string]Limit{"retrieve": {Rate: 0.01, Burst: 1}}},
+			paths:      []string{"/retrieve/known", "/retrieve/known"},
+			remoteAddr: "192.0.2.1:1234",
+			want:       []int{http.StatusOK, http.StatusOK},
+		},
+		{
+			name:       "zero value",
+			limiter:    Limiter{},
+			paths:      []string{"/retrieve/known", "/retrieve/known"},
+			remoteAddr: "192.0.2.1:1234",
+			want:       []int{http.StatusOK, http.StatusOK},
+		},
+		{
+			name:       "lambda source ip without port",
+			limiter:    Limiter{Store: NewMemoryStore(), Limits: map[string]Limit{"retrieve": {Rate: 0.01, Burst: 1}}},
+			paths:      []string{"/retrieve/known", "/retrieve/known"},
+			remoteAddr: "192.0.2.1",
+			want:       []int{http.StatusOK, http.StatusTooManyRequests},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			router := newTestRouter(tt.limiter)
+
+			var got []int
+			for _, path := range tt.paths {
+				got = append(got, serve(router, path, tt.remoteAddr).Code)
+			}
+
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestMiddleware_TooManyRequests(t *testing.T) {
+	t.Parallel()
+
+	router := newTestRouter(Limiter{Store: NewMemoryStore(), Limits: map[string]Limit{"retrieve": {Rate: 0.25, Burst: 1}}})
+
+	assert.Equal(t, http.StatusOK, serve(router, "/retrieve/known", "192.0.2.1:1234").Code)
+
+	// another client has its own bucket
+	assert.Equal(t, http.StatusOK, serve(router, "/retrieve/known", "192.0.2.2:1234").Code)
+
+	w := serve(router, "/retrieve/known", "192.0.2.1:5678")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "4", w.Header().Get("Retry-After"))
+
+	var body apierror.Error
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, apierror.CodeTooManyRequests, body.Code)
+}
@@ -0,0 +1,197 @@
+// Package ratelimit throttles API clients by IP with token buckets, and
+// blocks clients whose requests keep failing, such as ones guessing entry ids.
+package ratelimit
+
+import (
+	"context"
+	"personal-vault/internal/db"
+	"sync"
+	"time"
+)
+
+// Limit is a token bucket that holds Burst requests and refills at Rate
+// requests per second.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// fill is how long an empty bucket takes to refill.
+func (l Limit) fill() time.Duration {
+	return time.Duration(float64(l.Burst) / l.Rate * float64(time.Second))
+}
+
+// Penalty blocks a client after Failures failed requests within Window. The
+// first block lasts Block and every further one twice as long as the last, up
+// to MaxBlock. A zero Penalty blocks no one.
+type Penalty struct {
+	Failures int
+	Window   time.Duration
+	Block    time.Duration
+	MaxBlock time.Duration
+}
+
+// duration of the nth block
+func (p Penalty) duration(n int) time.Duration {
+	block := p.Block
+	for i := 1; i < n && block < p.MaxBlock; i++ {
+		block *= 2
+	}
+
+	return min(block, p.MaxBlock)
+}
+
+// Store keeps the state of each client. UpdateRateLimit applies update
+// atomically, and passes state that expired before now as a new state. It is
+// implemented by MemoryStore and by db.DynamoDBClient, which shares the state
+// between instances.
+type Store interface {
+	UpdateRateLimit(ctx context.Context, key string, now time.Time, update func(*db.RateLimit)) (db.RateLimit, error)
+}
+
+// Limiter applies a Limit per route group, see Middleware. The zero value
+// limits nothing.
+type Limiter struct {
+	Store   Store
+	Limits  map[string]Limit
+	Penalty Penalty
+}
+
+func (l Limiter) Enabled() bool {
+	return l.Store != nil
+}
+
+// Allow takes a request of client from its bucket in group. It returns how
+// long the client has to wait when the bucket is empty or the client is
+// blocked, and 0 when the request may proceed.
+func (l Limiter) Allow(ctx context.Context, group, client string, now time.Time) (time.Duration, error) {
+	limit, ok := l.Limits[group]
+	if !ok {
+		return 0, nil
+	}
+
+	var wait time.Duration
+
+	_, err := l.Store.UpdateRateLimit(ctx, group+"#"+client, now, func(state *db.RateLimit) {
+		wait = 0
+
+		if state.BlockedUntil.After(now) {
+			wait = state.BlockedUntil.Sub(now)
+			return
+		}
+
+		// a new bucket starts full
+		tokens := float64(limit.Burst)
+		if !state.UpdatedAt.IsZero() {
+			tokens = min(tokens, state.Tokens+now.Sub(state.UpdatedAt).Seconds()*limit.Rate)
+		}
+
+		state.UpdatedAt = now
+		state.Tokens = tokens
+
+		if tokens < 1 {
+			wait = time.Duration((1 - tokens) / limit.Rate * float64(time.Second))
+		} else {
+			state.Tokens--
+		}
+
+		state.ExpiresAt = l.expiresAt(limit, *state, now)
+	})
+
+	return wait, err
+}
+
+// Fail counts a failed request of client in group, and blocks the client once
+// the failures within the penalty window reach the limit. It returns how long
+// the client is blocked for, or 0.
+func (l Limiter) Fail(ctx context.Context, group, client string, now time.Time) (time.Duration, error) {
+	limit, ok := l.Limits[group]
+	if !ok || l.Penalty.Failures <= 0 {
+		return 0, nil
+	}
+
+	var blocked time.Duration
+
+	_, err := l.Store.UpdateRateLimit(ctx, group+"#"+client, now, func(state *db.RateLimit) {
+		blocked = 0
+
+		if now.Sub(state.WindowStart) >= l.Penalty.Window {
+			state.Failures = 0
+			state.WindowStart = now
+		}
+
+		state.Failures++
+
+		if state.Failures >= l.Penalty.Failures {
+			state.Blocks++
+			blocked = l.Penalty.duration(state.Blocks)
+			state.BlockedUntil = now.Add(blocked)
+			state.Failures = 0
+			state.WindowStart = now
+		}
+
+		state.ExpiresAt = l.expiresAt(limit, *state, now)
+	})
+
+	return blocked, err
+}
+
+// expiresAt keeps the state until the bucket is full again, the failures have
+// left the window and a further block would no longer be longer than the last.
+// A new state behaves the same from then on.
+func (l Limiter) expiresAt(limit Limit, state db.RateLimit, now time.Time) time.Time {
+	start := now
+	if state.BlockedUntil.After(now) {
+		start = state.BlockedUntil
+	}
+
+	return start.Add(max(limit.fill(), l.Penalty.Window, l.Penalty.MaxBlock))
+}
+
+// sweepInterval is how often MemoryStore forgets expired state.
+const sweepInterval = time.Minute
+
+// MemoryStore keeps the state in the process. Every instance of the server
+// then limits on its own.
+type MemoryStore struct {
+	mu     sync.Mutex
+	states map[string]db.RateLimit
+	swept  time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{states: map[string]db.RateLimit{}}
+}
+
+func (s *MemoryStore) UpdateRateLimit(ctx context.Context, key string, now time.Time, update func(*db.RateLimit)) (db.RateLimit, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.swept) >= sweepInterval {
+		for k, state := range s.states {
+			if !state.ExpiresAt.After(now) {
+				delete(s.states, k)
+			}
+		}
+
+		s.swept = now
+	}
+
+	state := s.states[key]
+	if !state.ExpiresAt.After(now) {
+		state = db.RateLimit{}
+	}
+
+	update(&state)
+	s.states[key] = state
+
+	return state, nil
+}
+
+// Len returns the number of clients with state, expired or not.
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.states)
+}
@@ -0,0 +1,177 @@
+package ratelimit
+
+import (
+	"context"
+	"personal-vault/internal/db"
+	"personal-vault/internal/dbtest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	testLimits  = map[string]Limit{"retrieve": {Rate: 1, Burst: 3}}
+	testPenalty = Penalty{Failures: 3, Window: time.Minute, Block: time.Minute, MaxBlock: 3 * time.Minute}
+)
+
+// stores runs every test against both stores, which must behave the same.
+func stores() map[string]func() Store {
+	return map[string]func() Store{
+		"memory": func() Store { return NewMemoryStore() },
+		"dynamodb": func() Store {
+			return db.DynamoDBClient{API: dbtest.NewMemoryAPI(), TableName: "vault"}
+		},
+	}
+}
+
+func TestLimiter_Allow(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		group    string
+		requests []time.Duration
+		want     []time.Duration
+	}{
+		{
+			name:     "burst then wait",
+			group:    "retrieve",
+			requests: []time.Duration{0, 0, 0, 0, 500 * time.Millisecond},
+			want:     []time.Duration{0, 0, 0, time.Second, 500 * time.Millisecond},
+		},
+		{
+			name:     "refills at rate",
+			group:    "retrieve",
+			requests: []time.Duration{0, 0, 0, time.Second, time.Second},
+			want:     []time.Duration{0, 0, 0, 0, time.Second},
+		},
+		{
+			name:     "full again after idling",
+			group:    "retrieve",
+			requests: []time.Duration{0, 0, 0, time.Hour, time.Hour, time.Hour},
+			want:     []time.Duration{0, 0, 0, 0, 0, 0},
+		},
+		{
+			name:     "group without limit",
+			group:    "default",
+			requests: []time.Duration{0, 0, 0, 0, 0},
+			want:     []time.Duration{0, 0, 0, 0, 0},
+		},
+	}
+
+	for name, newStore := range stores() {
+		for _, tt := range tests {
+			newStore, tt := newStore, tt
+			t.Run(name+"/"+tt.name, func(t *testing.T) {
+				t.Parallel()
+
+				limiter := Limiter{Store: newStore(), Limits: testLimits, Penalty: testPenalty}
+
+				var got []time.Duration
+				for _, at := range tt.requests {
+					wait, err := limiter.Allow(context.Background(), tt.group, "192.0.2.1", start.Add(at))
+					assert.NoError(t, err)
+					got = append(got, wait)
+				}
+
+				assert.Equal(t, tt.want, got)
+
+				// buckets are per client
+				wait, err := limiter.Allow(context.Background(), tt.group, "192.0.2.2", start)
+				assert.NoError(t, err)
+				assert.Zero(t, wait)
+			})
+		}
+	}
+}
+
+func TestLimiter_Fail(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+
+	for name, newStore := range stores() {
+		newStore := newStore
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			limiter := Limiter{Store: newStore(), Limits: testLimits, Penalty: testPenalty}
+			client := "192.0.2.1"
+
+			fail := func(at time.Time) time.Duration {
+				blocked, err := limiter.Fail(ctx, "retrieve", client, at)
+				assert.NoError(t, err)
+				return blocked
+			}
+
+			allow := func(at time.Time) time.Duration {
+				wait, err := limiter.Allow(ctx, "retrieve", client, at)
+				assert.NoError(t, err)
+				return wait
+			}
+
+			// failures spread over more than the window never block
+			assert.Zero(t, fail(start))
+			assert.Zero(t, fail(start.Add(30*time.Second)))
+			now := start.Add(90 * time.Second)
+			assert.Zero(t, fail(now))
+			assert.Zero(t, fail(now.Add(time.Second)))
+
+			assert.Equal(t, time.Minute, fail(now.Add(2*time.Second)))
+			now = now.Add(2 * time.Second)
+			assert.Equal(t, time.Minute, allow(now))
+			assert.Equal(t, 30*time.Second, allow(now.Add(30*time.Second)))
+
+			// every further block lasts twice as long, up to the maximum
+			now = now.Add(time.Minute)
+			assert.Zero(t, allow(now))
+			for _, want := range []time.Duration{2 * time.Minute, 3 * time.Minute} {
+				fail(now)
+				fail(now)
+				assert.Equal(t, want, fail(now))
+				now = now.Add(want)
+			}
+
+			// a client that behaves long enough starts over
+			now = now.Add(4 * time.Minute)
+			fail(now)
+			fail(now)
+			assert.Equal(t, time.Minute, fail(now))
+		})
+	}
+}
+
+func TestLimiter_NoPenalty(t *testing.T) {
+	t.Parallel()
+
+	limiter := Limiter{Store: NewMemoryStore(), Limits: testLimits}
+	now := time.Now()
+
+	for i := 0; i < 10; i++ {
+		blocked, err := limiter.Fail(context.Background(), "retrieve", "192.0.2.1", now)
+		assert.NoError(t, err)
+		assert.Zero(t, blocked)
+	}
+}
+
+func TestMemoryStore_Sweep(t *testing.T) {
+	t.Parallel()
+
+	store := NewMemoryStore()
+	limiter := Limiter{Store: store, Limits: testLimits, Penalty: testPenalty}
+	now := time.Now()
+
+	for _, client := range []string{"192.0.2.1", "192.0.2.2"} {
+		_, err := limiter.Allow(context.Background(), "retrieve", client, now)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 2, store.Len())
+
+	_, err := limiter.Allow(context.Background(), "retrieve", "192.0.2.3", now.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, store.Len())
+}
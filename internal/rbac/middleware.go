@@ -0,0 +1,219 @@
+package rbac
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"personal-vault/internal/apierror"
+	"personal-vault/internal/db"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+)
+
+const principalKey = "rbac.principal"
+
+// Authorizer enforces the roles in gin middleware, before the handlers reach
+// the table. The zero value, with no tokens, leaves the API open.
+type Authorizer struct {
+	Client db.DynamoDBClient
+	// Tokens maps the hash of each bearer token, see HashToken, to its
+	// principal.
+	Tokens map[string]string
+	Admins []string
+}
+
+func (a Authorizer) Enabled() bool {
+	return len(a.Tokens) > 0
+}
+
+// Target returns the names of the entries a request acts on. A request that
+// does not address a single entry returns none.
+type Target func(c *gin.Context, client db.DynamoDBClient) ([]string, error)
+
+// Authenticate resolves the bearer token to a principal and loads the
+// principal's roles.
+func (a Authorizer) Authenticate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !a.Enabled() {
+			c.Next()
+			return
+		}
+
+		token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		name, known := a.Tokens[HashToken(token)]
+		if !ok || !known {
+			slog.WarnContext(c, "authentication failed", slog.String("method", c.Request.Method), slog.String("route", c.FullPath()))
+			c.Header("WWW-Authenticate", `Bearer realm="personal-vault"`)
+			apierror.Respond(c, apierror.New(http.StatusUnauthorized, apierror.CodeUnauthorized, "a valid bearer token is required"))
+			return
+		}
+
+		assignments, err := a.Client.RoleAssignmentsFor(c, name)
+		if err != nil {
+			slog.ErrorContext(c, "unable to load role assignments", slog.String("principal", name), slog.Any("error", err))
+			apierror.Respond(c, err)
+			return
+		}
+
+		principal := Principal{Name: name, Assignments: assignments}
+		for _, admin := range a.Admins {
+			principal.Admin = principal.Admin || admin == name
+		}
+
+		c.Set(principalKey, principal)
+		c.Next()
+	}
+}
+
+// Require aborts the request unless the principal may perform action on
+// every entry named by targets, or, when they name none, on some prefix.
+// Denied attempts are logged with the principal and route.
+func (a Authorizer) Require(action Action, targets ...Target) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := PrincipalFrom(c)
+		if !ok {
+			c.Next()
+			return
+		}
+
+		var names []string
+		for _, target := range targets {
+			targetNames, err := target(c, a.Client)
+			if err != nil {
+				slog.ErrorContext(c, "unable to resolve access target", slog.Any("error", err))
+				apierror.Respond(c, err)
+				return
+			}
+
+			names = append(names, targetNames...)
+		}
+
+		allowed := len(names) > 0 || principal.CanAny(action)
+		for _, name := range names {
+			allowed = allowed && principal.Can(action, name)
+		}
+
+		if !allowed {
+			slog.WarnContext(c, "access denied",
+				slog.String("principal", principal.Name),
+				slog.String("method", c.Request.Method),
+				slog.String("route", c.FullPath()),
+				slog.String("action", string(action)),
+				slog.Any("names", names),
+			)
+			apierror.Respond(c, apierror.New(http.StatusForbidden, apierror.CodeForbidden, "your role does not allow this on the entry"))
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireAdmin aborts the request unless the principal is one of the admins.
+// Requests pass when access control is disabled.
+func (a Authorizer) RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := PrincipalFrom(c)
+		if !ok || principal.Admin {
+			c.Next()
+			return
+		}
+
+		slog.WarnContext(c, "access denied",
+			slog.String("principal", principal.Name),
+			slog.String("method", c.Request.Method),
+			slog.String("route", c.FullPath()),
+			slog.String("action", "admin"),
+		)
+		apierror.Respond(c, apierror.New(http.StatusForbidden, apierror.CodeForbidden, "only admins can do this"))
+	}
+}
+
+// PrincipalFrom returns the principal set by Authenticate. It returns false
+// when access control is disabled.
+func PrincipalFrom(c *gin.Context) (Principal, bool) {
+	value, ok := c.Get(principalKey)
+	if !ok {
+		return Principal{}, false
+	}
+
+	principal, ok := value.(Principal)
+
+	return principal, ok
+}
+
+// Visible keeps the items the principal may list. Everything is visible when
+// access control is disabled.
+func Visible[T any](c *gin.Context, items []T, name func(T) string) []T {
+	principal, ok := PrincipalFrom(c)
+	if !ok {
+		return items
+	}
+
+	visible := make([]T, 0, len(items))
+	for _, item := range items {
+		if principal.Can(ActionList, name(item)) {
+			visible = append(visible, item)
+		}
+	}
+
+	return visible
+}
+
+// EntryParam targets the entry whose id is in the path parameter. Entries in
+// the trash count too. Invalid and unknown ids are left to the handler to
+// report.
+func EntryParam(param string) Target {
+	return func(c *gin.Context, client db.DynamoDBClient) ([]string, error) {
+		id := c.Param(param)
+		if _, err := uuid.Parse(id); err != nil {
+			return nil, nil
+		}
+
+		name, err := client.EntryName(c, id)
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return []string{name}, nil
+	}
+}
+
+// Body targets the names in the JSON body of the request. It binds the body
+// into the handler's own request type, exactly as the handler does with
+// ShouldBindBodyWith, so the checked names are the ones the handler acts on
+// whatever the case or order of the keys.
+func Body[T any](names func(request T) []string) Target {
+	return func(c *gin.Context, _ db.DynamoDBClient) ([]string, error) {
+		if c.Request.Body == nil {
+			return nil, nil
+		}
+
+		// malformed bodies are reported by the handler, which fails to bind
+		// them too
+		var request T
+		if c.ShouldBindBodyWith(&request, binding.JSON) != nil {
+			return nil, nil
+		}
+
+		return names(request), nil
+	}
+}
+
+// QueryField targets the name in a query parameter, if present.
+func QueryField(field string) Target {
+	return func(c *gin.Context, _ db.DynamoDBClient) ([]string, error) {
+		value, ok := c.GetQuery(field)
+		if !ok {
+			return nil, nil
+		}
+
+		return []string{value}, nil
+	}
+}
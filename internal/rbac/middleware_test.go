@@ -0,0 +1,170 @@
+package rbac
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"personal-vault/internal/apierror"
+	"personal-vault/internal/db"
+	"personal-vault/internal/dbtest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	prodID = "6b2bfbc0-8c23-414b-9c39-cf9b76520b39"
+	devID  = "0e0f4b7c-8f4e-4d6a-9a43-3c1e2c1a5d2f"
+)
+
+// nameRequest stands in for the request types of the handlers.
+type nameRequest struct {
+	Name     *string `json:"name"`
+	Password string  `json:"password"`
+}
+
+func newTestRouter(t *testing.T, auth Authorizer) *gin.Engine {
+	t.Helper()
+
+	ctx := context.Background()
+	for id, name := range map[string]string{prodID: "prod/db", devID: "dev/db"} {
+		_, err := auth.Client.PutItem(ctx, db.VaultEntity{ID: id, Name: name, Password: "sealed"})
+		assert.NoError(t, err)
+	}
+
+	for _, assignment := range []db.RoleAssignment{
+		{Principal: "bob", Prefix: "prod/", Role: string(RoleViewer)},
+		{Principal: "carol", Prefix: "prod/", Role: string(RoleUse)},
+	} {
+		_, err := auth.Client.PutRoleAssignment(ctx, assignment)
+		assert.NoError(t, err)
+	}
+
+	// echo binds the body like the handlers do and shows the name it acts on
+	echo := func(c *gin.Context) {
+		var request nameRequest
+		if c.Request.ContentLength > 0 && c.ShouldBindBodyWith(&request, binding.JSON) != nil {
+			c.Status(http.StatusBadRequest)
+			return
+		}
+
+		c.String(http.StatusOK, aws.ToString(request.Name))
+	}
+	name := Body(func(request nameRequest) []string {
+		if request.Name == nil {
+			return nil
+		}
+
+		return []string{*request.Name}
+	})
+
+	list := func(c *gin.Context) {
+		names := Visible(c, []string{"prod/db", "dev/db"}, func(name string) string { return name })
+		c.JSON(http.StatusOK, names)
+	}
+
+	router := gin.New()
+	protected := router.Group("", auth.Authenticate())
+	protected.GET("/list", auth.Require(ActionList), list)
+	protected.GET("/entries/:id", auth.Require(ActionReveal, EntryParam("id")), echo)
+	protected.POST("/save", auth.Require(ActionWrite, name), echo)
+	protected.PATCH("/entries/:id", auth.Require(ActionWrite, EntryParam("id"), name), echo)
+
+	return router
+}
+
+func TestAuthorizer(t *testing.T) {
+	t.Parallel()
+
+	auth := Authorizer{
+		Client: db.DynamoDBClient{API: dbtest.NewMemoryAPI(), TableName: "vault"},
+		Tokens: map[string]string{
+			HashToken("alice-token"): "alice",
+			HashToken("bob-token"):   "bob",
+			HashToken("carol-token"): "carol",
+			HashToken("dave-token"):  "dave",
+		},
+		Admins: []string{"alice"},
+	}
+	router := newTestRouter(t, auth)
+
+	tests := []struct {
+		name           string
+		token          string
+		method         string
+		path           string
+		body           string
+		expectedStatus int
+		expectedCode   string
+		expectedBody   string
+	}{
+		{name: "no token", method: http.MethodGet, path: "/list", expectedStatus: http.StatusUnauthorized, expectedCode: apierror.CodeUnauthorized},
+		{name: "unknown token", token: "guess", method: http.MethodGet, path: "/list", expectedStatus: http.StatusUnauthorized, expectedCode: apierror.CodeUnauthorized},
+		{name: "admin lists everything", token: "alice-token", method: http.MethodGet, path: "/list", expectedStatus: http.StatusOK, expectedBody: `["prod/db","dev/db"]`},
+		{name: "list is filtered by prefix", token: "bob-token", method: http.MethodGet, path: "/list", expectedStatus: http.StatusOK, expectedBody: `["prod/db"]`},
+		{name: "no role at all", token: "dave-token", method: http.MethodGet, path: "/list", expectedStatus: http.StatusForbidden, expectedCode: apierror.CodeForbidden},
+		{name: "viewer reveals", token: "bob-token", method: http.MethodGet, path: "/entries/" + prodID, expectedStatus: http.StatusOK},
+		{name: "viewer outside the prefix", token: "bob-token", method: http.MethodGet, path: "/entries/" + devID, expectedStatus: http.StatusForbidden, expectedCode: apierror.CodeForbidden},
+		{name: "use cannot reveal", token: "carol-token", method: http.MethodGet, path: "/entries/" + prodID, expectedStatus: http.StatusForbidden, expectedCode: apierror.CodeForbidden},
+		{name: "unknown id is left to the handler", token: "bob-token", method: http.MethodGet, path: "/entries/9c4d1a54-4a49-4ae5-8f1e-f1a8c2ae4a10", expectedStatus: http.StatusOK},
+		{name: "use fills a password", token: "carol-token", method: http.MethodPost, path: "/save", body: `{"name":"prod/api","password":"p"}`, expectedStatus: http.StatusOK, expectedBody: "prod/api"},
+		// encoding/json matches keys case-insensitively and keeps the last
+		// duplicate, and the check sees the name the handler binds
+		{name: "mixed-case key", token: "carol-token", method: http.MethodPost, path: "/save", body: `{"Name":"dev/x"}`, expectedStatus: http.StatusForbidden, expectedCode: apierror.CodeForbidden},
+		{name: "duplicate keys", token: "carol-token", method: http.MethodPost, path: "/save", body: `{"name":"prod/x","NAME":"dev/x"}`, expectedStatus: http.StatusForbidden, expectedCode: apierror.CodeForbidden},
+		{name: "duplicate keys within the prefix", token: "carol-token", method: http.MethodPost, path: "/save", body: `{"name":"dev/x","NAME":"prod/x"}`, expectedStatus: http.StatusOK, expectedBody: "prod/x"},
+		{name: "mixed-case rename out of the prefix", token: "carol-token", method: http.MethodPatch, path: "/entries/" + prodID, body: `{"nAmE":"dev/api"}`, expectedStatus: http.StatusForbidden, expectedCode: apierror.CodeForbidden},
+		{name: "malformed body is left to the handler", token: "carol-token", method: http.MethodPost, path: "/save", body: `{"name":`, expectedStatus: http.StatusBadRequest},
+		{name: "viewer cannot save", token: "bob-token", method: http.MethodPost, path: "/save", body: `{"name":"prod/api"}`, expectedStatus: http.StatusForbidden, expectedCode: apierror.CodeForbidden},
+		{name: "rename out of the prefix", token: "carol-token", method: http.MethodPatch, path: "/entries/" + prodID, body: `{"name":"dev/api"}`, expectedStatus: http.StatusForbidden, expectedCode: apierror.CodeForbidden},
+		{name: "update within the prefix", token: "carol-token", method: http.MethodPatch, path: "/entries/" + prodID, body: `{"password":"new"}`, expectedStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.expectedStatus, w.Code)
+
+			if tt.expectedCode != "" {
+				var apiErr apierror.Error
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &apiErr))
+				assert.Equal(t, tt.expectedCode, apiErr.Code)
+			}
+
+			if tt.expectedBody != "" {
+				assert.Equal(t, tt.expectedBody, w.Body.String())
+			}
+
+			if tt.expectedStatus == http.StatusUnauthorized {
+				assert.Contains(t, w.Header().Get("WWW-Authenticate"), "Bearer")
+			}
+		})
+	}
+}
+
+func TestAuthorizer_Disabled(t *testing.T) {
+	t.Parallel()
+
+	router := newTestRouter(t, Authorizer{Client: db.DynamoDBClient{API: dbtest.NewMemoryAPI(), TableName: "vault"}})
+
+	req := httptest.NewRequest(http.MethodGet, "/list", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `["prod/db","dev/db"]`, w.Body.String())
+}
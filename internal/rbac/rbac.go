@@ -0,0 +1,115 @@
+// Package rbac grants API callers roles on entries by name prefix. Callers
+// are identified by bearer tokens, and the role assignments are stored in the
+// table, see db.RoleAssignment.
+package rbac
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"personal-vault/internal/db"
+	"strings"
+)
+
+type Role string
+
+const (
+	RoleOwner  Role = "owner"
+	RoleEditor Role = "editor"
+	RoleViewer Role = "viewer"
+	// RoleUse can fill in a password, by creating or updating the entry, but
+	// never reveal it.
+	RoleUse Role = "use"
+)
+
+type Action string
+
+const (
+	// ActionList shows entry metadata, never the password.
+	ActionList   Action = "list"
+	ActionReveal Action = "reveal"
+	ActionWrite  Action = "write"
+	// ActionDelete moves entries to the trash and restores them.
+	ActionDelete Action = "delete"
+	// ActionGrant manages the roles on a prefix.
+	ActionGrant Action = "grant"
+)
+
+var permissions = map[Role][]Action{
+	RoleOwner:  {ActionList, ActionReveal, ActionWrite, ActionDelete, ActionGrant},
+	RoleEditor: {ActionList, ActionReveal, ActionWrite, ActionDelete},
+	RoleViewer: {ActionList, ActionReveal},
+	RoleUse:    {ActionList, ActionWrite},
+}
+
+func (r Role) Allows(action Action) bool {
+	for _, allowed := range permissions[r] {
+		if allowed == action {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Principal is an authenticated API caller. Admins hold every role on every
+// entry; they grant the first roles.
+type Principal struct {
+	Name        string
+	Admin       bool
+	Assignments []db.RoleAssignment
+}
+
+// Can reports whether any of the principal's roles allows action on the
+// entry called name.
+func (p Principal) Can(action Action, name string) bool {
+	if p.Admin {
+		return true
+	}
+
+	for _, assignment := range p.Assignments {
+		if strings.HasPrefix(name, assignment.Prefix) && Role(assignment.Role).Allows(action) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CanAny reports whether the principal may perform action on at least one
+// prefix.
+func (p Principal) CanAny(action Action) bool {
+	if p.Admin {
+		return true
+	}
+
+	for _, assignment := range p.Assignments {
+		if Role(assignment.Role).Allows(action) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NewToken returns a random bearer token and the hash to configure for it.
+func NewToken() (token, hash string, err error) {
+	raw := make([]byte, 32)
+
+	_, err = rand.Read(raw)
+	if err != nil {
+		return "", "", err
+	}
+
+	token = base64.RawURLEncoding.EncodeToString(raw)
+
+	return token, HashToken(token), nil
+}
+
+// HashToken returns the hex encoded SHA-256 of token. Only hashes are
+// configured, so the config file never holds a usable token.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,70 @@
+package rbac
+
+import (
+	"personal-vault/internal/db"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrincipal_Can(t *testing.T) {
+	t.Parallel()
+
+	principal := Principal{Name: "bob", Assignments: []db.RoleAssignment{
+		{Principal: "bob", Prefix: "prod/", Role: string(RoleViewer)},
+		{Principal: "bob", Prefix: "prod/ci/", Role: string(RoleUse)},
+		{Principal: "bob", Prefix: "dev/", Role: string(RoleOwner)},
+	}}
+
+	tests := []struct {
+		name     string
+		action   Action
+		entry    string
+		expected bool
+	}{
+		{name: "viewer reveals", action: ActionReveal, entry: "prod/db", expected: true},
+		{name: "viewer cannot write", action: ActionWrite, entry: "prod/db", expected: false},
+		{name: "roles add up on nested prefixes", action: ActionWrite, entry: "prod/ci/token", expected: true},
+		{name: "roles on a parent prefix still apply", action: ActionReveal, entry: "prod/ci/token", expected: true},
+		{name: "owner grants", action: ActionGrant, entry: "dev/", expected: true},
+		{name: "no role on other prefixes", action: ActionList, entry: "staging/db", expected: false},
+		{name: "prefixes are not path segments", action: ActionList, entry: "production", expected: false},
+		{name: "no role on the whole vault", action: ActionGrant, entry: "", expected: false},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.expected, principal.Can(tt.action, tt.entry))
+		})
+	}
+}
+
+func TestRole_Allows(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, RoleUse.Allows(ActionWrite))
+	assert.False(t, RoleUse.Allows(ActionReveal), "use fills passwords but never reveals them")
+	assert.False(t, RoleEditor.Allows(ActionGrant))
+	assert.False(t, Role("unknown").Allows(ActionList))
+
+	admin := Principal{Name: "alice", Admin: true}
+	assert.True(t, admin.Can(ActionGrant, ""))
+	assert.True(t, admin.CanAny(ActionDelete))
+	assert.False(t, Principal{Name: "nobody"}.CanAny(ActionList))
+}
+
+func TestNewToken(t *testing.T) {
+	t.Parallel()
+
+	token, hash, err := NewToken()
+	assert.NoError(t, err)
+	assert.Len(t, token, 43)
+	assert.Equal(t, HashToken(token), hash)
+
+	other, _, err := NewToken()
+	assert.NoError(t, err)
+	assert.NotEqual(t, token, other)
+}
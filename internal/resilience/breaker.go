@@ -0,0 +1,110 @@
+package resilience
+
+import (
+	"fmt"
+	"personal-vault/internal/db"
+	"personal-vault/internal/metrics"
+	"sync"
+	"time"
+)
+
+// ErrOpen is returned for calls failed fast by an open Breaker. It wraps
+// db.ErrUnavailable.
+var ErrOpen = fmt.Errorf("%w: circuit breaker open", db.ErrUnavailable)
+
+type state int
+
+const (
+	closed state = iota
+	open
+	halfOpen
+)
+
+// Breaker opens after Failures consecutive failed calls and then fails every
+// call fast for OpenTimeout. After that one call is let through: its success
+// closes the breaker again, its failure reopens it. A nil Breaker, or one with
+// Failures 0, never opens.
+type Breaker struct {
+	Failures    int
+	OpenTimeout time.Duration
+
+	mu       sync.Mutex
+	state    state
+	failures int
+	openedAt time.Time
+}
+
+func NewBreaker(failures int, openTimeout time.Duration) *Breaker {
+	return &Breaker{Failures: failures, OpenTimeout: openTimeout}
+}
+
+// Allow returns ErrOpen unless a call may go ahead at now. Every allowed call
+// must be followed by Done.
+func (b *Breaker) Allow(now time.Time) error {
+	if b == nil || b.Failures <= 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case open:
+		if now.Sub(b.openedAt) < b.OpenTimeout {
+			return ErrOpen
+		}
+
+		// this call probes whether DynamoDB is back
+		b.state = halfOpen
+		return nil
+	case halfOpen:
+		return ErrOpen
+	default:
+		return nil
+	}
+}
+
+// Done records the result of an allowed call. A call that neither succeeded
+// nor failed, e.g. one canceled by its caller, only ends a probe.
+func (b *Breaker) Done(now time.Time, result Result) {
+	if b == nil || b.Failures <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch {
+	case result == Succeeded:
+		b.failures = 0
+		b.setState(closed)
+	case result == Failed:
+		b.failures++
+		if b.state == halfOpen || b.failures >= b.Failures {
+			b.openedAt = now
+			b.setState(open)
+		}
+	case b.state == halfOpen:
+		// let the next call probe
+		b.state = open
+		b.openedAt = now.Add(-b.OpenTimeout)
+	}
+}
+
+func (b *Breaker) setState(s state) {
+	b.state = s
+	metrics.SetCircuitOpen(s != closed)
+}
+
+// Result is the outcome of a call as seen by a Breaker.
+type Result int
+
+const (
+	// Succeeded calls got an answer from DynamoDB, even an error such as a
+	// failed condition.
+	Succeeded Result = iota
+	// Failed calls got no answer, or a server error.
+	Failed
+	// Abandoned calls were canceled by their caller.
+	Abandoned
+)
@@ -0,0 +1,254 @@
+// Package resilience bounds the DynamoDB calls of the server. Every attempt
+// has a deadline, throttled and transient failures are retried with jittered
+// backoff, and a circuit breaker fails calls fast while DynamoDB is down.
+package resilience
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"personal-vault/internal/db"
+	"personal-vault/internal/metrics"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// Retry reasons, as counted by metrics.ObserveRetry.
+const (
+	ReasonThrottled = "throttled"
+	ReasonTransient = "transient"
+	ReasonTimeout   = "timeout"
+)
+
+// Policy decides how long each attempt may take and which failures are tried
+// again. Validation errors, failed conditions and other errors that would
+// fail the same way again are never retried.
+type Policy struct {
+	// Timeouts bounds each attempt by operation name, such as GetItem.
+	// Operations without a timeout have no deadline of their own.
+	Timeouts    map[string]time.Duration
+	MaxAttempts int
+	// BaseDelay is the backoff before the first retry of a transient failure
+	// and ThrottleDelay that of a throttled call, which needs more time to
+	// recover. Each further retry doubles it up to MaxDelay. The actual sleep
+	// is random between zero and the backoff.
+	BaseDelay     time.Duration
+	ThrottleDelay time.Duration
+	MaxDelay      time.Duration
+}
+
+// backoff is the longest sleep before the given retry, counted from 1.
+func (p Policy) backoff(retry int, reason string) time.Duration {
+	delay := p.BaseDelay
+	if reason == ReasonThrottled {
+		delay = p.ThrottleDelay
+	}
+
+	for i := 1; i < retry && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+
+	return min(delay, p.MaxDelay)
+}
+
+// retryReason returns why err may be retried, or "" when it may not. ctx is
+// the context of the call, whose end is never retried.
+func retryReason(ctx context.Context, err error) string {
+	var (
+		apiErr   smithy.APIError
+		respErr  *smithyhttp.ResponseError
+		sendErr  *smithyhttp.RequestSendError
+		netErr   net.Error
+		outcome  = db.Outcome(err)
+		finished = ctx.Err() != nil
+	)
+
+	switch {
+	case err == nil || finished:
+		return ""
+	case outcome == "throttled":
+		return ReasonThrottled
+	case errors.Is(err, context.DeadlineExceeded):
+		// the attempt ran out of time, not the call
+		return ReasonTimeout
+	case errors.As(err, &apiErr) && apiErr.ErrorFault() == smithy.FaultServer:
+		return ReasonTransient
+	case errors.As(err, &respErr) && respErr.HTTPStatusCode() >= 500:
+		return ReasonTransient
+	case errors.As(err, &apiErr):
+		return ""
+	case errors.As(err, &sendErr), errors.As(err, &netErr):
+		return ReasonTransient
+	default:
+		return ""
+	}
+}
+
+// unsent reports whether err shows that the request never reached DynamoDB,
+// because the connection could not be made.
+func unsent(err error) bool {
+	var (
+		opErr  *net.OpError
+		dnsErr *net.DNSError
+	)
+
+	return (errors.As(err, &opErr) && opErr.Op == "dial") || errors.As(err, &dnsErr)
+}
+
+// DynamoDBAPI applies Policy and Breaker to every call to API, in place of
+// the retries of the SDK. A write that may have been applied, because it
+// timed out or failed on the server, is only retried when its context is
+// marked with db.Idempotent. Counters and conditional writes would otherwise
+// be applied twice or fail their condition against their own first attempt.
+type DynamoDBAPI struct {
+	API     db.DynamoDBAPI
+	Policy  Policy
+	Breaker *Breaker
+}
+
+func (d DynamoDBAPI) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	var output *dynamodb.GetItemOutput
+	err := d.call(ctx, "GetItem", func(ctx context.Context) (err error) {
+		output, err = d.API.GetItem(ctx, params, withoutRetries(optFns)...)
+		return err
+	})
+
+	return output, err
+}
+
+func (d DynamoDBAPI) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	var output *dynamodb.PutItemOutput
+	err := d.call(ctx, "PutItem", func(ctx context.Context) (err error) {
+		output, err = d.API.PutItem(ctx, params, withoutRetries(optFns)...)
+		return err
+	})
+
+	return output, err
+}
+
+func (d DynamoDBAPI) UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	var output *dynamodb.UpdateItemOutput
+	err := d.call(ctx, "UpdateItem", func(ctx context.Context) (err error) {
+		output, err = d.API.UpdateItem(ctx, params, withoutRetries(optFns)...)
+		return err
+	})
+
+	return output, err
+}
+
+func (d DynamoDBAPI) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	var output *dynamodb.ScanOutput
+	err := d.call(ctx, "Scan", func(ctx context.Context) (err error) {
+		output, err = d.API.Scan(ctx, params, withoutRetries(optFns)...)
+		return err
+	})
+
+	return output, err
+}
+
+func (d DynamoDBAPI) DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	var output *dynamodb.DeleteItemOutput
+	err := d.call(ctx, "DeleteItem", func(ctx context.Context) (err error) {
+		output, err = d.API.DeleteItem(ctx, params, withoutRetries(optFns)...)
+		return err
+	})
+
+	return output, err
+}
+
+func (d DynamoDBAPI) DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error) {
+	var output *dynamodb.DescribeTableOutput
+	err := d.call(ctx, "DescribeTable", func(ctx context.Context) (err error) {
+		output, err = d.API.DescribeTable(ctx, params, withoutRetries(optFns)...)
+		return err
+	})
+
+	return output, err
+}
+
+// writes are the operations that change the table.
+var writes = map[string]bool{"PutItem": true, "UpdateItem": true, "DeleteItem": true}
+
+// call runs attempt until it succeeds, fails for good or runs out of
+// attempts. Calls that keep failing transiently return db.ErrUnavailable.
+// A write that is not idempotent returns db.ErrUncertain as soon as an
+// attempt may have been applied, so it is never sent twice.
+func (d DynamoDBAPI) call(ctx context.Context, operation string, attempt func(ctx context.Context) error) error {
+	idempotent := !writes[operation] || db.IsIdempotent(ctx)
+
+	for n := 1; ; n++ {
+		err := d.Breaker.Allow(time.Now())
+		if err != nil {
+			metrics.ObserveRejected(operation)
+			return err
+		}
+
+		err = d.attempt(ctx, operation, attempt)
+		reason := retryReason(ctx, err)
+
+		switch {
+		case ctx.Err() != nil:
+			d.Breaker.Done(time.Now(), Abandoned)
+		case reason == ReasonTransient || reason == ReasonTimeout:
+			d.Breaker.Done(time.Now(), Failed)
+		default:
+			d.Breaker.Done(time.Now(), Succeeded)
+		}
+
+		if reason == "" {
+			return err
+		}
+
+		// throttled requests and requests that never left are not applied
+		if !idempotent && reason != ReasonThrottled && !unsent(err) {
+			return fmt.Errorf("%w: %w", db.ErrUncertain, err)
+		}
+
+		if n >= d.Policy.MaxAttempts {
+			if reason == ReasonThrottled {
+				return err
+			}
+
+			return fmt.Errorf("%w: %w", db.ErrUnavailable, err)
+		}
+
+		metrics.ObserveRetry(operation, reason)
+
+		timer := time.NewTimer(time.Duration(rand.Int63n(int64(d.Policy.backoff(n, reason)) + 1)))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+func (d DynamoDBAPI) attempt(ctx context.Context, operation string, attempt func(ctx context.Context) error) error {
+	timeout := d.Policy.Timeouts[operation]
+	if timeout <= 0 {
+		return attempt(ctx)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	return attempt(ctx)
+}
+
+// withoutRetries turns off the retries of the SDK for one call. The options
+// of the caller are copied, not appended to.
+func withoutRetries(optFns []func(*dynamodb.Options)) []func(*dynamodb.Options) {
+	opts := make([]func(*dynamodb.Options), 0, len(optFns)+1)
+	opts = append(opts, optFns...)
+
+	return append(opts, func(o *dynamodb.Options) {
+		o.Retryer = aws.NopRetryer{}
+	})
+}
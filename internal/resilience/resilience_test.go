@@ -0,0 +1,359 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"personal-vault/internal/db"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockAPI returns errs in turn, then succeeds. With block set, calls wait
+// for their context to end instead.
+type mockAPI struct {
+	mu    sync.Mutex
+	errs  []error
+	calls int
+	block bool
+	opts  []func(*dynamodb.Options)
+}
+
+func (m *mockAPI) next(ctx context.Context, optFns []func(*dynamodb.Options)) error {
+	m.mu.Lock()
+	m.calls++
+	m.opts = optFns
+	var err error
+	if len(m.errs) > 0 {
+		err, m.errs = m.errs[0], m.errs[1:]
+	}
+	m.mu.Unlock()
+
+	if m.block {
+		<-ctx.Done()
+		return &smithy.OperationError{ServiceID: "DynamoDB", OperationName: "GetItem", Err: ctx.Err()}
+	}
+
+	return err
+}
+
+func (m *mockAPI) Calls() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.calls
+}
+
+func (m *mockAPI) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	err := m.next(ctx, optFns)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dynamodb.GetItemOutput{}, nil
+}
+
+func (m *mockAPI) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	err := m.next(ctx, optFns)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (m *mockAPI) UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	err := m.next(ctx, optFns)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dynamodb.UpdateItemOutput{}, nil
+}
+
+func (m *mockAPI) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	err := m.next(ctx, optFns)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dynamodb.ScanOutput{}, nil
+}
+
+func (m *mockAPI) DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	err := m.next(ctx, optFns)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dynamodb.DeleteItemOutput{}, nil
+}
+
+func (m *mockAPI) DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error) {
+	err := m.next(ctx, optFns)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dynamodb.DescribeTableOutput{}, nil
+}
+
+var testPolicy = Policy{
+	Timeouts:      map[string]time.Duration{"GetItem": 20 * time.Millisecond, "PutItem": 20 * time.Millisecond},
+	MaxAttempts:   3,
+	BaseDelay:     time.Millisecond,
+	ThrottleDelay: 2 * time.Millisecond,
+	MaxDelay:      5 * time.Millisecond,
+}
+
+var (
+	throughputErr = &types.ProvisionedThroughputExceededException{Message: aws.String("mock")}
+	throttlingErr = &smithy.GenericAPIError{Code: "ThrottlingException", Message: "mock"}
+	serverErr     = &types.InternalServerError{Message: aws.String("mock")}
+	validationErr = &smithy.GenericAPIError{Code: "ValidationException", Message: "mock", Fault: smithy.FaultClient}
+	conditionErr  = &types.ConditionalCheckFailedException{Message: aws.String("mock")}
+	missingErr    = &types.ResourceNotFoundException{Message: aws.String("mock")}
+	unavailErr    = &smithyhttp.ResponseError{
+		Response: &smithyhttp.Response{Response: &http.Response{StatusCode: http.StatusServiceUnavailable}},
+		Err:      errors.New("mock"),
+	}
+	sendErr = &smithyhttp.RequestSendError{Err: errors.New("connection refused")}
+	dialErr = &smithyhttp.RequestSendError{Err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}}
+)
+
+func TestDynamoDBAPI_Retries(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		errs          []error
+		idempotent    bool
+		expectedCalls int
+		expectedErr   error
+	}{
+		{name: "success", expectedCalls: 1},
+		{name: "throttled once", errs: []error{throughputErr}, expectedCalls: 2},
+		{name: "throttling exception", errs: []error{throttlingErr, throttlingErr}, expectedCalls: 3},
+		{name: "server error", errs: []error{serverErr}, idempotent: true, expectedCalls: 2},
+		{name: "service unavailable", errs: []error{unavailErr}, idempotent: true, expectedCalls: 2},
+		{name: "connection refused", errs: []error{sendErr, sendErr}, idempotent: true, expectedCalls: 3},
+		{name: "validation error", errs: []error{validationErr}, expectedCalls: 1, expectedErr: validationErr},
+		{name: "failed condition", errs: []error{conditionErr}, expectedCalls: 1, expectedErr: conditionErr},
+		{name: "missing table", errs: []error{missingErr}, expectedCalls: 1, expectedErr: missingErr},
+		{
+			name:          "always throttled",
+			errs:          []error{throughputErr, throughputErr, throughputErr, throughputErr},
+			expectedCalls: 3,
+			expectedErr:   throughputErr,
+		},
+		{
+			name:          "always failing",
+			errs:          []error{serverErr, serverErr, serverErr, serverErr},
+			idempotent:    true,
+			expectedCalls: 3,
+			expectedErr:   db.ErrUnavailable,
+		},
+		{
+			// DynamoDB may have applied the write, which is not sent again
+			name:          "server error on a write",
+			errs:          []error{serverErr},
+			expectedCalls: 1,
+			expectedErr:   db.ErrUncertain,
+		},
+		{name: "connection reset on a write", errs: []error{sendErr}, expectedCalls: 1, expectedErr: db.ErrUncertain},
+		{name: "throttled write", errs: []error{throughputErr}, expectedCalls: 2},
+		{name: "write not connected once", errs: []error{dialErr}, expectedCalls: 2},
+		{
+			name:          "never connected",
+			errs:          []error{dialErr, dialErr, dialErr, dialErr},
+			expectedCalls: 3,
+			expectedErr:   db.ErrUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mock := &mockAPI{errs: tt.errs}
+			api := DynamoDBAPI{API: mock, Policy: testPolicy}
+
+			ctx := context.Background()
+			if tt.idempotent {
+				ctx = db.Idempotent(ctx)
+			}
+
+			_, err := api.PutItem(ctx, &dynamodb.PutItemInput{})
+			assert.Equal(t, tt.expectedCalls, mock.Calls())
+			assert.ErrorIs(t, err, tt.expectedErr)
+			if tt.expectedErr == nil {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestDynamoDBAPI_Timeout(t *testing.T) {
+	t.Parallel()
+
+	mock := &mockAPI{block: true}
+	api := DynamoDBAPI{API: mock, Policy: testPolicy}
+
+	// every attempt of GetItem times out, and the last one is reported as
+	// unavailable
+	start := time.Now()
+	_, err := api.GetItem(context.Background(), &dynamodb.GetItemInput{})
+	assert.ErrorIs(t, err, db.ErrUnavailable)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 3, mock.Calls())
+	assert.Less(t, time.Since(start), time.Second)
+
+	// a write that timed out may have been applied and is not sent again
+	mock = &mockAPI{block: true}
+	api = DynamoDBAPI{API: mock, Policy: testPolicy}
+
+	_, err = api.PutItem(context.Background(), &dynamodb.PutItemInput{})
+	assert.ErrorIs(t, err, db.ErrUncertain)
+	assert.NotErrorIs(t, err, db.ErrUnavailable)
+	assert.Equal(t, 1, mock.Calls())
+
+	// unless sending it twice is harmless
+	mock = &mockAPI{block: true}
+	api = DynamoDBAPI{API: mock, Policy: testPolicy}
+
+	_, err = api.PutItem(db.Idempotent(context.Background()), &dynamodb.PutItemInput{})
+	assert.ErrorIs(t, err, db.ErrUnavailable)
+	assert.Equal(t, 3, mock.Calls())
+
+	// a call canceled by its caller is not retried
+	mock = &mockAPI{block: true}
+	api = DynamoDBAPI{API: mock, Policy: Policy{MaxAttempts: 3}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err = api.Scan(ctx, &dynamodb.ScanInput{})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.NotErrorIs(t, err, db.ErrUnavailable)
+	assert.Equal(t, 1, mock.Calls())
+}
+
+func TestDynamoDBAPI_DisablesSDKRetries(t *testing.T) {
+	t.Parallel()
+
+	mock := &mockAPI{}
+	api := DynamoDBAPI{API: mock, Policy: testPolicy}
+
+	callerOpts := make([]func(*dynamodb.Options), 1, 2)
+	callerOpts[0] = func(o *dynamodb.Options) {}
+
+	_, err := api.DescribeTable(context.Background(), &dynamodb.DescribeTableInput{}, callerOpts...)
+	assert.NoError(t, err)
+	assert.Len(t, mock.opts, 2)
+
+	var options dynamodb.Options
+	for _, opt := range mock.opts {
+		opt(&options)
+	}
+	assert.Equal(t, aws.NopRetryer{}, options.Retryer)
+	assert.Len(t, callerOpts, 1, "the caller's options are not appended to")
+}
+
+func TestPolicy_Backoff(t *testing.T) {
+	t.Parallel()
+
+	policy := Policy{BaseDelay: 25 * time.Millisecond, ThrottleDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	assert.Equal(t, 25*time.Millisecond, policy.backoff(1, ReasonTransient))
+	assert.Equal(t, 50*time.Millisecond, policy.backoff(2, ReasonTimeout))
+	assert.Equal(t, 100*time.Millisecond, policy.backoff(1, ReasonThrottled))
+	assert.Equal(t, 400*time.Millisecond, policy.backoff(3, ReasonThrottled))
+	assert.Equal(t, time.Second, policy.backoff(10, ReasonThrottled))
+}
+
+func TestBreaker(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	breaker := NewBreaker(2, time.Minute)
+
+	assert.NoError(t, breaker.Allow(now))
+	breaker.Done(now, Failed)
+	assert.NoError(t, breaker.Allow(now))
+	breaker.Done(now, Succeeded)
+
+	// two failures in a row open it
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, breaker.Allow(now))
+		breaker.Done(now, Failed)
+	}
+	assert.ErrorIs(t, breaker.Allow(now.Add(59*time.Second)), ErrOpen)
+	assert.ErrorIs(t, breaker.Allow(now), db.ErrUnavailable)
+
+	// after the timeout one probe goes through, and its failure reopens it
+	now = now.Add(time.Minute)
+	assert.NoError(t, breaker.Allow(now))
+	assert.ErrorIs(t, breaker.Allow(now), ErrOpen, "only one probe at a time")
+	breaker.Done(now, Failed)
+	assert.ErrorIs(t, breaker.Allow(now.Add(time.Second)), ErrOpen)
+
+	// an abandoned probe lets the next call probe
+	now = now.Add(time.Minute)
+	assert.NoError(t, breaker.Allow(now))
+	breaker.Done(now, Abandoned)
+	assert.NoError(t, breaker.Allow(now))
+
+	// a successful probe closes it
+	breaker.Done(now, Succeeded)
+	assert.NoError(t, breaker.Allow(now))
+	breaker.Done(now, Failed)
+	assert.NoError(t, breaker.Allow(now), "the failures were reset")
+	breaker.Done(now, Succeeded)
+
+	// nil and disabled breakers never open
+	var none *Breaker
+	none.Done(now, Failed)
+	assert.NoError(t, none.Allow(now))
+
+	disabled := NewBreaker(0, time.Minute)
+	disabled.Done(now, Failed)
+	assert.NoError(t, disabled.Allow(now))
+}
+
+func TestDynamoDBAPI_Breaker(t *testing.T) {
+	t.Parallel()
+
+	mock := &mockAPI{errs: []error{dialErr, dialErr, dialErr}}
+	api := DynamoDBAPI{API: mock, Policy: testPolicy, Breaker: NewBreaker(2, time.Minute)}
+
+	// the breaker opens on the second attempt and ends the retries
+	_, err := api.UpdateItem(context.Background(), &dynamodb.UpdateItemInput{})
+	assert.ErrorIs(t, err, ErrOpen)
+	assert.Equal(t, 2, mock.Calls())
+
+	// later calls fail fast without reaching DynamoDB
+	_, err = api.GetItem(context.Background(), &dynamodb.GetItemInput{})
+	assert.ErrorIs(t, err, db.ErrUnavailable)
+	assert.Equal(t, 2, mock.Calls())
+
+	// throttling and client errors do not count as failures
+	mock = &mockAPI{errs: []error{throughputErr, throughputErr, validationErr, conditionErr}}
+	api = DynamoDBAPI{API: mock, Policy: testPolicy, Breaker: NewBreaker(2, time.Minute)}
+
+	for i := 0; i < 2; i++ {
+		_, err = api.PutItem(context.Background(), &dynamodb.PutItemInput{})
+		assert.Error(t, err)
+	}
+	assert.Equal(t, 4, mock.Calls())
+	assert.NoError(t, api.Breaker.Allow(time.Now()))
+}
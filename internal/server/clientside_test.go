@@ -0,0 +1,195 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"personal-vault/internal/db"
+	"personal-vault/internal/dbtest"
+	"personal-vault/internal/handler"
+	"personal-vault/pkg/clientcrypto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestClientSideEncryption runs a vault in client-side mode and checks that
+// no password reaches the server: requests only carry sealed secrets, the
+// handlers have no key to encrypt with, and neither the table, the responses
+// nor the logs ever contain a password.
+func TestClientSideEncryption(t *testing.T) {
+	t.Parallel()
+
+	const (
+		passphrase = "correct horse battery staple"
+		first      = "first password 1f4c"
+		second     = "second password 9e2a"
+	)
+
+	api := dbtest.NewMemoryAPI()
+	client := db.DynamoDBClient{API: api, TableName: "vault"}
+
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	// built like serve builds them, with a master key configured, so every
+	// route of a client-side vault is covered
+	router := NewRouter(logger, NewHandlers(client, HandlerOptions{
+		Key:           strings.Repeat("k", 32),
+		ClientSide:    true,
+		Retention:     time.Hour,
+		KDFIterations: 1000,
+	}))
+
+	var (
+		seen      strings.Builder
+		basicAuth []string
+	)
+	serve := func(method, path, accept string, body any) *httptest.ResponseRecorder {
+		var data []byte
+		if body != nil {
+			var err error
+			data, err = json.Marshal(body)
+			assert.NoError(t, err)
+		}
+
+		req := httptest.NewRequest(method, path, bytes.NewReader(data))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", accept)
+		if basicAuth != nil {
+			req.SetBasicAuth(basicAuth[0], basicAuth[1])
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		seen.Write(data)
+		seen.Write(w.Body.Bytes())
+
+		return w
+	}
+
+	key, err := clientcrypto.NewKey(passphrase, clientcrypto.MinIterations)
+	assert.NoError(t, err)
+
+	sealed, err := key.Seal(first)
+	assert.NoError(t, err)
+
+	w := serve(http.MethodPost, "/save", "application/json", map[string]any{"name": "github", "secret": sealed})
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var saved handler.SaveResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &saved))
+
+	open := func() string {
+		// the secret comes back as JSON even when text is asked for
+		w := serve(http.MethodGet, "/retrieve/"+saved.ID, "text/plain", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var entry handler.EntryResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &entry))
+		assert.Empty(t, entry.Password)
+
+		password, err := clientcrypto.Open(*entry.Secret, passphrase)
+		assert.NoError(t, err)
+
+		return password
+	}
+
+	assert.Equal(t, first, open())
+
+	sealed, err = key.Seal(second)
+	assert.NoError(t, err)
+
+	w = serve(http.MethodPatch, "/entries/"+saved.ID, "application/json", map[string]any{"secret": sealed})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, second, open())
+
+	// plaintext is refused, the handlers could not encrypt it anyway
+	const refused = "refused password 77d0"
+	w = serve(http.MethodPost, "/save", "application/json", map[string]any{"name": "gitlab", "password": refused})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = serve(http.MethodPatch, "/entries/"+saved.ID, "application/json", map[string]any{"password": refused})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	sealed.KDF.Iterations = 1000
+	w = serve(http.MethodPost, "/save", "application/json", map[string]any{"name": "weak", "secret": sealed})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = serve(http.MethodPost, "/entries/"+saved.ID+"/share", "application/json", nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	// collection entries are encrypted by the server, so they are refused
+	// before their password is read
+	const collected = "collection password 3b81"
+	w = serve(http.MethodPost, "/users", "application/json", map[string]any{"name": "alice", "passphrase": "alice passphrase"})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	basicAuth = []string{"alice", "alice passphrase"}
+
+	w = serve(http.MethodPost, "/collections", "application/json", map[string]any{"name": "team"})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var collection handler.CollectionResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &collection))
+
+	w = serve(http.MethodPost, "/collections/"+collection.ID+"/entries", "application/json", map[string]any{"name": "db", "password": collected})
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = serve(http.MethodGet, "/collections/"+collection.ID+"/entries/"+saved.ID, "application/json", nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	basicAuth = nil
+
+	// the entry, its access stats, the user and the collection
+	output, err := api.Scan(context.Background(), &dynamodb.ScanInput{})
+	assert.NoError(t, err)
+	assert.Len(t, output.Items, 4)
+
+	var items []map[string]any
+	assert.NoError(t, attributevalue.UnmarshalListOfMaps(output.Items, &items))
+	stored, err := json.Marshal(items)
+	assert.NoError(t, err)
+
+	for _, password := range []string{first, second} {
+		assert.NotContains(t, string(stored), password)
+		assert.NotContains(t, seen.String(), password)
+		assert.NotContains(t, logs.String(), password)
+	}
+
+	assert.NotContains(t, string(stored), refused)
+	assert.NotContains(t, string(stored), collected)
+}
+
+func TestServerSideEncryption_RefusesSecrets(t *testing.T) {
+	t.Parallel()
+
+	// secret is for testing only
+	secret, err := hex.DecodeString("0f6f8edf954592d7523b475bb56fd0486b7a049d67c1e5aa522bbc8bfe961971")
+	assert.NoError(t, err)
+
+	client := db.DynamoDBClient{API: dbtest.NewMemoryAPI(), TableName: "vault"}
+	router := NewRouter(slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil)), Handlers{
+		Save: handler.SaveHandler{Client: client, Validate: handler.NewValidator(), Key: string(secret)},
+	})
+
+	key, err := clientcrypto.NewKey("correct horse battery staple", clientcrypto.MinIterations)
+	assert.NoError(t, err)
+
+	sealed, err := key.Seal("password")
+	assert.NoError(t, err)
+
+	body, err := json.Marshal(map[string]any{"name": "github", "password": "password", "secret": sealed})
+	assert.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/save", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "encrypts on the server")
+}
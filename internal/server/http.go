@@ -0,0 +1,117 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+)
+
+type Options struct {
+	Addr            string
+	CertFile        string
+	KeyFile         string
+	SelfSigned      bool
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	IdleTimeout     time.Duration
+	ShutdownTimeout time.Duration
+}
+
+func (o Options) tlsEnabled() bool {
+	return o.SelfSigned || o.CertFile != ""
+}
+
+// HTTPServer serves the router until its context is cancelled and then drains
+// in-flight requests before returning.
+type HTTPServer struct {
+	srv  *http.Server
+	opts Options
+}
+
+func NewHTTPServer(opts Options, handler http.Handler) (*HTTPServer, error) {
+	if (opts.CertFile == "") != (opts.KeyFile == "") {
+		return nil, errors.New("both TLS cert and key files must be set")
+	}
+
+	if opts.SelfSigned && opts.CertFile != "" {
+		return nil, errors.New("self-signed TLS cannot be combined with cert and key files")
+	}
+
+	srv := &http.Server{
+		Addr:              opts.Addr,
+		Handler:           handler,
+		ReadTimeout:       opts.ReadTimeout,
+		ReadHeaderTimeout: opts.ReadTimeout,
+		WriteTimeout:      opts.WriteTimeout,
+		IdleTimeout:       opts.IdleTimeout,
+	}
+
+	if opts.tlsEnabled() {
+		srv.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+
+	if opts.SelfSigned {
+		host, _, err := net.SplitHostPort(opts.Addr)
+		if err != nil {
+			return nil, err
+		}
+
+		cert, err := selfSignedCertificate(host)
+		if err != nil {
+			return nil, fmt.Errorf("unable to generate self-signed certificate: %w", err)
+		}
+
+		srv.TLSConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return &HTTPServer{srv: srv, opts: opts}, nil
+}
+
+func (s *HTTPServer) ListenAndServe(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.opts.Addr)
+	if err != nil {
+		return err
+	}
+
+	return s.Serve(ctx, ln)
+}
+
+// Serve blocks until ctx is done or the server fails. On cancellation the
+// listener is closed immediately and active requests get ShutdownTimeout to
+// finish.
+func (s *HTTPServer) Serve(ctx context.Context, ln net.Listener) error {
+	errCh := make(chan error, 1)
+
+	go func() {
+		slog.Info("server listening", slog.String("addr", ln.Addr().String()), slog.Bool("tls", s.opts.tlsEnabled()))
+
+		if s.opts.tlsEnabled() {
+			errCh <- s.srv.ServeTLS(ln, s.opts.CertFile, s.opts.KeyFile)
+		} else {
+			errCh <- s.srv.Serve(ln)
+		}
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("shutting down server", slog.Duration("timeout", s.opts.ShutdownTimeout))
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.opts.ShutdownTimeout)
+	defer cancel()
+
+	err := s.srv.Shutdown(shutdownCtx)
+	if serveErr := <-errCh; !errors.Is(serveErr, http.ErrServerClosed) {
+		return serveErr
+	}
+
+	return err
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewHTTPServer(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		opts        Options
+		expectedErr bool
+	}{
+		{
+			name: "plain http",
+			opts: Options{Addr: "localhost:8080"},
+		},
+		{
+			name: "self-signed",
+			opts: Options{Addr: "localhost:8443", SelfSigned: true},
+		},
+		{
+			name:        "cert without key",
+			opts:        Options{Addr: "localhost:8443", CertFile: "cert.pem"},
+			expectedErr: true,
+		},
+		{
+			name:        "self-signed with files",
+			opts:        Options{Addr: "localhost:8443", CertFile: "cert.pem", KeyFile: "key.pem", SelfSigned: true},
+			expectedErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			srv, err := NewHTTPServer(tt.opts, http.NotFoundHandler())
+			if tt.expectedErr {
+				assert.Error(t, err)
+				assert.Nil(t, srv)
+			} else {
+				assert.NoError(t, err)
+				assert.NotNil(t, srv)
+			}
+		})
+	}
+}
+
+func TestHTTPServer_GracefulShutdown(t *testing.T) {
+	t.Parallel()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		_, _ = io.WriteString(w, "done")
+	})
+
+	srv, err := NewHTTPServer(Options{
+		Addr:            "127.0.0.1:0",
+		SelfSigned:      true,
+		ShutdownTimeout: 5 * time.Second,
+	}, handler)
+	assert.NoError(t, err)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.Serve(ctx, ln)
+	}()
+
+	client := &http.Client{Transport: &http.Transport{
+		// the certificate is generated per process, there is nothing to verify against
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}}
+
+	type result struct {
+		body string
+		err  error
+	}
+	resCh := make(chan result, 1)
+	go func() {
+		resp, err := client.Get("https://" + ln.Addr().String() + "/")
+		if err != nil {
+			resCh <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		resCh <- result{body: string(body), err: err}
+	}()
+
+	<-started
+	cancel()
+
+	// new connections are refused while the active request is still running
+	assert.Eventually(t, func() bool {
+		conn, err := net.DialTimeout("tcp", ln.Addr().String(), 100*time.Millisecond)
+		if err == nil {
+			_ = conn.Close()
+		}
+		return err != nil
+	}, 2*time.Second, 20*time.Millisecond)
+
+	close(release)
+
+	res := <-resCh
+	assert.NoError(t, res.err)
+	assert.Equal(t, "done", res.body)
+	assert.NoError(t, <-serveErr)
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+
+	"github.com/aws/aws-lambda-go/events"
+	ginadapter "github.com/awslabs/aws-lambda-go-api-proxy/gin"
+	"github.com/gin-gonic/gin"
+)
+
+const payloadVersionV2 = "2.0"
+
+// IsLambda reports whether the process was started by the Lambda runtime.
+func IsLambda() bool {
+	return os.Getenv("AWS_LAMBDA_RUNTIME_API") != ""
+}
+
+// LambdaHandler proxies API Gateway REST (v1) and HTTP API (v2) events to the
+// gin router, so one deployment artifact works behind either gateway.
+type LambdaHandler struct {
+	v1 *ginadapter.GinLambda
+	v2 *ginadapter.GinLambdaV2
+}
+
+func NewLambdaHandler(router *gin.Engine) *LambdaHandler {
+	return &LambdaHandler{
+		v1: ginadapter.New(router),
+		v2: ginadapter.NewV2(router),
+	}
+}
+
+// Invoke is passed to lambda.Start. The payload version decides which
+// adapter handles the event.
+func (h *LambdaHandler) Invoke(ctx context.Context, payload json.RawMessage) (any, error) {
+	var envelope struct {
+		Version string `json:"version"`
+	}
+
+	err := json.Unmarshal(payload, &envelope)
+	if err != nil {
+		return nil, err
+	}
+
+	if envelope.Version == payloadVersionV2 {
+		var event events.APIGatewayV2HTTPRequest
+		err = json.Unmarshal(payload, &event)
+		if err != nil {
+			return nil, err
+		}
+
+		return h.v2.ProxyWithContext(ctx, event)
+	}
+
+	var event events.APIGatewayProxyRequest
+	err = json.Unmarshal(payload, &event)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.v1.ProxyWithContext(ctx, event)
+}
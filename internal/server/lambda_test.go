@@ -0,0 +1,166 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"personal-vault/internal/db"
+	"personal-vault/internal/handler"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type dynamoDBMockAPI struct {
+	getItem       func(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	putItem       func(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	updateItem    func(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+	scan          func(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+	deleteItem    func(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+	describeTable func(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error)
+}
+
+func (m *dynamoDBMockAPI) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	return m.getItem(ctx, params, optFns...)
+}
+
+func (m *dynamoDBMockAPI) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	return m.putItem(ctx, params, optFns...)
+}
+
+func (m *dynamoDBMockAPI) UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	return m.updateItem(ctx, params, optFns...)
+}
+
+func (m *dynamoDBMockAPI) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	return m.scan(ctx, params, optFns...)
+}
+
+func (m *dynamoDBMockAPI) DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	return m.deleteItem(ctx, params, optFns...)
+}
+
+func (m *dynamoDBMockAPI) DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error) {
+	return m.describeTable(ctx, params, optFns...)
+}
+
+func newTestLambdaHandler() *LambdaHandler {
+	dbClient := db.DynamoDBClient{
+		API: &dynamoDBMockAPI{
+			scan: func(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+				return &dynamodb.ScanOutput{
+					Items: []map[string]types.AttributeValue{
+						{
+							"id":   &types.AttributeValueMemberS{Value: "001"},
+							"name": &types.AttributeValueMemberS{Value: "testName"},
+						},
+					},
+				}, nil
+			},
+		}}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	router := NewRouter(logger, Handlers{
+		Save:     handler.SaveHandler{Client: dbClient, Validate: handler.NewValidator()},
+		Retrieve: handler.RetrieveHandler{Client: dbClient},
+	})
+
+	return NewLambdaHandler(router)
+}
+
+func TestLambdaHandler_Invoke(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name           string
+		event          any
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "api gateway v1 healthcheck",
+			event: events.APIGatewayProxyRequest{
+				HTTPMethod: http.MethodGet,
+				Path:       "/healthcheck",
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "Hello World!",
+		},
+		{
+			name: "api gateway v1 retrieve all",
+			event: events.APIGatewayProxyRequest{
+				HTTPMethod: http.MethodGet,
+				Path:       "/retrieve/all",
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "testName",
+		},
+		{
+			name: "api gateway v2 retrieve all",
+			event: events.APIGatewayV2HTTPRequest{
+				Version:  payloadVersionV2,
+				RawPath:  "/retrieve/all",
+				RouteKey: "GET /retrieve/all",
+				RequestContext: events.APIGatewayV2HTTPRequestContext{
+					HTTP: events.APIGatewayV2HTTPRequestContextHTTPDescription{
+						Method: http.MethodGet,
+						Path:   "/retrieve/all",
+					},
+				},
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "testName",
+		},
+		{
+			name: "api gateway v2 unknown route",
+			event: events.APIGatewayV2HTTPRequest{
+				Version: payloadVersionV2,
+				RawPath: "/unknown",
+				RequestContext: events.APIGatewayV2HTTPRequestContext{
+					HTTP: events.APIGatewayV2HTTPRequestContextHTTPDescription{
+						Method: http.MethodGet,
+						Path:   "/unknown",
+					},
+				},
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   "PAGE_NOT_FOUND",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			payload, err := json.Marshal(tt.event)
+			assert.NoError(t, err)
+
+			response, err := newTestLambdaHandler().Invoke(context.Background(), payload)
+			assert.NoError(t, err)
+
+			switch r := response.(type) {
+			case events.APIGatewayProxyResponse:
+				assert.Equal(t, tt.expectedStatus, r.StatusCode)
+				assert.Contains(t, r.Body, tt.expectedBody)
+			case events.APIGatewayV2HTTPResponse:
+				assert.Equal(t, tt.expectedStatus, r.StatusCode)
+				assert.Contains(t, r.Body, tt.expectedBody)
+			default:
+				t.Fatalf("unexpected response type %T", response)
+			}
+		})
+	}
+}
+
+func TestLambdaHandler_InvokeInvalidPayload(t *testing.T) {
+	t.Parallel()
+
+	_, err := newTestLambdaHandler().Invoke(context.Background(), json.RawMessage(`not json`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"personal-vault/internal/db"
+	"personal-vault/internal/dbtest"
+	"personal-vault/internal/handler"
+	"personal-vault/internal/metrics"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMetrics_NoEntryLabels drives entries through the router with the
+// instrumented table and checks that the metrics name the routes and
+// operations, but never an entry.
+func TestMetrics_NoEntryLabels(t *testing.T) {
+	t.Parallel()
+
+	// secret is for testing only
+	secret, err := hex.DecodeString("0f6f8edf954592d7523b475bb56fd0486b7a049d67c1e5aa522bbc8bfe961971")
+	assert.NoError(t, err)
+
+	client := db.DynamoDBClient{API: metrics.DynamoDBAPI{API: dbtest.NewMemoryAPI()}, TableName: "vault"}
+	router := NewRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), Handlers{
+		Save:     handler.SaveHandler{Client: client, Validate: handler.NewValidator(), Key: string(secret)},
+		Retrieve: handler.RetrieveHandler{Client: client, Key: string(secret)},
+	})
+
+	const name = "metrics-test/github"
+
+	body, err := json.Marshal(map[string]string{"name": name, "password": "s3cret"})
+	assert.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/save", bytes.NewReader(body)))
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var saved handler.SaveResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &saved))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/retrieve/"+saved.ID, nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	exposition := w.Body.String()
+	assert.Contains(t, exposition, `vault_http_requests_total{method="GET",route="/retrieve/:id",status="200"}`)
+	assert.Contains(t, exposition, `vault_dynamodb_requests_total{operation="PutItem",outcome="ok"}`)
+	assert.Contains(t, exposition, `vault_crypto_duration_seconds_count{operation="encrypt"}`)
+	assert.Contains(t, exposition, `vault_crypto_duration_seconds_count{operation="decrypt"}`)
+	assert.Contains(t, exposition, "go_goroutines")
+	assert.NotContains(t, exposition, saved.ID)
+	assert.NotContains(t, exposition, name)
+}
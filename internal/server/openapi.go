@@ -0,0 +1,468 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"personal-vault/internal/apierror"
+	"personal-vault/internal/db"
+	"personal-vault/internal/handler"
+	"personal-vault/internal/health"
+	"personal-vault/internal/openapi"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OpenAPISpec describes every route registered by NewRouter. The router tests
+// fail when the two disagree, so add the operation here with the route.
+func OpenAPISpec() openapi.Document {
+	b := openapi.New("personal-vault", "1")
+
+	idParam := []openapi.Parameter{{
+		Name:     "id",
+		In:       "path",
+		Required: true,
+		Schema:   &openapi.Schema{Type: "string", Format: "uuid"},
+	}}
+
+	apiError := func(description string) openapi.Response {
+		return b.JSON(description, apierror.Error{})
+	}
+
+	token := b.Security("token", openapi.SecurityScheme{
+		Type:        "http",
+		Scheme:      "bearer",
+		Description: "A token from the auth section of the config. Only required when tokens are configured.",
+	})
+
+	// bearer marks the operations behind the rbac middleware
+	bearer := func(op openapi.Operation) openapi.Operation {
+		op.Security = token
+		op.Responses[openapi.Status(http.StatusUnauthorized)] = apiError("The bearer token is missing or unknown.")
+		op.Responses[openapi.Status(http.StatusForbidden)] = apiError("No role of the caller allows this on the entry.")
+		return op
+	}
+
+	b.Add(http.MethodGet, "/healthcheck", openapi.Operation{
+		OperationID: "healthcheck",
+		Summary:     "Check that the server is up",
+		Description: "Kept for existing probes, prefer /livez and /readyz.",
+		Responses: map[string]openapi.Response{
+			openapi.Status(http.StatusOK): openapi.Text("The server is up."),
+		},
+	})
+
+	b.Add(http.MethodGet, "/livez", openapi.Operation{
+		OperationID: "livez",
+		Summary:     "Check that the process is up",
+		Description: "Touches no dependency, for liveness probes.",
+		Responses: map[string]openapi.Response{
+			openapi.Status(http.StatusOK): b.JSON("The process is up.", health.Report{}),
+		},
+	})
+
+	b.Add(http.MethodGet, "/readyz", openapi.Operation{
+		OperationID: "readyz",
+		Summary:     "Check that the server can serve requests",
+		Description: "Checks that the table is reachable (dynamodb) and that the master key encrypts and " +
+			"matches the vault (key, unless the vault is in client-side mode). The result is cached briefly.",
+		Responses: map[string]openapi.Response{
+			openapi.Status(http.StatusOK):                 b.JSON("Every check passed.", health.Report{}),
+			openapi.Status(http.StatusServiceUnavailable): b.JSON("A check failed, see checks. The errors are logged.", health.Report{}),
+		},
+	})
+
+	b.Add(http.MethodGet, "/openapi.json", openapi.Operation{
+		OperationID: "getOpenAPI",
+		Summary:     "This document",
+		Responses: map[string]openapi.Response{
+			openapi.Status(http.StatusOK): {
+				Description: "The OpenAPI document.",
+				Content:     map[string]openapi.MediaType{"application/json": {Schema: &openapi.Schema{Type: "object"}}},
+			},
+		},
+	})
+
+	b.Add(http.MethodGet, "/metrics", openapi.Operation{
+		OperationID: "getMetrics",
+		Summary:     "Prometheus metrics",
+		Description: "Request, crypto and DynamoDB metrics in the Prometheus text format. Labels never hold entry ids or names.",
+		Responses: map[string]openapi.Response{
+			openapi.Status(http.StatusOK): openapi.Text("The metrics."),
+		},
+	})
+
+	created := b.JSON("The entry was stored.", handler.SaveResponse{})
+	created.Headers = map[string]openapi.Header{
+		"Location": {Description: "Path of the new entry.", Schema: &openapi.Schema{Type: "string"}},
+	}
+
+	b.Add(http.MethodPost, "/save", bearer(openapi.Operation{
+		OperationID: "saveEntry",
+		Summary:     "Store a new entry",
+		Description: "Send password to a vault that encrypts on the server, or a secret sealed with " +
+			"pkg/clientcrypto to a vault in client-side encryption mode.",
+		RequestBody: b.JSONBody(handler.Request{}),
+		Responses: map[string]openapi.Response{
+			openapi.Status(http.StatusCreated):    created,
+			openapi.Status(http.StatusBadRequest): apiError("The request is invalid."),
+		},
+	}))
+
+	b.Add(http.MethodGet, "/retrieve/all", bearer(openapi.Operation{
+		OperationID: "listEntries",
+		Summary:     "List the metadata of every entry",
+		Parameters: []openapi.Parameter{
+			{
+				Name:        "sort",
+				In:          "query",
+				Description: "Sort key, the scan order is kept when absent.",
+				Schema:      &openapi.Schema{Type: "string", Enum: handler.ListSortKeys()},
+			},
+			{
+				Name:   "order",
+				In:     "query",
+				Schema: &openapi.Schema{Type: "string", Enum: []string{"asc", "desc"}},
+			},
+		},
+		Responses: map[string]openapi.Response{
+			openapi.Status(http.StatusOK):         b.JSON("The entries.", []db.VaultMetadata{}),
+			openapi.Status(http.StatusBadRequest): apiError("The sort or order is invalid."),
+		},
+	}))
+
+	entry := openapi.Text("The decrypted password.")
+	entry.Content["application/json"] = openapi.MediaType{Schema: b.Schema(handler.EntryResponse{})}
+
+	b.Add(http.MethodGet, "/retrieve/:id", bearer(openapi.Operation{
+		OperationID: "getEntry",
+		Summary:     "Decrypt an entry",
+		Description: "Returns the bare password as text/plain unless the client accepts application/json. " +
+			"An entry sealed by the client is always returned as JSON, with its secret instead of a password.",
+		Parameters: idParam,
+		Responses: map[string]openapi.Response{
+			openapi.Status(http.StatusOK):         entry,
+			openapi.Status(http.StatusBadRequest): apiError("The id is not a UUID."),
+			openapi.Status(http.StatusNotFound):   apiError("The entry does not exist."),
+			openapi.Status(http.StatusConflict):   apiError("The entry is encrypted with the server key in a client-side vault."),
+		},
+	}))
+
+	b.Add(http.MethodGet, "/entries/expiring", bearer(openapi.Operation{
+		OperationID: "listExpiringEntries",
+		Summary:     "List the entries that expire soon or already expired, soonest first",
+		Parameters: []openapi.Parameter{{
+			Name:        "within",
+			In:          "query",
+			Description: "Period such as 14d or 36h, default " + handler.DefaultExpiringWithin + ".",
+			Schema:      &openapi.Schema{Type: "string"},
+		}},
+		Responses: map[string]openapi.Response{
+			openapi.Status(http.StatusOK):         b.JSON("The expiring entries.", []db.VaultMetadata{}),
+			openapi.Status(http.StatusBadRequest): apiError("The period is invalid."),
+		},
+	}))
+
+	b.Add(http.MethodPatch, "/entries/:id", bearer(openapi.Operation{
+		OperationID: "updateEntry",
+		Summary:     "Change the fields that are present",
+		Parameters:  idParam,
+		RequestBody: b.JSONBody(handler.UpdateRequest{}),
+		Responses: map[string]openapi.Response{
+			openapi.Status(http.StatusOK):         b.JSON("The updated entry.", db.VaultMetadata{}),
+			openapi.Status(http.StatusBadRequest): apiError("The request is invalid."),
+			openapi.Status(http.StatusNotFound):   apiError("The entry does not exist."),
+			openapi.Status(http.StatusConflict):   apiError("The entry was changed by another request since it was read."),
+		},
+	}))
+
+	b.Add(http.MethodDelete, "/entries/:id", bearer(openapi.Operation{
+		OperationID: "deleteEntry",
+		Summary:     "Move an entry to the trash",
+		Description: "The entry is purged when the trash retention ends, unless it is restored before.",
+		Parameters:  idParam,
+		Responses: map[string]openapi.Response{
+			openapi.Status(http.StatusNoContent):  openapi.NoContent("The entry is in the trash."),
+			openapi.Status(http.StatusBadRequest): apiError("The id is not a UUID."),
+			openapi.Status(http.StatusNotFound):   apiError("The entry does not exist."),
+		},
+	}))
+
+	shared := b.JSON("The link. The key is only in the fragment of the URL.", handler.ShareResponse{})
+	shared.Headers = map[string]openapi.Header{
+		"Location": {Description: "Path of the link without its key.", Schema: &openapi.Schema{Type: "string"}},
+	}
+
+	b.Add(http.MethodPost, "/entries/:id/share", bearer(openapi.Operation{
+		OperationID: "shareEntry",
+		Summary:     "Create a link that reveals the password a limited number of times",
+		Description: "The password is encrypted under a new key that is only returned in the fragment of the URL. " +
+			"expires_in defaults to " + handler.DefaultShareExpiresIn + " and max_views to 1.",
+		Parameters:  idParam,
+		RequestBody: b.JSONBody(handler.ShareRequest{}),
+		Responses: map[string]openapi.Response{
+			openapi.Status(http.StatusCreated):    shared,
+			openapi.Status(http.StatusBadRequest): apiError("The request is invalid."),
+			openapi.Status(http.StatusNotFound):   apiError("The entry does not exist."),
+			openapi.Status(http.StatusConflict):   apiError("The server cannot decrypt the entry."),
+		},
+	}))
+
+	b.Add(http.MethodGet, "/s/:shareId", openapi.Operation{
+		OperationID: "getShare",
+		Summary:     "Open a link",
+		Description: "Returns a page that decrypts the share in the browser, unless the client accepts " +
+			"application/json. Only the JSON response uses up a view; the share is deleted after the last one.",
+		Parameters: []openapi.Parameter{{
+			Name:     "shareId",
+			In:       "path",
+			Required: true,
+			Schema:   &openapi.Schema{Type: "string", Format: "uuid"},
+		}},
+		Responses: map[string]openapi.Response{
+			openapi.Status(http.StatusOK): {
+				Description: "The page, or the ciphertext of the share.",
+				Content: map[string]openapi.MediaType{
+					"text/html":        {Schema: &openapi.Schema{Type: "string"}},
+					"application/json": {Schema: b.Schema(handler.ShareContent{})},
+				},
+			},
+			openapi.Status(http.StatusBadRequest): apiError("The share id is not a UUID."),
+			openapi.Status(http.StatusNotFound):   apiError("The link does not exist, expired or was used up."),
+		},
+	})
+
+	member := b.Security("member", openapi.SecurityScheme{
+		Type:        "http",
+		Scheme:      "basic",
+		Description: "User name and passphrase of a collection member.",
+	})
+
+	b.Add(http.MethodGet, "/users", openapi.Operation{
+		OperationID: "listUsers",
+		Summary:     "List the users and their public keys",
+		Description: "Callers need a bearer token when tokens are configured, else the credentials of a user.",
+		Security:    append(append([]map[string][]string{}, token...), member...),
+		Responses: map[string]openapi.Response{
+			openapi.Status(http.StatusOK):           b.JSON("The users.", []handler.UserResponse{}),
+			openapi.Status(http.StatusUnauthorized): apiError("The bearer token or the user credentials are missing or wrong."),
+		},
+	})
+
+	b.Add(http.MethodPost, "/users", bearer(openapi.Operation{
+		OperationID: "createUser",
+		Summary:     "Register a user with a new X25519 key pair",
+		Description: "The private key is sealed under a key derived from the passphrase, which is not stored. Only admins register users when tokens are configured.",
+		RequestBody: b.JSONBody(handler.UserRequest{}),
+		Responses: map[string]openapi.Response{
+			openapi.Status(http.StatusCreated):    b.JSON("The user.", handler.UserResponse{}),
+			openapi.Status(http.StatusBadRequest): apiError("The request is invalid."),
+			openapi.Status(http.StatusConflict):   apiError("The user name is taken."),
+		},
+	}))
+
+	collectionParams := []openapi.Parameter{idParam[0]}
+
+	// memberResponses are the responses every collection operation can add
+	memberResponses := func(responses map[string]openapi.Response) map[string]openapi.Response {
+		responses[openapi.Status(http.StatusUnauthorized)] = apiError("The user name or passphrase is wrong.")
+		responses[openapi.Status(http.StatusNotFound)] = apiError("The collection does not exist or the user is not a member.")
+		return responses
+	}
+
+	b.Add(http.MethodGet, "/collections", openapi.Operation{
+		OperationID: "listCollections",
+		Summary:     "List the collections of the user",
+		Security:    member,
+		Responses: map[string]openapi.Response{
+			openapi.Status(http.StatusOK):           b.JSON("The collections.", []handler.CollectionResponse{}),
+			openapi.Status(http.StatusUnauthorized): apiError("The user name or passphrase is wrong."),
+		},
+	})
+
+	b.Add(http.MethodPost, "/collections", openapi.Operation{
+		OperationID: "createCollection",
+		Summary:     "Create a collection with the user as its only member",
+		Security:    member,
+		RequestBody: b.JSONBody(handler.CollectionRequest{}),
+		Responses: map[string]openapi.Response{
+			openapi.Status(http.StatusCreated):      b.JSON("The collection.", handler.CollectionResponse{}),
+			openapi.Status(http.StatusBadRequest):   apiError("The request is invalid."),
+			openapi.Status(http.StatusUnauthorized): apiError("The user name or passphrase is wrong."),
+		},
+	})
+
+	b.Add(http.MethodGet, "/collections/:id", openapi.Operation{
+		OperationID: "getCollection",
+		Summary:     "Describe a collection",
+		Security:    member,
+		Parameters:  collectionParams,
+		Responses: memberResponses(map[string]openapi.Response{
+			openapi.Status(http.StatusOK): b.JSON("The collection.", handler.CollectionResponse{}),
+		}),
+	})
+
+	b.Add(http.MethodPost, "/collections/:id/members", openapi.Operation{
+		OperationID: "addMember",
+		Summary:     "Wrap the collection key to another user",
+		Security:    member,
+		Parameters:  collectionParams,
+		RequestBody: b.JSONBody(handler.MemberRequest{}),
+		Responses: memberResponses(map[string]openapi.Response{
+			openapi.Status(http.StatusOK):         b.JSON("The collection.", handler.CollectionResponse{}),
+			openapi.Status(http.StatusBadRequest): apiError("The request is invalid."),
+			openapi.Status(http.StatusConflict):   apiError("The collection was modified concurrently."),
+		}),
+	})
+
+	b.Add(http.MethodDelete, "/collections/:id/members/:name", openapi.Operation{
+		OperationID: "removeMember",
+		Summary:     "Remove a member and re-key the collection",
+		Description: "Every entry is re-encrypted under a new key that is wrapped to the remaining members only.",
+		Security:    member,
+		Parameters:  collectionParams,
+		Responses: memberResponses(map[string]openapi.Response{
+			openapi.Status(http.StatusOK):         b.JSON("The re-keyed collection.", handler.CollectionResponse{}),
+			openapi.Status(http.StatusBadRequest): apiError("The member is the last one."),
+			openapi.Status(http.StatusConflict):   apiError("The collection was modified concurrently."),
+		}),
+	})
+
+	b.Add(http.MethodPost, "/collections/:id/rekey", openapi.Operation{
+		OperationID: "rekeyCollection",
+		Summary:     "Complete an interrupted re-key, or move the collection to a new key",
+		Security:    member,
+		Parameters:  collectionParams,
+		Responses: memberResponses(map[string]openapi.Response{
+			openapi.Status(http.StatusOK):       b.JSON("The re-keyed collection.", handler.CollectionResponse{}),
+			openapi.Status(http.StatusConflict): apiError("The collection was modified concurrently."),
+		}),
+	})
+
+	b.Add(http.MethodGet, "/collections/:id/entries", openapi.Operation{
+		OperationID: "listCollectionEntries",
+		Summary:     "List the entries of a collection, without passwords",
+		Security:    member,
+		Parameters:  collectionParams,
+		Responses: memberResponses(map[string]openapi.Response{
+			openapi.Status(http.StatusOK): b.JSON("The entries.", []handler.CollectionEntryResponse{}),
+		}),
+	})
+
+	b.Add(http.MethodPost, "/collections/:id/entries", openapi.Operation{
+		OperationID: "createCollectionEntry",
+		Summary:     "Store an entry encrypted under the collection key",
+		Security:    member,
+		Parameters:  collectionParams,
+		RequestBody: b.JSONBody(handler.CollectionEntryRequest{}),
+		Responses: memberResponses(map[string]openapi.Response{
+			openapi.Status(http.StatusCreated):    b.JSON("The entry, without its password.", handler.CollectionEntryResponse{}),
+			openapi.Status(http.StatusBadRequest): apiError("The request is invalid."),
+			openapi.Status(http.StatusConflict):   apiError("The vault is in client-side encryption mode, or the collection was re-keyed while the entry was saved."),
+		}),
+	})
+
+	b.Add(http.MethodGet, "/collections/:id/entries/:entryId", openapi.Operation{
+		OperationID: "getCollectionEntry",
+		Summary:     "Decrypt an entry of a collection",
+		Security:    member,
+		Parameters: append([]openapi.Parameter{{
+			Name:     "entryId",
+			In:       "path",
+			Required: true,
+			Schema:   &openapi.Schema{Type: "string", Format: "uuid"},
+		}}, collectionParams...),
+		Responses: memberResponses(map[string]openapi.Response{
+			openapi.Status(http.StatusOK):         b.JSON("The entry with its password.", handler.CollectionEntryResponse{}),
+			openapi.Status(http.StatusBadRequest): apiError("An id is not a UUID."),
+			openapi.Status(http.StatusConflict):   apiError("The vault is in client-side encryption mode."),
+		}),
+	})
+
+	b.Add(http.MethodGet, "/trash", bearer(openapi.Operation{
+		OperationID: "listTrash",
+		Summary:     "List the entries in the trash",
+		Responses: map[string]openapi.Response{
+			openapi.Status(http.StatusOK): b.JSON("The trashed entries.", []db.VaultMetadata{}),
+		},
+	}))
+
+	b.Add(http.MethodPost, "/trash/:id/restore", bearer(openapi.Operation{
+		OperationID: "restoreEntry",
+		Summary:     "Take an entry out of the trash",
+		Parameters:  idParam,
+		Responses: map[string]openapi.Response{
+			openapi.Status(http.StatusOK):         b.JSON("The restored entry.", db.VaultMetadata{}),
+			openapi.Status(http.StatusBadRequest): apiError("The id is not a UUID."),
+			openapi.Status(http.StatusNotFound):   apiError("The entry is not in the trash."),
+		},
+	}))
+
+	roleParams := []openapi.Parameter{
+		{Name: "principal", In: "query", Required: true, Schema: &openapi.Schema{Type: "string"}},
+		{Name: "prefix", In: "query", Required: true, Schema: &openapi.Schema{Type: "string"}},
+	}
+
+	b.Add(http.MethodGet, "/roles", bearer(openapi.Operation{
+		OperationID: "listRoles",
+		Summary:     "List the caller's roles and the roles on the prefixes the caller owns",
+		Responses: map[string]openapi.Response{
+			openapi.Status(http.StatusOK): b.JSON("The role assignments.", []handler.RoleResponse{}),
+		},
+	}))
+
+	b.Add(http.MethodPut, "/roles", bearer(openapi.Operation{
+		OperationID: "putRole",
+		Summary:     "Grant a role on the entries whose name starts with the prefix",
+		Description: "Requires the owner role on the prefix. The role replaces any role the principal had on the same prefix.",
+		RequestBody: b.JSONBody(handler.RoleRequest{}),
+		Responses: map[string]openapi.Response{
+			openapi.Status(http.StatusOK):         b.JSON("The role assignment.", handler.RoleResponse{}),
+			openapi.Status(http.StatusBadRequest): apiError("The request is invalid."),
+		},
+	}))
+
+	b.Add(http.MethodDelete, "/roles", bearer(openapi.Operation{
+		OperationID: "deleteRole",
+		Summary:     "Revoke a role",
+		Description: "Requires the owner role on the prefix.",
+		Parameters:  roleParams,
+		Responses: map[string]openapi.Response{
+			openapi.Status(http.StatusNoContent):  openapi.NoContent("The role was revoked."),
+			openapi.Status(http.StatusBadRequest): apiError("The principal or prefix is missing."),
+			openapi.Status(http.StatusNotFound):   apiError("The principal has no role on the prefix."),
+		},
+	}))
+
+	doc := b.Document()
+
+	// the rate limiter guards every other route when it is enabled
+	tooMany := apiError("The client sent too many requests, or too many that failed.")
+	tooMany.Headers = map[string]openapi.Header{
+		"Retry-After": {Description: "Seconds until the client may retry.", Schema: &openapi.Schema{Type: "integer"}},
+	}
+
+	for _, item := range doc.Paths {
+		for _, op := range item {
+			switch op.OperationID {
+			case "healthcheck", "livez", "readyz", "getOpenAPI", "getMetrics":
+			default:
+				op.Responses[openapi.Status(http.StatusTooManyRequests)] = tooMany
+			}
+		}
+	}
+
+	return doc
+}
+
+// openAPIHandler serves the spec, encoded once.
+func openAPIHandler(spec openapi.Document) gin.HandlerFunc {
+	body, err := json.Marshal(spec)
+	if err != nil {
+		// the document only holds plain data, this is a programming error
+		panic(err)
+	}
+
+	return func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+	}
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"personal-vault/internal/openapi"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenAPISpec_MatchesRoutes(t *testing.T) {
+	t.Parallel()
+
+	router := NewRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), Handlers{})
+
+	var routes []string
+	for _, route := range router.Routes() {
+		path, _ := openapi.Path(route.Path)
+		routes = append(routes, route.Method+" "+path)
+	}
+	sort.Strings(routes)
+
+	assert.Equal(t, routes, OpenAPISpec().Routes())
+}
+
+func TestOpenAPISpec_Served(t *testing.T) {
+	t.Parallel()
+
+	router := NewRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), Handlers{})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+
+	var doc openapi.Document
+	err := json.Unmarshal(w.Body.Bytes(), &doc)
+	assert.NoError(t, err)
+	assert.Equal(t, openapi.Version, doc.OpenAPI)
+
+	save := doc.Paths["/save"]["post"]
+	assert.Equal(t, "#/components/schemas/Request", save.RequestBody.Content["application/json"].Schema.Ref)
+	assert.Equal(t, []string{"name", "password"}, doc.Components.Schemas["Request"].Required)
+
+	// every reference must resolve
+	for _, ref := range refs(w.Body.String()) {
+		name := strings.TrimPrefix(ref, "#/components/schemas/")
+		assert.Contains(t, doc.Components.Schemas, name, ref)
+	}
+}
+
+func refs(body string) []string {
+	var found []string
+	for _, part := range strings.Split(body, `"$ref":"`)[1:] {
+		found = append(found, part[:strings.Index(part, `"`)])
+	}
+
+	return found
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"personal-vault/internal/db"
+	"personal-vault/internal/dbtest"
+	"personal-vault/internal/handler"
+	"personal-vault/internal/rbac"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestAuthorization_BodyKeys sends bodies whose keys differ from the JSON
+// names only in case, or repeat them, and checks that the roles are checked
+// on the names the handlers act on.
+func TestAuthorization_BodyKeys(t *testing.T) {
+	t.Parallel()
+
+	client := db.DynamoDBClient{API: dbtest.NewMemoryAPI(), TableName: "vault"}
+	_, err := client.PutRoleAssignment(context.Background(), db.RoleAssignment{Principal: "carol", Prefix: "prod/", Role: string(rbac.RoleOwner)})
+	assert.NoError(t, err)
+
+	router := NewRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), Handlers{
+		Save: handler.SaveHandler{Client: client, Validate: handler.NewValidator(), Key: "0123456789abcdef0123456789abcdef"},
+		Role: handler.RoleHandler{Client: client, Validate: handler.NewValidator()},
+		Auth: rbac.Authorizer{Client: client, Tokens: map[string]string{rbac.HashToken("carol-token"): "carol"}},
+	})
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		body           string
+		expectedStatus int
+	}{
+		{name: "save in the prefix", method: http.MethodPost, path: "/save", body: `{"name":"prod/x","password":"p"}`, expectedStatus: http.StatusCreated},
+		{name: "save outside the prefix", method: http.MethodPost, path: "/save", body: `{"name":"dev/x","password":"p"}`, expectedStatus: http.StatusForbidden},
+		{name: "mixed-case name", method: http.MethodPost, path: "/save", body: `{"Name":"dev/x","password":"p"}`, expectedStatus: http.StatusForbidden},
+		{name: "duplicate name", method: http.MethodPost, path: "/save", body: `{"name":"prod/x","NAME":"dev/x","password":"p"}`, expectedStatus: http.StatusForbidden},
+		{name: "grant in the prefix", method: http.MethodPut, path: "/roles", body: `{"principal":"dave","prefix":"prod/","role":"viewer"}`, expectedStatus: http.StatusOK},
+		{name: "mixed-case prefix", method: http.MethodPut, path: "/roles", body: `{"principal":"carol","Prefix":"dev/","role":"owner"}`, expectedStatus: http.StatusForbidden},
+		{name: "duplicate prefix", method: http.MethodPut, path: "/roles", body: `{"principal":"carol","prefix":"prod/","PREFIX":"","role":"owner"}`, expectedStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer carol-token")
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.expectedStatus, w.Code, w.Body.String())
+		})
+	}
+}
+
+// TestAuthorization_Users checks that with bearer tokens only admins register
+// users and only principals list them.
+func TestAuthorization_Users(t *testing.T) {
+	t.Parallel()
+
+	client := db.DynamoDBClient{API: dbtest.NewMemoryAPI(), TableName: "vault"}
+
+	router := NewRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), Handlers{
+		User: handler.UserHandler{Client: client, Validate: handler.NewValidator(), Iterations: 1000},
+		Auth: rbac.Authorizer{
+			Client: client,
+			Tokens: map[string]string{rbac.HashToken("root-token"): "root", rbac.HashToken("carol-token"): "carol"},
+			Admins: []string{"root"},
+		},
+	})
+
+	tests := []struct {
+		name           string
+		method         string
+		token          string
+		body           string
+		expectedStatus int
+	}{
+		{name: "anonymous registration", method: http.MethodPost, body: `{"name":"mallory","passphrase":"long enough"}`, expectedStatus: http.StatusUnauthorized},
+		{name: "principal registration", method: http.MethodPost, token: "carol-token", body: `{"name":"mallory","passphrase":"long enough"}`, expectedStatus: http.StatusForbidden},
+		{name: "admin registration", method: http.MethodPost, token: "root-token", body: `{"name":"alice","passphrase":"long enough"}`, expectedStatus: http.StatusCreated},
+		{name: "anonymous listing", method: http.MethodGet, expectedStatus: http.StatusUnauthorized},
+		{name: "principal listing", method: http.MethodGet, token: "carol-token", expectedStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(tt.method, "/users", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.expectedStatus, w.Code, w.Body.String())
+		})
+	}
+}
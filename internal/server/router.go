@@ -0,0 +1,194 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+	"personal-vault/internal/apierror"
+	"personal-vault/internal/db"
+	"personal-vault/internal/handler"
+	"personal-vault/internal/health"
+	"personal-vault/internal/logging"
+	"personal-vault/internal/metrics"
+	"personal-vault/internal/ratelimit"
+	"personal-vault/internal/rbac"
+	"personal-vault/internal/tracing"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Handlers struct {
+	Save       handler.SaveHandler
+	Retrieve   handler.RetrieveHandler
+	Delete     handler.DeleteHandler
+	Expiry     handler.ExpiryHandler
+	Share      handler.ShareHandler
+	User       handler.UserHandler
+	Collection handler.CollectionHandler
+	Role       handler.RoleHandler
+	// Auth guards the entry routes. The zero value leaves them open.
+	Auth rbac.Authorizer
+	// RateLimit throttles clients per route group, see rateLimitGroup. The
+	// zero value limits nothing.
+	RateLimit ratelimit.Limiter
+	// Health runs the checks of /readyz. The zero value is always ready.
+	Health health.Checker
+}
+
+// HandlerOptions configures the handlers built by NewHandlers.
+type HandlerOptions struct {
+	// Key is the master key. It is dropped in client-side mode.
+	Key        string
+	ClientSide bool
+	// Retention is how long deleted entries stay in the trash.
+	Retention     time.Duration
+	ShareBaseURL  string
+	KDFIterations int
+}
+
+// NewHandlers builds the handlers of every route on client. In client-side
+// mode no handler gets the master key, even when one is configured, and every
+// handler refuses what would make the server see a password. Auth, RateLimit
+// and Health are left for the caller to set.
+func NewHandlers(client db.DynamoDBClient, opts HandlerOptions) Handlers {
+	validate := handler.NewValidator()
+
+	key := opts.Key
+	if opts.ClientSide {
+		key = ""
+	}
+
+	return Handlers{
+		Save:       handler.SaveHandler{Client: client, Validate: validate, Key: key, ClientSide: opts.ClientSide},
+		Retrieve:   handler.RetrieveHandler{Client: client, Key: key, ClientSide: opts.ClientSide},
+		Delete:     handler.DeleteHandler{Client: client, Retention: opts.Retention},
+		Expiry:     handler.ExpiryHandler{Client: client},
+		Share:      handler.ShareHandler{Client: client, Validate: validate, Key: key, BaseURL: opts.ShareBaseURL, ClientSide: opts.ClientSide},
+		User:       handler.UserHandler{Client: client, Validate: validate, Iterations: opts.KDFIterations},
+		Collection: handler.CollectionHandler{Client: client, Validate: validate, ClientSide: opts.ClientSide},
+		Role:       handler.RoleHandler{Client: client, Validate: validate},
+	}
+}
+
+// NewRouter builds the gin engine shared by the HTTP server and the Lambda
+// entrypoint.
+func NewRouter(logger *slog.Logger, handlers Handlers) *gin.Engine {
+	router := gin.New()
+	// lets handlers pass the gin context down to the db layer with the request id
+	router.ContextWithFallback = true
+	router.HandleMethodNotAllowed = true
+	router.Use(logging.Middleware(logger), tracing.Middleware(), metrics.Middleware(), gin.Recovery(), handlers.RateLimit.Middleware(rateLimitGroup))
+
+	router.GET("/healthcheck", healthcheckHandler)
+	router.GET("/livez", health.Livez)
+	router.GET("/readyz", handlers.Health.Readyz)
+	router.GET("/openapi.json", openAPIHandler(OpenAPISpec()))
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
+
+	auth := handlers.Auth
+	entryParam := rbac.EntryParam("id")
+	savedName := rbac.Body(func(request handler.Request) []string { return []string{request.Name} })
+	// a rename needs the role on the new name too
+	newName := rbac.Body(func(request handler.UpdateRequest) []string {
+		if request.Name == nil {
+			return nil
+		}
+
+		return []string{*request.Name}
+	})
+	grantedPrefix := rbac.Body(func(request handler.RoleRequest) []string { return []string{request.Prefix} })
+
+	// the routes that address entries by name are guarded by the roles, and
+	// only admins register users; collections authenticate with the
+	// credentials of their members
+	protected := router.Group("", auth.Authenticate())
+
+	protected.POST("/save", auth.Require(rbac.ActionWrite, savedName), handlers.Save.AddItem)
+
+	retrieve := protected.Group("/retrieve")
+	{
+		retrieve.GET("/all", auth.Require(rbac.ActionList), handlers.Retrieve.GetAll)
+		retrieve.GET("/:id", auth.Require(rbac.ActionReveal, entryParam), handlers.Retrieve.GetByID)
+	}
+
+	entries := protected.Group("/entries")
+	{
+		entries.GET("/expiring", auth.Require(rbac.ActionList), handlers.Expiry.GetExpiring)
+		entries.PATCH("/:id", auth.Require(rbac.ActionWrite, entryParam, newName), handlers.Save.UpdateItem)
+		entries.DELETE("/:id", auth.Require(rbac.ActionDelete, entryParam), handlers.Delete.DeleteItem)
+		entries.POST("/:id/share", auth.Require(rbac.ActionReveal, entryParam), handlers.Share.CreateShare)
+	}
+
+	trash := protected.Group("/trash")
+	{
+		trash.GET("", auth.Require(rbac.ActionList), handlers.Delete.GetTrash)
+		trash.POST("/:id/restore", auth.Require(rbac.ActionDelete, entryParam), handlers.Delete.RestoreItem)
+	}
+
+	roles := protected.Group("/roles")
+	{
+		roles.GET("", handlers.Role.GetRoles)
+		roles.PUT("", auth.Require(rbac.ActionGrant, grantedPrefix), handlers.Role.PutRole)
+		roles.DELETE("", auth.Require(rbac.ActionGrant, rbac.QueryField("prefix")), handlers.Role.DeleteRole)
+	}
+
+	router.GET("/s/:shareId", handlers.Share.GetShare)
+
+	users := protected.Group("/users")
+	{
+		users.GET("", handlers.User.GetUsers)
+		users.POST("", auth.RequireAdmin(), handlers.User.CreateUser)
+	}
+
+	collections := router.Group("/collections")
+	{
+		collections.GET("", handlers.Collection.GetCollections)
+		collections.POST("", handlers.Collection.CreateCollection)
+		collections.GET("/:id", handlers.Collection.GetCollection)
+		collections.POST("/:id/members", handlers.Collection.AddMember)
+		collections.DELETE("/:id/members/:name", handlers.Collection.RemoveMember)
+		collections.POST("/:id/rekey", handlers.Collection.Rekey)
+		collections.GET("/:id/entries", handlers.Collection.GetEntries)
+		collections.POST("/:id/entries", handlers.Collection.CreateEntry)
+		collections.GET("/:id/entries/:entryId", handlers.Collection.GetEntry)
+	}
+
+	router.NoRoute(notFoundHandler)
+	router.NoMethod(notMethodHandler)
+
+	return router
+}
+
+// rateLimitGroup names the limit that applies to a route, see the rate_limit
+// config. The health checks, the spec and the metrics are never limited, and
+// unknown routes fall in the default group so probing them counts as failures.
+func rateLimitGroup(c *gin.Context) string {
+	route := c.FullPath()
+
+	switch {
+	case route == "/healthcheck" || route == "/livez" || route == "/readyz" ||
+		route == "/openapi.json" || route == "/metrics":
+		return ""
+	case strings.HasPrefix(route, "/retrieve/"):
+		return "retrieve"
+	case strings.HasPrefix(route, "/s/"):
+		return "shares"
+	case strings.HasPrefix(route, "/users") || strings.HasPrefix(route, "/collections"):
+		return "accounts"
+	default:
+		return "default"
+	}
+}
+
+func healthcheckHandler(c *gin.Context) {
+	c.String(http.StatusOK, "Hello World!")
+}
+
+func notFoundHandler(c *gin.Context) {
+	apierror.Respond(c, apierror.New(http.StatusNotFound, apierror.CodePageNotFound, "Page not found"))
+}
+
+func notMethodHandler(c *gin.Context) {
+	apierror.Respond(c, apierror.New(http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "405 method not allowed"))
+}
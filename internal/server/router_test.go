@@ -0,0 +1,64 @@
+package server
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"personal-vault/internal/ratelimit"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewRouter_RateLimitGroups(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		path  string
+		group string
+	}{
+		{name: "health check", path: "/healthcheck"},
+		{name: "liveness", path: "/livez"},
+		{name: "readiness", path: "/readyz"},
+		{name: "spec", path: "/openapi.json"},
+		{name: "metrics", path: "/metrics"},
+		{name: "retrieve", path: "/retrieve/all", group: "retrieve"},
+		{name: "share link", path: "/s/abc", group: "shares"},
+		{name: "users", path: "/users", group: "accounts"},
+		{name: "collections", path: "/collections/abc", group: "accounts"},
+		{name: "entries", path: "/entries/expiring", group: "default"},
+		{name: "unknown route", path: "/admin", group: "default"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			// only the expected group has a limit, and it is used up by the
+			// first request
+			limiter := ratelimit.Limiter{Store: ratelimit.NewMemoryStore()}
+			if tt.group != "" {
+				limiter.Limits = map[string]ratelimit.Limit{tt.group: {Rate: 0.01, Burst: 1}}
+			}
+
+			router := NewRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), Handlers{RateLimit: limiter})
+
+			var codes []int
+			for i := 0; i < 2; i++ {
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+				codes = append(codes, w.Code)
+			}
+
+			if tt.group == "" {
+				assert.NotContains(t, codes, http.StatusTooManyRequests)
+			} else {
+				assert.NotEqual(t, http.StatusTooManyRequests, codes[0])
+				assert.Equal(t, http.StatusTooManyRequests, codes[1])
+			}
+		})
+	}
+}
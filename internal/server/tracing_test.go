@@ -0,0 +1,89 @@
+package server
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"personal-vault/internal/db"
+	"personal-vault/internal/dbtest"
+	"personal-vault/internal/handler"
+	"personal-vault/internal/tracing"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// TestTracing_SpanTree drives an entry through the router with the traced
+// table and checks the spans of each request, and that none of them carries
+// the entry. It installs the global tracer provider, so it does not run in
+// parallel.
+func TestTracing_SpanTree(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(provider) })
+
+	// secret is for testing only
+	secret, err := hex.DecodeString("0f6f8edf954592d7523b475bb56fd0486b7a049d67c1e5aa522bbc8bfe961971")
+	assert.NoError(t, err)
+
+	client := db.DynamoDBClient{API: tracing.DynamoDBAPI{API: dbtest.NewMemoryAPI()}, TableName: "vault"}
+	router := NewRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), Handlers{
+		Save:     handler.SaveHandler{Client: client, Validate: handler.NewValidator(), Key: string(secret)},
+		Retrieve: handler.RetrieveHandler{Client: client, Key: string(secret)},
+	})
+
+	const (
+		name     = "tracing-test/github"
+		password = "tr4cing-s3cret"
+	)
+
+	body, err := json.Marshal(map[string]string{"name": name, "password": password})
+	assert.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/save", bytes.NewReader(body)))
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var saved handler.SaveResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &saved))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/retrieve/"+saved.ID, nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, password, w.Body.String())
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+
+		for _, kv := range span.Attributes() {
+			assert.NotContains(t, kv.Value.Emit(), saved.ID, kv.Key)
+			assert.NotContains(t, kv.Value.Emit(), name, kv.Key)
+			assert.NotContains(t, kv.Value.Emit(), password, kv.Key)
+		}
+	}
+
+	for child, parent := range map[string]string{
+		"SaveHandler.AddItem":     "POST /save",
+		"encrypt":                 "SaveHandler.AddItem",
+		"DynamoDB.PutItem":        "SaveHandler.AddItem",
+		"RetrieveHandler.GetByID": "GET /retrieve/:id",
+		"DynamoDB.GetItem":        "RetrieveHandler.GetByID",
+		"decrypt":                 "RetrieveHandler.GetByID",
+	} {
+		if assert.Contains(t, spans, child) && assert.Contains(t, spans, parent) {
+			assert.Equal(t, spans[parent].SpanContext().SpanID(), spans[child].Parent().SpanID(), child)
+			assert.Equal(t, spans[parent].SpanContext().TraceID(), spans[child].SpanContext().TraceID(), child)
+		}
+	}
+
+	assert.NotEqual(t, spans["POST /save"].SpanContext().TraceID(), spans["GET /retrieve/:id"].SpanContext().TraceID())
+}
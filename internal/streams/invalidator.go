@@ -0,0 +1,192 @@
+// Package streams follows the DynamoDB stream of the vault table, so the
+// metadata cache of one instance drops its listing when another instance
+// writes an entry.
+package streams
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"personal-vault/internal/db"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodbstreams"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodbstreams/types"
+)
+
+// API is implemented by the dynamodbstreams client and dbtest.MemoryStream.
+type API interface {
+	DescribeStream(ctx context.Context, params *dynamodbstreams.DescribeStreamInput, optFns ...func(*dynamodbstreams.Options)) (*dynamodbstreams.DescribeStreamOutput, error)
+	GetShardIterator(ctx context.Context, params *dynamodbstreams.GetShardIteratorInput, optFns ...func(*dynamodbstreams.Options)) (*dynamodbstreams.GetShardIteratorOutput, error)
+	GetRecords(ctx context.Context, params *dynamodbstreams.GetRecordsInput, optFns ...func(*dynamodbstreams.Options)) (*dynamodbstreams.GetRecordsOutput, error)
+}
+
+// Invalidator polls every shard of the stream and invalidates Cache when a
+// record is about an entry. Records only carry keys, so nothing of an entry
+// but its id is ever read.
+type Invalidator struct {
+	API       API
+	StreamARN string
+	Cache     *db.MetadataCache
+	Interval  time.Duration
+
+	// iterators holds the next iterator of every shard being read and
+	// finished the shards that were read to their end. Both are nil until
+	// the first poll and after an error.
+	iterators map[string]string
+	finished  map[string]bool
+}
+
+// Run polls immediately and then on every interval until ctx is done.
+func (i *Invalidator) Run(ctx context.Context) {
+	ticker := time.NewTicker(i.Interval)
+	defer ticker.Stop()
+
+	for {
+		err := i.Poll(ctx)
+		if err != nil {
+			slog.WarnContext(ctx, "unable to read the table stream", slog.Any("error", err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll reads the new records of every shard. Records that could not be read,
+// because of an error or an expired iterator, may have been writes, so the
+// cache is invalidated then and the stream is followed from its end again on
+// the next poll.
+func (i *Invalidator) Poll(ctx context.Context) error {
+	if i.iterators == nil {
+		err := i.start(ctx)
+		if err != nil {
+			i.reset()
+			return err
+		}
+	}
+
+	written, shardsEnded, err := i.read(ctx)
+	if written {
+		i.Cache.Invalidate()
+	}
+	if err != nil {
+		i.reset()
+		return err
+	}
+
+	if shardsEnded {
+		// the children of a shard start where it ended
+		err = i.discover(ctx, types.ShardIteratorTypeTrimHorizon)
+		if err != nil {
+			i.reset()
+			return err
+		}
+	}
+
+	return nil
+}
+
+// start follows every open shard from its end. Writes made before are not
+// read, so the cache is dropped once the iterators are in place.
+func (i *Invalidator) start(ctx context.Context) error {
+	i.iterators, i.finished = map[string]string{}, map[string]bool{}
+
+	err := i.discover(ctx, types.ShardIteratorTypeLatest)
+	if err != nil {
+		return err
+	}
+
+	i.Cache.Invalidate()
+
+	return nil
+}
+
+func (i *Invalidator) reset() {
+	i.iterators, i.finished = nil, nil
+	i.Cache.Invalidate()
+}
+
+// discover gets an iterator of the given type for every shard that is not
+// read yet. Closed shards are skipped when following the stream from its end.
+func (i *Invalidator) discover(ctx context.Context, iteratorType types.ShardIteratorType) error {
+	input := &dynamodbstreams.DescribeStreamInput{StreamArn: aws.String(i.StreamARN)}
+	for {
+		output, err := i.API.DescribeStream(ctx, input)
+		if err != nil {
+			return fmt.Errorf("describe stream: %w", err)
+		}
+
+		for _, shard := range output.StreamDescription.Shards {
+			id := aws.ToString(shard.ShardId)
+			if _, ok := i.iterators[id]; ok || i.finished[id] {
+				continue
+			}
+
+			closed := shard.SequenceNumberRange != nil && shard.SequenceNumberRange.EndingSequenceNumber != nil
+			if closed && iteratorType == types.ShardIteratorTypeLatest {
+				i.finished[id] = true
+				continue
+			}
+
+			iterator, err := i.API.GetShardIterator(ctx, &dynamodbstreams.GetShardIteratorInput{
+				StreamArn:         aws.String(i.StreamARN),
+				ShardId:           shard.ShardId,
+				ShardIteratorType: iteratorType,
+			})
+			if err != nil {
+				return fmt.Errorf("get shard iterator: %w", err)
+			}
+
+			i.iterators[id] = aws.ToString(iterator.ShardIterator)
+		}
+
+		if output.StreamDescription.LastEvaluatedShardId == nil {
+			return nil
+		}
+
+		input.ExclusiveStartShardId = output.StreamDescription.LastEvaluatedShardId
+	}
+}
+
+// read gets the new records of every shard. It reports whether any of them
+// was about an entry and whether a shard was read to its end.
+func (i *Invalidator) read(ctx context.Context) (written, shardsEnded bool, err error) {
+	for id, iterator := range i.iterators {
+		output, err := i.API.GetRecords(ctx, &dynamodbstreams.GetRecordsInput{ShardIterator: aws.String(iterator)})
+		if err != nil {
+			return written, shardsEnded, fmt.Errorf("get records: %w", err)
+		}
+
+		for _, record := range output.Records {
+			written = written || isEntry(record)
+		}
+
+		if output.NextShardIterator == nil {
+			delete(i.iterators, id)
+			i.finished[id] = true
+			shardsEnded = true
+			continue
+		}
+
+		i.iterators[id] = aws.ToString(output.NextShardIterator)
+	}
+
+	return written, shardsEnded, nil
+}
+
+// isEntry reports whether the record is about an entry rather than a reserved
+// item, such as the key check, which is not listed.
+func isEntry(record types.Record) bool {
+	if record.Dynamodb == nil {
+		return true
+	}
+
+	id, ok := record.Dynamodb.Keys["id"].(*types.AttributeValueMemberS)
+
+	return !ok || !db.IsReserved(id.Value)
+}
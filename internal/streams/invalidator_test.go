@@ -0,0 +1,194 @@
+package streams
+
+import (
+	"context"
+	"errors"
+	"personal-vault/internal/db"
+	"personal-vault/internal/dbtest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodbstreams"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodbstreams/types"
+	"github.com/stretchr/testify/assert"
+)
+
+const streamARN = "arn:aws:dynamodb:eu-west-1:123456789012:table/vault/stream/2024-01-01T00:00:00.000"
+
+// countingAPI counts the scans of the entries that reach the table.
+type countingAPI struct {
+	*dbtest.MemoryAPI
+	scans atomic.Int32
+}
+
+func (c *countingAPI) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	if strings.Contains(aws.ToString(params.FilterExpression), ":reserved") {
+		c.scans.Add(1)
+	}
+	return c.MemoryAPI.Scan(ctx, params, optFns...)
+}
+
+// failingAPI fails GetRecords while fail is set.
+type failingAPI struct {
+	API
+	fail bool
+}
+
+func (f *failingAPI) GetRecords(ctx context.Context, params *dynamodbstreams.GetRecordsInput, optFns ...func(*dynamodbstreams.Options)) (*dynamodbstreams.GetRecordsOutput, error) {
+	if f.fail {
+		return nil, errors.New("this is mock error")
+	}
+
+	return f.API.GetRecords(ctx, params, optFns...)
+}
+
+func TestInvalidator_Poll(t *testing.T) {
+	t.Parallel()
+
+	entry := func(id string) func(stream *dbtest.MemoryStream) {
+		return func(stream *dbtest.MemoryStream) {
+			stream.Publish(types.OperationTypeModify, id)
+		}
+	}
+
+	tests := []struct {
+		name              string
+		write             func(stream *dbtest.MemoryStream)
+		fail              bool
+		expectedErr       bool
+		expectedScans     int32
+		expectedNextScans int32
+	}{
+		{name: "no writes", write: func(stream *dbtest.MemoryStream) {}, expectedScans: 0},
+		{name: "entry written", write: entry("0f8fad5b-d9cb-469f-a165-70867728950e"), expectedScans: 1},
+		{name: "reserved item written", write: entry("_keycheck"), expectedScans: 0},
+		{name: "access recorded", write: entry("_access#0f8fad5b-d9cb-469f-a165-70867728950e"), expectedScans: 0},
+		{
+			name: "shard split",
+			write: func(stream *dbtest.MemoryStream) {
+				stream.Split()
+				stream.Publish(types.OperationTypeRemove, "0f8fad5b-d9cb-469f-a165-70867728950e")
+			},
+			// the child shard is only found once its parent ended
+			expectedScans:     0,
+			expectedNextScans: 1,
+		},
+		{
+			name: "shard split after a write",
+			write: func(stream *dbtest.MemoryStream) {
+				stream.Publish(types.OperationTypeInsert, "0f8fad5b-d9cb-469f-a165-70867728950e")
+				stream.Split()
+			},
+			expectedScans: 1,
+		},
+		{name: "expired iterators", write: func(stream *dbtest.MemoryStream) { stream.ExpireIterators() }, expectedErr: true, expectedScans: 1, expectedNextScans: 1},
+		{name: "error case", write: func(stream *dbtest.MemoryStream) {}, fail: true, expectedErr: true, expectedScans: 1, expectedNextScans: 1},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			stream := dbtest.NewMemoryStream(streamARN)
+			api := &countingAPI{MemoryAPI: dbtest.NewMemoryAPI()}
+			client := db.DynamoDBClient{API: api, TableName: "vault", Cache: db.NewMetadataCache(time.Hour)}
+			streamAPI := &failingAPI{API: stream}
+			invalidator := &Invalidator{API: streamAPI, StreamARN: streamARN, Cache: client.Cache}
+
+			// the first poll drops whatever was cached before it followed
+			// the stream
+			assert.NoError(t, invalidator.Poll(ctx))
+
+			scan := func() int32 {
+				before := api.scans.Load()
+				_, err := client.ScanItems(ctx)
+				assert.NoError(t, err)
+				return api.scans.Load() - before
+			}
+			scan()
+
+			tt.write(stream)
+			streamAPI.fail = tt.fail
+
+			err := invalidator.Poll(ctx)
+			if tt.expectedErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expectedScans, scan())
+
+			// the next poll recovers from the error
+			stream.Publish(types.OperationTypeModify, "_keycheck")
+			streamAPI.fail = false
+
+			assert.NoError(t, invalidator.Poll(ctx))
+			assert.Equal(t, tt.expectedNextScans, scan())
+		})
+	}
+}
+
+// TestInvalidator_Instances runs two instances with their own cache against
+// one table.
+func TestInvalidator_Instances(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	api := dbtest.NewMemoryAPI()
+	api.Stream = dbtest.NewMemoryStream(streamARN)
+
+	first := db.DynamoDBClient{API: api, TableName: "vault", Cache: db.NewMetadataCache(time.Hour)}
+	second := db.DynamoDBClient{API: api, TableName: "vault", Cache: db.NewMetadataCache(time.Hour)}
+
+	arn, err := first.StreamARN(ctx)
+	assert.NoError(t, err)
+	invalidator := &Invalidator{API: api.Stream, StreamARN: arn, Cache: first.Cache}
+	assert.NoError(t, invalidator.Poll(ctx))
+
+	_, err = first.PutItem(ctx, db.VaultEntity{ID: "0f8fad5b-d9cb-469f-a165-70867728950e", Name: "github", Password: "ciphertext"})
+	assert.NoError(t, err)
+
+	items, err := first.ScanItems(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, items, 1)
+
+	_, err = second.PutItem(ctx, db.VaultEntity{ID: "7c9e6679-7425-40de-944b-e07fc1f90ae7", Name: "gitlab", Password: "ciphertext"})
+	assert.NoError(t, err)
+
+	items, err = first.ScanItems(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, items, 1, "cached until the stream is read")
+
+	assert.NoError(t, invalidator.Poll(ctx))
+
+	items, err = first.ScanItems(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, items, 2)
+}
+
+func TestInvalidator_Run(t *testing.T) {
+	t.Parallel()
+
+	stream := dbtest.NewMemoryStream(streamARN)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		(&Invalidator{API: stream, StreamARN: streamARN, Interval: time.Millisecond}).Run(ctx)
+		close(done)
+	}()
+
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after ctx was done")
+	}
+}
@@ -0,0 +1,88 @@
+package tracing
+
+import (
+	"context"
+	"personal-vault/internal/db"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DynamoDBAPI starts a client span for every call to API. The spans name the
+// operation and table, never the keys or items.
+type DynamoDBAPI struct {
+	API db.DynamoDBAPI
+}
+
+func (d DynamoDBAPI) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	ctx, span := startDynamoDB(ctx, "GetItem", params.TableName)
+	output, err := d.API.GetItem(ctx, params, optFns...)
+	endDynamoDB(span, err)
+
+	return output, err
+}
+
+func (d DynamoDBAPI) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	ctx, span := startDynamoDB(ctx, "PutItem", params.TableName)
+	output, err := d.API.PutItem(ctx, params, optFns...)
+	endDynamoDB(span, err)
+
+	return output, err
+}
+
+func (d DynamoDBAPI) UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	ctx, span := startDynamoDB(ctx, "UpdateItem", params.TableName)
+	output, err := d.API.UpdateItem(ctx, params, optFns...)
+	endDynamoDB(span, err)
+
+	return output, err
+}
+
+func (d DynamoDBAPI) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	ctx, span := startDynamoDB(ctx, "Scan", params.TableName)
+	output, err := d.API.Scan(ctx, params, optFns...)
+	endDynamoDB(span, err)
+
+	return output, err
+}
+
+func (d DynamoDBAPI) DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	ctx, span := startDynamoDB(ctx, "DeleteItem", params.TableName)
+	output, err := d.API.DeleteItem(ctx, params, optFns...)
+	endDynamoDB(span, err)
+
+	return output, err
+}
+
+func (d DynamoDBAPI) DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error) {
+	ctx, span := startDynamoDB(ctx, "DescribeTable", params.TableName)
+	output, err := d.API.DescribeTable(ctx, params, optFns...)
+	endDynamoDB(span, err)
+
+	return output, err
+}
+
+func startDynamoDB(ctx context.Context, operation string, table *string) (context.Context, trace.Span) {
+	return Start(ctx, "DynamoDB."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemDynamoDB,
+			semconv.DBOperation(operation),
+			semconv.AWSDynamoDBTableNames(aws.ToString(table)),
+		),
+	)
+}
+
+func endDynamoDB(span trace.Span, err error) {
+	outcome := db.Outcome(err)
+
+	span.SetAttributes(OutcomeKey.String(outcome))
+	if outcome != "ok" && outcome != "conditional_check_failed" {
+		span.SetStatus(codes.Error, outcome)
+	}
+
+	span.End()
+}
@@ -0,0 +1,84 @@
+package tracing
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Middleware starts a server span per request, continuing the trace of the
+// caller. The span is named after the route template, such as
+// GET /retrieve/:id, and never holds the requested path or query.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+
+		route := c.FullPath()
+		method := c.Request.Method
+		if route == "" {
+			route = "unmatched"
+			method = "other"
+		}
+
+		ctx, span := Start(ctx, method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(semconv.HTTPRequestMethodKey.String(method), semconv.HTTPRoute(route)),
+		)
+		defer span.End()
+
+		// handlers pass the gin context on, which falls back to the request
+		// context
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status), OutcomeKey.String(outcome(status)))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, strconv.Itoa(status))
+		}
+	}
+}
+
+// Handler starts a span for a handler, as the parent of the spans the
+// handler starts from c. The returned function ends it with the outcome of
+// the response.
+func Handler(c *gin.Context, name string) func() {
+	// the span must not derive from c itself, whose values fall back to the
+	// request context it replaces
+	if c.Request == nil {
+		_, span := Start(c, name)
+		return func() { span.End() }
+	}
+
+	ctx, span := Start(c.Request.Context(), name)
+	c.Request = c.Request.WithContext(ctx)
+
+	return func() {
+		status := c.Writer.Status()
+		span.SetAttributes(OutcomeKey.String(outcome(status)))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, strconv.Itoa(status))
+		}
+
+		span.End()
+	}
+}
+
+// outcome groups the statuses: ok, client_error or server_error.
+func outcome(status int) string {
+	switch {
+	case status >= http.StatusInternalServerError:
+		return "server_error"
+	case status >= http.StatusBadRequest:
+		return "client_error"
+	default:
+		return "ok"
+	}
+}
@@ -0,0 +1,120 @@
+// Package tracing exports OpenTelemetry traces of the requests, the
+// encryption steps and the DynamoDB calls. Spans carry routes, operations and
+// outcomes only: never passwords, keys, secrets, share ids, entry ids or
+// names, and never error messages.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	ServiceName = "personal-vault"
+
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+// OutcomeKey is the attribute with the result of a span, e.g. ok or error.
+const OutcomeKey = attribute.Key("vault.outcome")
+
+type Options struct {
+	// Exporter is none, stdout or otlp.
+	Exporter string
+	// Endpoint is the URL of an OTLP HTTP receiver, such as
+	// http://localhost:4318. The OTEL_EXPORTER_OTLP_* variables apply when
+	// it is empty.
+	Endpoint    string
+	SampleRatio float64
+	// Synchronous exports every span when it ends, for Lambda functions that
+	// are frozen between invocations.
+	Synchronous bool
+	// Stdout receives the spans of the stdout exporter, os.Stdout when nil.
+	Stdout io.Writer
+}
+
+// Setup installs the global tracer provider and the W3C trace context
+// propagator. The returned function flushes the remaining spans.
+func Setup(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	var (
+		exporter sdktrace.SpanExporter
+		err      error
+	)
+
+	switch opts.Exporter {
+	case ExporterNone, "":
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		out := opts.Stdout
+		if out == nil {
+			out = os.Stdout
+		}
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(out))
+	case ExporterOTLP:
+		var otlpOpts []otlptracehttp.Option
+		if opts.Endpoint != "" {
+			otlpOpts = append(otlpOpts, otlptracehttp.WithEndpointURL(opts.Endpoint))
+		}
+		exporter, err = otlptracehttp.New(ctx, otlpOpts...)
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", opts.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName(ServiceName)))
+	if err != nil {
+		return nil, err
+	}
+
+	processor := sdktrace.NewBatchSpanProcessor(exporter)
+	if opts.Synchronous {
+		processor = sdktrace.NewSimpleSpanProcessor(exporter)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithResource(res),
+		sdktrace.WithSpanProcessor(processor),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+	)
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	return provider.Shutdown, nil
+}
+
+// Start starts a span as a child of the span in ctx. The tracer is looked up
+// on every call so a provider installed later, e.g. by a test, takes effect.
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.GetTracerProvider().Tracer(ServiceName).Start(ctx, name, opts...)
+}
+
+// End records the outcome of the span and ends it. The error itself is left
+// out, its message could quote what failed.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.SetAttributes(OutcomeKey.String("error"))
+		span.SetStatus(codes.Error, "error")
+	} else {
+		span.SetAttributes(OutcomeKey.String("ok"))
+	}
+
+	span.End()
+}
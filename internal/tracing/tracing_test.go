@@ -0,0 +1,241 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// The tests install the global tracer provider, so none of them runs in
+// parallel.
+
+func record(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+
+	provider, propagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(provider)
+		otel.SetTextMapPropagator(propagator)
+	})
+
+	return recorder
+}
+
+func attributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+
+	return attrs
+}
+
+func TestSetup(t *testing.T) {
+	provider, propagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	t.Cleanup(func() {
+		otel.SetTracerProvider(provider)
+		otel.SetTextMapPropagator(propagator)
+	})
+
+	shutdown, err := Setup(context.Background(), Options{Exporter: ExporterNone})
+	assert.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+
+	_, err = Setup(context.Background(), Options{Exporter: "jaeger"})
+	assert.ErrorContains(t, err, "jaeger")
+
+	var out bytes.Buffer
+	shutdown, err = Setup(context.Background(), Options{Exporter: ExporterStdout, SampleRatio: 1, Synchronous: true, Stdout: &out})
+	assert.NoError(t, err)
+
+	_, span := Start(context.Background(), "test span")
+	End(span, nil)
+	assert.NoError(t, shutdown(context.Background()))
+	assert.Contains(t, out.String(), `"Name":"test span"`)
+	assert.Contains(t, out.String(), ServiceName)
+
+	shutdown, err = Setup(context.Background(), Options{Exporter: ExporterOTLP, Endpoint: "http://localhost:4318"})
+	assert.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+}
+
+func TestMiddleware(t *testing.T) {
+	recorder := record(t)
+
+	router := gin.New()
+	router.ContextWithFallback = true
+	router.Use(Middleware())
+	router.GET("/retrieve/:id", func(c *gin.Context) {
+		defer Handler(c, "RetrieveHandler.GetByID")()
+
+		_, span := Start(c, "decrypt")
+		End(span, errors.New("wrong key for entry 42"))
+
+		c.Status(http.StatusInternalServerError)
+	})
+
+	// the caller's trace is continued
+	parent := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{1},
+		TraceFlags: trace.FlagsSampled,
+	})
+	r := httptest.NewRequest(http.MethodGet, "/retrieve/42?token=abc", nil)
+	otel.GetTextMapPropagator().Inject(trace.ContextWithSpanContext(context.Background(), parent), propagation.HeaderCarrier(r.Header))
+	router.ServeHTTP(httptest.NewRecorder(), r)
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/s/abc", nil))
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 4)
+
+	decrypt, handler, server, unmatched := spans[0], spans[1], spans[2], spans[3]
+
+	assert.Equal(t, "decrypt", decrypt.Name())
+	assert.Equal(t, codes.Error, decrypt.Status().Code)
+	assert.Equal(t, "error", decrypt.Status().Description)
+	assert.Empty(t, decrypt.Events(), "the error is not recorded")
+	assert.Equal(t, handler.SpanContext().SpanID(), decrypt.Parent().SpanID())
+
+	assert.Equal(t, "RetrieveHandler.GetByID", handler.Name())
+	assert.Equal(t, "server_error", attributes(handler)[OutcomeKey].AsString())
+	assert.Equal(t, server.SpanContext().SpanID(), handler.Parent().SpanID())
+
+	assert.Equal(t, "GET /retrieve/:id", server.Name())
+	assert.Equal(t, trace.SpanKindServer, server.SpanKind())
+	assert.Equal(t, parent.TraceID(), server.SpanContext().TraceID())
+	assert.Equal(t, parent.SpanID(), server.Parent().SpanID())
+	attrs := attributes(server)
+	assert.Equal(t, "/retrieve/:id", attrs["http.route"].AsString())
+	assert.Equal(t, int64(http.StatusInternalServerError), attrs["http.response.status_code"].AsInt64())
+	assert.Equal(t, codes.Error, server.Status().Code)
+
+	assert.Equal(t, "other unmatched", unmatched.Name())
+	assert.Equal(t, "client_error", attributes(unmatched)[OutcomeKey].AsString())
+
+	for _, span := range spans {
+		for _, kv := range span.Attributes() {
+			assert.NotContains(t, kv.Value.Emit(), "42", kv.Key)
+			assert.NotContains(t, kv.Value.Emit(), "abc", kv.Key)
+		}
+	}
+}
+
+type mockAPI struct {
+	err error
+}
+
+func (m mockAPI) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	return &dynamodb.GetItemOutput{}, m.err
+}
+
+func (m mockAPI) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	return &dynamodb.PutItemOutput{}, m.err
+}
+
+func (m mockAPI) UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	return &dynamodb.UpdateItemOutput{}, m.err
+}
+
+func (m mockAPI) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	return &dynamodb.ScanOutput{}, m.err
+}
+
+func (m mockAPI) DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	return &dynamodb.DeleteItemOutput{}, m.err
+}
+
+func (m mockAPI) DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error) {
+	return &dynamodb.DescribeTableOutput{}, m.err
+}
+
+func TestDynamoDBAPI(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		call    func(api DynamoDBAPI) error
+		span    string
+		outcome string
+		status  codes.Code
+	}{
+		{
+			name: "ok",
+			call: func(api DynamoDBAPI) error {
+				_, err := api.GetItem(context.Background(), &dynamodb.GetItemInput{
+					TableName: aws.String("vault"),
+					Key:       map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: "entry-id"}},
+				})
+				return err
+			},
+			span:    "DynamoDB.GetItem",
+			outcome: "ok",
+			status:  codes.Unset,
+		},
+		{
+			name: "failed condition",
+			err:  &types.ConditionalCheckFailedException{Message: aws.String("mock")},
+			call: func(api DynamoDBAPI) error {
+				_, err := api.PutItem(context.Background(), &dynamodb.PutItemInput{TableName: aws.String("vault")})
+				return err
+			},
+			span:    "DynamoDB.PutItem",
+			outcome: "conditional_check_failed",
+			status:  codes.Unset,
+		},
+		{
+			name: "error",
+			err:  errors.New("mock"),
+			call: func(api DynamoDBAPI) error {
+				_, err := api.Scan(context.Background(), &dynamodb.ScanInput{TableName: aws.String("vault")})
+				return err
+			},
+			span:    "DynamoDB.Scan",
+			outcome: "error",
+			status:  codes.Error,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := record(t)
+
+			err := tt.call(DynamoDBAPI{API: mockAPI{err: tt.err}})
+			assert.Equal(t, tt.err, err)
+
+			spans := recorder.Ended()
+			assert.Len(t, spans, 1)
+
+			span := spans[0]
+			assert.Equal(t, tt.span, span.Name())
+			assert.Equal(t, trace.SpanKindClient, span.SpanKind())
+			assert.Equal(t, tt.status, span.Status().Code)
+
+			attrs := attributes(span)
+			assert.Equal(t, tt.outcome, attrs[OutcomeKey].AsString())
+			assert.Equal(t, "dynamodb", attrs["db.system"].AsString())
+			assert.Equal(t, []string{"vault"}, attrs["aws.dynamodb.table_names"].AsStringSlice())
+			for _, kv := range span.Attributes() {
+				assert.NotContains(t, kv.Value.Emit(), "entry-id")
+			}
+		})
+	}
+}
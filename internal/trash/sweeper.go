@@ -0,0 +1,50 @@
+// Package trash purges entries whose trash retention ended.
+package trash
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Purger is implemented by db.DynamoDBClient.
+type Purger interface {
+	PurgeTrash(ctx context.Context, now time.Time) (int, error)
+}
+
+// Sweeper purges expired trash on every tick. DynamoDB TTL removes the same
+// entries eventually; sweeping keeps the retention close to exact.
+type Sweeper struct {
+	Purger   Purger
+	Interval time.Duration
+}
+
+// Run sweeps immediately and then on every interval until ctx is done.
+func (s Sweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		s.Sweep(ctx, time.Now())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sweep purges the entries whose retention ended before now and logs how many
+// were purged.
+func (s Sweeper) Sweep(ctx context.Context, now time.Time) {
+	purged, err := s.Purger.PurgeTrash(ctx, now)
+	if err != nil {
+		slog.ErrorContext(ctx, "unable to purge trash", slog.Int("purged", purged), slog.Any("error", err))
+		return
+	}
+
+	if purged > 0 {
+		slog.InfoContext(ctx, "purged trash", slog.Int("purged", purged))
+	}
+}
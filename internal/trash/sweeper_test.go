@@ -0,0 +1,68 @@
+package trash
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type purgerMock struct {
+	purgeTrash func(ctx context.Context, now time.Time) (int, error)
+}
+
+func (m purgerMock) PurgeTrash(ctx context.Context, now time.Time) (int, error) {
+	return m.purgeTrash(ctx, now)
+}
+
+func TestSweeper_Run(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{name: "success case"},
+		// a failed sweep does not stop the sweeper
+		{name: "error case", err: errors.New("this is mock error")},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			sweeps := make(chan time.Time)
+			sweeper := Sweeper{
+				Purger: purgerMock{purgeTrash: func(ctx context.Context, now time.Time) (int, error) {
+					sweeps <- now
+					return 1, tt.err
+				}},
+				Interval: time.Millisecond,
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				sweeper.Run(ctx)
+				close(done)
+			}()
+
+			first := <-sweeps
+			second := <-sweeps
+			assert.False(t, second.Before(first))
+
+			cancel()
+			// drain the sweeps that raced the cancel until Run returns
+			for stopped := false; !stopped; {
+				select {
+				case <-sweeps:
+				case <-done:
+					stopped = true
+				}
+			}
+		})
+	}
+}
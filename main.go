@@ -2,67 +2,369 @@ package main
 
 import (
 	"context"
-	"github.com/go-playground/validator/v10"
+	"errors"
+	"fmt"
 	"log/slog"
-	"net/http"
+	"os"
+	"os/signal"
 	"personal-vault/internal/configuration"
 	"personal-vault/internal/db"
-	"personal-vault/internal/handler"
+	"personal-vault/internal/expiry"
+	"personal-vault/internal/health"
+	"personal-vault/internal/keycheck"
+	"personal-vault/internal/logging"
+	"personal-vault/internal/metrics"
+	"personal-vault/internal/ratelimit"
+	"personal-vault/internal/rbac"
+	"personal-vault/internal/resilience"
+	"personal-vault/internal/server"
+	"personal-vault/internal/streams"
+	"personal-vault/internal/tracing"
+	"personal-vault/internal/trash"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
-	"github.com/gin-gonic/gin"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodbstreams"
+	"github.com/spf13/pflag"
 )
 
-func healthcheckHandler(c *gin.Context) {
-	c.String(http.StatusOK, "Hello World!")
+const usage = `Usage: personal-vault [command] [flags]
+
+Commands:
+  serve   run the API server (default)
+  init    create the table and master key for a new vault
+  token   generate a bearer token for a principal: token NAME
+
+Flags:
+`
+
+func main() {
+	command, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	fs := configuration.NewFlagSet("personal-vault")
+	passwordStdin := fs.Bool("password-stdin", false, "init: read the master password from stdin instead of prompting")
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		fs.PrintDefaults()
+	}
+
+	err := fs.Parse(args)
+	if errors.Is(err, pflag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		os.Exit(2)
+	}
+
+	cfg, err := configuration.LoadConfig(fs)
+	if err != nil {
+		slog.Error("unable to load config", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	switch command {
+	case "serve":
+		err = serve(cfg)
+	case "init":
+		err = initVault(cfg, *passwordStdin)
+	case "token":
+		err = newToken(fs.Args())
+	default:
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		slog.Error(command+" failed", slog.Any("error", err))
+		os.Exit(1)
+	}
+}
+
+func serve(cfg configuration.Config) error {
+	clientSide := cfg.Encryption.ClientSide()
+
+	// in client-side mode the server never encrypts a password, so it needs
+	// no master key
+	var err error
+	if !clientSide {
+		err = cfg.RequireSecret()
+		if err != nil {
+			return err
+		}
+	}
+
+	logger, err := logging.NewLogger(os.Stdout, cfg.Log.Format, cfg.Log.Level)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+
+	// a Lambda function can be frozen before a batch is exported, so there
+	// every span is exported as it ends
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		Exporter:    cfg.Tracing.Exporter,
+		Endpoint:    cfg.Tracing.Endpoint,
+		SampleRatio: cfg.Tracing.SampleRatio,
+		Synchronous: server.IsLambda(),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err := shutdownTracing(context.Background())
+		if err != nil {
+			slog.Error("unable to flush traces", slog.Any("error", err))
+		}
+	}()
+
+	svc, err := newDynamoDB(context.Background(), cfg.DB)
+	if err != nil {
+		return err
+	}
+
+	// every attempt is measured and traced on its own
+	dbClient := db.NewClient(resilience.DynamoDBAPI{
+		API:     metrics.DynamoDBAPI{API: tracing.DynamoDBAPI{API: svc}},
+		Policy:  newDynamoDBPolicy(cfg.DB),
+		Breaker: resilience.NewBreaker(cfg.DB.CircuitBreaker.Failures, cfg.DB.CircuitBreaker.OpenTimeout),
+	}, cfg.DB.Table)
+
+	// tables created before the trash have no TTL, and on Lambda nothing
+	// else purges it
+	err = db.EnableTTL(context.Background(), svc, cfg.DB.Table)
+	if err != nil {
+		slog.Warn("DynamoDB TTL is not enabled, trashed entries are only purged by the HTTP server", slog.String("attribute", db.TTLAttribute), slog.Any("error", err))
+	}
+
+	if cfg.Cache.Enabled {
+		dbClient.Cache = db.NewMetadataCache(cfg.Cache.TTL)
+	}
+
+	// a Lambda function is frozen once it has answered, so a write left in
+	// the background may never be sent
+	dbClient.SyncAccess = server.IsLambda()
+
+	if clientSide {
+		slog.Info("client-side encryption mode, only secrets sealed by clients are accepted")
+	} else {
+		err = keycheck.VerifyVault(context.Background(), dbClient, cfg.Secret)
+		if errors.Is(err, keycheck.ErrNoKeyCheck) {
+			slog.Warn("starting without master key verification", slog.Any("error", err))
+		} else if err != nil {
+			return err
+		}
+	}
+
+	// roles were first stored one item per prefix, which the authorizer no
+	// longer reads
+	migrated, err := dbClient.MigrateRoles(context.Background())
+	if err != nil {
+		return fmt.Errorf("unable to migrate role assignments: %w", err)
+	}
+	if migrated > 0 {
+		slog.Info("role assignments migrated", slog.Int("count", migrated))
+	}
+
+	authorizer := rbac.Authorizer{Client: *dbClient, Tokens: cfg.Auth.Principals(), Admins: cfg.Auth.Admins}
+	if !authorizer.Enabled() {
+		slog.Warn("no auth tokens configured, every caller can read and write every entry")
+	}
+
+	checks := []health.Check{{Name: "dynamodb", Run: dbClient.PingTable}}
+	if !clientSide {
+		checks = append(checks, health.Check{Name: "key", Run: func(ctx context.Context) error {
+			return keycheck.Probe(ctx, dbClient, cfg.Secret)
+		}})
+	}
+
+	handlers := server.NewHandlers(*dbClient, server.HandlerOptions{
+		Key:           cfg.Secret,
+		ClientSide:    clientSide,
+		Retention:     cfg.Trash.Retention,
+		ShareBaseURL:  cfg.Share.BaseURL,
+		KDFIterations: cfg.KDF.Iterations,
+	})
+	handlers.Auth = authorizer
+	handlers.RateLimit = newRateLimiter(cfg.RateLimit, dbClient)
+	handlers.Health = health.NewChecker(cfg.Health.CacheTTL, cfg.Health.Timeout, checks...)
+
+	router := server.NewRouter(logger, handlers)
+
+	err = router.SetTrustedProxies(cfg.Server.TrustedProxies)
+	if err != nil {
+		return err
+	}
+
+	// everything above runs once per cold start and is reused across invocations
+	if server.IsLambda() {
+		if cfg.Cache.Streams {
+			slog.Warn("cache.streams is ignored on Lambda, cached listings expire after cache.ttl")
+		}
+
+		lambda.Start(server.NewLambdaHandler(router).Invoke)
+		return nil
+	}
+
+	httpServer, err := server.NewHTTPServer(server.Options{
+		Addr:            cfg.Server.ListenAddr,
+		CertFile:        cfg.Server.TLSCertFile,
+		KeyFile:         cfg.Server.TLSKeyFile,
+		SelfSigned:      cfg.Server.TLSSelfSigned,
+		ReadTimeout:     cfg.Server.ReadTimeout,
+		WriteTimeout:    cfg.Server.WriteTimeout,
+		IdleTimeout:     cfg.Server.IdleTimeout,
+		ShutdownTimeout: cfg.Server.ShutdownTimeout,
+	}, router)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// a Lambda function is frozen between invocations, so only the long
+	// running server checks for expired entries, sweeps the trash and follows
+	// the stream; on Lambda DynamoDB TTL purges the trash on its own
+	notifier := &expiry.Notifier{Store: dbClient, Interval: cfg.Expiry.CheckInterval, WebhookURL: cfg.Expiry.WebhookURL}
+	go notifier.Run(ctx)
+
+	sweeper := trash.Sweeper{Purger: dbClient, Interval: cfg.Trash.SweepInterval}
+	go sweeper.Run(ctx)
+
+	if cfg.Cache.Streams {
+		invalidator, err := newInvalidator(ctx, cfg, dbClient)
+		if err != nil {
+			return err
+		}
+
+		go invalidator.Run(ctx)
+	}
+
+	err = httpServer.ListenAndServe(ctx)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("server stopped")
+
+	return nil
 }
 
-func notFoundHandler(c *gin.Context) {
-	c.JSON(http.StatusNotFound, gin.H{"code": "PAGE_NOT_FOUND", "message": "Page not found"})
+func loadAWSConfig(ctx context.Context, dbCfg configuration.DBConfig) (aws.Config, error) {
+	var opts []func(*config.LoadOptions) error
+	if dbCfg.Region != "" {
+		opts = append(opts, config.WithRegion(dbCfg.Region))
+	}
+
+	return config.LoadDefaultConfig(ctx, opts...)
 }
 
-func notMethodHandler(c *gin.Context) {
-	c.JSON(http.StatusMethodNotAllowed, gin.H{"code": "METHOD_NOT_ALLOWED", "message": "405 method not allowed"})
+func newDynamoDB(ctx context.Context, dbCfg configuration.DBConfig) (*dynamodb.Client, error) {
+	awsConfig, err := loadAWSConfig(ctx, dbCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	svc := dynamodb.NewFromConfig(awsConfig, func(o *dynamodb.Options) {
+		if dbCfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(dbCfg.Endpoint)
+		}
+	})
+
+	return svc, nil
 }
 
-func main() {
-	cfg, err := configuration.LoadConfig()
+// newInvalidator follows the stream of the table, which DynamoDB Local serves
+// on the same endpoint as the table.
+func newInvalidator(ctx context.Context, cfg configuration.Config, dbClient *db.DynamoDBClient) (*streams.Invalidator, error) {
+	streamARN, err := dbClient.StreamARN(ctx)
 	if err != nil {
-		slog.Error("error", err)
-		return
+		return nil, fmt.Errorf("cache.streams: %w", err)
 	}
 
-	awsConfig, err := config.LoadDefaultConfig(context.Background())
+	awsConfig, err := loadAWSConfig(ctx, cfg.DB)
 	if err != nil {
-		return
+		return nil, err
 	}
 
-	svc := dynamodb.NewFromConfig(awsConfig)
-	dbClient := db.NewClient(svc)
+	svc := dynamodbstreams.NewFromConfig(awsConfig, func(o *dynamodbstreams.Options) {
+		if cfg.DB.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.DB.Endpoint)
+		}
+	})
 
-	validate := validator.New()
+	return &streams.Invalidator{API: svc, StreamARN: streamARN, Cache: dbClient.Cache, Interval: cfg.Cache.PollInterval}, nil
+}
 
-	saveHandler := handler.SaveHandler{Client: *dbClient, Validate: validate, Key: cfg.Secret}
-	retrieveHandler := handler.RetrieveHandler{Client: *dbClient, Key: cfg.Secret}
+// newDynamoDBPolicy replaces the retries of the SDK for the server.
+func newDynamoDBPolicy(dbCfg configuration.DBConfig) resilience.Policy {
+	return resilience.Policy{
+		Timeouts: map[string]time.Duration{
+			"GetItem":       dbCfg.Timeouts.GetItem,
+			"PutItem":       dbCfg.Timeouts.PutItem,
+			"UpdateItem":    dbCfg.Timeouts.UpdateItem,
+			"DeleteItem":    dbCfg.Timeouts.DeleteItem,
+			"Scan":          dbCfg.Timeouts.Scan,
+			"DescribeTable": dbCfg.Timeouts.DescribeTable,
+		},
+		MaxAttempts:   dbCfg.Retry.MaxAttempts,
+		BaseDelay:     dbCfg.Retry.BaseDelay,
+		ThrottleDelay: dbCfg.Retry.ThrottleDelay,
+		MaxDelay:      dbCfg.Retry.MaxDelay,
+	}
+}
 
-	router := gin.Default()
+// newRateLimiter keeps the limits in memory unless they are shared through
+// the table.
+func newRateLimiter(rlCfg configuration.RateLimitConfig, dbClient *db.DynamoDBClient) ratelimit.Limiter {
+	if !rlCfg.Enabled {
+		slog.Warn("rate limiting is disabled")
+		return ratelimit.Limiter{}
+	}
 
-	router.GET("/healthcheck", healthcheckHandler)
+	var store ratelimit.Store = ratelimit.NewMemoryStore()
+	if rlCfg.Store == configuration.RateLimitDynamoDB {
+		store = *dbClient
+	}
 
-	router.POST("/save", saveHandler.AddItem)
+	limits := make(map[string]ratelimit.Limit, len(rlCfg.Groups))
+	for name, limit := range rlCfg.Groups {
+		limits[name] = ratelimit.Limit{Rate: limit.Rate, Burst: limit.Burst}
+	}
 
-	retrieve := router.Group("/retrieve")
-	{
-		retrieve.GET("/all", retrieveHandler.GetAll)
-		retrieve.GET("/:id", retrieveHandler.GetByID)
+	return ratelimit.Limiter{
+		Store:  store,
+		Limits: limits,
+		Penalty: ratelimit.Penalty{
+			Failures: rlCfg.Penalty.Failures,
+			Window:   rlCfg.Penalty.Window,
+			Block:    rlCfg.Penalty.Block,
+			MaxBlock: rlCfg.Penalty.MaxBlock,
+		},
 	}
+}
 
-	router.NoRoute(notFoundHandler)
-	router.NoMethod(notMethodHandler)
+// newToken prints a new bearer token and the config entry that maps it to
+// the principal. The token itself is never stored.
+func newToken(args []string) error {
+	if len(args) != 1 || args[0] == "" || strings.Contains(args[0], "#") {
+		return errors.New("usage: personal-vault token NAME, where NAME cannot contain #")
+	}
 
-	err = router.Run("localhost:8080")
+	token, hash, err := rbac.NewToken()
 	if err != nil {
-		return
+		return err
 	}
+
+	fmt.Printf("token: %s\n\nadd to the auth section of the config:\n\n  tokens:\n    - principal: %s\n      sha256: %s\n", token, args[0], hash)
+
+	return nil
 }
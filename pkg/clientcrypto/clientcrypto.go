@@ -0,0 +1,210 @@
+// Package clientcrypto seals passwords on the client for vaults that run with
+// encryption.mode set to client. The server stores a sealed Secret as it is
+// and never sees the password or the passphrase.
+//
+// A Secret is sent and returned as the JSON object
+//
+//	{
+//	  "version": 1,
+//	  "kdf": {"algorithm": "pbkdf2-sha256", "iterations": 600000, "salt": "<base64>"},
+//	  "cipher": "aes-256-gcm",
+//	  "nonce": "<base64>",
+//	  "ciphertext": "<base64>"
+//	}
+//
+// The key is the 32 byte PBKDF2-SHA256 of the passphrase, using the salt (at
+// least 16 bytes) and iterations of the kdf object. The ciphertext is the
+// AES-256-GCM encryption of the UTF-8 password under the 12 byte nonce, with
+// the 16 byte tag appended and no additional data. base64 is the standard
+// alphabet with padding.
+package clientcrypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	Version      = 1
+	KDFAlgorithm = "pbkdf2-sha256"
+	Cipher       = "aes-256-gcm"
+
+	DefaultIterations = 600_000
+	MinIterations     = 100_000
+	// MaxIterations keeps a tampered secret from making clients spin.
+	MaxIterations = 10_000_000
+
+	// MaxCiphertextLength bounds what a server accepts for a single password.
+	MaxCiphertextLength = 64 * 1024
+
+	minSaltLength = 16
+	saltLength    = 32
+	keyLength     = 32
+	nonceLength   = 12
+	tagLength     = 16
+)
+
+// ErrDecrypt is returned by Open when the passphrase is wrong or the secret
+// was changed.
+var ErrDecrypt = errors.New("unable to open the secret: wrong passphrase or corrupted secret")
+
+type KDF struct {
+	Algorithm  string `json:"algorithm"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+}
+
+// Secret is a password sealed by the client, see the package documentation
+// for its format.
+type Secret struct {
+	Version    int    `json:"version"`
+	KDF        KDF    `json:"kdf"`
+	Cipher     string `json:"cipher"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// Validate checks the format of the secret without opening it, as the server
+// does before storing it.
+func (s Secret) Validate() error {
+	var errs []error
+
+	if s.Version != Version {
+		errs = append(errs, fmt.Errorf("version must be %d", Version))
+	}
+
+	errs = append(errs, s.KDF.validate())
+
+	if s.Cipher != Cipher {
+		errs = append(errs, fmt.Errorf("cipher must be %s", Cipher))
+	}
+
+	if len(s.Nonce) != nonceLength {
+		errs = append(errs, fmt.Errorf("nonce must be %d bytes", nonceLength))
+	}
+
+	if len(s.Ciphertext) < tagLength || len(s.Ciphertext) > MaxCiphertextLength {
+		errs = append(errs, fmt.Errorf("ciphertext must be between %d and %d bytes", tagLength, MaxCiphertextLength))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (kdf KDF) validate() error {
+	var errs []error
+
+	if kdf.Algorithm != KDFAlgorithm {
+		errs = append(errs, fmt.Errorf("kdf.algorithm must be %s", KDFAlgorithm))
+	}
+
+	if kdf.Iterations < MinIterations || kdf.Iterations > MaxIterations {
+		errs = append(errs, fmt.Errorf("kdf.iterations must be between %d and %d", MinIterations, MaxIterations))
+	}
+
+	if len(kdf.Salt) < minSaltLength {
+		errs = append(errs, fmt.Errorf("kdf.salt must be at least %d bytes", minSaltLength))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Key is derived from a passphrase once and seals any number of passwords.
+type Key struct {
+	kdf KDF
+	key []byte
+}
+
+// NewKey derives a key from the passphrase with a new random salt.
+func NewKey(passphrase string, iterations int) (Key, error) {
+	salt := make([]byte, saltLength)
+
+	_, err := rand.Read(salt)
+	if err != nil {
+		return Key{}, err
+	}
+
+	return DeriveKey(passphrase, KDF{Algorithm: KDFAlgorithm, Iterations: iterations, Salt: salt})
+}
+
+// DeriveKey derives the key of a secret sealed with the given KDF parameters.
+func DeriveKey(passphrase string, kdf KDF) (Key, error) {
+	err := kdf.validate()
+	if err != nil {
+		return Key{}, err
+	}
+
+	return Key{kdf: kdf, key: pbkdf2.Key([]byte(passphrase), kdf.Salt, kdf.Iterations, keyLength, sha256.New)}, nil
+}
+
+// KDF returns the parameters the key was derived with.
+func (k Key) KDF() KDF {
+	return k.kdf
+}
+
+func (k Key) Seal(password string) (Secret, error) {
+	gcm, err := newGCM(k.key)
+	if err != nil {
+		return Secret{}, err
+	}
+
+	nonce := make([]byte, nonceLength)
+
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return Secret{}, err
+	}
+
+	return Secret{
+		Version:    Version,
+		KDF:        k.kdf,
+		Cipher:     Cipher,
+		Nonce:      nonce,
+		Ciphertext: gcm.Seal(nil, nonce, []byte(password), nil),
+	}, nil
+}
+
+// Open decrypts a secret sealed with this key. Secrets sealed with another
+// salt need their own key, see the package level Open.
+func (k Key) Open(secret Secret) (string, error) {
+	err := secret.Validate()
+	if err != nil {
+		return "", err
+	}
+
+	gcm, err := newGCM(k.key)
+	if err != nil {
+		return "", err
+	}
+
+	password, err := gcm.Open(nil, secret.Nonce, secret.Ciphertext, nil)
+	if err != nil {
+		return "", ErrDecrypt
+	}
+
+	return string(password), nil
+}
+
+// Open derives the key of the secret from the passphrase and decrypts it.
+func Open(secret Secret, passphrase string) (string, error) {
+	key, err := DeriveKey(passphrase, secret.KDF)
+	if err != nil {
+		return "", err
+	}
+
+	return key.Open(secret)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,87 @@
+package clientcrypto
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testIterations keeps the tests fast; real keys use DefaultIterations.
+const testIterations = MinIterations
+
+func TestKey_SealOpen(t *testing.T) {
+	t.Parallel()
+
+	key, err := NewKey("correct horse battery staple", testIterations)
+	assert.NoError(t, err)
+
+	secret, err := key.Seal("hunter2")
+	assert.NoError(t, err)
+	assert.NoError(t, secret.Validate())
+	assert.NotContains(t, string(secret.Ciphertext), "hunter2")
+
+	password, err := key.Open(secret)
+	assert.NoError(t, err)
+	assert.Equal(t, "hunter2", password)
+
+	// the secret carries everything but the passphrase
+	data, err := json.Marshal(secret)
+	assert.NoError(t, err)
+
+	var decoded Secret
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+
+	password, err = Open(decoded, "correct horse battery staple")
+	assert.NoError(t, err)
+	assert.Equal(t, "hunter2", password)
+
+	_, err = Open(decoded, "wrong passphrase")
+	assert.ErrorIs(t, err, ErrDecrypt)
+
+	decoded.Ciphertext[0] ^= 1
+	_, err = key.Open(decoded)
+	assert.ErrorIs(t, err, ErrDecrypt)
+
+	other, err := key.Seal("hunter2")
+	assert.NoError(t, err)
+	assert.NotEqual(t, secret.Nonce, other.Nonce)
+}
+
+func TestSecret_Validate(t *testing.T) {
+	t.Parallel()
+
+	key, err := NewKey("correct horse battery staple", testIterations)
+	assert.NoError(t, err)
+
+	valid, err := key.Seal("hunter2")
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		change      func(s *Secret)
+		expectedErr string
+	}{
+		{name: "unknown version", change: func(s *Secret) { s.Version = 2 }, expectedErr: "version"},
+		{name: "unknown kdf", change: func(s *Secret) { s.KDF.Algorithm = "scrypt" }, expectedErr: "kdf.algorithm"},
+		{name: "weak kdf", change: func(s *Secret) { s.KDF.Iterations = 1000 }, expectedErr: "kdf.iterations"},
+		{name: "excessive kdf", change: func(s *Secret) { s.KDF.Iterations = MaxIterations + 1 }, expectedErr: "kdf.iterations"},
+		{name: "short salt", change: func(s *Secret) { s.KDF.Salt = s.KDF.Salt[:8] }, expectedErr: "kdf.salt"},
+		{name: "unknown cipher", change: func(s *Secret) { s.Cipher = "aes-128-cbc" }, expectedErr: "cipher"},
+		{name: "short nonce", change: func(s *Secret) { s.Nonce = s.Nonce[:8] }, expectedErr: "nonce"},
+		{name: "no tag", change: func(s *Secret) { s.Ciphertext = s.Ciphertext[:4] }, expectedErr: "ciphertext"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			secret := valid
+			secret.KDF.Salt = append([]byte(nil), valid.KDF.Salt...)
+			tt.change(&secret)
+
+			assert.ErrorContains(t, secret.Validate(), tt.expectedErr)
+		})
+	}
+}
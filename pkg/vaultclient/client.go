@@ -0,0 +1,249 @@
+// Package vaultclient is a Go client for the personal-vault API.
+//
+//	client, err := vaultclient.New("https://vault.example.com",
+//		vaultclient.WithAuth(vaultclient.BearerToken(token)))
+//	saved, err := client.Save(ctx, vaultclient.SaveRequest{Name: "prod/db", Password: "..."})
+//	entry, err := client.Get(ctx, saved.ID)
+//
+// Requests the server turned away with 429 or 503 are retried with
+// exponential backoff, as are other 5xx responses and transport errors of
+// idempotent requests. Failed requests return an *Error, which matches
+// ErrNotFound and the other sentinels with errors.Is.
+package vaultclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Authenticator adds credentials to every request, for example a bearer
+// token fetched from a secret store.
+type Authenticator interface {
+	Authenticate(req *http.Request) error
+}
+
+// AuthenticatorFunc adapts a function to Authenticator.
+type AuthenticatorFunc func(req *http.Request) error
+
+func (f AuthenticatorFunc) Authenticate(req *http.Request) error {
+	return f(req)
+}
+
+// BearerToken sends a token configured in the auth section of the server.
+func BearerToken(token string) Authenticator {
+	return AuthenticatorFunc(func(req *http.Request) error {
+		req.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	})
+}
+
+// RetryPolicy controls how often and how long a request is retried. Delays
+// grow exponentially from BaseDelay up to MaxDelay, with jitter; a
+// Retry-After header from the server takes precedence.
+type RetryPolicy struct {
+	// MaxAttempts counts the first attempt, 1 disables retries.
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 4, BaseDelay: 200 * time.Millisecond, MaxDelay: 5 * time.Second}
+
+type Client struct {
+	baseURL string
+	http    *http.Client
+	auth    Authenticator
+	retry   RetryPolicy
+}
+
+type Option func(*Client)
+
+// WithHTTPClient replaces http.DefaultClient, e.g. to set timeouts or TLS
+// settings.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.http = httpClient
+	}
+}
+
+func WithAuth(auth Authenticator) Option {
+	return func(c *Client) {
+		c.auth = auth
+	}
+}
+
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *Client) {
+		c.retry = policy
+	}
+}
+
+// New returns a client for the server at baseURL.
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("vaultclient: invalid base URL: %w", err)
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("vaultclient: base URL %q must be an http(s) URL", baseURL)
+	}
+
+	c := &Client{baseURL: u.String(), http: http.DefaultClient, retry: DefaultRetryPolicy}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.retry.MaxAttempts < 1 {
+		c.retry.MaxAttempts = 1
+	}
+
+	return c, nil
+}
+
+// do sends body as JSON and decodes a successful response into out, retrying
+// as described in the package documentation. path must already be escaped.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out any) error {
+	var data []byte
+	if body != nil {
+		var err error
+		data, err = json.Marshal(body)
+		if err != nil {
+			return err
+		}
+	}
+
+	target := c.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	for attempt := 1; ; attempt++ {
+		resp, err := c.send(ctx, method, target, data)
+
+		retry, wait := c.shouldRetry(method, resp, err, attempt)
+		if !retry {
+			if err != nil {
+				return err
+			}
+
+			return decode(resp, out)
+		}
+
+		if resp != nil {
+			// drain so the connection is reused
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (c *Client) send(ctx context.Context, method, target string, data []byte) (*http.Response, error) {
+	var reader io.Reader
+	if data != nil {
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if data != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+
+	if c.auth != nil {
+		err = c.auth.Authenticate(req)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", errAuthenticate, err)
+		}
+	}
+
+	return c.http.Do(req)
+}
+
+// shouldRetry reports whether the attempt is retried and after how long. A
+// 429 or 503 means the server did not process the request, so every method
+// is retried. Other failures could have been processed, such as a 504 for a
+// change that timed out, and only idempotent requests are retried then.
+func (c *Client) shouldRetry(method string, resp *http.Response, err error, attempt int) (bool, time.Duration) {
+	if attempt >= c.retry.MaxAttempts {
+		return false, 0
+	}
+
+	idempotent := method == http.MethodGet || method == http.MethodPut || method == http.MethodDelete
+
+	switch {
+	case err != nil:
+		if !idempotent || errors.Is(err, errAuthenticate) ||
+			errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return false, 0
+		}
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable:
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+			return true, min(time.Duration(seconds)*time.Second, c.retry.MaxDelay)
+		}
+	case resp.StatusCode >= 500:
+		if !idempotent {
+			return false, 0
+		}
+	default:
+		return false, 0
+	}
+
+	return true, c.backoff(attempt)
+}
+
+// backoff returns a random delay of up to BaseDelay * 2^(attempt-1), capped
+// at MaxDelay.
+func (c *Client) backoff(attempt int) time.Duration {
+	delay := c.retry.BaseDelay << (attempt - 1)
+	if delay <= 0 || delay > c.retry.MaxDelay {
+		delay = c.retry.MaxDelay
+	}
+
+	if delay <= 0 {
+		return 0
+	}
+
+	return time.Duration(rand.Int63n(int64(delay))) + 1
+}
+
+func decode(resp *http.Response, out any) error {
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return newError(resp)
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	err := json.NewDecoder(resp.Body).Decode(out)
+	if err != nil {
+		return fmt.Errorf("vaultclient: unable to decode the response: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,338 @@
+package vaultclient
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"personal-vault/internal/db"
+	"personal-vault/internal/dbtest"
+	"personal-vault/internal/handler"
+	"personal-vault/internal/rbac"
+	"personal-vault/internal/server"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var fastRetries = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+// newTestServer runs the real router over an in-memory table. alice may do
+// anything, bob may only use the entries under team/.
+func newTestServer(t *testing.T) http.Handler {
+	t.Helper()
+
+	// secret is for testing only
+	secret, err := hex.DecodeString("0f6f8edf954592d7523b475bb56fd0486b7a049d67c1e5aa522bbc8bfe961971")
+	assert.NoError(t, err)
+
+	dbClient := db.NewClient(dbtest.NewMemoryAPI(), "personal-vault")
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	_, err = dbClient.PutRoleAssignment(context.Background(), db.RoleAssignment{Principal: "bob", Prefix: "team/", Role: "use", GrantedBy: "alice"})
+	assert.NoError(t, err)
+
+	return server.NewRouter(logger, server.Handlers{
+		Save:     handler.SaveHandler{Client: *dbClient, Validate: handler.NewValidator(), Key: string(secret)},
+		Retrieve: handler.RetrieveHandler{Client: *dbClient, Key: string(secret)},
+		Delete:   handler.DeleteHandler{Client: *dbClient, Retention: time.Hour},
+		Auth: rbac.Authorizer{
+			Client: *dbClient,
+			Tokens: map[string]string{rbac.HashToken("alice-token"): "alice", rbac.HashToken("bob-token"): "bob"},
+			Admins: []string{"alice"},
+		},
+	})
+}
+
+func newTestClient(t *testing.T, h http.Handler, token string) *Client {
+	t.Helper()
+
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+
+	client, err := New(srv.URL, WithAuth(BearerToken(token)), WithRetryPolicy(fastRetries))
+	assert.NoError(t, err)
+
+	return client
+}
+
+func TestClient_Entries(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	client := newTestClient(t, newTestServer(t), "alice-token")
+
+	saved, err := client.Save(ctx, SaveRequest{Name: "team/github", Description: "work", Password: "s3cret", RotationDays: 30})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, saved.ID)
+	assert.Equal(t, "team/github", saved.Name)
+	assert.NotNil(t, saved.ExpiresAt)
+
+	_, err = client.Save(ctx, SaveRequest{Name: "aws", Password: "other"})
+	assert.NoError(t, err)
+
+	entry, err := client.Get(ctx, saved.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "s3cret", entry.Password)
+	assert.Equal(t, "work", entry.Description)
+	assert.Equal(t, 30, entry.RotationDays)
+
+	entries, err := client.List(ctx, ListOptions{Sort: "name", Order: "asc"})
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, "aws", entries[0].Name)
+		assert.Equal(t, saved.ID, entries[1].ID)
+	}
+
+	description := "personal"
+	updated, err := client.Update(ctx, saved.ID, UpdateRequest{Description: &description})
+	assert.NoError(t, err)
+	assert.Equal(t, saved.ID, updated.ID)
+
+	assert.NoError(t, client.Delete(ctx, saved.ID))
+
+	_, err = client.Get(ctx, saved.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestClient_Errors(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	h := newTestServer(t)
+
+	alice := newTestClient(t, h, "alice-token")
+	saved, err := alice.Save(ctx, SaveRequest{Name: "prod/db", Password: "s3cret"})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		token   string
+		call    func(client *Client) error
+		want    error
+		code    string
+		details []FieldError
+	}{
+		{
+			name:  "unknown token",
+			token: "mallory-token",
+			call:  func(client *Client) error { _, err := client.List(ctx, ListOptions{}); return err },
+			want:  ErrUnauthorized,
+			code:  "UNAUTHORIZED",
+		},
+		{
+			name:  "no role on the entry",
+			token: "bob-token",
+			call:  func(client *Client) error { _, err := client.Get(ctx, saved.ID); return err },
+			want:  ErrForbidden,
+			code:  "FORBIDDEN",
+		},
+		{
+			name:  "unknown entry",
+			token: "alice-token",
+			call: func(client *Client) error {
+				_, err := client.Get(ctx, "3f1e0d54-0000-4000-8000-000000000000")
+				return err
+			},
+			want: ErrNotFound,
+		},
+		{
+			name:    "missing name",
+			token:   "alice-token",
+			call:    func(client *Client) error { _, err := client.Save(ctx, SaveRequest{Password: "s3cret"}); return err },
+			want:    ErrBadRequest,
+			code:    "VALIDATION_FAILED",
+			details: []FieldError{{Field: "name", Rule: "required", Message: "name is required"}},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tt.call(newTestClient(t, h, tt.token))
+			assert.ErrorIs(t, err, tt.want)
+
+			var apiErr *Error
+			if assert.True(t, errors.As(err, &apiErr)) {
+				if tt.code != "" {
+					assert.Equal(t, tt.code, apiErr.Code)
+				}
+				if tt.details != nil {
+					assert.Equal(t, tt.details, apiErr.Details)
+				}
+				assert.NotEmpty(t, apiErr.RequestID)
+			}
+		})
+	}
+}
+
+// flaky answers the first failures requests with status before passing them
+// on to next.
+func flaky(next http.Handler, status, failures int, attempts *atomic.Int32) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if int(attempts.Add(1)) <= failures {
+			if status == http.StatusTooManyRequests {
+				w.Header().Set("Retry-After", "0")
+			}
+			w.WriteHeader(status)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func TestClient_Retry(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		status   int
+		failures int
+		call     func(ctx context.Context, client *Client) error
+		want     error
+		attempts int32
+	}{
+		{
+			name:     "get after 503",
+			status:   http.StatusServiceUnavailable,
+			failures: 2,
+			call:     func(ctx context.Context, client *Client) error { _, err := client.List(ctx, ListOptions{}); return err },
+			attempts: 3,
+		},
+		{
+			name:     "save after 429",
+			status:   http.StatusTooManyRequests,
+			failures: 1,
+			call: func(ctx context.Context, client *Client) error {
+				_, err := client.Save(ctx, SaveRequest{Name: "github", Password: "s3cret"})
+				return err
+			},
+			attempts: 2,
+		},
+		{
+			name:     "save is not retried after 500",
+			status:   http.StatusInternalServerError,
+			failures: 1,
+			call: func(ctx context.Context, client *Client) error {
+				_, err := client.Save(ctx, SaveRequest{Name: "github", Password: "s3cret"})
+				return err
+			},
+			want:     &Error{},
+			attempts: 1,
+		},
+		{
+			name:     "save is not retried after 504",
+			status:   http.StatusGatewayTimeout,
+			failures: 1,
+			call: func(ctx context.Context, client *Client) error {
+				_, err := client.Save(ctx, SaveRequest{Name: "github", Password: "s3cret"})
+				return err
+			},
+			want:     ErrTimeout,
+			attempts: 1,
+		},
+		{
+			name:     "gives up after max attempts",
+			status:   http.StatusServiceUnavailable,
+			failures: 5,
+			call:     func(ctx context.Context, client *Client) error { _, err := client.List(ctx, ListOptions{}); return err },
+			want:     ErrUnavailable,
+			attempts: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var attempts atomic.Int32
+			client := newTestClient(t, flaky(newTestServer(t), tt.status, tt.failures, &attempts), "alice-token")
+
+			err := tt.call(context.Background(), client)
+			switch want := tt.want.(type) {
+			case nil:
+				assert.NoError(t, err)
+			case *Error:
+				assert.True(t, errors.As(err, &want))
+			default:
+				assert.ErrorIs(t, err, want)
+			}
+			assert.Equal(t, tt.attempts, attempts.Load())
+		})
+	}
+}
+
+func TestClient_ContextCanceled(t *testing.T) {
+	t.Parallel()
+
+	var attempts atomic.Int32
+	h := flaky(newTestServer(t), http.StatusServiceUnavailable, 10, &attempts)
+
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+
+	client, err := New(srv.URL, WithAuth(BearerToken("alice-token")),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 10, BaseDelay: time.Hour, MaxDelay: time.Hour}))
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err = client.List(ctx, ListOptions{})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, int32(1), attempts.Load())
+}
+
+func TestClient_AuthenticatorError(t *testing.T) {
+	t.Parallel()
+
+	var attempts atomic.Int32
+	h := flaky(newTestServer(t), http.StatusServiceUnavailable, 0, &attempts)
+
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+
+	failure := errors.New("token expired")
+	client, err := New(srv.URL, WithRetryPolicy(fastRetries), WithAuth(AuthenticatorFunc(func(*http.Request) error {
+		return failure
+	})))
+	assert.NoError(t, err)
+
+	_, err = client.List(context.Background(), ListOptions{})
+	assert.ErrorIs(t, err, failure)
+	assert.Equal(t, int32(0), attempts.Load())
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		baseURL string
+		wantErr bool
+	}{
+		{name: "https", baseURL: "https://vault.example.com/"},
+		{name: "http with port", baseURL: "http://localhost:8080"},
+		{name: "no scheme", baseURL: "vault.example.com", wantErr: true},
+		{name: "other scheme", baseURL: "ftp://vault.example.com", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := New(tt.baseURL)
+			assert.Equal(t, tt.wantErr, err != nil)
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package vaultclient
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"personal-vault/pkg/clientcrypto"
+	"time"
+)
+
+// SaveRequest stores a new entry. Set Password for a vault that encrypts on
+// the server, or Secret for one in client-side encryption mode.
+type SaveRequest struct {
+	Name         string               `json:"name"`
+	Description  string               `json:"description,omitempty"`
+	Password     string               `json:"password,omitempty"`
+	Secret       *clientcrypto.Secret `json:"secret,omitempty"`
+	ExpiresAt    *time.Time           `json:"expires_at,omitempty"`
+	RotationDays int                  `json:"rotation_days,omitempty"`
+}
+
+type SaveResult struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	Version   int        `json:"version"`
+}
+
+// Entry is a decrypted entry. An entry sealed by the client has Secret set
+// instead of Password, see clientcrypto.Open.
+type Entry struct {
+	ID             string               `json:"id"`
+	Name           string               `json:"name"`
+	Description    string               `json:"description"`
+	Password       string               `json:"password"`
+	Secret         *clientcrypto.Secret `json:"secret"`
+	CreatedAt      time.Time            `json:"created_at"`
+	UpdatedAt      time.Time            `json:"updated_at"`
+	LastAccessedAt *time.Time           `json:"last_accessed_at"`
+	AccessCount    int                  `json:"access_count"`
+	ExpiresAt      *time.Time           `json:"expires_at"`
+	RotationDays   int                  `json:"rotation_days"`
+	Version        int                  `json:"version"`
+}
+
+// Metadata is an entry without its password. The API sends these fields
+// under their Go names.
+type Metadata struct {
+	ID             string     `json:"ID"`
+	Name           string     `json:"Name"`
+	CreatedAt      time.Time  `json:"CreatedAt"`
+	UpdatedAt      time.Time  `json:"UpdatedAt"`
+	LastAccessedAt *time.Time `json:"LastAccessedAt"`
+	AccessCount    int        `json:"AccessCount"`
+	ExpiresAt      *time.Time `json:"ExpiresAt"`
+	RotationDays   int        `json:"RotationDays"`
+	DeletedAt      *time.Time `json:"DeletedAt"`
+}
+
+// ListOptions sorts the entries returned by List. Sort is one of name,
+// created_at, updated_at, last_accessed_at or access_count; Order is asc or
+// desc. The zero value keeps the order of the server.
+type ListOptions struct {
+	Sort  string
+	Order string
+}
+
+// UpdateRequest changes the fields that are set.
+type UpdateRequest struct {
+	Name         *string              `json:"name,omitempty"`
+	Description  *string              `json:"description,omitempty"`
+	Password     *string              `json:"password,omitempty"`
+	Secret       *clientcrypto.Secret `json:"secret,omitempty"`
+	ExpiresAt    *time.Time           `json:"expires_at,omitempty"`
+	RotationDays *int                 `json:"rotation_days,omitempty"`
+}
+
+// Save stores a new entry. It is not retried after a failure the server may
+// have processed, so a failed Save never stores the entry twice.
+func (c *Client) Save(ctx context.Context, request SaveRequest) (SaveResult, error) {
+	var result SaveResult
+
+	err := c.do(ctx, http.MethodPost, "/save", nil, request, &result)
+
+	return result, err
+}
+
+// Get decrypts the entry with the given id.
+func (c *Client) Get(ctx context.Context, id string) (Entry, error) {
+	var entry Entry
+
+	err := c.do(ctx, http.MethodGet, "/retrieve/"+url.PathEscape(id), nil, nil, &entry)
+
+	return entry, err
+}
+
+// List returns the metadata of the entries the caller may list.
+func (c *Client) List(ctx context.Context, opts ListOptions) ([]Metadata, error) {
+	query := url.Values{}
+	if opts.Sort != "" {
+		query.Set("sort", opts.Sort)
+	}
+	if opts.Order != "" {
+		query.Set("order", opts.Order)
+	}
+
+	entries := []Metadata{}
+
+	err := c.do(ctx, http.MethodGet, "/retrieve/all", query, nil, &entries)
+
+	return entries, err
+}
+
+// Update changes the fields set in request and returns the new metadata.
+func (c *Client) Update(ctx context.Context, id string, request UpdateRequest) (Metadata, error) {
+	var updated Metadata
+
+	err := c.do(ctx, http.MethodPatch, "/entries/"+url.PathEscape(id), nil, request, &updated)
+
+	return updated, err
+}
+
+// Delete moves the entry to the trash.
+func (c *Client) Delete(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/entries/"+url.PathEscape(id), nil, nil, nil)
+}
@@ -0,0 +1,78 @@
+package vaultclient
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// The sentinels match an *Error with the corresponding status.
+var (
+	ErrBadRequest   = errors.New("vaultclient: bad request")
+	ErrUnauthorized = errors.New("vaultclient: unauthorized")
+	ErrForbidden    = errors.New("vaultclient: forbidden")
+	ErrNotFound     = errors.New("vaultclient: not found")
+	ErrConflict     = errors.New("vaultclient: conflict")
+	ErrUnavailable  = errors.New("vaultclient: service unavailable")
+	// ErrTimeout is a change that timed out on the server and may have been
+	// applied anyway.
+	ErrTimeout = errors.New("vaultclient: timed out, the change may have been applied")
+)
+
+// errAuthenticate wraps the errors of the Authenticator.
+var errAuthenticate = errors.New("vaultclient: unable to authenticate the request")
+
+var statusErrors = map[int]error{
+	http.StatusBadRequest:         ErrBadRequest,
+	http.StatusUnauthorized:       ErrUnauthorized,
+	http.StatusForbidden:          ErrForbidden,
+	http.StatusNotFound:           ErrNotFound,
+	http.StatusConflict:           ErrConflict,
+	http.StatusTooManyRequests:    ErrUnavailable,
+	http.StatusServiceUnavailable: ErrUnavailable,
+	http.StatusGatewayTimeout:     ErrTimeout,
+}
+
+// FieldError describes a request field that failed validation.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Error is a response outside the 2xx range. Code, Message, Details and
+// RequestID come from the JSON error body of the API.
+type Error struct {
+	StatusCode int          `json:"-"`
+	Code       string       `json:"code"`
+	Message    string       `json:"message"`
+	Details    []FieldError `json:"details"`
+	RequestID  string       `json:"request_id"`
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("vaultclient: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+
+	return fmt.Sprintf("vaultclient: %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+func (e *Error) Is(target error) bool {
+	return statusErrors[e.StatusCode] == target
+}
+
+func newError(resp *http.Response) error {
+	apiErr := &Error{StatusCode: resp.StatusCode}
+
+	// a proxy in front of the server may answer with something else than the
+	// JSON error body
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err == nil {
+		_ = json.Unmarshal(body, apiErr)
+	}
+
+	return apiErr
+}